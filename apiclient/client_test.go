@@ -0,0 +1,20 @@
+package apiclient_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/handlers"
+	"github.com/atmiguel/cerealnotes/openapi"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestClientIsUpToDate(t *testing.T) {
+	expected, err := openapi.GenerateClient(handlers.ApiDocument(), "apiclient")
+	test_util.Ok(t, err)
+
+	actual, err := ioutil.ReadFile("client.go")
+	test_util.Ok(t, err)
+
+	test_util.Assert(t, string(expected) == string(actual), "client.go is out of date; run go generate ./apiclient")
+}
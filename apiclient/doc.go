@@ -0,0 +1,10 @@
+/*
+Package apiclient is a client for the versioned api, for scripts to import.
+
+It is generated from the OpenAPI document the handlers describe the api with,
+which is also served at /api/openapi.json. After changing the api, regenerate
+it with go generate.
+*/
+package apiclient
+
+//go:generate go run generate.go
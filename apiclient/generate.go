@@ -0,0 +1,24 @@
+//go:build ignore
+// +build ignore
+
+// generate writes client.go from the OpenAPI document describing the api.
+package main
+
+import (
+	"io/ioutil"
+	"log"
+
+	"github.com/atmiguel/cerealnotes/handlers"
+	"github.com/atmiguel/cerealnotes/openapi"
+)
+
+func main() {
+	source, err := openapi.GenerateClient(handlers.ApiDocument(), "apiclient")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("client.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,8 @@
+/*
+Package blobstore stores uploaded files such as note attachments.
+
+A Store saves, reads and deletes blobs by key. LocalStore keeps them on the
+filesystem, which suits a single instance, and S3Store keeps them in any
+S3-compatible bucket so that several instances can share them.
+*/
+package blobstore
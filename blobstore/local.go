@@ -0,0 +1,90 @@
+package blobstore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps blobs as files under a root directory.
+type LocalStore struct {
+	Root string
+}
+
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{Root: root}
+}
+
+func (store *LocalStore) Put(key string, content io.Reader, size int64, contentType string) error {
+	filePath, err := store.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see a partial blob
+	file, err := ioutil.TempFile(filepath.Dir(filePath), ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filePath)
+}
+
+func (store *LocalStore) Get(key string) (io.ReadCloser, error) {
+	filePath, err := store.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, NoBlobFoundError
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (store *LocalStore) Delete(key string) error {
+	filePath, err := store.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return NoBlobFoundError
+		}
+		return err
+	}
+
+	return nil
+}
+
+// filePath maps the key to a file under the root, refusing keys that would escape it.
+func (store *LocalStore) filePath(key string) (string, error) {
+	if len(key) == 0 || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", InvalidKeyError
+	}
+
+	return filepath.Join(store.Root, filepath.FromSlash(key)), nil
+}
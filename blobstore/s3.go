@@ -0,0 +1,248 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Store keeps blobs in a bucket of an S3-compatible object store, addressed path style
+// (Endpoint/Bucket/key) so that stand-ins such as MinIO work without DNS tricks.
+type S3Store struct {
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyId     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+func NewS3Store(endpoint string, bucket string, region string, accessKeyId string, secretAccessKey string) *S3Store {
+	return &S3Store{
+		Endpoint:        strings.TrimSuffix(endpoint, "/"),
+		Bucket:          bucket,
+		Region:          region,
+		AccessKeyId:     accessKeyId,
+		SecretAccessKey: secretAccessKey,
+		Client:          &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (store *S3Store) Put(key string, content io.Reader, size int64, contentType string) error {
+	request, err := store.newRequest(http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := store.do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+func (store *S3Store) Get(key string) (io.ReadCloser, error) {
+	request, err := store.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := store.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+func (store *S3Store) Delete(key string) error {
+	// S3 deletes succeed whether or not the object exists, so check first
+	request, err := store.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := store.do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	request, err = store.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err = store.do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+func (store *S3Store) newRequest(method string, key string, body io.Reader) (*http.Request, error) {
+	if len(key) == 0 || strings.HasPrefix(key, "/") {
+		return nil, InvalidKeyError
+	}
+
+	objectUrl := store.Endpoint + "/" + awsEscape(store.Bucket) + "/" + escapeKey(key)
+
+	return http.NewRequest(method, objectUrl, body)
+}
+
+// do signs and sends the request, turning error statuses into errors.
+func (store *S3Store) do(request *http.Request) (*http.Response, error) {
+	request.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	store.Sign(request, time.Now().UTC())
+
+	client := store.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, NoBlobFoundError
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		return nil, fmt.Errorf("blobstore: %s %s responded %d: %s", request.Method, request.URL.Path, response.StatusCode, message)
+	}
+
+	return response, nil
+}
+
+// Sign adds an AWS Signature Version 4 Authorization header covering the host and every header
+// already set on the request. The payload hash must already be set in X-Amz-Content-Sha256.
+func (store *S3Store) Sign(request *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		request.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := date + "/" + store.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSha256([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+store.SecretAccessKey), date)
+	signingKey = hmacSha256(signingKey, store.Region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		store.AccessKeyId,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// escapeKey escapes each segment of the key the way S3 expects, keeping the slashes.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsEscape percent-encodes everything except unreserved characters, as SigV4 requires.
+func awsEscape(input string) string {
+	var escaped strings.Builder
+	for _, character := range []byte(input) {
+		switch {
+		case character >= 'A' && character <= 'Z',
+			character >= 'a' && character <= 'z',
+			character >= '0' && character <= '9',
+			character == '-', character == '_', character == '.', character == '~':
+			escaped.WriteByte(character)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", character)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
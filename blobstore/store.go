@@ -0,0 +1,18 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+)
+
+var NoBlobFoundError = errors.New("No blob with that key could be found")
+var InvalidKeyError = errors.New("Blob keys must be relative paths without '..' segments")
+
+// Store saves blobs under keys such as "attachments/12/3f9a". Keys use forward slashes.
+type Store interface {
+	Put(key string, content io.Reader, size int64, contentType string) error
+	// Get returns NoBlobFoundError if nothing is stored under the key.
+	Get(key string) (io.ReadCloser, error)
+	// Delete returns NoBlobFoundError if nothing is stored under the key.
+	Delete(key string) error
+}
@@ -0,0 +1,17 @@
+\c cerealnotes;
+
+CREATE TABLE IF NOT EXISTS note_import (
+	note_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	author_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	content_hash text NOT NULL,
+	UNIQUE (author_id, content_hash)
+);
+
+\c cerealnotes_test;
+
+CREATE TABLE IF NOT EXISTS note_import (
+	note_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	author_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	content_hash text NOT NULL,
+	UNIQUE (author_id, content_hash)
+);
@@ -0,0 +1,15 @@
+\c cerealnotes;
+
+CREATE TABLE IF NOT EXISTS feed_token (
+	user_id bigint PRIMARY KEY references app_user(id) ON DELETE CASCADE,
+	token text UNIQUE NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+\c cerealnotes_test;
+
+CREATE TABLE IF NOT EXISTS feed_token (
+	user_id bigint PRIMARY KEY references app_user(id) ON DELETE CASCADE,
+	token text UNIQUE NOT NULL,
+	creation_time timestamp NOT NULL
+);
@@ -0,0 +1,41 @@
+\c cerealnotes;
+
+-- Types
+CREATE TYPE notification_frequency_type AS ENUM ('immediate', 'daily', 'never');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS notification_preference (
+	user_id bigint PRIMARY KEY references app_user(id) ON DELETE CASCADE,
+	frequency notification_frequency_type NOT NULL,
+	unsubscribe_token text UNIQUE NOT NULL,
+	last_digest_time timestamp
+);
+
+CREATE TABLE IF NOT EXISTS publication_notification (
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	publication_id bigint references publication(id) ON DELETE CASCADE NOT NULL,
+	creation_time timestamp NOT NULL,
+	sent_time timestamp,
+	PRIMARY KEY (user_id, publication_id)
+);
+
+\c cerealnotes_test;
+
+-- Types
+CREATE TYPE notification_frequency_type AS ENUM ('immediate', 'daily', 'never');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS notification_preference (
+	user_id bigint PRIMARY KEY references app_user(id) ON DELETE CASCADE,
+	frequency notification_frequency_type NOT NULL,
+	unsubscribe_token text UNIQUE NOT NULL,
+	last_digest_time timestamp
+);
+
+CREATE TABLE IF NOT EXISTS publication_notification (
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	publication_id bigint references publication(id) ON DELETE CASCADE NOT NULL,
+	creation_time timestamp NOT NULL,
+	sent_time timestamp,
+	PRIMARY KEY (user_id, publication_id)
+);
@@ -0,0 +1,61 @@
+\c cerealnotes;
+
+-- Types
+CREATE TYPE webhook_delivery_status_type AS ENUM ('pending', 'delivered', 'failed');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS webhook_subscription (
+	id bigserial PRIMARY KEY,
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	url text NOT NULL,
+	secret text NOT NULL,
+	event_types text[] NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id bigserial PRIMARY KEY,
+	subscription_id bigint references webhook_subscription(id) ON DELETE CASCADE NOT NULL,
+	event_type text NOT NULL,
+	payload text NOT NULL,
+	status webhook_delivery_status_type NOT NULL,
+	attempts integer NOT NULL,
+	next_attempt_time timestamp NOT NULL,
+	last_status_code integer,
+	last_error text,
+	creation_time timestamp NOT NULL,
+	delivered_time timestamp
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_index ON webhook_delivery (next_attempt_time) WHERE status = 'pending';
+
+\c cerealnotes_test;
+
+-- Types
+CREATE TYPE webhook_delivery_status_type AS ENUM ('pending', 'delivered', 'failed');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS webhook_subscription (
+	id bigserial PRIMARY KEY,
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	url text NOT NULL,
+	secret text NOT NULL,
+	event_types text[] NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id bigserial PRIMARY KEY,
+	subscription_id bigint references webhook_subscription(id) ON DELETE CASCADE NOT NULL,
+	event_type text NOT NULL,
+	payload text NOT NULL,
+	status webhook_delivery_status_type NOT NULL,
+	attempts integer NOT NULL,
+	next_attempt_time timestamp NOT NULL,
+	last_status_code integer,
+	last_error text,
+	creation_time timestamp NOT NULL,
+	delivered_time timestamp
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_index ON webhook_delivery (next_attempt_time) WHERE status = 'pending';
@@ -0,0 +1,43 @@
+\c cerealnotes;
+
+-- Types
+CREATE TYPE prediction_outcome_type AS ENUM ('true', 'false', 'ambiguous');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS prediction (
+	note_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	probability double precision NOT NULL CHECK (probability >= 0 AND probability <= 1),
+	resolution_date timestamp NOT NULL,
+	outcome prediction_outcome_type,
+	resolver_id bigint references app_user(id) ON DELETE SET NULL,
+	resolved_time timestamp,
+	reminder_sent_time timestamp
+);
+
+CREATE TABLE IF NOT EXISTS prediction_judge (
+	note_id bigint references prediction(note_id) ON DELETE CASCADE NOT NULL,
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	PRIMARY KEY (note_id, user_id)
+);
+
+\c cerealnotes_test;
+
+-- Types
+CREATE TYPE prediction_outcome_type AS ENUM ('true', 'false', 'ambiguous');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS prediction (
+	note_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	probability double precision NOT NULL CHECK (probability >= 0 AND probability <= 1),
+	resolution_date timestamp NOT NULL,
+	outcome prediction_outcome_type,
+	resolver_id bigint references app_user(id) ON DELETE SET NULL,
+	resolved_time timestamp,
+	reminder_sent_time timestamp
+);
+
+CREATE TABLE IF NOT EXISTS prediction_judge (
+	note_id bigint references prediction(note_id) ON DELETE CASCADE NOT NULL,
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	PRIMARY KEY (note_id, user_id)
+);
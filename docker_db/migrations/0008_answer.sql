@@ -0,0 +1,25 @@
+\c cerealnotes;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS question_answer (
+	answer_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	question_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	accepted boolean NOT NULL DEFAULT false
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS question_answer_question_index ON question_answer (question_id);
+CREATE UNIQUE INDEX IF NOT EXISTS question_answer_accepted_index ON question_answer (question_id) WHERE accepted;
+
+\c cerealnotes_test;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS question_answer (
+	answer_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	question_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	accepted boolean NOT NULL DEFAULT false
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS question_answer_question_index ON question_answer (question_id);
+CREATE UNIQUE INDEX IF NOT EXISTS question_answer_accepted_index ON question_answer (question_id) WHERE accepted;
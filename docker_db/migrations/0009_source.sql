@@ -0,0 +1,53 @@
+\c cerealnotes;
+
+-- Types
+CREATE TYPE source_location_type AS ENUM ('page', 'chapter', 'timestamp');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS source (
+	id bigserial PRIMARY KEY,
+	title text NOT NULL,
+	author text NOT NULL,
+	url text NOT NULL,
+	isbn text NOT NULL,
+	doi text NOT NULL,
+	dedup_key text UNIQUE NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS note_to_source_relationship (
+	note_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	source_id bigint references source(id) ON DELETE CASCADE NOT NULL,
+	location_type source_location_type,
+	location text NOT NULL
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS note_to_source_relationship_source_index ON note_to_source_relationship (source_id);
+
+\c cerealnotes_test;
+
+-- Types
+CREATE TYPE source_location_type AS ENUM ('page', 'chapter', 'timestamp');
+
+-- Tables
+CREATE TABLE IF NOT EXISTS source (
+	id bigserial PRIMARY KEY,
+	title text NOT NULL,
+	author text NOT NULL,
+	url text NOT NULL,
+	isbn text NOT NULL,
+	doi text NOT NULL,
+	dedup_key text UNIQUE NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS note_to_source_relationship (
+	note_id bigint PRIMARY KEY references note(id) ON DELETE CASCADE,
+	source_id bigint references source(id) ON DELETE CASCADE NOT NULL,
+	location_type source_location_type,
+	location text NOT NULL
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS note_to_source_relationship_source_index ON note_to_source_relationship (source_id);
@@ -0,0 +1,57 @@
+\c cerealnotes;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS category (
+	name text PRIMARY KEY,
+	colour text NOT NULL,
+	description text NOT NULL,
+	sort_order integer NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+-- Seed the categories that used to be hard-coded
+INSERT INTO category (name, colour, description, sort_order, creation_time) VALUES
+	('marginalia', '#607d8b', 'Notes about something you are reading', 0, now() at time zone 'utc'),
+	('meta', '#9c27b0', 'Notes about CerealNotes itself', 1, now() at time zone 'utc'),
+	('question', '#2196f3', 'Questions for other readers to answer', 2, now() at time zone 'utc'),
+	('prediction', '#ff9800', 'Predictions with a probability that are resolved later', 3, now() at time zone 'utc')
+ON CONFLICT (name) DO NOTHING;
+
+-- Migrate existing categorizations from the enum
+ALTER TABLE note_to_category_relationship
+	ALTER COLUMN category TYPE text USING category::text;
+
+ALTER TABLE note_to_category_relationship
+	ADD CONSTRAINT note_to_category_relationship_category_fkey
+	FOREIGN KEY (category) REFERENCES category(name) ON UPDATE CASCADE;
+
+DROP TYPE category_type;
+
+\c cerealnotes_test;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS category (
+	name text PRIMARY KEY,
+	colour text NOT NULL,
+	description text NOT NULL,
+	sort_order integer NOT NULL,
+	creation_time timestamp NOT NULL
+);
+
+-- Seed the categories that used to be hard-coded
+INSERT INTO category (name, colour, description, sort_order, creation_time) VALUES
+	('marginalia', '#607d8b', 'Notes about something you are reading', 0, now() at time zone 'utc'),
+	('meta', '#9c27b0', 'Notes about CerealNotes itself', 1, now() at time zone 'utc'),
+	('question', '#2196f3', 'Questions for other readers to answer', 2, now() at time zone 'utc'),
+	('prediction', '#ff9800', 'Predictions with a probability that are resolved later', 3, now() at time zone 'utc')
+ON CONFLICT (name) DO NOTHING;
+
+-- Migrate existing categorizations from the enum
+ALTER TABLE note_to_category_relationship
+	ALTER COLUMN category TYPE text USING category::text;
+
+ALTER TABLE note_to_category_relationship
+	ADD CONSTRAINT note_to_category_relationship_category_fkey
+	FOREIGN KEY (category) REFERENCES category(name) ON UPDATE CASCADE;
+
+DROP TYPE category_type;
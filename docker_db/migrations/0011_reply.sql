@@ -0,0 +1,31 @@
+\c cerealnotes;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS note_reply (
+	id bigserial PRIMARY KEY,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	parent_id bigint references note_reply(id) ON DELETE CASCADE,
+	author_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	content text NOT NULL,
+	creation_time timestamp NOT NULL,
+	last_edit_time timestamp,
+	deleted_time timestamp
+);
+
+CREATE INDEX IF NOT EXISTS note_reply_note_id_idx ON note_reply (note_id);
+
+\c cerealnotes_test;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS note_reply (
+	id bigserial PRIMARY KEY,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	parent_id bigint references note_reply(id) ON DELETE CASCADE,
+	author_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	content text NOT NULL,
+	creation_time timestamp NOT NULL,
+	last_edit_time timestamp,
+	deleted_time timestamp
+);
+
+CREATE INDEX IF NOT EXISTS note_reply_note_id_idx ON note_reply (note_id);
@@ -0,0 +1,23 @@
+\c cerealnotes;
+
+-- Tables
+-- the primary key starts with note_id so the reactions for a page of notes can be counted from the index
+CREATE TABLE IF NOT EXISTS note_reaction (
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	reaction text NOT NULL,
+	creation_time timestamp NOT NULL,
+	PRIMARY KEY (note_id, reaction, user_id)
+);
+
+\c cerealnotes_test;
+
+-- Tables
+-- the primary key starts with note_id so the reactions for a page of notes can be counted from the index
+CREATE TABLE IF NOT EXISTS note_reaction (
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	reaction text NOT NULL,
+	creation_time timestamp NOT NULL,
+	PRIMARY KEY (note_id, reaction, user_id)
+);
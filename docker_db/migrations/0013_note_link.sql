@@ -0,0 +1,21 @@
+\c cerealnotes;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS note_link (
+	source_note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	target_note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	PRIMARY KEY (source_note_id, target_note_id)
+);
+
+CREATE INDEX IF NOT EXISTS note_link_target_note_id_idx ON note_link (target_note_id);
+
+\c cerealnotes_test;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS note_link (
+	source_note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	target_note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	PRIMARY KEY (source_note_id, target_note_id)
+);
+
+CREATE INDEX IF NOT EXISTS note_link_target_note_id_idx ON note_link (target_note_id);
@@ -0,0 +1,11 @@
+\c cerealnotes;
+
+-- Columns
+-- bumped whenever a note's content changes, so anything derived from the content can be cached per revision
+ALTER TABLE note ADD COLUMN IF NOT EXISTS revision integer NOT NULL DEFAULT 1;
+
+\c cerealnotes_test;
+
+-- Columns
+-- bumped whenever a note's content changes, so anything derived from the content can be cached per revision
+ALTER TABLE note ADD COLUMN IF NOT EXISTS revision integer NOT NULL DEFAULT 1;
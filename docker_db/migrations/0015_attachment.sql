@@ -0,0 +1,33 @@
+\c cerealnotes;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS note_attachment (
+	id bigserial PRIMARY KEY,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	uploader_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	file_name text NOT NULL,
+	content_type text NOT NULL,
+	size bigint NOT NULL,
+	blob_key text UNIQUE NOT NULL,
+	thumbnail_key text,
+	creation_time timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS note_attachment_note_id_idx ON note_attachment (note_id);
+
+\c cerealnotes_test;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS note_attachment (
+	id bigserial PRIMARY KEY,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	uploader_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	file_name text NOT NULL,
+	content_type text NOT NULL,
+	size bigint NOT NULL,
+	blob_key text UNIQUE NOT NULL,
+	thumbnail_key text,
+	creation_time timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS note_attachment_note_id_idx ON note_attachment (note_id);
@@ -0,0 +1,19 @@
+\c cerealnotes;
+
+-- Columns
+-- Trashed notes keep their row until purged. Published notes are never removed,
+-- so purging them only clears their content and leaves a tombstone in the issue.
+ALTER TABLE note ADD COLUMN IF NOT EXISTS deleted_time timestamp;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS purged_time timestamp;
+
+CREATE INDEX IF NOT EXISTS note_deleted_time_idx ON note (deleted_time) WHERE deleted_time IS NOT NULL;
+
+\c cerealnotes_test;
+
+-- Columns
+-- Trashed notes keep their row until purged. Published notes are never removed,
+-- so purging them only clears their content and leaves a tombstone in the issue.
+ALTER TABLE note ADD COLUMN IF NOT EXISTS deleted_time timestamp;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS purged_time timestamp;
+
+CREATE INDEX IF NOT EXISTS note_deleted_time_idx ON note (deleted_time) WHERE deleted_time IS NOT NULL;
@@ -0,0 +1,13 @@
+\c cerealnotes;
+
+-- Columns
+-- Retracted issues keep their row so that issue numbers and publication counts don't change.
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS retracted_time timestamp;
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS retraction_reason text;
+
+\c cerealnotes_test;
+
+-- Columns
+-- Retracted issues keep their row so that issue numbers and publication counts don't change.
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS retracted_time timestamp;
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS retraction_reason text;
@@ -0,0 +1,15 @@
+\c cerealnotes;
+
+-- Columns
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS title text NOT NULL DEFAULT '';
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS intro text NOT NULL DEFAULT '';
+-- the note's place in its issue, counting from 1; issues published before this have none and are ordered by creation time
+ALTER TABLE note_to_publication_relationship ADD COLUMN IF NOT EXISTS position integer;
+
+\c cerealnotes_test;
+
+-- Columns
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS title text NOT NULL DEFAULT '';
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS intro text NOT NULL DEFAULT '';
+-- the note's place in its issue, counting from 1; issues published before this have none and are ordered by creation time
+ALTER TABLE note_to_publication_relationship ADD COLUMN IF NOT EXISTS position integer;
@@ -0,0 +1,21 @@
+\c cerealnotes;
+
+-- Tables
+-- the primary key starts with user_id so a reader's unread notes can be found from the index
+CREATE TABLE IF NOT EXISTS note_read (
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	read_time timestamp NOT NULL,
+	PRIMARY KEY (user_id, note_id)
+);
+
+\c cerealnotes_test;
+
+-- Tables
+-- the primary key starts with user_id so a reader's unread notes can be found from the index
+CREATE TABLE IF NOT EXISTS note_read (
+	user_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	read_time timestamp NOT NULL,
+	PRIMARY KEY (user_id, note_id)
+);
@@ -0,0 +1,47 @@
+\c cerealnotes;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS collection (
+	id bigserial PRIMARY KEY,
+	owner_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	name text NOT NULL,
+	creation_time timestamp NOT NULL,
+	UNIQUE (owner_id, name)
+);
+
+-- Rows are removed along with the note, and by the application when the note is trashed or its issue
+-- retracted, so collections only ever hold notes their owner can see.
+CREATE TABLE IF NOT EXISTS collection_note (
+	collection_id bigint references collection(id) ON DELETE CASCADE NOT NULL,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	annotation text NOT NULL DEFAULT '',
+	position integer NOT NULL,
+	added_time timestamp NOT NULL,
+	PRIMARY KEY (collection_id, note_id)
+);
+
+CREATE INDEX IF NOT EXISTS collection_note_note_id_idx ON collection_note (note_id);
+
+\c cerealnotes_test;
+
+-- Tables
+CREATE TABLE IF NOT EXISTS collection (
+	id bigserial PRIMARY KEY,
+	owner_id bigint references app_user(id) ON DELETE CASCADE NOT NULL,
+	name text NOT NULL,
+	creation_time timestamp NOT NULL,
+	UNIQUE (owner_id, name)
+);
+
+-- Rows are removed along with the note, and by the application when the note is trashed or its issue
+-- retracted, so collections only ever hold notes their owner can see.
+CREATE TABLE IF NOT EXISTS collection_note (
+	collection_id bigint references collection(id) ON DELETE CASCADE NOT NULL,
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	annotation text NOT NULL DEFAULT '',
+	position integer NOT NULL,
+	added_time timestamp NOT NULL,
+	PRIMARY KEY (collection_id, note_id)
+);
+
+CREATE INDEX IF NOT EXISTS collection_note_note_id_idx ON collection_note (note_id);
@@ -0,0 +1,13 @@
+\c cerealnotes;
+
+-- Columns
+-- only unpublished notes are pinned or archived; archived notes are left out of listings and publications
+ALTER TABLE note ADD COLUMN IF NOT EXISTS pinned_time timestamp;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS archived_time timestamp;
+
+\c cerealnotes_test;
+
+-- Columns
+-- only unpublished notes are pinned or archived; archived notes are left out of listings and publications
+ALTER TABLE note ADD COLUMN IF NOT EXISTS pinned_time timestamp;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS archived_time timestamp;
@@ -0,0 +1,19 @@
+\c cerealnotes;
+
+-- Tables
+-- tags are the author's own labels; they are removed when the note is purged
+CREATE TABLE IF NOT EXISTS note_tag (
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	tag text NOT NULL,
+	PRIMARY KEY (note_id, tag)
+);
+
+\c cerealnotes_test;
+
+-- Tables
+-- tags are the author's own labels; they are removed when the note is purged
+CREATE TABLE IF NOT EXISTS note_tag (
+	note_id bigint references note(id) ON DELETE CASCADE NOT NULL,
+	tag text NOT NULL,
+	PRIMARY KEY (note_id, tag)
+);
@@ -0,0 +1,11 @@
+\c cerealnotes;
+
+-- Columns
+-- set whenever a note's content is edited, so responses can say when a note last changed
+ALTER TABLE note ADD COLUMN IF NOT EXISTS last_edit_time timestamp;
+
+\c cerealnotes_test;
+
+-- Columns
+-- set whenever a note's content is edited, so responses can say when a note last changed
+ALTER TABLE note ADD COLUMN IF NOT EXISTS last_edit_time timestamp;
@@ -0,0 +1,11 @@
+\c cerealnotes;
+
+-- Columns
+-- the user who created the category, who may change it along with admins; null for the seeded categories
+ALTER TABLE category ADD COLUMN IF NOT EXISTS creator_id bigint references app_user(id) ON DELETE SET NULL;
+
+\c cerealnotes_test;
+
+-- Columns
+-- the user who created the category, who may change it along with admins; null for the seeded categories
+ALTER TABLE category ADD COLUMN IF NOT EXISTS creator_id bigint references app_user(id) ON DELETE SET NULL;
@@ -1,7 +1,53 @@
-DROP TYPE category_type CASCADE;
+DROP TYPE notification_frequency_type CASCADE;
+
+DROP TYPE webhook_delivery_status_type CASCADE;
+
+DROP TYPE prediction_outcome_type CASCADE;
+
+DROP TYPE source_location_type CASCADE;
+
+DROP TABLE note_tag CASCADE;
+
+DROP TABLE collection_note CASCADE;
+
+DROP TABLE collection CASCADE;
+
+DROP TABLE note_read CASCADE;
+
+DROP TABLE note_attachment CASCADE;
+
+DROP TABLE note_link CASCADE;
+
+DROP TABLE note_reaction CASCADE;
+
+DROP TABLE note_reply CASCADE;
+
+DROP TABLE note_to_source_relationship CASCADE;
+
+DROP TABLE source CASCADE;
+
+DROP TABLE question_answer CASCADE;
+
+DROP TABLE prediction_judge CASCADE;
+
+DROP TABLE prediction CASCADE;
+
+DROP TABLE webhook_delivery CASCADE;
+
+DROP TABLE webhook_subscription CASCADE;
+
+DROP TABLE publication_notification CASCADE;
+
+DROP TABLE notification_preference CASCADE;
+
+DROP TABLE feed_token CASCADE;
+
+DROP TABLE note_import CASCADE;
 
 DROP TABLE note_to_category_relationship CASCADE;
 
+DROP TABLE category CASCADE;
+
 DROP TABLE note_to_publication_relationship CASCADE;
 
 DROP TABLE publication CASCADE;
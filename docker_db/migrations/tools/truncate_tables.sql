@@ -1,3 +1,41 @@
+TRUNCATE note_tag CASCADE;
+
+TRUNCATE collection_note CASCADE;
+
+TRUNCATE collection CASCADE;
+
+TRUNCATE note_read CASCADE;
+
+TRUNCATE note_attachment CASCADE;
+
+TRUNCATE note_link CASCADE;
+
+TRUNCATE note_reaction CASCADE;
+
+TRUNCATE note_reply CASCADE;
+
+TRUNCATE note_to_source_relationship CASCADE;
+
+TRUNCATE source CASCADE;
+
+TRUNCATE question_answer CASCADE;
+
+TRUNCATE prediction_judge CASCADE;
+
+TRUNCATE prediction CASCADE;
+
+TRUNCATE webhook_delivery CASCADE;
+
+TRUNCATE webhook_subscription CASCADE;
+
+TRUNCATE publication_notification CASCADE;
+
+TRUNCATE notification_preference CASCADE;
+
+TRUNCATE feed_token CASCADE;
+
+TRUNCATE note_import CASCADE;
+
 TRUNCATE note_to_publication_relationship CASCADE;
 
 TRUNCATE publication CASCADE;
@@ -0,0 +1,9 @@
+/*
+Package events fans note and publication events out to the users entitled to see them.
+
+Handlers publish events to a Hub along with their audience, and each live
+connection subscribes for its user. InProcessHub keeps recent events so
+reconnecting clients can resume from the last event they saw; a hub backed
+by Postgres LISTEN/NOTIFY can implement the same interface to span processes.
+*/
+package events
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// subscriberBufferSize is how many events a subscriber can fall behind before it is dropped.
+const subscriberBufferSize = 64
+
+// Event is a published event, numbered in the order the hub received it.
+type Event struct {
+	Id       int64
+	Audience []models.UserId
+	Payload  *models.WebhookEvent
+}
+
+func (event *Event) isVisibleTo(userId models.UserId) bool {
+	for _, audienceUserId := range event.Audience {
+		if audienceUserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub delivers published events to the subscribers in their audience.
+type Hub interface {
+	Publish(event *models.WebhookEvent, audience []models.UserId) error
+
+	// Subscribe listens for events visible to the user. A non zero lastEventId replays the
+	// events published since then, or marks the subscription Missed if that is no longer possible.
+	Subscribe(userId models.UserId, lastEventId int64) (*Subscription, error)
+}
+
+// Subscription is a single listener's view of a Hub.
+type Subscription struct {
+	// Replayed holds the events published after the requested last event id.
+	Replayed []*Event
+
+	// Missed is set when events after the requested last event id could not be replayed,
+	// so the listener should reload everything instead.
+	Missed bool
+
+	// Events is closed when the subscription is closed or falls too far behind.
+	Events <-chan *Event
+
+	close func()
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (subscription *Subscription) Close() {
+	subscription.close()
+}
+
+type subscriber struct {
+	userId models.UserId
+	events chan *Event
+}
+
+// InProcessHub is a Hub for the subscribers of a single process. It remembers the
+// last historySize events for replaying to reconnecting subscribers.
+type InProcessHub struct {
+	mutex       sync.Mutex
+	lastEventId int64
+	history     []*Event
+	historySize int
+	subscribers map[*subscriber]bool
+}
+
+func NewInProcessHub(historySize int) *InProcessHub {
+	return &InProcessHub{
+		history:     make([]*Event, 0, historySize),
+		historySize: historySize,
+		subscribers: make(map[*subscriber]bool),
+	}
+}
+
+func (hub *InProcessHub) Publish(payload *models.WebhookEvent, audience []models.UserId) error {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.lastEventId++
+	event := &Event{Id: hub.lastEventId, Audience: audience, Payload: payload}
+
+	if len(hub.history) == hub.historySize && hub.historySize > 0 {
+		copy(hub.history, hub.history[1:])
+		hub.history = hub.history[:len(hub.history)-1]
+	}
+	if hub.historySize > 0 {
+		hub.history = append(hub.history, event)
+	}
+
+	for subscriber := range hub.subscribers {
+		if !event.isVisibleTo(subscriber.userId) {
+			continue
+		}
+
+		select {
+		case subscriber.events <- event:
+		default:
+			// the subscriber can resume from its last event once it reconnects
+			hub.removeSubscriber(subscriber)
+		}
+	}
+
+	return nil
+}
+
+func (hub *InProcessHub) Subscribe(userId models.UserId, lastEventId int64) (*Subscription, error) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	newSubscriber := &subscriber{
+		userId: userId,
+		events: make(chan *Event, subscriberBufferSize),
+	}
+	hub.subscribers[newSubscriber] = true
+
+	subscription := &Subscription{
+		Replayed: make([]*Event, 0),
+		Events:   newSubscriber.events,
+		close: func() {
+			hub.mutex.Lock()
+			defer hub.mutex.Unlock()
+
+			hub.removeSubscriber(newSubscriber)
+		},
+	}
+
+	if lastEventId == 0 {
+		return subscription, nil
+	}
+
+	oldestEventId := hub.lastEventId + 1
+	if len(hub.history) > 0 {
+		oldestEventId = hub.history[0].Id
+	}
+
+	// ids from before a restart, or older than the history, can't be resumed from
+	if lastEventId > hub.lastEventId || lastEventId < oldestEventId-1 {
+		subscription.Missed = true
+		return subscription, nil
+	}
+
+	for _, event := range hub.history {
+		if event.Id > lastEventId && event.isVisibleTo(userId) {
+			subscription.Replayed = append(subscription.Replayed, event)
+		}
+	}
+
+	return subscription, nil
+}
+
+// removeSubscriber must be called with the hub's mutex held.
+func (hub *InProcessHub) removeSubscriber(subscriber *subscriber) {
+	if hub.subscribers[subscriber] {
+		delete(hub.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
@@ -0,0 +1,87 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/events"
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func publish(t *testing.T, hub events.Hub, authorId models.UserId, audience ...models.UserId) {
+	err := hub.Publish(&models.WebhookEvent{Type: models.NOTE_CREATED, AuthorId: authorId}, audience)
+	test_util.Ok(t, err)
+}
+
+func TestSubscribersOnlyReceiveVisibleEvents(t *testing.T) {
+	hub := events.NewInProcessHub(10)
+
+	alice, err := hub.Subscribe(1, 0)
+	test_util.Ok(t, err)
+	defer alice.Close()
+
+	bob, err := hub.Subscribe(2, 0)
+	test_util.Ok(t, err)
+	defer bob.Close()
+
+	publish(t, hub, 1, 1)
+	publish(t, hub, 2, 1, 2)
+
+	test_util.Equals(t, int64(1), (<-alice.Events).Id)
+	test_util.Equals(t, int64(2), (<-alice.Events).Id)
+	test_util.Equals(t, int64(2), (<-bob.Events).Id)
+	test_util.Equals(t, 0, len(bob.Events))
+}
+
+func TestResumingFromLastEventId(t *testing.T) {
+	hub := events.NewInProcessHub(3)
+
+	for i := 0; i < 5; i++ {
+		publish(t, hub, 1, 1)
+	}
+	publish(t, hub, 2, 2)
+
+	// events 4, 5 and 6 are remembered, and only 5 is visible and unseen
+	subscription, err := hub.Subscribe(1, 4)
+	test_util.Ok(t, err)
+	test_util.Assert(t, !subscription.Missed, "Expected the subscription to resume")
+	test_util.Equals(t, 1, len(subscription.Replayed))
+	test_util.Equals(t, int64(5), subscription.Replayed[0].Id)
+	subscription.Close()
+
+	subscription, err = hub.Subscribe(1, 3)
+	test_util.Ok(t, err)
+	test_util.Assert(t, !subscription.Missed, "Expected the subscription to resume")
+	test_util.Equals(t, 2, len(subscription.Replayed))
+	subscription.Close()
+
+	// event 3 was forgotten
+	subscription, err = hub.Subscribe(1, 2)
+	test_util.Ok(t, err)
+	test_util.Assert(t, subscription.Missed, "Expected forgotten events to be missed")
+	subscription.Close()
+
+	// ids from before a restart are missed too
+	subscription, err = hub.Subscribe(1, 7)
+	test_util.Ok(t, err)
+	test_util.Assert(t, subscription.Missed, "Expected unknown events to be missed")
+	subscription.Close()
+}
+
+func TestSlowSubscribersAreDropped(t *testing.T) {
+	hub := events.NewInProcessHub(0)
+
+	subscription, err := hub.Subscribe(1, 0)
+	test_util.Ok(t, err)
+
+	received := 0
+	for i := 0; i < 1000; i++ {
+		publish(t, hub, 1, 1)
+	}
+	for range subscription.Events {
+		received++
+	}
+
+	test_util.Assert(t, received < 1000, "Expected the subscription to be closed once it fell behind")
+	subscription.Close()
+}
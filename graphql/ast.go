@@ -0,0 +1,135 @@
+package graphql
+
+// Location is where something is in the query, counting lines and columns from 1.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type document struct {
+	operations []*operationDefinition
+	fragments  map[string]*fragmentDefinition
+}
+
+type operationDefinition struct {
+	operation    string
+	name         string
+	variables    []*variableDefinition
+	selectionSet []selection
+	location     Location
+}
+
+type variableDefinition struct {
+	name         string
+	variableType *typeReference
+	defaultValue *value
+	location     Location
+}
+
+// typeReference is a type as written in the query, such as [ID!]!. Lists have an element type and no name.
+type typeReference struct {
+	name    string
+	element *typeReference
+	nonNull bool
+}
+
+func (reference *typeReference) String() string {
+	text := reference.name
+	if reference.element != nil {
+		text = "[" + reference.element.String() + "]"
+	}
+
+	if reference.nonNull {
+		text += "!"
+	}
+
+	return text
+}
+
+type fragmentDefinition struct {
+	name          string
+	typeCondition string
+	directives    []*directive
+	selectionSet  []selection
+	location      Location
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment.
+type selection interface {
+	selectionDirectives() []*directive
+}
+
+type field struct {
+	alias        string
+	name         string
+	arguments    []*argument
+	directives   []*directive
+	selectionSet []selection
+	location     Location
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	location   Location
+}
+
+type inlineFragment struct {
+	typeCondition string
+	directives    []*directive
+	selectionSet  []selection
+	location      Location
+}
+
+func (field *field) selectionDirectives() []*directive             { return field.directives }
+func (spread *fragmentSpread) selectionDirectives() []*directive   { return spread.directives }
+func (fragment *inlineFragment) selectionDirectives() []*directive { return fragment.directives }
+
+// responseKey is the name of the field in the response, which is its alias if it has one.
+func (field *field) responseKey() string {
+	if len(field.alias) > 0 {
+		return field.alias
+	}
+
+	return field.name
+}
+
+type argument struct {
+	name     string
+	value    *value
+	location Location
+}
+
+type directive struct {
+	name      string
+	arguments []*argument
+	location  Location
+}
+
+type valueKind int
+
+const (
+	variableValue valueKind = iota
+	intValue
+	floatValue
+	stringValue
+	booleanValue
+	nullValue
+	enumValue
+	listValue
+	objectValue
+)
+
+// value is a literal or variable in the query. Raw holds the text of scalars and the name of variables.
+type value struct {
+	kind     valueKind
+	raw      string
+	list     []*value
+	fields   []*objectField
+	location Location
+}
+
+type objectField struct {
+	name  string
+	value *value
+}
@@ -0,0 +1,18 @@
+/*
+Package graphql parses and executes GraphQL requests against a schema of
+object and scalar types defined in Go.
+
+It covers what the api needs rather than the whole specification: queries and
+mutations with variables, aliases, fragments and the @skip and @include
+directives. Of introspection only __typename is supported; a schema prints
+itself in the schema definition language instead.
+
+Operations are validated before any field is resolved. A schema's MaxDepth
+limits how deeply they can nest fields, since fields that refer to each other
+let a short query ask for a very large response.
+
+Fields are resolved a level of the query at a time. Resolvers can return a
+Thunk rather than a value, which is only forced once every field at its level
+has been resolved, so that a Loader can fetch what all of them need in one go.
+*/
+package graphql
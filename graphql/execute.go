@@ -0,0 +1,599 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response has no data when the request couldn't be executed at all, and null data when a field that can't be
+// null was.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []*Error        `json:"errors,omitempty"`
+}
+
+// Error is an error in a response. The path leads to the field it happened in, if it happened in one.
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Execute runs the request. Resolvers are given the context, and are called one at a time, so that they don't
+// need to synchronize what they share.
+func (schema *Schema) Execute(ctx context.Context, request *Request) *Response {
+	document, err := parse(request.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+
+	operation, err := findOperation(document, request.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+
+	if errs := schema.validate(document, operation); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	variables, errs := schema.coerceVariables(operation, request.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	executor := &executor{
+		schema:    schema,
+		context:   ctx,
+		document:  document,
+		variables: variables,
+	}
+
+	rootType := schema.Query
+	if operation.operation == "mutation" {
+		rootType = schema.Mutation
+	}
+
+	root := &resultNode{isObject: true}
+	keys, fieldsByKey := executor.collectFields(operation.selectionSet)
+
+	if operation.operation == "mutation" {
+		// each top-level field of a mutation is done with before the next is begun
+		for _, key := range keys {
+			executor.executeLevels([]*objectTask{{
+				objectType:  rootType,
+				node:        root,
+				keys:        []string{key},
+				fieldsByKey: fieldsByKey,
+				path:        []interface{}{},
+			}})
+		}
+	} else {
+		executor.executeLevels([]*objectTask{{
+			objectType:  rootType,
+			node:        root,
+			keys:        keys,
+			fieldsByKey: fieldsByKey,
+			path:        []interface{}{},
+		}})
+	}
+
+	root.propagateNulls()
+
+	data := new(bytes.Buffer)
+	if err := root.writeJson(data); err != nil {
+		executor.errors = append(executor.errors, &Error{Message: err.Error()})
+		data.Reset()
+		data.WriteString("null")
+	}
+
+	return &Response{Data: data.Bytes(), Errors: executor.errors}
+}
+
+func findOperation(document *document, operationName string) (*operationDefinition, *Error) {
+	if len(operationName) == 0 {
+		if len(document.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return document.operations[0], nil
+	}
+
+	for _, operation := range document.operations {
+		if operation.name == operationName {
+			return operation, nil
+		}
+	}
+
+	return nil, &Error{Message: fmt.Sprintf(`Unknown operation named "%s".`, operationName)}
+}
+
+func (schema *Schema) coerceVariables(
+	operation *operationDefinition,
+	inputs map[string]interface{},
+) (map[string]interface{}, []*Error) {
+	variables := make(map[string]interface{})
+	errs := make([]*Error, 0)
+
+	for _, definition := range operation.variables {
+		variableType, _ := schema.inputType(definition.variableType)
+
+		input, ok := inputs[definition.name]
+		if !ok && definition.defaultValue != nil {
+			input, ok, _ = literalInput(definition.defaultValue, nil)
+		}
+
+		if !ok {
+			if _, ok := variableType.(*NonNull); ok {
+				errs = append(errs, &Error{
+					Message:   fmt.Sprintf(`Variable "$%s" of required type "%s" was not provided.`, definition.name, variableType),
+					Locations: []Location{definition.location},
+				})
+			}
+			continue
+		}
+
+		coerced, err := coerceInput(input, variableType)
+		if err != nil {
+			errs = append(errs, &Error{
+				Message:   fmt.Sprintf(`Variable "$%s" got an invalid value: %s.`, definition.name, err),
+				Locations: []Location{definition.location},
+			})
+			continue
+		}
+		variables[definition.name] = coerced
+	}
+
+	return variables, errs
+}
+
+type executor struct {
+	schema    *Schema
+	context   context.Context
+	document  *document
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// resultNode is a value in the response, filled in as the query is executed.
+type resultNode struct {
+	leaf     interface{}
+	isObject bool
+	isList   bool
+	// keys name the children of objects
+	keys     []string
+	children []*resultNode
+	null     bool
+	// nonNull nodes make their parent null when they are
+	nonNull bool
+}
+
+// objectTask is an object whose fields are to be resolved.
+type objectTask struct {
+	objectType  *Object
+	source      interface{}
+	node        *resultNode
+	keys        []string
+	fieldsByKey map[string][]*field
+	path        []interface{}
+}
+
+// fieldTask is a field that was resolved, whose value is yet to be completed.
+type fieldTask struct {
+	parentType *Object
+	fieldType  Type
+	fields     []*field
+	node       *resultNode
+	path       []interface{}
+	value      interface{}
+	err        error
+}
+
+// executeLevels resolves every field at one level of the response before going on to the next, so that the
+// thunks resolvers return at a level can be forced together.
+func (executor *executor) executeLevels(tasks []*objectTask) {
+	for len(tasks) > 0 {
+		fieldTasks := make([]*fieldTask, 0)
+		for _, task := range tasks {
+			fieldTasks = append(fieldTasks, executor.resolveFields(task)...)
+		}
+
+		executor.forceThunks(fieldTasks)
+
+		tasks = make([]*objectTask, 0)
+		for _, task := range fieldTasks {
+			if task.err != nil {
+				executor.fieldError(task, task.path, task.err)
+				_, task.node.nonNull = task.fieldType.(*NonNull)
+				task.node.null = true
+				continue
+			}
+
+			tasks = append(tasks, executor.completeValue(task, task.node, task.fieldType, task.value, task.path)...)
+		}
+	}
+}
+
+func (executor *executor) resolveFields(task *objectTask) []*fieldTask {
+	fieldTasks := make([]*fieldTask, 0, len(task.keys))
+	for _, key := range task.keys {
+		fields := task.fieldsByKey[key]
+		node := &resultNode{}
+		task.node.keys = append(task.node.keys, key)
+		task.node.children = append(task.node.children, node)
+
+		fieldTask := &fieldTask{
+			parentType: task.objectType,
+			fields:     fields,
+			node:       node,
+			path:       appendPath(task.path, key),
+		}
+		fieldTasks = append(fieldTasks, fieldTask)
+
+		if fields[0].name == "__typename" {
+			fieldTask.fieldType = &NonNull{OfType: String}
+			fieldTask.value = task.objectType.Name
+			continue
+		}
+
+		definition := task.objectType.Fields[fields[0].name]
+		fieldTask.fieldType = definition.Type
+
+		args, err := executor.arguments(definition.Args, fields[0].arguments)
+		if err != nil {
+			fieldTask.err = err
+			continue
+		}
+
+		params := ResolveParams{Source: task.source, Args: args, Context: executor.context}
+		if definition.Visible != nil && !definition.Visible(params) {
+			continue
+		}
+
+		if definition.Resolve == nil {
+			if source, ok := task.source.(map[string]interface{}); ok {
+				fieldTask.value = source[fields[0].name]
+			}
+			continue
+		}
+
+		fieldTask.value, fieldTask.err = definition.Resolve(params)
+	}
+
+	return fieldTasks
+}
+
+// forceThunks forces the thunks of the fields, again and again while forcing them returns more.
+func (executor *executor) forceThunks(tasks []*fieldTask) {
+	for {
+		forced := false
+		for _, task := range tasks {
+			if task.err != nil {
+				continue
+			}
+
+			if thunk, ok := asThunk(task.value); ok {
+				task.value, task.err = thunk()
+				forced = true
+			}
+		}
+
+		if !forced {
+			return
+		}
+	}
+}
+
+func asThunk(value interface{}) (Thunk, bool) {
+	switch value := value.(type) {
+	case Thunk:
+		return value, true
+	case func() (interface{}, error):
+		return value, true
+	}
+
+	return nil, false
+}
+
+// completeValue fills in the node with the value of the type, returning the objects whose fields are to be
+// resolved next.
+func (executor *executor) completeValue(
+	task *fieldTask,
+	node *resultNode,
+	valueType Type,
+	value interface{},
+	path []interface{},
+) []*objectTask {
+	if nonNull, ok := valueType.(*NonNull); ok {
+		node.nonNull = true
+		if isNil(value) {
+			executor.fieldError(task, path, &Error{Message: fmt.Sprintf(
+				"Cannot return null for non-nullable field %s.%s.",
+				task.parentType.Name,
+				task.fields[0].name)})
+			node.null = true
+			return nil
+		}
+		valueType = nonNull.OfType
+	}
+
+	if isNil(value) {
+		node.null = true
+		return nil
+	}
+
+	switch valueType := valueType.(type) {
+	case *Scalar:
+		serialized, err := valueType.Serialize(value)
+		if err != nil {
+			executor.fieldError(task, path, &Error{Message: err.Error()})
+			node.null = true
+			return nil
+		}
+		node.leaf = serialized
+		return nil
+
+	case *List:
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			executor.fieldError(task, path, &Error{Message: fmt.Sprintf(
+				"Expected a list for field %s.%s.",
+				task.parentType.Name,
+				task.fields[0].name)})
+			node.null = true
+			return nil
+		}
+
+		node.isList = true
+		node.children = make([]*resultNode, items.Len())
+		tasks := make([]*objectTask, 0)
+		for i := 0; i < items.Len(); i++ {
+			node.children[i] = &resultNode{}
+			tasks = append(tasks, executor.completeValue(
+				task,
+				node.children[i],
+				valueType.OfType,
+				items.Index(i).Interface(),
+				appendPath(path, i))...)
+		}
+		return tasks
+
+	case *Object:
+		node.isObject = true
+
+		selections := make([]selection, 0)
+		for _, field := range task.fields {
+			selections = append(selections, field.selectionSet...)
+		}
+		keys, fieldsByKey := executor.collectFields(selections)
+
+		return []*objectTask{{
+			objectType:  valueType,
+			source:      value,
+			node:        node,
+			keys:        keys,
+			fieldsByKey: fieldsByKey,
+			path:        path,
+		}}
+	}
+
+	return nil
+}
+
+func (executor *executor) fieldError(task *fieldTask, path []interface{}, err error) {
+	queryError, ok := err.(*Error)
+	if !ok {
+		queryError = &Error{Message: err.Error()}
+		if executor.schema.FormatError != nil {
+			queryError.Message, queryError.Extensions = executor.schema.FormatError(err)
+		}
+	}
+
+	located := *queryError
+	if len(located.Locations) == 0 {
+		located.Locations = []Location{task.fields[0].location}
+	}
+	located.Path = path
+
+	executor.errors = append(executor.errors, &located)
+}
+
+// collectFields groups the selected fields by their response key, in the order they first appear, leaving out
+// those skipped by directives.
+func (executor *executor) collectFields(selections []selection) ([]string, map[string][]*field) {
+	keys := make([]string, 0)
+	fieldsByKey := make(map[string][]*field)
+	executor.collectFieldsInto(selections, &keys, fieldsByKey, make(map[string]bool))
+
+	return keys, fieldsByKey
+}
+
+func (executor *executor) collectFieldsInto(
+	selections []selection,
+	keys *[]string,
+	fieldsByKey map[string][]*field,
+	visitedFragments map[string]bool,
+) {
+	for _, selection := range selections {
+		if !executor.included(selection.selectionDirectives()) {
+			continue
+		}
+
+		switch selection := selection.(type) {
+		case *field:
+			key := selection.responseKey()
+			if _, ok := fieldsByKey[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fieldsByKey[key] = append(fieldsByKey[key], selection)
+
+		case *inlineFragment:
+			executor.collectFieldsInto(selection.selectionSet, keys, fieldsByKey, visitedFragments)
+
+		case *fragmentSpread:
+			if visitedFragments[selection.name] {
+				continue
+			}
+			visitedFragments[selection.name] = true
+
+			fragment := executor.document.fragments[selection.name]
+			if !executor.included(fragment.directives) {
+				continue
+			}
+			executor.collectFieldsInto(fragment.selectionSet, keys, fieldsByKey, visitedFragments)
+		}
+	}
+}
+
+func (executor *executor) included(directives []*directive) bool {
+	for _, directive := range directives {
+		input, _, _ := literalInput(directive.arguments[0].value, executor.variables)
+		condition, _ := input.(bool)
+
+		if (directive.name == "skip" && condition) || (directive.name == "include" && !condition) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (executor *executor) arguments(
+	definitions map[string]*Argument,
+	arguments []*argument,
+) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for name, definition := range definitions {
+		var given *argument
+		for _, argument := range arguments {
+			if argument.name == name {
+				given = argument
+			}
+		}
+
+		if given != nil {
+			input, ok, err := literalInput(given.value, executor.variables)
+			if err != nil {
+				return nil, &Error{Message: err.Error(), Locations: []Location{given.location}}
+			}
+
+			if ok {
+				coerced, err := coerceInput(input, definition.Type)
+				if err != nil {
+					return nil, &Error{
+						Message:   fmt.Sprintf(`Argument "%s" has an invalid value: %s.`, name, err),
+						Locations: []Location{given.location},
+					}
+				}
+				args[name] = coerced
+				continue
+			}
+		}
+
+		if definition.Default != nil {
+			args[name] = definition.Default
+			continue
+		}
+
+		if _, ok := definition.Type.(*NonNull); ok {
+			return nil, &Error{Message: fmt.Sprintf(`Argument "%s" of type "%s" was not provided.`, name, definition.Type)}
+		}
+	}
+
+	return args, nil
+}
+
+// propagateNulls makes null the objects and lists with a child that's null but can't be, and says whether
+// the node is null.
+func (node *resultNode) propagateNulls() bool {
+	for _, child := range node.children {
+		if child.propagateNulls() && child.nonNull {
+			node.null = true
+		}
+	}
+
+	return node.null
+}
+
+func (node *resultNode) writeJson(buffer *bytes.Buffer) error {
+	switch {
+	case node.null:
+		buffer.WriteString("null")
+
+	case node.isObject:
+		buffer.WriteByte('{')
+		for i, key := range node.keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			keyJson, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buffer.Write(keyJson)
+			buffer.WriteByte(':')
+
+			if err := node.children[i].writeJson(buffer); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+
+	case node.isList:
+		buffer.WriteByte('[')
+		for i, child := range node.children {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			if err := child.writeJson(buffer); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+
+	default:
+		leafJson, err := json.Marshal(node.leaf)
+		if err != nil {
+			return err
+		}
+		buffer.Write(leafJson)
+	}
+
+	return nil
+}
+
+// appendPath returns a new path, so that paths that share a parent don't share their backing array.
+func appendPath(path []interface{}, segment interface{}) []interface{} {
+	extended := make([]interface{}, len(path), len(path)+1)
+	copy(extended, path)
+
+	return append(extended, segment)
+}
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+		return reflected.IsNil()
+	}
+
+	return false
+}
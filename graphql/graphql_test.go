@@ -0,0 +1,369 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/graphql"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+type librarianKey struct{}
+
+type testBook struct {
+	id       string
+	title    string
+	authorId string
+	secret   string
+}
+
+type testLibrary struct {
+	books      []*testBook
+	authors    map[string]string
+	authorLoad [][]interface{}
+	log        []string
+}
+
+func newTestSchema(t *testing.T, library *testLibrary) *graphql.Schema {
+	authors := graphql.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		library.authorLoad = append(library.authorLoad, keys)
+
+		names := make(map[interface{}]interface{})
+		for _, key := range keys {
+			if name, ok := library.authors[key.(string)]; ok {
+				names[key] = name
+			}
+		}
+		return names, nil
+	})
+
+	bookType := &graphql.Object{Name: "Book", Description: "A book in the library."}
+	bookType.Fields = graphql.Fields{
+		"id": {Type: &graphql.NonNull{OfType: graphql.ID}, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*testBook).id, nil
+		}},
+		"title": {Type: &graphql.NonNull{OfType: graphql.String}, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*testBook).title, nil
+		}},
+		"author": {Type: graphql.String, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return authors.Load(params.Source.(*testBook).authorId), nil
+		}},
+		"requiredAuthor": {Type: &graphql.NonNull{OfType: graphql.String}, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return authors.Load(params.Source.(*testBook).authorId), nil
+		}},
+		"secret": {
+			Type:    graphql.String,
+			Visible: func(params graphql.ResolveParams) bool { return params.Context.Value(librarianKey{}) != nil },
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return params.Source.(*testBook).secret, nil
+			},
+		},
+		"broken": {Type: graphql.Int, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return nil, errors.New("broken")
+		}},
+		"requiredBroken": {Type: &graphql.NonNull{OfType: graphql.Int}, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return nil, errors.New("broken")
+		}},
+	}
+
+	books := func(params graphql.ResolveParams) (interface{}, error) {
+		first, ok := params.Args["first"].(int)
+		if !ok || first > len(library.books) {
+			first = len(library.books)
+		}
+		return library.books[:first], nil
+	}
+
+	queryType := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"books": {
+			Type:    &graphql.NonNull{OfType: &graphql.List{OfType: &graphql.NonNull{OfType: bookType}}},
+			Args:    map[string]*graphql.Argument{"first": {Type: graphql.Int}},
+			Resolve: books,
+		},
+		"book": {
+			Type: bookType,
+			Args: map[string]*graphql.Argument{"id": {Type: &graphql.NonNull{OfType: graphql.ID}}},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				for _, book := range library.books {
+					if book.id == params.Args["id"] {
+						return book, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+	}}
+
+	mutationType := &graphql.Object{Name: "Mutation", Fields: graphql.Fields{
+		"rename": {
+			Type: bookType,
+			Args: map[string]*graphql.Argument{
+				"id":    {Type: &graphql.NonNull{OfType: graphql.ID}},
+				"title": {Type: &graphql.NonNull{OfType: graphql.String}},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				library.log = append(library.log, params.Args["title"].(string))
+				for _, book := range library.books {
+					if book.id == params.Args["id"] {
+						book.title = params.Args["title"].(string)
+						return book, nil
+					}
+				}
+				return nil, errors.New("no such book")
+			},
+		},
+	}}
+
+	schema, err := graphql.NewSchema(queryType, mutationType)
+	test_util.Ok(t, err)
+
+	return schema
+}
+
+func newTestLibrary() *testLibrary {
+	return &testLibrary{
+		books: []*testBook{
+			{id: "1", title: "Dune", authorId: "herbert", secret: "spice"},
+			{id: "2", title: "Emma", authorId: "austen"},
+			{id: "3", title: "Persuasion", authorId: "austen"},
+			{id: "4", title: "Anonymous", authorId: "unknown"},
+		},
+		authors: map[string]string{"herbert": "Frank Herbert", "austen": "Jane Austen"},
+	}
+}
+
+func execute(t *testing.T, schema *graphql.Schema, ctx context.Context, request *graphql.Request) (string, []string) {
+	response := schema.Execute(ctx, request)
+
+	messages := make([]string, 0)
+	for _, err := range response.Errors {
+		messages = append(messages, err.Message)
+	}
+
+	return string(response.Data), messages
+}
+
+func TestExecute(t *testing.T) {
+	library := newTestLibrary()
+	schema := newTestSchema(t, library)
+
+	data, errs := execute(t, schema, context.Background(), &graphql.Request{Query: `
+		query Shelf($count: Int = 2, $withTitle: Boolean!) {
+			books(first: $count) { ...Names kind: __typename }
+			dune: book(id: 1) { title @include(if: $withTitle) secret }
+		}
+
+		fragment Names on Book { id, author }`,
+		Variables: map[string]interface{}{"withTitle": false},
+	})
+	test_util.Equals(t, 0, len(errs))
+	test_util.Equals(
+		t,
+		`{"books":[{"id":"1","author":"Frank Herbert","kind":"Book"},{"id":"2","author":"Jane Austen","kind":"Book"}],"dune":{"secret":null}}`,
+		data)
+
+	// every author the books needed was loaded in one batch
+	test_util.Equals(t, 1, len(library.authorLoad))
+	test_util.Equals(t, 2, len(library.authorLoad[0]))
+
+	data, errs = execute(t, schema, context.WithValue(context.Background(), librarianKey{}, true), &graphql.Request{
+		Query: `{ book(id: "1") { secret } }`,
+	})
+	test_util.Equals(t, 0, len(errs))
+	test_util.Equals(t, `{"book":{"secret":"spice"}}`, data)
+}
+
+func TestNullPropagation(t *testing.T) {
+	schema := newTestSchema(t, newTestLibrary())
+
+	// a nullable field that fails is null by itself
+	data, errs := execute(t, schema, context.Background(), &graphql.Request{Query: `{ book(id: 2) { title broken } }`})
+	test_util.Equals(t, []string{"broken"}, errs)
+	test_util.Equals(t, `{"book":{"title":"Emma","broken":null}}`, data)
+
+	// a non-null field that's null makes its parent null, up to the nearest nullable field
+	data, errs = execute(t, schema, context.Background(), &graphql.Request{Query: `{ book(id: 4) { requiredAuthor } }`})
+	test_util.Equals(t, []string{"Cannot return null for non-nullable field Book.requiredAuthor."}, errs)
+	test_util.Equals(t, `{"book":null}`, data)
+
+	data, errs = execute(t, schema, context.Background(), &graphql.Request{Query: `{ book(id: 2) { title requiredBroken } }`})
+	test_util.Equals(t, []string{"broken"}, errs)
+	test_util.Equals(t, `{"book":null}`, data)
+
+	data, errs = execute(t, schema, context.Background(), &graphql.Request{Query: `{ books { requiredAuthor } }`})
+	test_util.Equals(t, 1, len(errs))
+	test_util.Equals(t, `null`, data)
+
+	response := schema.Execute(context.Background(), &graphql.Request{Query: `{ books { requiredAuthor } }`})
+	test_util.Equals(t, []interface{}{"books", 3, "requiredAuthor"}, response.Errors[0].Path)
+	test_util.Equals(t, []graphql.Location{{Line: 1, Column: 11}}, response.Errors[0].Locations)
+}
+
+func TestMutation(t *testing.T) {
+	library := newTestLibrary()
+	schema := newTestSchema(t, library)
+
+	data, errs := execute(t, schema, context.Background(), &graphql.Request{
+		Query: `mutation ($title: String!) {
+			first: rename(id: 2, title: $title) { title }
+			second: rename(id: 2, title: "Emma!") { title }
+			missing: rename(id: 9, title: "None") { title }
+		}`,
+		Variables: map[string]interface{}{"title": "Emma?"},
+	})
+	test_util.Equals(t, []string{"no such book"}, errs)
+	test_util.Equals(t, `{"first":{"title":"Emma?"},"second":{"title":"Emma!"},"missing":null}`, data)
+	test_util.Equals(t, []string{"Emma?", "Emma!", "None"}, library.log)
+}
+
+var requestErrorTests = []struct {
+	name    string
+	request *graphql.Request
+	error   string
+}{
+	{"syntax", &graphql.Request{Query: `{ books { id }`}, `Syntax error: expected a name, found the end of the query`},
+	{"unknown field", &graphql.Request{Query: `{ books { isbn } }`}, `Cannot query field "isbn" on type "Book".`},
+	{"unknown argument", &graphql.Request{Query: `{ books(last: 1) { id } }`}, `Unknown argument "last" on field "Query.books".`},
+	{"missing argument", &graphql.Request{Query: `{ book { id } }`}, `Argument "id" of type "ID!" is required, but it was not provided.`},
+	{"invalid argument", &graphql.Request{Query: `{ books(first: "two") { id } }`}, `Argument "first" has an invalid value: Int cannot represent "two".`},
+	{"leaf selection", &graphql.Request{Query: `{ books { id { value } } }`}, `Field "id" must not have a selection since type "ID!" has no subfields.`},
+	{"object selection", &graphql.Request{Query: `{ books }`}, `Field "books" of type "[Book!]!" must have a selection of subfields.`},
+	{"fragment cycle", &graphql.Request{Query: `{ books { ...A } } fragment A on Book { ...A }`}, `Cannot spread fragment "A" within itself.`},
+	{"conflict", &graphql.Request{Query: `{ books { id: title id } }`}, `Fields "id" conflict because title and id are different fields.`},
+	{"undefined variable", &graphql.Request{Query: `{ books(first: $n) { id } }`}, `Variable "$n" is not defined.`},
+	{"variable type", &graphql.Request{Query: `query ($id: ID) { book(id: $id) { id } }`}, `Variable "$id" of type "ID" used in position expecting type "ID!".`},
+	{"missing variable", &graphql.Request{Query: `query ($id: ID!) { book(id: $id) { id } }`}, `Variable "$id" of required type "ID!" was not provided.`},
+	{
+		"invalid variable",
+		&graphql.Request{Query: `query ($id: ID!) { book(id: $id) { id } }`, Variables: map[string]interface{}{"id": true}},
+		`Variable "$id" got an invalid value: ID cannot represent true.`,
+	},
+	{"operation name", &graphql.Request{Query: `query A { books { id } } query B { books { id } }`}, `Must provide operation name if query contains multiple operations.`},
+	{"subscription", &graphql.Request{Query: `subscription { books { id } }`}, `The schema doesn't support subscription operations.`},
+}
+
+func TestRequestErrors(t *testing.T) {
+	schema := newTestSchema(t, newTestLibrary())
+
+	for _, test := range requestErrorTests {
+		t.Run(test.name, func(t *testing.T) {
+			response := schema.Execute(context.Background(), test.request)
+			test_util.Equals(t, 0, len(response.Data))
+			test_util.Assert(t, len(response.Errors) > 0, "Expected an error")
+			test_util.Equals(t, test.error, response.Errors[0].Message)
+		})
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	schema := newTestSchema(t, newTestLibrary())
+
+	data, errs := execute(t, schema, context.Background(), &graphql.Request{Query: `
+		# comments and commas are ignored
+		{ book(id: """
+			2
+		"""), { title } }`})
+	test_util.Equals(t, 0, len(errs))
+	test_util.Equals(t, `{"book":{"title":"Emma"}}`, data)
+
+	data, errs = execute(t, schema, context.Background(), &graphql.Request{Query: `{ book(id: "3") { title } }`})
+	test_util.Equals(t, 0, len(errs))
+	test_util.Equals(t, `{"book":{"title":"Persuasion"}}`, data)
+
+	_, errs = execute(t, schema, context.Background(), &graphql.Request{Query: `{ books(first: 01) { id } }`})
+	test_util.Equals(t, 1, len(errs))
+}
+
+func TestMaxDepth(t *testing.T) {
+	// each link leads to the next, as deep as a query cares to go
+	linkType := &graphql.Object{Name: "Link"}
+	linkType.Fields = graphql.Fields{
+		"depth": {Type: graphql.Int, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(int), nil
+		}},
+		"next": {Type: linkType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(int) + 1, nil
+		}},
+	}
+
+	schema, err := graphql.NewSchema(&graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"first": {Type: linkType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return 1, nil
+		}},
+	}}, nil)
+	test_util.Ok(t, err)
+	schema.MaxDepth = 3
+
+	data, errs := execute(t, schema, context.Background(), &graphql.Request{Query: `{ first { next { next { depth } } } }`})
+	test_util.Equals(t, 0, len(errs))
+	test_util.Equals(t, `{"first":{"next":{"next":{"depth":3}}}}`, data)
+
+	// fragments don't hide how deep the fields in them are
+	for _, query := range []string{
+		`{ first { next { next { next { depth } } } } }`,
+		`{ first { next { ...Deeper } } } fragment Deeper on Link { next { next { depth } } }`,
+	} {
+		data, errs = execute(t, schema, context.Background(), &graphql.Request{Query: query})
+		test_util.Equals(t, []string{`Field "next" is nested more than 3 levels deep.`}, errs)
+		test_util.Equals(t, "", data)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	batches := make([][]interface{}, 0)
+	loader := graphql.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		batches = append(batches, keys)
+
+		values := make(map[interface{}]interface{})
+		for _, key := range keys {
+			values[key] = key.(int) * 10
+		}
+		return values, nil
+	})
+
+	first := loader.Load(1)
+	second := loader.Load(2)
+	again := loader.Load(1)
+
+	value, err := second()
+	test_util.Ok(t, err)
+	test_util.Equals(t, 20, value)
+
+	value, err = first()
+	test_util.Ok(t, err)
+	test_util.Equals(t, 10, value)
+
+	value, _ = again()
+	test_util.Equals(t, 10, value)
+	test_util.Equals(t, [][]interface{}{{1, 2}}, batches)
+
+	loader.Clear()
+	value, _ = loader.Load(1)()
+	test_util.Equals(t, 10, value)
+	test_util.Equals(t, 2, len(batches))
+}
+
+func TestSchemaString(t *testing.T) {
+	schema := newTestSchema(t, newTestLibrary())
+
+	printed := schema.String()
+	test_util.Assert(t, strings.HasPrefix(printed, "type Query {\n  book(id: ID!): Book\n"), "%s", printed)
+	test_util.Assert(t, strings.Contains(printed, "\"A book in the library.\"\ntype Book {\n"), "%s", printed)
+	test_util.Assert(t, strings.Contains(printed, "  books(first: Int): [Book!]!\n"), "%s", printed)
+
+	_, err := graphql.NewSchema(&graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"hidden": {Type: &graphql.NonNull{OfType: graphql.Int}, Visible: func(graphql.ResolveParams) bool { return false }},
+	}}, nil)
+	test_util.Assert(t, err != nil, "Expected a hidden non-null field to be rejected")
+}
+
+func TestResponseJson(t *testing.T) {
+	schema := newTestSchema(t, newTestLibrary())
+
+	responseJson, err := json.Marshal(schema.Execute(context.Background(), &graphql.Request{Query: `{ book(id: 9) { id } }`}))
+	test_util.Ok(t, err)
+	test_util.Equals(t, `{"data":{"book":null}}`, string(responseJson))
+}
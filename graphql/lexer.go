@@ -0,0 +1,331 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const byteOrderMark = "\uFEFF"
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	punctuatorToken
+	nameToken
+	intToken
+	floatToken
+	stringToken
+)
+
+var tokenKindNames = map[tokenKind]string{
+	endToken:        "the end of the query",
+	punctuatorToken: "punctuator",
+	nameToken:       "name",
+	intToken:        "integer",
+	floatToken:      "number",
+	stringToken:     "string",
+}
+
+type token struct {
+	kind     tokenKind
+	value    string
+	location Location
+}
+
+func (token *token) String() string {
+	switch token.kind {
+	case endToken:
+		return tokenKindNames[endToken]
+	case stringToken:
+		return strconv.Quote(token.value)
+	default:
+		return `"` + token.value + `"`
+	}
+}
+
+// lexer splits a query into tokens, skipping whitespace, commas and comments as insignificant.
+type lexer struct {
+	source   string
+	position int
+	line     int
+	// lineStart is the position the current line starts at
+	lineStart int
+}
+
+func newLexer(source string) *lexer {
+	return &lexer{source: source, line: 1}
+}
+
+func (lexer *lexer) location() Location {
+	return Location{Line: lexer.line, Column: utf8.RuneCountInString(lexer.source[lexer.lineStart:lexer.position]) + 1}
+}
+
+func (lexer *lexer) syntaxError(location Location, format string, arguments ...interface{}) *Error {
+	return &Error{Message: "Syntax error: " + fmt.Sprintf(format, arguments...), Locations: []Location{location}}
+}
+
+func (lexer *lexer) next() (*token, *Error) {
+	lexer.skipIgnored()
+
+	location := lexer.location()
+	if lexer.position >= len(lexer.source) {
+		return &token{kind: endToken, location: location}, nil
+	}
+
+	character := lexer.source[lexer.position]
+	switch {
+	case strings.IndexByte("!$&():=@[]{|}", character) >= 0:
+		lexer.position++
+		return &token{kind: punctuatorToken, value: string(character), location: location}, nil
+
+	case character == '.':
+		if strings.HasPrefix(lexer.source[lexer.position:], "...") {
+			lexer.position += 3
+			return &token{kind: punctuatorToken, value: "...", location: location}, nil
+		}
+		return nil, lexer.syntaxError(location, `unexpected ".", did you mean "..."?`)
+
+	case character == '_' || isLetter(character):
+		start := lexer.position
+		for lexer.position < len(lexer.source) && isNameCharacter(lexer.source[lexer.position]) {
+			lexer.position++
+		}
+		return &token{kind: nameToken, value: lexer.source[start:lexer.position], location: location}, nil
+
+	case character == '-' || isDigit(character):
+		return lexer.number(location)
+
+	case character == '"':
+		if strings.HasPrefix(lexer.source[lexer.position:], `"""`) {
+			return lexer.blockString(location)
+		}
+		return lexer.string(location)
+
+	default:
+		character, _ := utf8.DecodeRuneInString(lexer.source[lexer.position:])
+		return nil, lexer.syntaxError(location, "unexpected character %q", character)
+	}
+}
+
+func (lexer *lexer) skipIgnored() {
+	for lexer.position < len(lexer.source) {
+		switch lexer.source[lexer.position] {
+		case ' ', '\t', ',':
+			lexer.position++
+
+		case '\n':
+			lexer.newLine(1)
+
+		case '\r':
+			if strings.HasPrefix(lexer.source[lexer.position:], "\r\n") {
+				lexer.newLine(2)
+			} else {
+				lexer.newLine(1)
+			}
+
+		case '#':
+			for lexer.position < len(lexer.source) && lexer.source[lexer.position] != '\n' && lexer.source[lexer.position] != '\r' {
+				lexer.position++
+			}
+
+		default:
+			// the byte order mark is ignored too
+			if strings.HasPrefix(lexer.source[lexer.position:], byteOrderMark) {
+				lexer.position += len(byteOrderMark)
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (lexer *lexer) newLine(length int) {
+	lexer.position += length
+	lexer.line++
+	lexer.lineStart = lexer.position
+}
+
+func (lexer *lexer) number(location Location) (*token, *Error) {
+	start := lexer.position
+	kind := intToken
+
+	if lexer.source[lexer.position] == '-' {
+		lexer.position++
+	}
+
+	integerStart := lexer.position
+	if !lexer.digits() {
+		return nil, lexer.syntaxError(location, "expected a digit in %q", lexer.source[start:lexer.position])
+	}
+
+	if lexer.position-integerStart > 1 && lexer.source[integerStart] == '0' {
+		return nil, lexer.syntaxError(location, "invalid number %q, which can't start with a 0", lexer.source[start:lexer.position])
+	}
+
+	if lexer.position < len(lexer.source) && lexer.source[lexer.position] == '.' {
+		kind = floatToken
+		lexer.position++
+		if !lexer.digits() {
+			return nil, lexer.syntaxError(location, "expected a digit after the point in %q", lexer.source[start:lexer.position])
+		}
+	}
+
+	if lexer.position < len(lexer.source) && (lexer.source[lexer.position] == 'e' || lexer.source[lexer.position] == 'E') {
+		kind = floatToken
+		lexer.position++
+		if lexer.position < len(lexer.source) && (lexer.source[lexer.position] == '+' || lexer.source[lexer.position] == '-') {
+			lexer.position++
+		}
+		if !lexer.digits() {
+			return nil, lexer.syntaxError(location, "expected a digit in the exponent of %q", lexer.source[start:lexer.position])
+		}
+	}
+
+	// a number runs straight into the next token only if it's malformed, as in 1x or 0.1.2
+	if lexer.position < len(lexer.source) && (isNameCharacter(lexer.source[lexer.position]) || lexer.source[lexer.position] == '.') {
+		return nil, lexer.syntaxError(location, "invalid number %q", lexer.source[start:lexer.position+1])
+	}
+
+	return &token{kind: kind, value: lexer.source[start:lexer.position], location: location}, nil
+}
+
+func (lexer *lexer) digits() bool {
+	start := lexer.position
+	for lexer.position < len(lexer.source) && isDigit(lexer.source[lexer.position]) {
+		lexer.position++
+	}
+
+	return lexer.position > start
+}
+
+var stringEscapes = map[byte]string{'"': `"`, '\\': `\`, '/': "/", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t"}
+
+func (lexer *lexer) string(location Location) (*token, *Error) {
+	lexer.position++
+
+	var text strings.Builder
+	for lexer.position < len(lexer.source) {
+		character := lexer.source[lexer.position]
+		switch character {
+		case '"':
+			lexer.position++
+			return &token{kind: stringToken, value: text.String(), location: location}, nil
+
+		case '\n', '\r':
+			return nil, lexer.syntaxError(location, "unterminated string")
+
+		case '\\':
+			if lexer.position+1 >= len(lexer.source) {
+				return nil, lexer.syntaxError(location, "unterminated string")
+			}
+
+			escaped := lexer.source[lexer.position+1]
+			if replacement, ok := stringEscapes[escaped]; ok {
+				text.WriteString(replacement)
+				lexer.position += 2
+				continue
+			}
+
+			if escaped != 'u' || lexer.position+6 > len(lexer.source) {
+				return nil, lexer.syntaxError(lexer.location(), `invalid escape "\%c"`, escaped)
+			}
+
+			code, err := strconv.ParseUint(lexer.source[lexer.position+2:lexer.position+6], 16, 32)
+			if err != nil {
+				return nil, lexer.syntaxError(lexer.location(), "invalid unicode escape %q", lexer.source[lexer.position:lexer.position+6])
+			}
+			text.WriteRune(rune(code))
+			lexer.position += 6
+
+		default:
+			text.WriteByte(character)
+			lexer.position++
+		}
+	}
+
+	return nil, lexer.syntaxError(location, "unterminated string")
+}
+
+// blockString reads a """ string, whose content is raw apart from escaped quotes. Its common indentation and
+// its blank first and last lines are removed, as the specification says.
+func (lexer *lexer) blockString(location Location) (*token, *Error) {
+	lexer.position += 3
+
+	var raw strings.Builder
+	for lexer.position < len(lexer.source) {
+		rest := lexer.source[lexer.position:]
+		switch {
+		case strings.HasPrefix(rest, `"""`):
+			lexer.position += 3
+			return &token{kind: stringToken, value: blockStringValue(raw.String()), location: location}, nil
+
+		case strings.HasPrefix(rest, `\"""`):
+			raw.WriteString(`"""`)
+			lexer.position += 4
+
+		case rest[0] == '\n':
+			raw.WriteByte('\n')
+			lexer.newLine(1)
+
+		case strings.HasPrefix(rest, "\r\n"):
+			raw.WriteByte('\n')
+			lexer.newLine(2)
+
+		case rest[0] == '\r':
+			raw.WriteByte('\n')
+			lexer.newLine(1)
+
+		default:
+			raw.WriteByte(rest[0])
+			lexer.position++
+		}
+	}
+
+	return nil, lexer.syntaxError(location, "unterminated block string")
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(raw, "\n")
+
+	commonIndent := -1
+	for _, line := range lines[1:] {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < len(line) && (commonIndent < 0 || indent < commonIndent) {
+			commonIndent = indent
+		}
+	}
+
+	if commonIndent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= commonIndent {
+				lines[i] = lines[i][commonIndent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+
+	for len(lines) > 0 && len(strings.TrimLeft(lines[0], " \t")) == 0 {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && len(strings.TrimLeft(lines[len(lines)-1], " \t")) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(character byte) bool {
+	return (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z')
+}
+
+func isDigit(character byte) bool {
+	return character >= '0' && character <= '9'
+}
+
+func isNameCharacter(character byte) bool {
+	return character == '_' || isLetter(character) || isDigit(character)
+}
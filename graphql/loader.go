@@ -0,0 +1,73 @@
+package graphql
+
+// BatchFunc fetches the values for many keys at once. Keys it returns no value for are given nil.
+type BatchFunc func(keys []interface{}) (map[interface{}]interface{}, error)
+
+// Loader collects the keys that the resolvers at a level ask for and fetches them all in one batch, when the
+// first of their thunks is forced. Values are cached for as long as the loader is kept, which is usually one
+// request. Loaders are not safe for concurrent use.
+type Loader struct {
+	batch   BatchFunc
+	results map[interface{}]*loaderResult
+	queue   []interface{}
+}
+
+type loaderResult struct {
+	value  interface{}
+	err    error
+	loaded bool
+}
+
+func NewLoader(batch BatchFunc) *Loader {
+	return &Loader{batch: batch, results: make(map[interface{}]*loaderResult)}
+}
+
+// Load returns a thunk for the value of the key, which must be comparable.
+func (loader *Loader) Load(key interface{}) Thunk {
+	result, ok := loader.results[key]
+	if !ok {
+		result = &loaderResult{}
+		loader.results[key] = result
+		loader.queue = append(loader.queue, key)
+	}
+
+	return func() (interface{}, error) {
+		if !result.loaded {
+			loader.dispatch()
+		}
+
+		return result.value, result.err
+	}
+}
+
+// Clear forgets the values fetched so far, for after they may have changed. Keys waiting to be fetched stay
+// queued.
+func (loader *Loader) Clear() {
+	for key, result := range loader.results {
+		if result.loaded {
+			delete(loader.results, key)
+		}
+	}
+}
+
+func (loader *Loader) dispatch() {
+	keys := loader.queue
+	loader.queue = nil
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := loader.batch(keys)
+	for _, key := range keys {
+		result := loader.results[key]
+		if result == nil {
+			continue
+		}
+
+		result.loaded = true
+		result.err = err
+		if err == nil {
+			result.value = values[key]
+		}
+	}
+}
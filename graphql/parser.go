@@ -0,0 +1,537 @@
+package graphql
+
+// parser reads a query document by recursive descent, one token ahead.
+type parser struct {
+	lexer *lexer
+	token *token
+}
+
+func parse(source string) (*document, *Error) {
+	parser := &parser{lexer: newLexer(source)}
+	if err := parser.advance(); err != nil {
+		return nil, err
+	}
+
+	return parser.document()
+}
+
+func (parser *parser) advance() *Error {
+	token, err := parser.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	parser.token = token
+	return nil
+}
+
+func (parser *parser) unexpected() *Error {
+	return parser.lexer.syntaxError(parser.token.location, "unexpected %s", parser.token)
+}
+
+// peek says whether the current token is the given punctuator.
+func (parser *parser) peek(punctuator string) bool {
+	return parser.token.kind == punctuatorToken && parser.token.value == punctuator
+}
+
+// skip moves past the current token if it's the given punctuator, and says whether it did.
+func (parser *parser) skip(punctuator string) (bool, *Error) {
+	if !parser.peek(punctuator) {
+		return false, nil
+	}
+
+	return true, parser.advance()
+}
+
+func (parser *parser) expect(punctuator string) *Error {
+	if !parser.peek(punctuator) {
+		return parser.lexer.syntaxError(parser.token.location, `expected "%s", found %s`, punctuator, parser.token)
+	}
+
+	return parser.advance()
+}
+
+func (parser *parser) name() (string, *Error) {
+	if parser.token.kind != nameToken {
+		return "", parser.lexer.syntaxError(parser.token.location, "expected a name, found %s", parser.token)
+	}
+
+	name := parser.token.value
+	return name, parser.advance()
+}
+
+func (parser *parser) document() (*document, *Error) {
+	document := &document{fragments: make(map[string]*fragmentDefinition)}
+
+	for parser.token.kind != endToken {
+		switch {
+		case parser.peek("{"):
+			operation, err := parser.operation()
+			if err != nil {
+				return nil, err
+			}
+			document.operations = append(document.operations, operation)
+
+		case parser.token.kind == nameToken && parser.token.value == "fragment":
+			fragment, err := parser.fragment()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := document.fragments[fragment.name]; ok {
+				return nil, &Error{
+					Message:   `There can be only one fragment named "` + fragment.name + `".`,
+					Locations: []Location{fragment.location},
+				}
+			}
+			document.fragments[fragment.name] = fragment
+
+		case parser.token.kind == nameToken &&
+			(parser.token.value == "query" || parser.token.value == "mutation" || parser.token.value == "subscription"):
+			operation, err := parser.operation()
+			if err != nil {
+				return nil, err
+			}
+			document.operations = append(document.operations, operation)
+
+		default:
+			return nil, parser.unexpected()
+		}
+	}
+
+	if len(document.operations) == 0 {
+		return nil, &Error{Message: "The query has no operations."}
+	}
+
+	return document, nil
+}
+
+func (parser *parser) operation() (*operationDefinition, *Error) {
+	operation := &operationDefinition{operation: "query", location: parser.token.location}
+
+	// a bare selection set is a query without a name
+	if !parser.peek("{") {
+		operation.operation = parser.token.value
+		if err := parser.advance(); err != nil {
+			return nil, err
+		}
+
+		if parser.token.kind == nameToken {
+			operation.name = parser.token.value
+			if err := parser.advance(); err != nil {
+				return nil, err
+			}
+		}
+
+		if parser.peek("(") {
+			variables, err := parser.variableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			operation.variables = variables
+		}
+
+		if parser.peek("@") {
+			return nil, parser.lexer.syntaxError(parser.token.location, "operations can't have directives")
+		}
+	}
+
+	selectionSet, err := parser.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.selectionSet = selectionSet
+
+	return operation, nil
+}
+
+func (parser *parser) variableDefinitions() ([]*variableDefinition, *Error) {
+	if err := parser.expect("("); err != nil {
+		return nil, err
+	}
+
+	definitions := make([]*variableDefinition, 0)
+	for !parser.peek(")") {
+		definition := &variableDefinition{location: parser.token.location}
+		if err := parser.expect("$"); err != nil {
+			return nil, err
+		}
+
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		definition.name = name
+
+		if err := parser.expect(":"); err != nil {
+			return nil, err
+		}
+
+		variableType, err := parser.typeReference()
+		if err != nil {
+			return nil, err
+		}
+		definition.variableType = variableType
+
+		if ok, err := parser.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			defaultValue, err := parser.value(true)
+			if err != nil {
+				return nil, err
+			}
+			definition.defaultValue = defaultValue
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, parser.expect(")")
+}
+
+func (parser *parser) typeReference() (*typeReference, *Error) {
+	reference := &typeReference{}
+
+	if ok, err := parser.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		element, err := parser.typeReference()
+		if err != nil {
+			return nil, err
+		}
+		reference.element = element
+
+		if err := parser.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		reference.name = name
+	}
+
+	nonNull, err := parser.skip("!")
+	if err != nil {
+		return nil, err
+	}
+	reference.nonNull = nonNull
+
+	return reference, nil
+}
+
+func (parser *parser) fragment() (*fragmentDefinition, *Error) {
+	fragment := &fragmentDefinition{location: parser.token.location}
+	if err := parser.advance(); err != nil {
+		return nil, err
+	}
+
+	name, err := parser.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, parser.lexer.syntaxError(fragment.location, `a fragment can't be named "on"`)
+	}
+	fragment.name = name
+
+	if parser.token.kind != nameToken || parser.token.value != "on" {
+		return nil, parser.lexer.syntaxError(parser.token.location, `expected "on", found %s`, parser.token)
+	}
+	if err := parser.advance(); err != nil {
+		return nil, err
+	}
+
+	typeCondition, err := parser.name()
+	if err != nil {
+		return nil, err
+	}
+	fragment.typeCondition = typeCondition
+
+	directives, err := parser.directives()
+	if err != nil {
+		return nil, err
+	}
+	fragment.directives = directives
+
+	selectionSet, err := parser.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	fragment.selectionSet = selectionSet
+
+	return fragment, nil
+}
+
+func (parser *parser) selectionSet() ([]selection, *Error) {
+	if err := parser.expect("{"); err != nil {
+		return nil, err
+	}
+
+	selections := make([]selection, 0)
+	for !parser.peek("}") {
+		selection, err := parser.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+
+	if len(selections) == 0 {
+		return nil, parser.lexer.syntaxError(parser.token.location, "a selection set can't be empty")
+	}
+
+	return selections, parser.expect("}")
+}
+
+func (parser *parser) selection() (selection, *Error) {
+	location := parser.token.location
+
+	if ok, err := parser.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		return parser.fragmentSelection(location)
+	}
+
+	field := &field{location: location}
+
+	name, err := parser.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := parser.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.alias = name
+		if name, err = parser.name(); err != nil {
+			return nil, err
+		}
+	}
+	field.name = name
+
+	if parser.peek("(") {
+		arguments, err := parser.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		field.arguments = arguments
+	}
+
+	directives, err := parser.directives()
+	if err != nil {
+		return nil, err
+	}
+	field.directives = directives
+
+	if parser.peek("{") {
+		selectionSet, err := parser.selectionSet()
+		if err != nil {
+			return nil, err
+		}
+		field.selectionSet = selectionSet
+	}
+
+	return field, nil
+}
+
+// fragmentSelection reads what follows a "...", which is either the name of a fragment or an inline fragment.
+func (parser *parser) fragmentSelection(location Location) (selection, *Error) {
+	if parser.token.kind == nameToken && parser.token.value != "on" {
+		spread := &fragmentSpread{name: parser.token.value, location: location}
+		if err := parser.advance(); err != nil {
+			return nil, err
+		}
+
+		directives, err := parser.directives()
+		if err != nil {
+			return nil, err
+		}
+		spread.directives = directives
+
+		return spread, nil
+	}
+
+	fragment := &inlineFragment{location: location}
+	if parser.token.kind == nameToken {
+		if err := parser.advance(); err != nil {
+			return nil, err
+		}
+
+		typeCondition, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		fragment.typeCondition = typeCondition
+	}
+
+	directives, err := parser.directives()
+	if err != nil {
+		return nil, err
+	}
+	fragment.directives = directives
+
+	selectionSet, err := parser.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	fragment.selectionSet = selectionSet
+
+	return fragment, nil
+}
+
+func (parser *parser) arguments(constant bool) ([]*argument, *Error) {
+	if err := parser.expect("("); err != nil {
+		return nil, err
+	}
+
+	arguments := make([]*argument, 0)
+	for !parser.peek(")") {
+		argument := &argument{location: parser.token.location}
+
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		argument.name = name
+
+		if err := parser.expect(":"); err != nil {
+			return nil, err
+		}
+
+		value, err := parser.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		argument.value = value
+
+		arguments = append(arguments, argument)
+	}
+
+	if len(arguments) == 0 {
+		return nil, parser.lexer.syntaxError(parser.token.location, "an argument list can't be empty")
+	}
+
+	return arguments, parser.expect(")")
+}
+
+func (parser *parser) directives() ([]*directive, *Error) {
+	directives := make([]*directive, 0)
+	for parser.peek("@") {
+		directive := &directive{location: parser.token.location}
+		if err := parser.advance(); err != nil {
+			return nil, err
+		}
+
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		directive.name = name
+
+		if parser.peek("(") {
+			arguments, err := parser.arguments(false)
+			if err != nil {
+				return nil, err
+			}
+			directive.arguments = arguments
+		}
+
+		directives = append(directives, directive)
+	}
+
+	return directives, nil
+}
+
+// value reads a value, which can't refer to variables where it's constant, as in their defaults.
+func (parser *parser) value(constant bool) (*value, *Error) {
+	token := parser.token
+	result := &value{raw: token.value, location: token.location}
+
+	switch token.kind {
+	case intToken:
+		result.kind = intValue
+	case floatToken:
+		result.kind = floatValue
+	case stringToken:
+		result.kind = stringValue
+
+	case nameToken:
+		switch token.value {
+		case "true", "false":
+			result.kind = booleanValue
+		case "null":
+			result.kind = nullValue
+		default:
+			result.kind = enumValue
+		}
+
+	case punctuatorToken:
+		switch token.value {
+		case "$":
+			if constant {
+				return nil, parser.lexer.syntaxError(token.location, "a default value can't refer to a variable")
+			}
+			if err := parser.advance(); err != nil {
+				return nil, err
+			}
+
+			name, err := parser.name()
+			if err != nil {
+				return nil, err
+			}
+			result.kind = variableValue
+			result.raw = name
+			return result, nil
+
+		case "[":
+			if err := parser.advance(); err != nil {
+				return nil, err
+			}
+
+			result.kind = listValue
+			result.list = make([]*value, 0)
+			for !parser.peek("]") {
+				item, err := parser.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				result.list = append(result.list, item)
+			}
+			return result, parser.expect("]")
+
+		case "{":
+			if err := parser.advance(); err != nil {
+				return nil, err
+			}
+
+			result.kind = objectValue
+			for !parser.peek("}") {
+				name, err := parser.name()
+				if err != nil {
+					return nil, err
+				}
+
+				if err := parser.expect(":"); err != nil {
+					return nil, err
+				}
+
+				fieldValue, err := parser.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				result.fields = append(result.fields, &objectField{name: name, value: fieldValue})
+			}
+			return result, parser.expect("}")
+
+		default:
+			return nil, parser.unexpected()
+		}
+
+	default:
+		return nil, parser.unexpected()
+	}
+
+	return result, parser.advance()
+}
@@ -0,0 +1,446 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a *Scalar, *Object, *List or *NonNull.
+type Type interface {
+	// String is the type as it's written in queries, such as [Note!]!.
+	String() string
+}
+
+// Scalar is a leaf type. Serialize turns what resolvers return into a JSON value, and ParseValue turns a JSON
+// value, or a literal in the query read as one, into what resolvers are given. Numbers reach ParseValue as
+// json.Number.
+type Scalar struct {
+	Name        string
+	Description string
+	Serialize   func(interface{}) (interface{}, error)
+	ParseValue  func(interface{}) (interface{}, error)
+}
+
+// Object is a type with fields to select.
+type Object struct {
+	Name        string
+	Description string
+	Fields      Fields
+}
+
+type List struct {
+	OfType Type
+}
+
+type NonNull struct {
+	OfType Type
+}
+
+func (scalar *Scalar) String() string   { return scalar.Name }
+func (object *Object) String() string   { return object.Name }
+func (list *List) String() string       { return "[" + list.OfType.String() + "]" }
+func (nonNull *NonNull) String() string { return nonNull.OfType.String() + "!" }
+
+type Fields map[string]*Field
+
+type Field struct {
+	Type        Type
+	Description string
+	Args        map[string]*Argument
+	// Resolve returns the field's value, or a Thunk for it, given the object it's on as the source. Without a
+	// resolver, the field is looked up in sources that are a map[string]interface{}.
+	Resolve ResolveFunc
+	// Visible, where set, says whether the current user may see the field, which is null for them otherwise.
+	// Fields that can be hidden must be nullable.
+	Visible func(ResolveParams) bool
+}
+
+// Argument is an argument of a field, which can only be of a scalar type or a list of them.
+type Argument struct {
+	Type        Type
+	Default     interface{}
+	Description string
+}
+
+type ResolveParams struct {
+	Source  interface{}
+	Args    map[string]interface{}
+	Context context.Context
+}
+
+type ResolveFunc func(ResolveParams) (interface{}, error)
+
+// Thunk is a value that's yet to be worked out, returned by resolvers that can do better working out many at
+// once. It may return another Thunk.
+type Thunk func() (interface{}, error)
+
+// Schema is the types that can be queried, starting from Query, and changed, starting from Mutation.
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+	// FormatError, where set, gives the message and extensions of the errors resolvers return.
+	FormatError func(error) (string, map[string]interface{})
+	// MaxDepth, where set, is how deeply fields with subfields may be nested. Fields that refer to each other
+	// would otherwise let a short query ask for a response that grows with every level.
+	MaxDepth int
+
+	types map[string]Type
+}
+
+// NewSchema checks the types reachable from the query and mutation types, of which mutation may be nil.
+func NewSchema(query *Object, mutation *Object) (*Schema, error) {
+	schema := &Schema{Query: query, Mutation: mutation, types: make(map[string]Type)}
+
+	for _, scalar := range []*Scalar{Int, Float, String, Boolean, ID} {
+		schema.types[scalar.Name] = scalar
+	}
+
+	if query == nil {
+		return nil, fmt.Errorf("a schema must have a query type")
+	}
+
+	if err := schema.collect(query); err != nil {
+		return nil, err
+	}
+
+	if mutation != nil {
+		if err := schema.collect(mutation); err != nil {
+			return nil, err
+		}
+	}
+
+	return schema, nil
+}
+
+func (schema *Schema) collect(fieldType Type) error {
+	namedType := unwrapType(fieldType)
+
+	name := namedType.String()
+	if existing, ok := schema.types[name]; ok {
+		if existing != namedType {
+			return fmt.Errorf("there are two types named %s", name)
+		}
+		return nil
+	}
+	schema.types[name] = namedType
+
+	object, ok := namedType.(*Object)
+	if !ok {
+		return nil
+	}
+
+	if len(object.Fields) == 0 {
+		return fmt.Errorf("%s has no fields", name)
+	}
+
+	for fieldName, field := range object.Fields {
+		if field.Type == nil {
+			return fmt.Errorf("%s.%s has no type", name, fieldName)
+		}
+
+		if _, ok := field.Type.(*NonNull); ok && field.Visible != nil {
+			return fmt.Errorf("%s.%s can be hidden, so it must be nullable", name, fieldName)
+		}
+
+		for argumentName, argument := range field.Args {
+			if argument.Type == nil || !isInputType(argument.Type) {
+				return fmt.Errorf("%s.%s(%s) must be a scalar or a list of them", name, fieldName, argumentName)
+			}
+
+			if err := schema.collect(argument.Type); err != nil {
+				return err
+			}
+		}
+
+		if err := schema.collect(field.Type); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Type returns the named type, if the schema has it.
+func (schema *Schema) Type(name string) (Type, bool) {
+	namedType, ok := schema.types[name]
+	return namedType, ok
+}
+
+// String prints the schema in the schema definition language, with the query and mutation types first and the
+// rest in alphabetical order.
+func (schema *Schema) String() string {
+	names := make([]string, 0, len(schema.types))
+	for name, namedType := range schema.types {
+		if scalar, ok := namedType.(*Scalar); ok && isBuiltInScalar(scalar) {
+			continue
+		}
+
+		if namedType == schema.Query || (schema.Mutation != nil && namedType == schema.Mutation) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := []Type{schema.Query}
+	if schema.Mutation != nil {
+		ordered = append(ordered, schema.Mutation)
+	}
+	for _, name := range names {
+		ordered = append(ordered, schema.types[name])
+	}
+
+	text := new(strings.Builder)
+	if schema.Query.Name != "Query" || (schema.Mutation != nil && schema.Mutation.Name != "Mutation") {
+		text.WriteString("schema {\n  query: " + schema.Query.Name + "\n")
+		if schema.Mutation != nil {
+			text.WriteString("  mutation: " + schema.Mutation.Name + "\n")
+		}
+		text.WriteString("}\n\n")
+	}
+
+	for i, namedType := range ordered {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+
+		switch namedType := namedType.(type) {
+		case *Scalar:
+			writeDescription(text, "", namedType.Description)
+			text.WriteString("scalar " + namedType.Name + "\n")
+
+		case *Object:
+			writeDescription(text, "", namedType.Description)
+			text.WriteString("type " + namedType.Name + " {\n")
+			for _, fieldName := range sortedKeys(namedType.Fields) {
+				field := namedType.Fields[fieldName]
+				writeDescription(text, "  ", field.Description)
+				text.WriteString("  " + fieldName + printArguments(field.Args) + ": " + field.Type.String() + "\n")
+			}
+			text.WriteString("}\n")
+		}
+	}
+
+	return text.String()
+}
+
+func printArguments(arguments map[string]*Argument) string {
+	if len(arguments) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(arguments))
+	for name := range arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	printed := make([]string, len(names))
+	for i, name := range names {
+		argument := arguments[name]
+		printed[i] = name + ": " + argument.Type.String()
+		if argument.Default != nil {
+			defaultJson, _ := json.Marshal(argument.Default)
+			printed[i] += " = " + string(defaultJson)
+		}
+	}
+
+	return "(" + strings.Join(printed, ", ") + ")"
+}
+
+func writeDescription(text *strings.Builder, indent string, description string) {
+	if len(description) == 0 {
+		return
+	}
+
+	if strings.Contains(description, "\n") {
+		text.WriteString(indent + `"""` + "\n")
+		for _, line := range strings.Split(description, "\n") {
+			text.WriteString(indent + line + "\n")
+		}
+		text.WriteString(indent + `"""` + "\n")
+		return
+	}
+
+	text.WriteString(indent + strconv.Quote(description) + "\n")
+}
+
+func sortedKeys(fields Fields) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// unwrapType returns the named type inside any lists and non-nulls.
+func unwrapType(fieldType Type) Type {
+	for {
+		switch wrapper := fieldType.(type) {
+		case *List:
+			fieldType = wrapper.OfType
+		case *NonNull:
+			fieldType = wrapper.OfType
+		default:
+			return fieldType
+		}
+	}
+}
+
+func isInputType(fieldType Type) bool {
+	_, ok := unwrapType(fieldType).(*Scalar)
+	return ok
+}
+
+func isBuiltInScalar(scalar *Scalar) bool {
+	return scalar == Int || scalar == Float || scalar == String || scalar == Boolean || scalar == ID
+}
+
+// Built-in scalars
+
+var Int = &Scalar{
+	Name: "Int",
+	Serialize: func(value interface{}) (interface{}, error) {
+		number, ok := integerOf(value)
+		if !ok || number < math.MinInt32 || number > math.MaxInt32 {
+			return nil, fmt.Errorf("Int cannot represent %v", value)
+		}
+		return number, nil
+	},
+	ParseValue: func(value interface{}) (interface{}, error) {
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("Int cannot represent %s", describeInput(value))
+		}
+
+		integer, err := strconv.ParseInt(string(number), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Int cannot represent %s", number)
+		}
+		return int(integer), nil
+	},
+}
+
+var Float = &Scalar{
+	Name: "Float",
+	Serialize: func(value interface{}) (interface{}, error) {
+		reflected := reflect.ValueOf(value)
+		switch reflected.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return reflected.Float(), nil
+		}
+
+		if number, ok := integerOf(value); ok {
+			return float64(number), nil
+		}
+		return nil, fmt.Errorf("Float cannot represent %v", value)
+	},
+	ParseValue: func(value interface{}) (interface{}, error) {
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("Float cannot represent %s", describeInput(value))
+		}
+		return number.Float64()
+	},
+}
+
+var String = &Scalar{
+	Name: "String",
+	Serialize: func(value interface{}) (interface{}, error) {
+		switch value := value.(type) {
+		case string:
+			return value, nil
+		case fmt.Stringer:
+			return value.String(), nil
+		}
+
+		reflected := reflect.ValueOf(value)
+		if reflected.Kind() == reflect.String {
+			return reflected.String(), nil
+		}
+		return nil, fmt.Errorf("String cannot represent %v", value)
+	},
+	ParseValue: func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("String cannot represent %s", describeInput(value))
+		}
+		return text, nil
+	},
+}
+
+var Boolean = &Scalar{
+	Name: "Boolean",
+	Serialize: func(value interface{}) (interface{}, error) {
+		reflected := reflect.ValueOf(value)
+		if reflected.Kind() != reflect.Bool {
+			return nil, fmt.Errorf("Boolean cannot represent %v", value)
+		}
+		return reflected.Bool(), nil
+	},
+	ParseValue: func(value interface{}) (interface{}, error) {
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("Boolean cannot represent %s", describeInput(value))
+		}
+		return boolean, nil
+	},
+}
+
+// ID is written as a string, and can be given as a string or an integer. Resolvers are given it as a string.
+var ID = &Scalar{
+	Name: "ID",
+	Serialize: func(value interface{}) (interface{}, error) {
+		if number, ok := integerOf(value); ok {
+			return strconv.FormatInt(number, 10), nil
+		}
+
+		reflected := reflect.ValueOf(value)
+		if reflected.Kind() == reflect.String {
+			return reflected.String(), nil
+		}
+		return nil, fmt.Errorf("ID cannot represent %v", value)
+	},
+	ParseValue: func(value interface{}) (interface{}, error) {
+		switch value := value.(type) {
+		case string:
+			return value, nil
+		case json.Number:
+			if _, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+				return string(value), nil
+			}
+		}
+		return nil, fmt.Errorf("ID cannot represent %s", describeInput(value))
+	},
+}
+
+// integerOf returns the value as an int64, if it's of an integer kind.
+func integerOf(value interface{}) (int64, bool) {
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflected.Int(), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return int64(reflected.Uint()), true
+	}
+
+	return 0, false
+}
+
+func describeInput(value interface{}) string {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(valueJson)
+}
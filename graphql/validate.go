@@ -0,0 +1,383 @@
+package graphql
+
+import (
+	"fmt"
+)
+
+// validator checks an operation against the schema before any of it is executed, so that requests that can't
+// be carried out as written fail as a whole.
+type validator struct {
+	schema    *Schema
+	document  *document
+	variables map[string]*variableDefinition
+	// variableTypes are the types of the operation's variables, where they're known
+	variableTypes map[string]Type
+	usedVariables map[string]bool
+	// fragmentPath is the fragments being validated, to find those that spread themselves
+	fragmentPath map[string]bool
+	// depth is how many fields with subfields enclose the selection being validated
+	depth  int
+	errors []*Error
+}
+
+func (validator *validator) report(location Location, format string, arguments ...interface{}) {
+	validator.errors = append(validator.errors, &Error{
+		Message:   fmt.Sprintf(format, arguments...),
+		Locations: []Location{location},
+	})
+}
+
+func (schema *Schema) validate(document *document, operation *operationDefinition) []*Error {
+	validator := &validator{
+		schema:        schema,
+		document:      document,
+		variables:     make(map[string]*variableDefinition),
+		variableTypes: make(map[string]Type),
+		usedVariables: make(map[string]bool),
+		fragmentPath:  make(map[string]bool),
+	}
+
+	for _, definition := range operation.variables {
+		if _, ok := validator.variables[definition.name]; ok {
+			validator.report(definition.location, `There can be only one variable named "$%s".`, definition.name)
+			continue
+		}
+		validator.variables[definition.name] = definition
+
+		variableType, ok := schema.inputType(definition.variableType)
+		if !ok {
+			validator.report(
+				definition.location,
+				`Variable "$%s" cannot be of type "%s", which isn't a scalar or a list of them in this schema.`,
+				definition.name,
+				definition.variableType)
+			continue
+		}
+
+		// a variable with a default can be used where a value is required
+		if definition.defaultValue != nil && definition.defaultValue.kind != nullValue {
+			if _, ok := variableType.(*NonNull); !ok {
+				variableType = &NonNull{OfType: variableType}
+			}
+		}
+		validator.variableTypes[definition.name] = variableType
+	}
+
+	rootType := schema.Query
+	if operation.operation == "mutation" {
+		rootType = schema.Mutation
+	}
+
+	if rootType == nil || operation.operation == "subscription" {
+		validator.report(operation.location, "The schema doesn't support %s operations.", operation.operation)
+		return validator.errors
+	}
+
+	validator.selectionSet(rootType, operation.selectionSet)
+
+	for _, definition := range operation.variables {
+		if !validator.usedVariables[definition.name] {
+			validator.report(definition.location, `Variable "$%s" is never used.`, definition.name)
+		}
+	}
+
+	return validator.errors
+}
+
+func (validator *validator) selectionSet(objectType *Object, selections []selection) {
+	validator.mergeableFields(objectType, selections)
+
+	for _, selection := range selections {
+		validator.directives(selection.selectionDirectives())
+
+		switch selection := selection.(type) {
+		case *field:
+			validator.field(objectType, selection)
+
+		case *inlineFragment:
+			if len(selection.typeCondition) > 0 && selection.typeCondition != objectType.Name {
+				validator.report(
+					selection.location,
+					`Fragment cannot be spread here as objects of type "%s" can never be of type "%s".`,
+					objectType.Name,
+					selection.typeCondition)
+				continue
+			}
+			validator.selectionSet(objectType, selection.selectionSet)
+
+		case *fragmentSpread:
+			fragment, ok := validator.document.fragments[selection.name]
+			if !ok {
+				validator.report(selection.location, `Unknown fragment "%s".`, selection.name)
+				continue
+			}
+
+			if validator.fragmentPath[fragment.name] {
+				validator.report(selection.location, `Cannot spread fragment "%s" within itself.`, fragment.name)
+				continue
+			}
+
+			if fragment.typeCondition != objectType.Name {
+				validator.report(
+					selection.location,
+					`Fragment "%s" cannot be spread here as objects of type "%s" can never be of type "%s".`,
+					fragment.name,
+					objectType.Name,
+					fragment.typeCondition)
+				continue
+			}
+
+			validator.fragmentPath[fragment.name] = true
+			validator.directives(fragment.directives)
+			validator.selectionSet(objectType, fragment.selectionSet)
+			delete(validator.fragmentPath, fragment.name)
+		}
+	}
+}
+
+func (validator *validator) field(objectType *Object, selection *field) {
+	if selection.name == "__typename" {
+		if len(selection.arguments) > 0 || selection.selectionSet != nil {
+			validator.report(selection.location, `Field "__typename" takes no arguments or subfields.`)
+		}
+		return
+	}
+
+	definition, ok := objectType.Fields[selection.name]
+	if !ok {
+		validator.report(selection.location, `Cannot query field "%s" on type "%s".`, selection.name, objectType.Name)
+		return
+	}
+
+	validator.arguments(
+		fmt.Sprintf(`field "%s.%s"`, objectType.Name, selection.name),
+		definition.Args,
+		selection.arguments,
+		selection.location)
+
+	switch namedType := unwrapType(definition.Type).(type) {
+	case *Scalar:
+		if selection.selectionSet != nil {
+			validator.report(
+				selection.location,
+				`Field "%s" must not have a selection since type "%s" has no subfields.`,
+				selection.name,
+				definition.Type)
+		}
+
+	case *Object:
+		if selection.selectionSet == nil {
+			validator.report(
+				selection.location,
+				`Field "%s" of type "%s" must have a selection of subfields.`,
+				selection.name,
+				definition.Type)
+			return
+		}
+
+		if validator.schema.MaxDepth > 0 && validator.depth >= validator.schema.MaxDepth {
+			validator.report(
+				selection.location,
+				`Field "%s" is nested more than %d levels deep.`,
+				selection.name,
+				validator.schema.MaxDepth)
+			return
+		}
+
+		validator.depth++
+		validator.selectionSet(namedType, selection.selectionSet)
+		validator.depth--
+	}
+}
+
+func (validator *validator) arguments(
+	owner string,
+	definitions map[string]*Argument,
+	arguments []*argument,
+	location Location,
+) {
+	given := make(map[string]bool)
+	for _, argument := range arguments {
+		if given[argument.name] {
+			validator.report(argument.location, `There can be only one argument named "%s".`, argument.name)
+			continue
+		}
+		given[argument.name] = true
+
+		definition, ok := definitions[argument.name]
+		if !ok {
+			validator.report(argument.location, `Unknown argument "%s" on %s.`, argument.name, owner)
+			continue
+		}
+
+		validator.value(argument.value, definition.Type, fmt.Sprintf(`Argument "%s"`, argument.name))
+	}
+
+	for name, definition := range definitions {
+		if _, ok := definition.Type.(*NonNull); ok && definition.Default == nil && !given[name] {
+			validator.report(
+				location,
+				`Argument "%s" of type "%s" is required, but it was not provided.`,
+				name,
+				definition.Type)
+		}
+	}
+}
+
+// value checks that a value in the query can be of the expected type. Where it uses variables, they must be
+// of a type that can be used there.
+func (validator *validator) value(literal *value, expectedType Type, owner string) {
+	if literal.kind == variableValue {
+		validator.usedVariables[literal.raw] = true
+
+		if _, ok := validator.variables[literal.raw]; !ok {
+			validator.report(literal.location, `Variable "$%s" is not defined.`, literal.raw)
+			return
+		}
+
+		variableType, ok := validator.variableTypes[literal.raw]
+		if ok && !isTypeSubtype(variableType, expectedType) {
+			validator.report(
+				literal.location,
+				`Variable "$%s" of type "%s" used in position expecting type "%s".`,
+				literal.raw,
+				variableType,
+				expectedType)
+		}
+		return
+	}
+
+	if literal.kind == listValue {
+		itemType := expectedType
+		if nonNull, ok := itemType.(*NonNull); ok {
+			itemType = nonNull.OfType
+		}
+
+		if list, ok := itemType.(*List); ok {
+			for _, item := range literal.list {
+				validator.value(item, list.OfType, owner)
+			}
+			return
+		}
+	}
+
+	input, _, err := literalInput(literal, nil)
+	if err == nil {
+		_, err = coerceInput(input, expectedType)
+	}
+
+	if err != nil {
+		validator.report(literal.location, "%s has an invalid value: %s.", owner, err)
+	}
+}
+
+func (validator *validator) directives(directives []*directive) {
+	for _, directive := range directives {
+		if directive.name != "skip" && directive.name != "include" {
+			validator.report(directive.location, `Unknown directive "@%s".`, directive.name)
+			continue
+		}
+
+		validator.arguments(
+			fmt.Sprintf(`directive "@%s"`, directive.name),
+			map[string]*Argument{"if": {Type: &NonNull{OfType: Boolean}}},
+			directive.arguments,
+			directive.location)
+	}
+}
+
+// mergeableFields checks that fields with the same response key in a selection set, including those spread
+// into it, select the same field with the same arguments, so that they can be merged.
+func (validator *validator) mergeableFields(objectType *Object, selections []selection) {
+	fieldsByKey := make(map[string]*field)
+	for _, selected := range validator.flattenFields(selections, make(map[string]bool)) {
+		other, ok := fieldsByKey[selected.responseKey()]
+		if !ok {
+			fieldsByKey[selected.responseKey()] = selected
+			continue
+		}
+
+		if other.name != selected.name {
+			validator.report(
+				selected.location,
+				`Fields "%s" conflict because %s and %s are different fields.`,
+				selected.responseKey(),
+				other.name,
+				selected.name)
+			continue
+		}
+
+		if !sameArguments(other.arguments, selected.arguments) {
+			validator.report(
+				selected.location,
+				`Fields "%s" conflict because they have differing arguments.`,
+				selected.responseKey())
+		}
+	}
+}
+
+func (validator *validator) flattenFields(selections []selection, visited map[string]bool) []*field {
+	fields := make([]*field, 0, len(selections))
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *field:
+			fields = append(fields, selection)
+
+		case *inlineFragment:
+			fields = append(fields, validator.flattenFields(selection.selectionSet, visited)...)
+
+		case *fragmentSpread:
+			fragment, ok := validator.document.fragments[selection.name]
+			if !ok || visited[selection.name] {
+				continue
+			}
+			visited[selection.name] = true
+			fields = append(fields, validator.flattenFields(fragment.selectionSet, visited)...)
+		}
+	}
+
+	return fields
+}
+
+func sameArguments(arguments []*argument, others []*argument) bool {
+	if len(arguments) != len(others) {
+		return false
+	}
+
+	for _, argument := range arguments {
+		found := false
+		for _, other := range others {
+			if other.name == argument.name && sameValue(argument.value, other.value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameValue(literal *value, other *value) bool {
+	if literal.kind != other.kind || literal.raw != other.raw || len(literal.list) != len(other.list) ||
+		len(literal.fields) != len(other.fields) {
+		return false
+	}
+
+	for i := range literal.list {
+		if !sameValue(literal.list[i], other.list[i]) {
+			return false
+		}
+	}
+
+	for i := range literal.fields {
+		if literal.fields[i].name != other.fields[i].name || !sameValue(literal.fields[i].value, other.fields[i].value) {
+			return false
+		}
+	}
+
+	return true
+}
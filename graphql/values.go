@@ -0,0 +1,164 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// inputType finds the type a variable is declared as, which must be a scalar or a list of them.
+func (schema *Schema) inputType(reference *typeReference) (Type, bool) {
+	var inputType Type
+	if reference.element != nil {
+		elementType, ok := schema.inputType(reference.element)
+		if !ok {
+			return nil, false
+		}
+		inputType = &List{OfType: elementType}
+	} else {
+		namedType, ok := schema.types[reference.name]
+		if !ok {
+			return nil, false
+		}
+		if _, ok := namedType.(*Scalar); !ok {
+			return nil, false
+		}
+		inputType = namedType
+	}
+
+	if reference.nonNull {
+		inputType = &NonNull{OfType: inputType}
+	}
+
+	return inputType, true
+}
+
+// literalInput turns a value in the query into the JSON value it stands for, taking variables from those
+// given. It says whether the value was provided, which a variable that wasn't given isn't.
+func literalInput(literal *value, variables map[string]interface{}) (interface{}, bool, error) {
+	switch literal.kind {
+	case variableValue:
+		input, ok := variables[literal.raw]
+		return input, ok, nil
+
+	case intValue, floatValue:
+		return json.Number(literal.raw), true, nil
+
+	case stringValue:
+		return literal.raw, true, nil
+
+	case booleanValue:
+		return literal.raw == "true", true, nil
+
+	case nullValue:
+		return nil, true, nil
+
+	case listValue:
+		list := make([]interface{}, len(literal.list))
+		for i, item := range literal.list {
+			// an item that's a missing variable is null
+			input, _, err := literalInput(item, variables)
+			if err != nil {
+				return nil, false, err
+			}
+			list[i] = input
+		}
+		return list, true, nil
+
+	case objectValue:
+		object := make(map[string]interface{}, len(literal.fields))
+		for _, field := range literal.fields {
+			input, _, err := literalInput(field.value, variables)
+			if err != nil {
+				return nil, false, err
+			}
+			object[field.name] = input
+		}
+		return object, true, nil
+
+	default:
+		return nil, false, fmt.Errorf("%s is not a value of any type in this schema", literal.raw)
+	}
+}
+
+// coerceInput turns a JSON value into what resolvers are given for the type.
+func coerceInput(input interface{}, inputType Type) (interface{}, error) {
+	if nonNull, ok := inputType.(*NonNull); ok {
+		if input == nil {
+			return nil, fmt.Errorf("expected a non-null value of type %s", inputType)
+		}
+		return coerceInput(input, nonNull.OfType)
+	}
+
+	if input == nil {
+		return nil, nil
+	}
+
+	switch inputType := inputType.(type) {
+	case *List:
+		// a single value stands for a list of one
+		items, ok := input.([]interface{})
+		if !ok {
+			item, err := coerceInput(input, inputType.OfType)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(item, inputType.OfType)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+
+	case *Scalar:
+		return inputType.ParseValue(normalizeNumber(input))
+
+	default:
+		return nil, fmt.Errorf("%s is not an input type", inputType)
+	}
+}
+
+// normalizeNumber turns numbers decoded without json.Decoder.UseNumber, or given from Go, into json.Number.
+func normalizeNumber(input interface{}) interface{} {
+	if number, ok := integerOf(input); ok {
+		return json.Number(strconv.FormatInt(number, 10))
+	}
+
+	reflected := reflect.ValueOf(input)
+	switch reflected.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return json.Number(strconv.FormatFloat(reflected.Float(), 'g', -1, 64))
+	}
+
+	return input
+}
+
+// isTypeSubtype says whether a variable of the given type can be used where the other type is expected.
+func isTypeSubtype(variableType Type, expectedType Type) bool {
+	if expectedNonNull, ok := expectedType.(*NonNull); ok {
+		variableNonNull, ok := variableType.(*NonNull)
+		return ok && isTypeSubtype(variableNonNull.OfType, expectedNonNull.OfType)
+	}
+
+	if variableNonNull, ok := variableType.(*NonNull); ok {
+		return isTypeSubtype(variableNonNull.OfType, expectedType)
+	}
+
+	if expectedList, ok := expectedType.(*List); ok {
+		variableList, ok := variableType.(*List)
+		return ok && isTypeSubtype(variableList.OfType, expectedList.OfType)
+	}
+
+	if _, ok := variableType.(*List); ok {
+		return false
+	}
+
+	return variableType == expectedType
+}
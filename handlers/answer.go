@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var NotAQuestionError = errors.New("Only question notes can be answered")
+
+// HandleAnswerApiRequest responds to GET requests with the answers to the question given by questionId
+// that the user may read, and to POST requests by answering it with a new note. Only readers of a
+// published question can answer it, and answers are published with the rest of the answerer's notes.
+func HandleAnswerApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type AnswerForm struct {
+		Content string `json:"content"`
+	}
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("questionId"), 10, 64)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	questionId := models.NoteId(id)
+
+	switch request.Method {
+	case http.MethodGet:
+		question, err, errCode := getQuestion(env, questionId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if question.AuthorId != userId {
+			if err, errCode := checkPublishedNoteVisibleTo(env, questionId, userId); err != nil {
+				return err, errCode
+			}
+		}
+
+		answers, err := env.Db.GetAnswersVisibleBy(questionId, userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, answers)
+
+	case http.MethodPost:
+		answerForm := new(AnswerForm)
+		if err := json.NewDecoder(request.Body).Decode(answerForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		content := strings.TrimSpace(answerForm.Content)
+		if len(content) == 0 {
+			return EmptyNoteContentError, http.StatusBadRequest
+		}
+
+		if err, errCode := checkPublishedNoteVisibleTo(env, questionId, userId); err != nil {
+			return err, errCode
+		}
+
+		if _, err, errCode := getQuestion(env, questionId); err != nil {
+			return err, errCode
+		}
+
+		note := &models.Note{
+			AuthorId:     userId,
+			Content:      content,
+			CreationTime: time.Now().UTC(),
+		}
+
+		answerId, err := env.Db.StoreNewAnswer(questionId, note)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		answer := &models.Answer{
+			NoteId:       answerId,
+			QuestionId:   questionId,
+			AuthorId:     note.AuthorId,
+			Content:      note.Content,
+			CreationTime: note.CreationTime,
+		}
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_CREATED,
+			AuthorId: userId,
+			NoteId:   answerId,
+			Data:     answer,
+		})
+
+		return respondWithJson(responseWriter, http.StatusCreated, answer)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleAcceptedAnswerApiRequest responds to PUT requests by marking an answer the asker can read
+// as the accepted answer to their question given by questionId, and to DELETE requests by unmarking it.
+func HandleAcceptedAnswerApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type AcceptedAnswerForm struct {
+		AnswerId models.NoteId `json:"answerId"`
+	}
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("questionId"), 10, 64)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	questionId := models.NoteId(id)
+
+	switch request.Method {
+	case http.MethodPut:
+		acceptedAnswerForm := new(AcceptedAnswerForm)
+		if err := json.NewDecoder(request.Body).Decode(acceptedAnswerForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		question, err, errCode := getQuestion(env, questionId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if question.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		// askers can only accept answers that have been published to them
+		visible, err := env.Db.IsPublishedNoteVisibleTo(acceptedAnswerForm.AnswerId, userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		if !visible {
+			return models.NoAnswerFoundError, http.StatusNotFound
+		}
+
+		if err := env.Db.AcceptAnswer(questionId, acceptedAnswerForm.AnswerId); err != nil {
+			if err == models.NoAnswerFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	case http.MethodDelete:
+		question, err, errCode := getQuestion(env, questionId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if question.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		if err := env.Db.ClearAcceptedAnswer(questionId); err != nil {
+			if err == models.NoAnswerFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// getQuestion returns the note, as long as it is categorized as a question.
+func getQuestion(env *Environment, questionId models.NoteId) (*models.Note, error, int) {
+	question, err := env.Db.GetNoteById(questionId)
+	if err != nil {
+		if err == models.NoNoteFoundError {
+			return nil, err, http.StatusNotFound
+		}
+		return nil, err, http.StatusInternalServerError
+	}
+
+	category, err := env.Db.GetNoteCategory(questionId)
+	if err != nil {
+		if err == models.QueryResultContainedNoRowsError {
+			return nil, NotAQuestionError, http.StatusBadRequest
+		}
+		return nil, err, http.StatusInternalServerError
+	}
+
+	if category != models.QUESTION {
+		return nil, NotAQuestionError, http.StatusBadRequest
+	}
+
+	return question, nil, 0
+}
+
+// checkPublishedNoteVisibleTo hides notes the user may not read yet as if they didn't exist.
+func checkPublishedNoteVisibleTo(env *Environment, noteId models.NoteId, userId models.UserId) (error, int) {
+	visible, err := env.Db.IsPublishedNoteVisibleTo(noteId, userId)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	if !visible {
+		return models.NoNoteFoundError, http.StatusNotFound
+	}
+
+	return nil, 0
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/paths"
+	"github.com/atmiguel/cerealnotes/webhooks"
+)
+
+var NoApiRouteError = errors.New("No api endpoint has that path")
+
+// ApiError is the body of every error response under /api/v1, wrapped as {"error": ...}. Code is stable and
+// meant for programs; Message is meant for people and may change.
+type ApiError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// ApiErrorEnvelope is the body of every error response under /api/v1.
+type ApiErrorEnvelope struct {
+	Error *ApiError `json:"error"`
+}
+
+type errorMapping struct {
+	status int
+	code   string
+}
+
+// errorMappings gives each sentinel error its status and code, whichever handler returns it. Errors that aren't
+// listed keep the status the handler returned them with.
+var errorMappings = map[error]errorMapping{
+	// handlers
+	EmptyNoteContentError:          {http.StatusBadRequest, "empty_note_content"},
+	NotYourNoteError:               {http.StatusForbidden, "not_your_note"},
+	NoChangeError:                  {http.StatusBadRequest, "no_change"},
+	InvalidMethodError:             {http.StatusMethodNotAllowed, "method_not_allowed"},
+	NoApiRouteError:                {http.StatusNotFound, "route_not_found"},
+	InvalidJWTokenError:            {http.StatusUnauthorized, "invalid_token"},
+	EmptyReplyContentError:         {http.StatusBadRequest, "empty_reply_content"},
+	NotYourReplyError:              {http.StatusForbidden, "not_your_reply"},
+	NotYourCategoryError:           {http.StatusForbidden, "not_your_category"},
+	MissingSourceError:             {http.StatusBadRequest, "missing_source"},
+	NotAQuestionError:              {http.StatusBadRequest, "not_a_question"},
+	MissingNoteStateError:          {http.StatusBadRequest, "missing_note_state"},
+	UnknownReactionError:           {http.StatusBadRequest, "unknown_reaction"},
+	NotPredictionJudgeError:        {http.StatusForbidden, "not_prediction_judge"},
+	MissingResolutionDateError:     {http.StatusBadRequest, "missing_resolution_date"},
+	UnknownJudgeError:              {http.StatusBadRequest, "unknown_judge"},
+	AttachmentTooLargeError:        {http.StatusRequestEntityTooLarge, "attachment_too_large"},
+	ImportTooLargeError:            {http.StatusRequestEntityTooLarge, "import_too_large"},
+	GraphQlRequestTooLargeError:    {http.StatusRequestEntityTooLarge, "graphql_request_too_large"},
+	AttachmentTypeNotAllowedError:  {http.StatusUnsupportedMediaType, "attachment_type_not_allowed"},
+	MissingAttachmentError:         {http.StatusBadRequest, "missing_attachment"},
+	CannotCollectOwnNoteError:      {http.StatusBadRequest, "cannot_collect_own_note"},
+	InvalidWebhookUrlError:         {http.StatusBadRequest, "invalid_webhook_url"},
+	NoWebhookEventTypesError:       {http.StatusBadRequest, "no_webhook_event_types"},
+	webhooks.ForbiddenAddressError: {http.StatusBadRequest, "forbidden_webhook_address"},
+	StreamingUnsupportedError:      {http.StatusInternalServerError, "streaming_unsupported"},
+	EventsUnavailableError:         {http.StatusServiceUnavailable, "events_unavailable"},
+	InvalidIdError:                 {http.StatusBadRequest, "invalid_id"},
+	InvalidFeedAuthorError:         {http.StatusBadRequest, "invalid_feed_author"},
+	PreconditionFailedError:        {http.StatusPreconditionFailed, "precondition_failed"},
+
+	// users
+	models.EmailAddressAlreadyInUseError: {http.StatusConflict, "email_address_in_use"},
+	models.CredentialsNotAuthorizedError: {http.StatusUnauthorized, "invalid_credentials"},
+
+	// notes
+	models.NoNoteFoundError:                         {http.StatusNotFound, "note_not_found"},
+	models.PinnedAndArchivedError:                   {http.StatusBadRequest, "pinned_and_archived"},
+	models.NoteRevisionChangedError:                 {http.StatusConflict, "note_changed"},
+	models.NoNoteOperationsError:                    {http.StatusBadRequest, "no_note_operations"},
+	models.TooManyNoteOperationsError:               {http.StatusBadRequest, "too_many_note_operations"},
+	models.NoteBatchFailedError:                     {http.StatusBadRequest, "note_batch_failed"},
+	models.UnknownNoteOperationError:                {http.StatusBadRequest, "unknown_note_operation"},
+	models.NoteOperationContentRequiredError:        {http.StatusBadRequest, "empty_note_content"},
+	models.NoteOperationTagsRequiredError:           {http.StatusBadRequest, "missing_tags"},
+	models.EmptyTagError:                            {http.StatusBadRequest, "empty_tag"},
+	models.TagTooLongError:                          {http.StatusBadRequest, "tag_too_long"},
+	models.CannotDeserializeNoteCategoryStringError: {http.StatusBadRequest, "unknown_category"},
+
+	// categories
+	models.NoCategoryFoundError:       {http.StatusNotFound, "category_not_found"},
+	models.CategoryAlreadyExistsError: {http.StatusConflict, "category_exists"},
+	models.CategoryInUseError:         {http.StatusConflict, "category_in_use"},
+	models.ReservedCategoryError:      {http.StatusConflict, "reserved_category"},
+	models.InvalidCategoryNameError:   {http.StatusBadRequest, "invalid_category_name"},
+	models.InvalidColourError:         {http.StatusBadRequest, "invalid_colour"},
+
+	// publications
+	models.NoNotesToPublishError:            {http.StatusBadRequest, "no_notes_to_publish"},
+	models.NoPublicationFoundError:          {http.StatusNotFound, "publication_not_found"},
+	models.PublicationAlreadyRetractedError: {http.StatusConflict, "publication_already_retracted"},
+	models.RetractionReasonRequiredError:    {http.StatusBadRequest, "retraction_reason_required"},
+	models.IssueTitleTooLongError:           {http.StatusBadRequest, "issue_title_too_long"},
+	models.IssueIntroTooLongError:           {http.StatusBadRequest, "issue_intro_too_long"},
+	models.InvalidNoteOrderError:            {http.StatusBadRequest, "invalid_note_order"},
+
+	// replies, reactions and answers
+	models.NoReplyFoundError:        {http.StatusNotFound, "reply_not_found"},
+	models.ReplyParentMismatchError: {http.StatusBadRequest, "reply_parent_mismatch"},
+	models.ReplyDeletedError:        {http.StatusBadRequest, "reply_deleted"},
+	models.NoAnswerFoundError:       {http.StatusNotFound, "answer_not_found"},
+
+	// predictions
+	models.CannotDeserializePredictionOutcomeStringError: {http.StatusBadRequest, "unknown_prediction_outcome"},
+	models.NoPredictionFoundError:                        {http.StatusNotFound, "prediction_not_found"},
+	models.InvalidProbabilityError:                       {http.StatusBadRequest, "invalid_probability"},
+	models.PredictionLockedError:                         {http.StatusConflict, "prediction_locked"},
+	models.PredictionAlreadyResolvedError:                {http.StatusConflict, "prediction_already_resolved"},
+
+	// sources
+	models.CannotDeserializeSourceLocationTypeStringError: {http.StatusBadRequest, "unknown_source_location_type"},
+	models.NoSourceFoundError:                             {http.StatusNotFound, "source_not_found"},
+	models.MissingSourceTitleError:                        {http.StatusBadRequest, "missing_source_title"},
+	models.InvalidIsbnError:                               {http.StatusBadRequest, "invalid_isbn"},
+
+	// attachments
+	models.NoAttachmentFoundError: {http.StatusNotFound, "attachment_not_found"},
+	blobstore.NoBlobFoundError:    {http.StatusNotFound, "attachment_content_not_found"},
+
+	// collections
+	models.NoCollectionFoundError:           {http.StatusNotFound, "collection_not_found"},
+	models.CollectionNameRequiredError:      {http.StatusBadRequest, "collection_name_required"},
+	models.CollectionNameTooLongError:       {http.StatusBadRequest, "collection_name_too_long"},
+	models.CollectionAlreadyExistsError:     {http.StatusConflict, "collection_exists"},
+	models.CollectionAnnotationTooLongError: {http.StatusBadRequest, "collection_annotation_too_long"},
+	models.NoteAlreadyInCollectionError:     {http.StatusConflict, "note_already_in_collection"},
+	models.NoteNotInCollectionError:         {http.StatusNotFound, "note_not_in_collection"},
+	models.InvalidCollectionOrderError:      {http.StatusBadRequest, "invalid_collection_order"},
+
+	// imports, feeds, notifications and webhooks
+	models.UnsupportedImportFormatError:                      {http.StatusBadRequest, "unsupported_import_format"},
+	models.MalformedFrontMatterError:                         {http.StatusBadRequest, "malformed_front_matter"},
+	models.NoFeedTokenFoundError:                             {http.StatusNotFound, "feed_token_not_found"},
+	models.CannotDeserializeNotificationFrequencyStringError: {http.StatusBadRequest, "unknown_notification_frequency"},
+	models.NoNotificationPreferencesFoundError:               {http.StatusNotFound, "notification_preferences_not_found"},
+	models.CannotDeserializeWebhookEventTypeStringError:      {http.StatusBadRequest, "unknown_webhook_event_type"},
+	models.NoWebhookSubscriptionFoundError:                   {http.StatusNotFound, "webhook_not_found"},
+	models.NoWebhookDeliveryFoundError:                       {http.StatusNotFound, "webhook_delivery_not_found"},
+}
+
+// statusCodes name the errors that have no mapping of their own.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// mapError returns the status and body for an error a handler returned with the given status.
+// The messages of unexpected server errors are kept out of the body, since they can leak internals.
+func mapError(err error, errCode int) (int, *ApiError) {
+	if mapping, ok := errorMappings[err]; ok {
+		return mapping.status, &ApiError{Code: mapping.code, Message: err.Error()}
+	}
+
+	if errCode < 400 {
+		errCode = http.StatusInternalServerError
+	}
+
+	if errCode >= 500 {
+		code, ok := statusCodes[errCode]
+		if !ok {
+			code = "internal_error"
+		}
+		return errCode, &ApiError{Code: code, Message: http.StatusText(errCode)}
+	}
+
+	code, ok := statusCodes[errCode]
+	if !ok {
+		code = "error"
+	}
+
+	return errCode, &ApiError{Code: code, Message: err.Error()}
+}
+
+// RespondWithError writes the error a handler returned, or one found before any handler was reached. Requests
+// under /api/v1 get the JSON error envelope; the deprecated routes and pages keep their plain text bodies.
+func RespondWithError(responseWriter http.ResponseWriter, request *http.Request, err error, errCode int) {
+	status, apiError := mapError(err, errCode)
+	if status >= 500 {
+		log.Print(err)
+	}
+
+	if !isVersionedApiRequest(request) {
+		http.Error(responseWriter, err.Error(), status)
+		return
+	}
+
+	if allow := responseWriter.Header().Get("Allow"); status == http.StatusMethodNotAllowed && len(allow) > 0 {
+		apiError.Details = map[string][]string{"allowedMethods": strings.Split(allow, ", ")}
+	}
+
+	respondWithJson(responseWriter, status, &ApiErrorEnvelope{Error: apiError})
+}
+
+// isVersionedApiRequest says whether the request is for the versioned api, whose errors are JSON.
+func isVersionedApiRequest(request *http.Request) bool {
+	return strings.HasPrefix(request.URL.Path, paths.ApiV1+"/")
+}
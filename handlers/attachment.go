@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+const maxAttachmentSize = 10 << 20
+const maxAttachmentFileNameLength = 255
+const attachmentFormField = "file"
+
+// allowedAttachmentTypes are the content types attachments may have, judged from their content rather than
+// what the uploader claims.
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+var AttachmentTooLargeError = fmt.Errorf("Attachments cannot be larger than %d bytes", maxAttachmentSize)
+var AttachmentTypeNotAllowedError = errors.New("Attachments must be PNG, JPEG or GIF images, PDFs or plain text")
+var MissingAttachmentError = fmt.Errorf("The upload must include the file in the %q field", attachmentFormField)
+
+// HandleNoteAttachmentApiRequest responds to GET requests with the attachments of the note given by noteId,
+// and to POST requests by attaching the multipart uploaded file to it. DELETE requests remove the attachment
+// given by id. Attachments can be seen by anyone who can see the note, but only its author can change them.
+func HandleNoteAttachmentApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if _, err, errCode := getNoteVisibleTo(env, noteId, userId); err != nil {
+			return err, errCode
+		}
+
+		attachments, err := env.Db.GetNoteAttachments(noteId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, attachments)
+
+	case http.MethodPost:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		note, err, errCode := getNoteVisibleTo(env, noteId, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if note.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		attachment, err, errCode := storeUploadedAttachment(env, responseWriter, request, noteId, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		return respondWithJson(responseWriter, http.StatusCreated, attachment)
+
+	case http.MethodDelete:
+		attachment, err, errCode := getAttachmentVisibleTo(env, request, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if attachment.UploaderId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		if err := env.Db.DeleteAttachment(attachment.Id); err != nil {
+			if err == models.NoAttachmentFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		deleteAttachmentBlobs(env, []*models.Attachment{attachment})
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// HandleNoteAttachmentContentApiRequest responds to GET requests with the content of the attachment given by
+// id, or with the thumbnail of an image when thumbnail is true.
+func HandleNoteAttachmentContentApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		attachment, err, errCode := getAttachmentVisibleTo(env, request, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		key := attachment.BlobKey
+		contentType := attachment.ContentType
+		if request.URL.Query().Get("thumbnail") == "true" {
+			if !attachment.HasThumbnail {
+				return blobstore.NoBlobFoundError, http.StatusNotFound
+			}
+			key = attachment.ThumbnailKey
+			contentType = "image/png"
+		}
+
+		content, err := env.Blobs.Get(key)
+		if err != nil {
+			if err == blobstore.NoBlobFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+		defer content.Close()
+
+		// only images are shown in the page; anything else is downloaded
+		disposition := "attachment"
+		if strings.HasPrefix(contentType, "image/") {
+			disposition = "inline"
+		}
+
+		responseWriter.Header().Set("Content-Type", contentType)
+		responseWriter.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+		responseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+		responseWriter.Header().Set("Content-Security-Policy", "sandbox")
+		responseWriter.Header().Set("Cache-Control", "private")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		if _, err := io.Copy(responseWriter, content); err != nil {
+			log.Print(err)
+		}
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+// storeUploadedAttachment checks the uploaded file, puts it and, for images, a thumbnail into the blob store,
+// then records the attachment.
+func storeUploadedAttachment(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	noteId models.NoteId,
+	userId models.UserId,
+) (*models.Attachment, error, int) {
+	// leave room for the multipart framing around the file
+	request.Body = http.MaxBytesReader(responseWriter, request.Body, maxAttachmentSize+(1<<20))
+
+	file, header, err := request.FormFile(attachmentFormField)
+	if err != nil {
+		switch err {
+		case http.ErrMissingFile:
+			return nil, MissingAttachmentError, http.StatusBadRequest
+		default:
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return nil, AttachmentTooLargeError, http.StatusRequestEntityTooLarge
+			}
+			return nil, err, http.StatusBadRequest
+		}
+	}
+	defer file.Close()
+
+	if header.Size > maxAttachmentSize {
+		return nil, AttachmentTooLargeError, http.StatusRequestEntityTooLarge
+	}
+
+	sniffed := make([]byte, 512)
+	sniffedLength, err := io.ReadFull(file, sniffed)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err, http.StatusBadRequest
+	}
+
+	contentType := http.DetectContentType(sniffed[:sniffedLength])
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !allowedAttachmentTypes[mediaType] {
+		return nil, AttachmentTypeNotAllowedError, http.StatusUnsupportedMediaType
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+
+	blobKey, err := newAttachmentKey(noteId)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+
+	if err := env.Blobs.Put(blobKey, file, header.Size, contentType); err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+
+	attachment := &models.Attachment{
+		NoteId:       noteId,
+		UploaderId:   userId,
+		FileName:     cleanAttachmentFileName(header.Filename),
+		ContentType:  contentType,
+		Size:         header.Size,
+		CreationTime: time.Now().UTC(),
+		BlobKey:      blobKey,
+	}
+
+	if strings.HasPrefix(mediaType, "image/") {
+		// an image that can't be thumbnailed is still a fine attachment
+		if err := storeThumbnail(env, file, attachment); err != nil {
+			log.Print(err)
+		}
+	}
+
+	attachmentId, err := env.Db.StoreNewAttachment(attachment)
+	if err != nil {
+		deleteAttachmentBlobs(env, []*models.Attachment{attachment})
+		return nil, err, http.StatusInternalServerError
+	}
+	attachment.Id = attachmentId
+
+	return attachment, nil, 0
+}
+
+func storeThumbnail(env *Environment, file io.ReadSeeker, attachment *models.Attachment) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	thumbnail, err := makeThumbnail(file)
+	if err != nil {
+		return err
+	}
+
+	thumbnailKey := attachment.BlobKey + "-thumbnail"
+	if err := env.Blobs.Put(thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/png"); err != nil {
+		return err
+	}
+
+	attachment.ThumbnailKey = thumbnailKey
+	attachment.HasThumbnail = true
+
+	return nil
+}
+
+// deleteAttachmentBlobs removes the content and thumbnails of attachments that are no longer recorded.
+// Failures are only logged, as the attachments are already gone as far as readers are concerned.
+func deleteAttachmentBlobs(env *Environment, attachments []*models.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range attachment.BlobKeys() {
+			if err := env.Blobs.Delete(key); err != nil && err != blobstore.NoBlobFoundError {
+				log.Print(err)
+			}
+		}
+	}
+}
+
+// getNoteVisibleTo returns the note given its id, as long as the user wrote it or can read it once published.
+func getNoteVisibleTo(env *Environment, noteId models.NoteId, userId models.UserId) (*models.Note, error, int) {
+	note, err := env.Db.GetNoteById(noteId)
+	if err != nil {
+		if err == models.NoNoteFoundError {
+			return nil, err, http.StatusNotFound
+		}
+		return nil, err, http.StatusInternalServerError
+	}
+
+	if note.AuthorId != userId {
+		if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+			return nil, err, errCode
+		}
+	}
+
+	return note, nil, 0
+}
+
+// getAttachmentVisibleTo returns the attachment given by id, as long as the user can see its note.
+func getAttachmentVisibleTo(env *Environment, request *http.Request, userId models.UserId) (*models.Attachment, error, int) {
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return nil, err, http.StatusBadRequest
+	}
+
+	attachment, err := env.Db.GetAttachment(models.AttachmentId(id))
+	if err != nil {
+		if err == models.NoAttachmentFoundError {
+			return nil, err, http.StatusNotFound
+		}
+		return nil, err, http.StatusInternalServerError
+	}
+
+	if _, err, errCode := getNoteVisibleTo(env, attachment.NoteId, userId); err != nil {
+		// don't reveal attachments of notes the user can't see
+		if errCode == http.StatusNotFound {
+			return nil, models.NoAttachmentFoundError, http.StatusNotFound
+		}
+		return nil, err, errCode
+	}
+
+	return attachment, nil, 0
+}
+
+// newAttachmentKey returns an unguessable blob key grouped under the note.
+func newAttachmentKey(noteId models.NoteId) (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("attachments/%d/%s", noteId, hex.EncodeToString(randomBytes)), nil
+}
+
+func cleanAttachmentFileName(fileName string) string {
+	// browsers may send a full path; keep only the name
+	fileName = path.Base(strings.Replace(fileName, "\\", "/", -1))
+	fileName = strings.TrimSpace(fileName)
+
+	if fileName == "." || fileName == "/" || len(fileName) == 0 {
+		return "attachment"
+	}
+
+	if len(fileName) > maxAttachmentFileNameLength {
+		end := maxAttachmentFileNameLength
+		for end > 0 && !utf8.RuneStart(fileName[end]) {
+			end--
+		}
+		fileName = fileName[:end]
+	}
+
+	return fileName
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var NotYourCategoryError = errors.New("Only the creator of a category or an admin can change it")
+
+// HandleCategoryApiRequest responds to GET requests with every category in display order, to POST
+// requests by creating a category, to PUT requests by editing or renaming the category given by name,
+// and to DELETE requests by deleting it once no notes are left in it. Only the category's creator or an
+// admin can edit or delete it.
+func HandleCategoryApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		categories, err := env.Db.GetCategories()
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, categories)
+
+	case http.MethodPost:
+		category := new(models.Category)
+		if err := json.NewDecoder(request.Body).Decode(category); err != nil {
+			return err, http.StatusBadRequest
+		}
+		category.CreationTime = time.Now().UTC()
+		category.CreatorId = userId
+
+		if err := env.Db.StoreNewCategory(category); err != nil {
+			return err, categoryErrorStatus(err)
+		}
+
+		return respondWithJson(responseWriter, http.StatusCreated, category)
+
+	case http.MethodPut:
+		name := models.NoteCategory(request.URL.Query().Get("name"))
+
+		if err, errCode := checkCategoryChangeableBy(env, name, userId); err != nil {
+			return err, errCode
+		}
+
+		category := new(models.Category)
+		if err := json.NewDecoder(request.Body).Decode(category); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.UpdateCategory(name, category); err != nil {
+			return err, categoryErrorStatus(err)
+		}
+
+		updatedCategory, err := env.Db.GetCategory(category.Name)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, updatedCategory)
+
+	case http.MethodDelete:
+		name := models.NoteCategory(request.URL.Query().Get("name"))
+
+		if err, errCode := checkCategoryChangeableBy(env, name, userId); err != nil {
+			return err, errCode
+		}
+
+		if err := env.Db.DeleteCategory(name); err != nil {
+			return err, categoryErrorStatus(err)
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// checkCategoryChangeableBy returns 403 unless the user created the category or is an admin, since every
+// user sees the same categories.
+func checkCategoryChangeableBy(env *Environment, name models.NoteCategory, userId models.UserId) (error, int) {
+	category, err := env.Db.GetCategory(name)
+	if err != nil {
+		return err, categoryErrorStatus(err)
+	}
+
+	if category.CreatorId != userId && !env.Admins[userId] {
+		return NotYourCategoryError, http.StatusForbidden
+	}
+
+	return nil, 0
+}
+
+func categoryErrorStatus(err error) int {
+	switch err {
+	case models.InvalidCategoryNameError, models.InvalidColourError:
+		return http.StatusBadRequest
+	case models.NoCategoryFoundError:
+		return http.StatusNotFound
+	case models.CategoryAlreadyExistsError, models.CategoryInUseError, models.ReservedCategoryError:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
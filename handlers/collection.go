@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var CannotCollectOwnNoteError = errors.New("Collections are for other authors' notes")
+
+// HandleCollectionApiRequest responds to GET requests with the current user's collections, and to POST
+// requests by creating a collection with the given name. PUT requests rename the collection given by id and
+// DELETE requests delete it.
+func HandleCollectionApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type CollectionForm struct {
+		Name string `json:"name"`
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		collections, err := env.Db.GetCollections(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, collections)
+
+	case http.MethodPost:
+		collectionForm := new(CollectionForm)
+		if err := json.NewDecoder(request.Body).Decode(collectionForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		name, err := models.NormalizeCollectionName(collectionForm.Name)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		collection := &models.Collection{
+			OwnerId:      userId,
+			Name:         name,
+			CreationTime: time.Now().UTC(),
+		}
+
+		collectionId, err := env.Db.StoreNewCollection(collection)
+		if err != nil {
+			if err == models.CollectionAlreadyExistsError {
+				return err, http.StatusConflict
+			}
+			return err, http.StatusInternalServerError
+		}
+		collection.Id = collectionId
+
+		return respondWithJson(responseWriter, http.StatusCreated, collection)
+
+	case http.MethodPut:
+		collection, err, errCode := getOwnCollection(env, request, "id", userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		collectionForm := new(CollectionForm)
+		if err := json.NewDecoder(request.Body).Decode(collectionForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		name, err := models.NormalizeCollectionName(collectionForm.Name)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.RenameCollection(collection.Id, name); err != nil {
+			switch err {
+			case models.CollectionAlreadyExistsError:
+				return err, http.StatusConflict
+			case models.NoCollectionFoundError:
+				return err, http.StatusNotFound
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+		collection.Name = name
+
+		return respondWithJson(responseWriter, http.StatusOK, collection)
+
+	case http.MethodDelete:
+		collection, err, errCode := getOwnCollection(env, request, "id", userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if err := env.Db.DeleteCollection(collection.Id); err != nil {
+			if err == models.NoCollectionFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// HandleCollectionNoteApiRequest responds to GET requests with the notes in the current user's collection
+// given by collectionId, in order. POST requests add another author's published note to it, PUT requests
+// change the annotation on the note given by noteId and DELETE requests take that note out.
+func HandleCollectionNoteApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type CollectionNoteForm struct {
+		NoteId     models.NoteId `json:"noteId"`
+		Annotation string        `json:"annotation"`
+	}
+
+	collection, err, errCode := getOwnCollection(env, request, "collectionId", userId)
+	if err != nil {
+		return err, errCode
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		collectionNotes, err := env.Db.GetCollectionNotes(collection.Id)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		for _, collectionNote := range collectionNotes {
+			if err := renderNote(env, collectionNote.NoteId, collectionNote.Note); err != nil {
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, collectionNotes)
+
+	case http.MethodPost:
+		collectionNoteForm := new(CollectionNoteForm)
+		if err := json.NewDecoder(request.Body).Decode(collectionNoteForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		annotation, err := models.NormalizeCollectionAnnotation(collectionNoteForm.Annotation)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		note, err, errCode := getNoteVisibleTo(env, collectionNoteForm.NoteId, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if note.AuthorId == userId {
+			return CannotCollectOwnNoteError, http.StatusBadRequest
+		}
+
+		if err := env.Db.AddNoteToCollection(collection.Id, collectionNoteForm.NoteId, annotation, time.Now().UTC()); err != nil {
+			switch err {
+			case models.NoteAlreadyInCollectionError:
+				return err, http.StatusConflict
+			case models.NoCollectionFoundError:
+				return err, http.StatusNotFound
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		responseWriter.WriteHeader(http.StatusCreated)
+
+		return nil, 0
+
+	case http.MethodPut:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		collectionNoteForm := new(CollectionNoteForm)
+		if err := json.NewDecoder(request.Body).Decode(collectionNoteForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		annotation, err := models.NormalizeCollectionAnnotation(collectionNoteForm.Annotation)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.UpdateCollectionAnnotation(collection.Id, noteId, annotation); err != nil {
+			if err == models.NoteNotInCollectionError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	case http.MethodDelete:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.RemoveNoteFromCollection(collection.Id, noteId); err != nil {
+			if err == models.NoteNotInCollectionError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// HandleCollectionOrderApiRequest responds to PUT requests by putting the notes of the current user's
+// collection given by id in the given order.
+func HandleCollectionOrderApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type CollectionOrderForm struct {
+		NoteOrder []models.NoteId `json:"noteOrder"`
+	}
+
+	switch request.Method {
+	case http.MethodPut:
+		collection, err, errCode := getOwnCollection(env, request, "id", userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		collectionOrderForm := new(CollectionOrderForm)
+		if err := json.NewDecoder(request.Body).Decode(collectionOrderForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.ReorderCollection(collection.Id, collectionOrderForm.NoteOrder); err != nil {
+			if err == models.InvalidCollectionOrderError {
+				return err, http.StatusBadRequest
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPut)
+	}
+}
+
+// HandleCollectionExportApiRequest responds to GET requests with the current user's collection given by id
+// as a Markdown document, annotations included.
+func HandleCollectionExportApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		collection, err, errCode := getOwnCollection(env, request, "id", userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		collectionNotes, err := env.Db.GetCollectionNotes(collection.Id)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		usersById, err := env.Db.GetAllUsersById()
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		var markdown bytes.Buffer
+		if err := models.WriteCollectionMarkdown(&markdown, collection, collectionNotes, usersById); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		responseWriter.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": collection.Name + ".md"}))
+		responseWriter.WriteHeader(http.StatusOK)
+		responseWriter.Write(markdown.Bytes())
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+// getOwnCollection returns the collection given by the named parameter, as long as the user owns it.
+// Other users' collections are private, so they are reported as not found.
+func getOwnCollection(
+	env *Environment,
+	request *http.Request,
+	parameterName string,
+	userId models.UserId,
+) (*models.Collection, error, int) {
+	id, err := strconv.ParseInt(request.URL.Query().Get(parameterName), 10, 64)
+	if err != nil {
+		return nil, err, http.StatusBadRequest
+	}
+
+	collection, err := env.Db.GetCollection(models.CollectionId(id))
+	if err != nil {
+		if err == models.NoCollectionFoundError {
+			return nil, err, http.StatusNotFound
+		}
+		return nil, err, http.StatusInternalServerError
+	}
+
+	if collection.OwnerId != userId {
+		return nil, models.NoCollectionFoundError, http.StatusNotFound
+	}
+
+	return collection, nil, 0
+}
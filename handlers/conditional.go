@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var PreconditionFailedError = errors.New("The resource has changed since the version this request is based on")
+
+// strongETag returns an entity tag for the exact bytes of a response body, so it changes with any change to the
+// representation, including ones that aren't reflected in Last-Modified.
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// noteETag is the strongETag of a single note's body with the note's revision in front. The whole tag still
+// changes with any change to the body, but an If-Match on an edit only compares the revision, so other users'
+// reactions and what this user has read since don't make it fail.
+func noteETag(revision int, body []byte) string {
+	return `"r` + strconv.Itoa(revision) + "-" + strings.Trim(strongETag(body), `"`) + `"`
+}
+
+// etagRevision returns the revision in front of a noteETag, or false if the tag isn't one.
+func etagRevision(etag string) (int, bool) {
+	if !strings.HasPrefix(etag, `"r`) || !strings.HasSuffix(etag, `"`) {
+		return 0, false
+	}
+
+	dash := strings.Index(etag, "-")
+	if dash < 0 {
+		return 0, false
+	}
+
+	revision, err := strconv.Atoi(etag[len(`"r`):dash])
+	if err != nil {
+		return 0, false
+	}
+
+	return revision, true
+}
+
+// ifMatchesRevision says whether the If-Match list is "*" or has a noteETag for the revision. Weak tags never
+// match, as If-Match compares strongly.
+func ifMatchesRevision(list string, revision int) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if candidateRevision, ok := etagRevision(candidate); ok && candidateRevision == revision {
+			return true
+		}
+	}
+
+	return false
+}
+
+// respondWithConditionalJson writes a JSON body along with its ETag and Last-Modified validators, or just the
+// validators and 304 Not Modified when the request's preconditions show the client already has this body.
+// A zero lastModified leaves Last-Modified out.
+func respondWithConditionalJson(
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	body []byte,
+	etag string,
+	lastModified time.Time,
+) (error, int) {
+	header := responseWriter.Header()
+	header.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	// responses differ from user to user and must be revalidated before they are reused
+	header.Set("Cache-Control", "private, no-cache")
+
+	if isNotModified(request, etag, lastModified) {
+		responseWriter.WriteHeader(http.StatusNotModified)
+		return nil, 0
+	}
+
+	header.Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+	responseWriter.Write(body)
+
+	return nil, 0
+}
+
+// isNotModified evaluates If-None-Match or, when the request has none, If-Modified-Since, in the order RFC 7232
+// gives them.
+func isNotModified(request *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := strings.Join(request.Header["If-None-Match"], ","); len(ifNoneMatch) > 0 {
+		return etagListContains(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := request.Header.Get("If-Modified-Since")
+	if len(ifModifiedSince) == 0 || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	// http dates only go down to the second
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagListContains says whether etag is in the comma separated list of an If-None-Match header, or the list is
+// "*". If-None-Match compares weakly, so weak tags in it match too.
+func etagListContains(list string, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// noteLastModified is the latest time the note itself records a change at. Replies, reactions and tags don't
+// record one, so changes to them only show in the ETag.
+func noteLastModified(note *models.Note) time.Time {
+	lastModified := note.CreationTime
+
+	for _, changeTime := range []*time.Time{note.LastEditTime, note.PinnedTime, note.ArchivedTime, note.DeletionTime} {
+		if changeTime != nil && changeTime.After(lastModified) {
+			lastModified = *changeTime
+		}
+	}
+
+	return lastModified
+}
+
+// usersLastModified is when the newest user joined, since users can't be changed once they have.
+func usersLastModified(users models.UsersById) time.Time {
+	var lastModified time.Time
+
+	for _, user := range users {
+		if user.CreationTime.After(lastModified) {
+			lastModified = user.CreationTime
+		}
+	}
+
+	return lastModified
+}
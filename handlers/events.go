@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/events"
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+const eventStreamHeartbeatInterval = time.Second * 30
+
+// eventStreamResetType tells a client that it missed events and should reload everything.
+const eventStreamResetType = "reset"
+
+var StreamingUnsupportedError = errors.New("This connection does not support streaming responses")
+var EventsUnavailableError = errors.New("Live events are not available")
+
+// emitEvent queues webhook deliveries for something that happened to a note or publication,
+// and publishes it to the live event streams of everyone entitled to see it.
+// Failing to emit an event is logged but never fails the request that caused it.
+func emitEvent(env *Environment, event *models.WebhookEvent) {
+	if event.CreationTime.IsZero() {
+		event.CreationTime = time.Now().UTC()
+	}
+
+	if err := env.Db.QueueWebhookDeliveries(event); err != nil {
+		log.Print(err)
+	}
+
+	if env.Events == nil {
+		return
+	}
+
+	audience, err := env.Db.GetEventAudience(event)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	if err := env.Events.Publish(event, audience); err != nil {
+		log.Print(err)
+	}
+}
+
+// HandleEventsApiRequest responds to GET requests with a Server-Sent Events stream of the note and
+// publication events visible to the current user. Clients resume after a reconnect by sending the
+// Last-Event-ID header, or the lastEventId query parameter.
+func HandleEventsApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		if env.Events == nil {
+			return EventsUnavailableError, http.StatusServiceUnavailable
+		}
+
+		flusher, ok := responseWriter.(http.Flusher)
+		if !ok {
+			return StreamingUnsupportedError, http.StatusInternalServerError
+		}
+
+		lastEventIdString := request.Header.Get("Last-Event-ID")
+		if len(lastEventIdString) == 0 {
+			lastEventIdString = request.URL.Query().Get("lastEventId")
+		}
+
+		var lastEventId int64
+		if len(lastEventIdString) > 0 {
+			id, err := strconv.ParseInt(lastEventIdString, 10, 64)
+			if err != nil || id < 0 {
+				return errors.New("Invalid last event id"), http.StatusBadRequest
+			}
+			lastEventId = id
+		}
+
+		subscription, err := env.Events.Subscribe(userId, lastEventId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		defer subscription.Close()
+
+		responseWriter.Header().Set("Content-Type", "text/event-stream")
+		responseWriter.Header().Set("Cache-Control", "no-cache")
+		responseWriter.Header().Set("X-Accel-Buffering", "no")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		if subscription.Missed {
+			fmt.Fprintf(responseWriter, "event: %s\ndata: {}\n\n", eventStreamResetType)
+		}
+
+		for _, event := range subscription.Replayed {
+			if err := writeStreamEvent(responseWriter, event); err != nil {
+				log.Print(err)
+				return nil, 0
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventStreamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-request.Context().Done():
+				return nil, 0
+
+			case event, ok := <-subscription.Events:
+				if !ok {
+					// dropped for falling behind, the client reconnects and resumes
+					return nil, 0
+				}
+
+				if err := writeStreamEvent(responseWriter, event); err != nil {
+					log.Print(err)
+					return nil, 0
+				}
+				flusher.Flush()
+
+			case <-heartbeat.C:
+				// comments keep proxies from closing an idle connection
+				fmt.Fprint(responseWriter, ": heartbeat\n\n")
+				flusher.Flush()
+			}
+		}
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+func writeStreamEvent(responseWriter http.ResponseWriter, event *events.Event) error {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(responseWriter, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Payload.Type, payload)
+	return err
+}
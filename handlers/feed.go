@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/paths"
+)
+
+const feedTokenQueryParameter = "token"
+const feedAuthorQueryParameter = "author"
+const feedTitle = "CerealNotes"
+
+var InvalidFeedAuthorError = errors.New("The author of a feed must be given by their user id")
+
+// issueContentTemplate lays out an issue's already sanitised intro and notes, labelling deleted notes the way
+// the notes page does.
+var issueContentTemplate = template.Must(template.New("issue").Parse(
+	`{{ if .RetractionReason }}<p>This issue was retracted: {{ .RetractionReason }}</p>{{ end }}` +
+		`{{ .IntroHtml }}` +
+		`{{ range .Notes }}{{ if .Deleted }}<p><em>This note was deleted.</em></p>{{ else }}{{ .ContentHtml }}{{ end }}{{ end }}`))
+
+// feedNote is a note as laid out by issueContentTemplate.
+type feedNote struct {
+	Deleted     bool
+	ContentHtml template.HTML
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+// feedIssue is the format independent data needed to render one feed entry.
+type feedIssue struct {
+	id           string
+	title        string
+	authorName   string
+	link         string
+	creationTime time.Time
+	contentHtml  string
+}
+
+// HandleAtomFeedRequest responds to GET requests with an Atom feed of the issues visible to the owner of the feed token.
+func HandleAtomFeedRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		issues, err := getFeedIssues(env, request)
+		if err != nil {
+			switch err {
+			case models.NoFeedTokenFoundError:
+				return err, http.StatusNotFound
+			case InvalidFeedAuthorError:
+				return err, http.StatusBadRequest
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		feed := &atomFeed{
+			Title:   feedTitle,
+			Id:      getBaseUrl(request) + request.URL.Path,
+			Updated: time.Now().UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Href: getBaseUrl(request) + paths.NotesPage},
+				{Href: getBaseUrl(request) + request.URL.RequestURI(), Rel: "self", Type: "application/atom+xml"},
+			},
+			Entries: make([]atomEntry, 0, len(issues)),
+		}
+
+		if len(issues) > 0 {
+			feed.Updated = issues[0].creationTime.Format(time.RFC3339)
+		}
+
+		for _, issue := range issues {
+			feed.Entries = append(feed.Entries, atomEntry{
+				Title:   issue.title,
+				Id:      issue.id,
+				Updated: issue.creationTime.Format(time.RFC3339),
+				Author:  atomPerson{Name: issue.authorName},
+				Link:    atomLink{Href: issue.link},
+				Content: atomContent{Type: "html", Body: issue.contentHtml},
+			})
+		}
+
+		return respondWithXml(responseWriter, "application/atom+xml; charset=utf-8", feed)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+// HandleRssFeedRequest responds to GET requests with an RSS feed of the issues visible to the owner of the feed token.
+func HandleRssFeedRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		issues, err := getFeedIssues(env, request)
+		if err != nil {
+			switch err {
+			case models.NoFeedTokenFoundError:
+				return err, http.StatusNotFound
+			case InvalidFeedAuthorError:
+				return err, http.StatusBadRequest
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		feed := &rssFeed{
+			Version: "2.0",
+			Channel: rssChannel{
+				Title:       feedTitle,
+				Link:        getBaseUrl(request) + paths.NotesPage,
+				Description: "Issues published on CerealNotes",
+				Items:       make([]rssItem, 0, len(issues)),
+			},
+		}
+
+		if len(issues) > 0 {
+			feed.Channel.LastBuildDate = issues[0].creationTime.Format(time.RFC1123Z)
+		}
+
+		for _, issue := range issues {
+			feed.Channel.Items = append(feed.Channel.Items, rssItem{
+				Title:       issue.title,
+				Link:        issue.link,
+				Guid:        rssGuid{IsPermaLink: "false", Value: issue.id},
+				PubDate:     issue.creationTime.Format(time.RFC1123Z),
+				Description: issue.contentHtml,
+			})
+		}
+
+		return respondWithXml(responseWriter, "application/rss+xml; charset=utf-8", feed)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+// HandleFeedTokenApiRequest lets a user see (GET), regenerate (POST) or revoke (DELETE) their private feed token.
+func HandleFeedTokenApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type FeedTokenResponse struct {
+		Token   string `json:"token"`
+		AtomUrl string `json:"atomUrl"`
+		RssUrl  string `json:"rssUrl"`
+	}
+
+	respondWithFeedToken := func(token string, statusCode int) (error, int) {
+		query := url.Values{feedTokenQueryParameter: []string{token}}.Encode()
+
+		feedTokenJson, err := json.Marshal(&FeedTokenResponse{
+			Token:   token,
+			AtomUrl: getBaseUrl(request) + paths.AtomFeed + "?" + query,
+			RssUrl:  getBaseUrl(request) + paths.RssFeed + "?" + query,
+		})
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(statusCode)
+		fmt.Fprint(responseWriter, string(feedTokenJson))
+
+		return nil, 0
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		token, err := env.Db.GetFeedToken(userId)
+		if err != nil {
+			if err == models.NoFeedTokenFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithFeedToken(token, http.StatusOK)
+
+	case http.MethodPost:
+		token, err := env.Db.CreateFeedToken(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithFeedToken(token, http.StatusCreated)
+
+	case http.MethodDelete:
+		if err := env.Db.DeleteFeedToken(userId); err != nil {
+			if err == models.NoFeedTokenFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// getFeedIssues looks up the feed token's owner and returns the issues they can read, newest first,
+// optionally limited to a single author.
+func getFeedIssues(env *Environment, request *http.Request) ([]*feedIssue, error) {
+	userId, err := env.Db.GetUserIdForFeedToken(request.URL.Query().Get(feedTokenQueryParameter))
+	if err != nil {
+		return nil, err
+	}
+
+	authorFilter := int64(0)
+	if authorString := request.URL.Query().Get(feedAuthorQueryParameter); len(authorString) > 0 {
+		authorFilter, err = strconv.ParseInt(authorString, 10, 64)
+		if err != nil {
+			return nil, InvalidFeedAuthorError
+		}
+	}
+
+	publishedIssues, err := env.Db.GetPublishedIssuesVisibleBy(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	usersById, err := env.Db.GetAllUsersById()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]*feedIssue, 0, len(publishedIssues))
+	for _, publishedIssue := range publishedIssues {
+		if authorFilter != 0 && int64(publishedIssue.AuthorId) != authorFilter {
+			continue
+		}
+
+		authorName := "Unknown"
+		if author, ok := usersById[publishedIssue.AuthorId]; ok {
+			authorName = author.DisplayName
+		}
+
+		if err := renderIssue(env, publishedIssue); err != nil {
+			return nil, err
+		}
+
+		notes := make([]*feedNote, 0, len(publishedIssue.NoteOrder))
+		for _, noteId := range publishedIssue.NoteOrder {
+			note := publishedIssue.Notes[noteId]
+			notes = append(notes, &feedNote{
+				Deleted:     note.DeletionTime != nil,
+				ContentHtml: template.HTML(note.ContentHtml),
+			})
+		}
+
+		contentHtml := &bytes.Buffer{}
+		if err := issueContentTemplate.Execute(contentHtml, map[string]interface{}{
+			"RetractionReason": publishedIssue.RetractionReason,
+			"IntroHtml":        template.HTML(publishedIssue.IntroHtml),
+			"Notes":            notes,
+		}); err != nil {
+			return nil, err
+		}
+
+		title := fmt.Sprintf("%s, issue %d", authorName, publishedIssue.IssueNumber)
+		if len(publishedIssue.Title) > 0 {
+			title += ": " + publishedIssue.Title
+		}
+
+		issues = append(issues, &feedIssue{
+			id:           fmt.Sprintf("%s%s#publication-%d", getBaseUrl(request), paths.NotesPage, publishedIssue.PublicationId),
+			title:        title,
+			authorName:   authorName,
+			link:         getBaseUrl(request) + paths.NotesPage,
+			creationTime: publishedIssue.CreationTime.UTC(),
+			contentHtml:  contentHtml.String(),
+		})
+	}
+
+	return issues, nil
+}
+
+func respondWithXml(responseWriter http.ResponseWriter, contentType string, value interface{}) (error, int) {
+	xmlBytes, err := xml.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	responseWriter.Header().Set("Content-Type", contentType)
+	responseWriter.WriteHeader(http.StatusOK)
+	fmt.Fprint(responseWriter, xml.Header+string(xmlBytes))
+
+	return nil, 0
+}
+
+// getBaseUrl returns the scheme and host the request was made to, honouring Heroku's forwarded protocol.
+func getBaseUrl(request *http.Request) string {
+	scheme := "http"
+	if request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + request.Host
+}
@@ -0,0 +1,994 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/graphql"
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// maxGraphQlRequestSize and maxGraphQlDepth bound how much work a single query can ask for. Notes have authors
+// and authors have notes, so each level of nesting can multiply the size of the response.
+const maxGraphQlRequestSize = 1 << 20
+const maxGraphQlDepth = 6
+
+var InvalidIdError = errors.New("Ids must be whole numbers")
+var GraphQlRequestTooLargeError = fmt.Errorf("GraphQL requests cannot be larger than %d bytes", maxGraphQlRequestSize)
+
+// HandleGraphQlApiRequest responds to POST requests by executing the GraphQL query in the body as the current
+// user, and to GET requests with the schema the queries are written against. Everything a user can see
+// through the schema, they can see through the other endpoints.
+func HandleGraphQlApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		responseWriter.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		responseWriter.WriteHeader(http.StatusOK)
+		fmt.Fprint(responseWriter, graphQlSchema.String())
+
+		return nil, 0
+
+	case http.MethodPost:
+		graphQlRequest := new(graphql.Request)
+
+		request.Body = http.MaxBytesReader(responseWriter, request.Body, maxGraphQlRequestSize)
+
+		decoder := json.NewDecoder(request.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(graphQlRequest); err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return GraphQlRequestTooLargeError, http.StatusRequestEntityTooLarge
+			}
+			return err, http.StatusBadRequest
+		}
+
+		ctx := context.WithValue(request.Context(), graphQlContextKey{}, newGraphQlContext(env, userId))
+		response := graphQlSchema.Execute(ctx, graphQlRequest)
+
+		// requests that couldn't be executed at all have no data
+		if len(response.Data) == 0 {
+			return respondWithJson(responseWriter, http.StatusBadRequest, response)
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, response)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost)
+	}
+}
+
+// PRIVATE
+
+type graphQlContextKey struct{}
+
+// graphQlContext is what the resolvers of one request share: who is asking, what they can see, and the
+// loaders that fetch what's needed for many notes at once rather than a note at a time.
+type graphQlContext struct {
+	env    *Environment
+	userId models.UserId
+
+	// each of these is fetched at most once, until a mutation changes them
+	users         models.UsersById
+	visibleNotes  models.NotesById
+	unreadNoteIds map[models.NoteId]bool
+	issues        []*models.PublishedIssue
+
+	categories  *graphql.Loader
+	replies     *graphql.Loader
+	replyCounts *graphql.Loader
+	tags        *graphql.Loader
+	reactions   *graphql.Loader
+}
+
+// graphQlNote is a note along with its id, which notes don't hold themselves.
+type graphQlNote struct {
+	id models.NoteId
+	*models.Note
+}
+
+func newGraphQlContext(env *Environment, userId models.UserId) *graphQlContext {
+	graphQlContext := &graphQlContext{env: env, userId: userId}
+
+	graphQlContext.categories = newNoteLoader(func(noteIds []models.NoteId) (map[interface{}]interface{}, error) {
+		categories, err := env.Db.GetNoteCategories(noteIds)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[interface{}]interface{}, len(categories))
+		for noteId, category := range categories {
+			values[noteId] = category
+		}
+		return values, nil
+	})
+
+	graphQlContext.replies = newNoteLoader(func(noteIds []models.NoteId) (map[interface{}]interface{}, error) {
+		replies, err := env.Db.GetRepliesForNotes(noteIds)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[interface{}]interface{}, len(noteIds))
+		for _, noteId := range noteIds {
+			values[noteId] = models.NestReplies(replies[noteId])
+		}
+		return values, nil
+	})
+
+	graphQlContext.replyCounts = newNoteLoader(func(noteIds []models.NoteId) (map[interface{}]interface{}, error) {
+		counts, err := env.Db.GetReplyCounts(noteIds)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[interface{}]interface{}, len(noteIds))
+		for _, noteId := range noteIds {
+			values[noteId] = counts[noteId]
+		}
+		return values, nil
+	})
+
+	graphQlContext.tags = newNoteLoader(func(noteIds []models.NoteId) (map[interface{}]interface{}, error) {
+		tags, err := env.Db.GetNoteTags(noteIds)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[interface{}]interface{}, len(noteIds))
+		for _, noteId := range noteIds {
+			noteTags := tags[noteId]
+			if noteTags == nil {
+				noteTags = make([]string, 0)
+			}
+			values[noteId] = noteTags
+		}
+		return values, nil
+	})
+
+	graphQlContext.reactions = newNoteLoader(func(noteIds []models.NoteId) (map[interface{}]interface{}, error) {
+		summaries, err := env.Db.GetReactionSummaries(noteIds, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[interface{}]interface{}, len(noteIds))
+		for _, noteId := range noteIds {
+			noteSummaries := summaries[noteId]
+			if noteSummaries == nil {
+				noteSummaries = make([]*models.ReactionSummary, 0)
+			}
+			values[noteId] = noteSummaries
+		}
+		return values, nil
+	})
+
+	return graphQlContext
+}
+
+// newNoteLoader makes a loader whose keys are note ids, fetched in ascending order.
+func newNoteLoader(batch func([]models.NoteId) (map[interface{}]interface{}, error)) *graphql.Loader {
+	return graphql.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		noteIds := make([]models.NoteId, len(keys))
+		for i, key := range keys {
+			noteIds[i] = key.(models.NoteId)
+		}
+		sort.Slice(noteIds, func(i, j int) bool { return noteIds[i] < noteIds[j] })
+
+		return batch(noteIds)
+	})
+}
+
+func graphQlContextOf(params graphql.ResolveParams) *graphQlContext {
+	return params.Context.Value(graphQlContextKey{}).(*graphQlContext)
+}
+
+// changed forgets everything fetched so far, after a mutation.
+func (graphQlContext *graphQlContext) changed() {
+	graphQlContext.users = nil
+	graphQlContext.visibleNotes = nil
+	graphQlContext.unreadNoteIds = nil
+	graphQlContext.issues = nil
+
+	for _, loader := range []*graphql.Loader{
+		graphQlContext.categories,
+		graphQlContext.replies,
+		graphQlContext.replyCounts,
+		graphQlContext.tags,
+		graphQlContext.reactions,
+	} {
+		loader.Clear()
+	}
+}
+
+func (graphQlContext *graphQlContext) getUsers() (models.UsersById, error) {
+	if graphQlContext.users == nil {
+		users, err := graphQlContext.env.Db.GetAllUsersById()
+		if err != nil {
+			return nil, err
+		}
+		graphQlContext.users = users
+	}
+
+	return graphQlContext.users, nil
+}
+
+func (graphQlContext *graphQlContext) getVisibleNotes() (models.NotesById, error) {
+	if graphQlContext.visibleNotes == nil {
+		notes, err := getNotesVisibleBy(graphQlContext.env, graphQlContext.userId)
+		if err != nil {
+			return nil, err
+		}
+
+		unreadNoteIds, err := graphQlContext.getUnreadNoteIds()
+		if err != nil {
+			return nil, err
+		}
+
+		for noteId, note := range notes {
+			note.Unread = unreadNoteIds[noteId]
+		}
+		graphQlContext.visibleNotes = notes
+	}
+
+	return graphQlContext.visibleNotes, nil
+}
+
+func (graphQlContext *graphQlContext) getUnreadNoteIds() (map[models.NoteId]bool, error) {
+	if graphQlContext.unreadNoteIds == nil {
+		unreadNoteIds, err := graphQlContext.env.Db.GetUnreadNoteIds(graphQlContext.userId)
+		if err != nil {
+			return nil, err
+		}
+		graphQlContext.unreadNoteIds = unreadNoteIds
+	}
+
+	return graphQlContext.unreadNoteIds, nil
+}
+
+func (graphQlContext *graphQlContext) getIssues() ([]*models.PublishedIssue, error) {
+	if graphQlContext.issues == nil {
+		issues, err := graphQlContext.env.Db.GetPublishedIssuesVisibleBy(graphQlContext.userId)
+		if err != nil {
+			return nil, err
+		}
+		graphQlContext.issues = issues
+	}
+
+	return graphQlContext.issues, nil
+}
+
+// getVisibleNote returns the note if the user can see it, and nil otherwise.
+func (graphQlContext *graphQlContext) getVisibleNote(noteId models.NoteId) (*graphQlNote, error) {
+	notes, err := graphQlContext.getVisibleNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	note, ok := notes[noteId]
+	if !ok {
+		return nil, nil
+	}
+
+	return &graphQlNote{id: noteId, Note: note}, nil
+}
+
+// getUser returns the user, who is nil if there's no such user.
+func (graphQlContext *graphQlContext) getUser(userId models.UserId) (*graphQlUser, error) {
+	users, err := graphQlContext.getUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	user, ok := users[userId]
+	if !ok {
+		return nil, nil
+	}
+
+	return &graphQlUser{id: userId, User: user}, nil
+}
+
+type graphQlUser struct {
+	id models.UserId
+	*models.User
+}
+
+// applyNoteOperation applies the operation as a batch of one, sending the events the single note endpoints
+// would.
+func (graphQlContext *graphQlContext) applyNoteOperation(operation *models.NoteOperation) (*models.NoteOperationResult, error) {
+	env := graphQlContext.env
+
+	results, err := env.Db.ApplyNoteOperations(graphQlContext.userId, []*models.NoteOperation{operation}, time.Now().UTC())
+	if err != nil {
+		if err == models.NoteBatchFailedError {
+			return nil, noteOperationError(results[0])
+		}
+		return nil, err
+	}
+
+	if err := emitNoteOperationEvent(env, graphQlContext.userId, operation, results[0]); err != nil {
+		return nil, err
+	}
+	graphQlContext.changed()
+
+	return results[0], nil
+}
+
+// noteOperationError returns the error a failed operation was reported with, as the sentinel error it came
+// from where there is one.
+func noteOperationError(result *models.NoteOperationResult) error {
+	for err := range errorMappings {
+		if err.Error() == result.Error {
+			return err
+		}
+	}
+
+	return errors.New(result.Error)
+}
+
+// formatGraphQlError gives the errors resolvers return the same message and code they'd have under /api/v1.
+func formatGraphQlError(err error) (string, map[string]interface{}) {
+	status, apiError := mapError(err, http.StatusInternalServerError)
+	if status >= 500 {
+		log.Print(err)
+	}
+
+	return apiError.Message, map[string]interface{}{"code": apiError.Code}
+}
+
+func parseGraphQlId(value interface{}) (int64, error) {
+	id, err := strconv.ParseInt(value.(string), 10, 64)
+	if err != nil {
+		return 0, InvalidIdError
+	}
+
+	return id, nil
+}
+
+// Schema
+
+var dateTimeType = &graphql.Scalar{
+	Name:        "DateTime",
+	Description: "A time in RFC 3339 format, in UTC.",
+	Serialize: func(value interface{}) (interface{}, error) {
+		switch value := value.(type) {
+		case time.Time:
+			return value.UTC().Format(time.RFC3339Nano), nil
+		case *time.Time:
+			return value.UTC().Format(time.RFC3339Nano), nil
+		}
+		return nil, fmt.Errorf("DateTime cannot represent %v", value)
+	},
+	ParseValue: func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("DateTime cannot represent %v", value)
+		}
+		return time.Parse(time.RFC3339Nano, text)
+	},
+}
+
+var graphQlSchema = newGraphQlSchema()
+
+func nonNull(ofType graphql.Type) graphql.Type {
+	return &graphql.NonNull{OfType: ofType}
+}
+
+func listOf(ofType graphql.Type) graphql.Type {
+	return &graphql.NonNull{OfType: &graphql.List{OfType: &graphql.NonNull{OfType: ofType}}}
+}
+
+func newGraphQlSchema() *graphql.Schema {
+	userType := &graphql.Object{Name: "User", Description: "Someone with an account."}
+	noteType := &graphql.Object{Name: "Note", Description: "A note, published or not yet."}
+	replyType := &graphql.Object{Name: "Reply", Description: "A reply to a published note, or to another reply to it."}
+	publicationType := &graphql.Object{Name: "Publication", Description: "An issue of someone's published notes."}
+	reactionType := &graphql.Object{Name: "ReactionSummary", Description: "How many readers reacted to a note with a reaction."}
+
+	isOwnNote := func(params graphql.ResolveParams) bool {
+		return params.Source.(*graphQlNote).AuthorId == graphQlContextOf(params).userId
+	}
+
+	resolveAuthor := func(authorId models.UserId, params graphql.ResolveParams) (interface{}, error) {
+		return graphQlContextOf(params).getUser(authorId)
+	}
+
+	userType.Fields = graphql.Fields{
+		"id": {Type: nonNull(graphql.ID), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*graphQlUser).id, nil
+		}},
+		"displayName": {Type: nonNull(graphql.String), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*graphQlUser).DisplayName, nil
+		}},
+		"notes": {
+			Type:        listOf(noteType),
+			Description: "The notes of the user that the current user can see.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				notes, err := graphQlContextOf(params).getVisibleNotes()
+				if err != nil {
+					return nil, err
+				}
+
+				userNotes := make([]*graphQlNote, 0)
+				for _, noteId := range sortedNoteIds(notes) {
+					if notes[noteId].AuthorId == params.Source.(*graphQlUser).id {
+						userNotes = append(userNotes, &graphQlNote{id: noteId, Note: notes[noteId]})
+					}
+				}
+				return userNotes, nil
+			},
+		},
+	}
+
+	noteType.Fields = graphql.Fields{
+		"id": {Type: nonNull(graphql.ID), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*graphQlNote).id, nil
+		}},
+		"author": {Type: nonNull(userType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return resolveAuthor(params.Source.(*graphQlNote).AuthorId, params)
+		}},
+		"content": {
+			Type:        graphql.String,
+			Description: "The content in markdown, which is null once a published note was deleted.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				note := params.Source.(*graphQlNote)
+				if note.DeletionTime != nil {
+					return nil, nil
+				}
+				return note.Content, nil
+			},
+		},
+		"contentHtml": {
+			Type:        graphql.String,
+			Description: "The content rendered as sanitised HTML, which is null once a published note was deleted.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				note := params.Source.(*graphQlNote)
+				if note.DeletionTime != nil {
+					return nil, nil
+				}
+
+				if err := renderNote(graphQlContextOf(params).env, note.id, note.Note); err != nil {
+					return nil, err
+				}
+				return note.ContentHtml, nil
+			},
+		},
+		"creationTime": {Type: nonNull(dateTimeType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*graphQlNote).CreationTime, nil
+		}},
+		"deletionTime": {Type: dateTimeType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*graphQlNote).DeletionTime, nil
+		}},
+		"revision": {Type: nonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*graphQlNote).Revision, nil
+		}},
+		"category": {Type: graphql.String, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return graphQlContextOf(params).categories.Load(params.Source.(*graphQlNote).id), nil
+		}},
+		"issueNumber": {
+			Type:        graphql.Int,
+			Description: "The issue the note was published in, counting the author's issues from 1.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if issueNumber := params.Source.(*graphQlNote).IssueNumber; issueNumber > 0 {
+					return issueNumber, nil
+				}
+				return nil, nil
+			},
+		},
+		"position": {
+			Type:        graphql.Int,
+			Description: "Where the note is in its issue, counting from 1.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if position := params.Source.(*graphQlNote).Position; position > 0 {
+					return position, nil
+				}
+				return nil, nil
+			},
+		},
+		"unread": {Type: nonNull(graphql.Boolean), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			unreadNoteIds, err := graphQlContextOf(params).getUnreadNoteIds()
+			if err != nil {
+				return nil, err
+			}
+			return unreadNoteIds[params.Source.(*graphQlNote).id], nil
+		}},
+		"replyCount": {Type: nonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return graphQlContextOf(params).replyCounts.Load(params.Source.(*graphQlNote).id), nil
+		}},
+		"replies": {
+			Type:        listOf(replyType),
+			Description: "The threads of replies to the note, oldest first.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return graphQlContextOf(params).replies.Load(params.Source.(*graphQlNote).id), nil
+			},
+		},
+		"reactions": {Type: listOf(reactionType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return graphQlContextOf(params).reactions.Load(params.Source.(*graphQlNote).id), nil
+		}},
+		"tags": {
+			Type:        &graphql.List{OfType: nonNull(graphql.String)},
+			Description: "The author's own labels for the note, which only they can see.",
+			Visible:     isOwnNote,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return graphQlContextOf(params).tags.Load(params.Source.(*graphQlNote).id), nil
+			},
+		},
+		"pinned": {
+			Type:        graphql.Boolean,
+			Description: "Whether the author pinned the note, which only they can see.",
+			Visible:     isOwnNote,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return params.Source.(*graphQlNote).PinnedTime != nil, nil
+			},
+		},
+		"archived": {
+			Type:        graphql.Boolean,
+			Description: "Whether the author archived the note, which only they can see.",
+			Visible:     isOwnNote,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return params.Source.(*graphQlNote).ArchivedTime != nil, nil
+			},
+		},
+	}
+
+	replyType.Fields = graphql.Fields{
+		"id": {Type: nonNull(graphql.ID), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.Reply).Id, nil
+		}},
+		"author": {Type: nonNull(userType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return resolveAuthor(params.Source.(*models.Reply).AuthorId, params)
+		}},
+		"content": {
+			Type:        graphql.String,
+			Description: "The content, which is null once the reply was deleted.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				reply := params.Source.(*models.Reply)
+				if reply.Deleted {
+					return nil, nil
+				}
+				return reply.Content, nil
+			},
+		},
+		"creationTime": {Type: nonNull(dateTimeType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.Reply).CreationTime, nil
+		}},
+		"lastEditTime": {Type: dateTimeType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.Reply).LastEditTime, nil
+		}},
+		"deleted": {Type: nonNull(graphql.Boolean), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.Reply).Deleted, nil
+		}},
+		"replies": {Type: listOf(replyType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.Reply).Replies, nil
+		}},
+	}
+
+	publicationType.Fields = graphql.Fields{
+		"id": {Type: nonNull(graphql.ID), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.PublishedIssue).PublicationId, nil
+		}},
+		"author": {Type: nonNull(userType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return resolveAuthor(params.Source.(*models.PublishedIssue).AuthorId, params)
+		}},
+		"issueNumber": {Type: nonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.PublishedIssue).IssueNumber, nil
+		}},
+		"title": {Type: nonNull(graphql.String), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.PublishedIssue).Title, nil
+		}},
+		"intro": {Type: nonNull(graphql.String), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.PublishedIssue).Intro, nil
+		}},
+		"creationTime": {Type: nonNull(dateTimeType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.PublishedIssue).CreationTime, nil
+		}},
+		"notes": {
+			Type:        listOf(noteType),
+			Description: "The notes of the issue in the order the author laid them out, which are withheld once it's retracted.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				issue := params.Source.(*models.PublishedIssue)
+
+				notes := make([]*graphQlNote, 0, len(issue.NoteOrder))
+				for _, noteId := range issue.NoteOrder {
+					if note, ok := issue.Notes[noteId]; ok {
+						notes = append(notes, &graphQlNote{id: noteId, Note: note})
+					}
+				}
+				return notes, nil
+			},
+		},
+		"unreadCount": {Type: nonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			unreadNoteIds, err := graphQlContextOf(params).getUnreadNoteIds()
+			if err != nil {
+				return nil, err
+			}
+
+			unreadCount := 0
+			for noteId := range params.Source.(*models.PublishedIssue).Notes {
+				if unreadNoteIds[noteId] {
+					unreadCount++
+				}
+			}
+			return unreadCount, nil
+		}},
+		"retractedTime": {Type: dateTimeType, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.PublishedIssue).RetractedTime, nil
+		}},
+		"retractionReason": {Type: graphql.String, Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			issue := params.Source.(*models.PublishedIssue)
+			if issue.RetractedTime == nil {
+				return nil, nil
+			}
+			return issue.RetractionReason, nil
+		}},
+	}
+
+	reactionType.Fields = graphql.Fields{
+		"reaction": {Type: nonNull(graphql.String), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.ReactionSummary).Reaction, nil
+		}},
+		"count": {Type: nonNull(graphql.Int), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.ReactionSummary).Count, nil
+		}},
+		"reactedByMe": {Type: nonNull(graphql.Boolean), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			return params.Source.(*models.ReactionSummary).ReactedByMe, nil
+		}},
+	}
+
+	queryType := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"viewer": {
+			Type:        nonNull(userType),
+			Description: "The current user.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				graphQlContext := graphQlContextOf(params)
+				return graphQlContext.getUser(graphQlContext.userId)
+			},
+		},
+		"users": {Type: listOf(userType), Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			graphQlContext := graphQlContextOf(params)
+			users, err := graphQlContext.getUsers()
+			if err != nil {
+				return nil, err
+			}
+
+			userIds := make([]models.UserId, 0, len(users))
+			for userId := range users {
+				userIds = append(userIds, userId)
+			}
+			sort.Slice(userIds, func(i, j int) bool { return userIds[i] < userIds[j] })
+
+			graphQlUsers := make([]*graphQlUser, len(userIds))
+			for i, userId := range userIds {
+				graphQlUsers[i] = &graphQlUser{id: userId, User: users[userId]}
+			}
+			return graphQlUsers, nil
+		}},
+		"user": {
+			Type: userType,
+			Args: map[string]*graphql.Argument{"id": {Type: nonNull(graphql.ID)}},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQlId(params.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				return graphQlContextOf(params).getUser(models.UserId(id))
+			},
+		},
+		"notes": {
+			Type: listOf(noteType),
+			Description: "The user's own unpublished notes and the published notes they may read, oldest first. " +
+				"Archived notes are left out unless they are asked for, in which case only archived notes are listed.",
+			Args: map[string]*graphql.Argument{
+				"category":   {Type: graphql.String},
+				"unanswered": {Type: graphql.Boolean, Default: false},
+				"unread":     {Type: graphql.Boolean, Default: false},
+				"pinned":     {Type: graphql.Boolean, Default: false},
+				"archived":   {Type: graphql.Boolean, Default: false},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				graphQlContext := graphQlContextOf(params)
+				notes, err := graphQlContext.getVisibleNotes()
+				if err != nil {
+					return nil, err
+				}
+
+				filter := &noteFilter{}
+				filter.category, _ = params.Args["category"].(string)
+				filter.unanswered, _ = params.Args["unanswered"].(bool)
+				filter.unread, _ = params.Args["unread"].(bool)
+				filter.pinned, _ = params.Args["pinned"].(bool)
+				filter.archived, _ = params.Args["archived"].(bool)
+
+				filteredNotes, err := filterNotes(graphQlContext.env, notes, filter)
+				if err != nil {
+					return nil, err
+				}
+
+				graphQlNotes := make([]*graphQlNote, 0, len(filteredNotes))
+				for _, noteId := range sortedNoteIds(filteredNotes) {
+					graphQlNotes = append(graphQlNotes, &graphQlNote{id: noteId, Note: filteredNotes[noteId]})
+				}
+				return graphQlNotes, nil
+			},
+		},
+		"note": {
+			Type:        noteType,
+			Description: "The note, which is null if the user can't see it.",
+			Args:        map[string]*graphql.Argument{"id": {Type: nonNull(graphql.ID)}},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQlId(params.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				return graphQlContextOf(params).getVisibleNote(models.NoteId(id))
+			},
+		},
+		"publications": {
+			Type:        listOf(publicationType),
+			Description: "The issues the user can read, newest first.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return graphQlContextOf(params).getIssues()
+			},
+		},
+	}}
+
+	// resolveNoteOperation applies an operation to the note given by id and returns it as it is afterwards
+	resolveNoteOperation := func(params graphql.ResolveParams, operation *models.NoteOperation) (interface{}, error) {
+		id, err := parseGraphQlId(params.Args["id"])
+		if err != nil {
+			return nil, err
+		}
+		operation.NoteId = models.NoteId(id)
+
+		graphQlContext := graphQlContextOf(params)
+		if _, err := graphQlContext.applyNoteOperation(operation); err != nil {
+			return nil, err
+		}
+
+		return graphQlContext.getVisibleNote(operation.NoteId)
+	}
+
+	tagsOf := func(params graphql.ResolveParams) []string {
+		tags := make([]string, 0)
+		for _, tag := range params.Args["tags"].([]interface{}) {
+			tags = append(tags, tag.(string))
+		}
+		return tags
+	}
+
+	mutationType := &graphql.Object{Name: "Mutation", Fields: graphql.Fields{
+		"createNote": {
+			Type: nonNull(noteType),
+			Args: map[string]*graphql.Argument{"content": {Type: nonNull(graphql.String)}},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				graphQlContext := graphQlContextOf(params)
+				env := graphQlContext.env
+
+				content := strings.TrimSpace(params.Args["content"].(string))
+				if len(content) == 0 {
+					return nil, EmptyNoteContentError
+				}
+
+				note := &models.Note{
+					AuthorId:     graphQlContext.userId,
+					Content:      content,
+					CreationTime: time.Now().UTC(),
+				}
+
+				noteId, err := env.Db.StoreNewNote(note)
+				if err != nil {
+					return nil, err
+				}
+
+				note.Revision = 1
+				if err := renderNote(env, noteId, note); err != nil {
+					return nil, err
+				}
+
+				emitEvent(env, &models.WebhookEvent{
+					Type:     models.NOTE_CREATED,
+					AuthorId: graphQlContext.userId,
+					NoteId:   noteId,
+					Data:     note,
+				})
+				graphQlContext.changed()
+
+				return &graphQlNote{id: noteId, Note: note}, nil
+			},
+		},
+		"updateNote": {
+			Type: nonNull(noteType),
+			Args: map[string]*graphql.Argument{
+				"id":      {Type: nonNull(graphql.ID)},
+				"content": {Type: nonNull(graphql.String)},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return resolveNoteOperation(params, &models.NoteOperation{
+					Op:      models.UPDATE_CONTENT,
+					Content: params.Args["content"].(string),
+				})
+			},
+		},
+		"deleteNote": {
+			Type:        nonNull(graphql.ID),
+			Description: "Moves the note to the trash, returning its id.",
+			Args:        map[string]*graphql.Argument{"id": {Type: nonNull(graphql.ID)}},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, err := parseGraphQlId(params.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+
+				result, err := graphQlContextOf(params).applyNoteOperation(&models.NoteOperation{
+					Op:     models.DELETE_NOTE,
+					NoteId: models.NoteId(id),
+				})
+				if err != nil {
+					return nil, err
+				}
+				return result.NoteId, nil
+			},
+		},
+		"setNoteCategory": {
+			Type:        nonNull(noteType),
+			Description: "Sets the category of the note, or clears it given null.",
+			Args: map[string]*graphql.Argument{
+				"id":       {Type: nonNull(graphql.ID)},
+				"category": {Type: graphql.String},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				category, ok := params.Args["category"].(string)
+				if !ok {
+					return resolveNoteOperation(params, &models.NoteOperation{Op: models.CLEAR_CATEGORY})
+				}
+				return resolveNoteOperation(params, &models.NoteOperation{Op: models.SET_CATEGORY, Category: category})
+			},
+		},
+		"tagNote": {
+			Type: nonNull(noteType),
+			Args: map[string]*graphql.Argument{
+				"id":   {Type: nonNull(graphql.ID)},
+				"tags": {Type: listOf(graphql.String)},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return resolveNoteOperation(params, &models.NoteOperation{Op: models.ADD_TAGS, Tags: tagsOf(params)})
+			},
+		},
+		"untagNote": {
+			Type: nonNull(noteType),
+			Args: map[string]*graphql.Argument{
+				"id":   {Type: nonNull(graphql.ID)},
+				"tags": {Type: listOf(graphql.String)},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return resolveNoteOperation(params, &models.NoteOperation{Op: models.REMOVE_TAGS, Tags: tagsOf(params)})
+			},
+		},
+		"publishIssue": {
+			Type:        nonNull(publicationType),
+			Description: "Publishes the user's unpublished notes as a new issue, in the given order if any.",
+			Args: map[string]*graphql.Argument{
+				"title":     {Type: graphql.String},
+				"intro":     {Type: graphql.String},
+				"noteOrder": {Type: &graphql.List{OfType: nonNull(graphql.ID)}},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				graphQlContext := graphQlContextOf(params)
+
+				draft := new(models.IssueDraft)
+				title, _ := params.Args["title"].(string)
+				draft.Title = strings.TrimSpace(title)
+				draft.Intro, _ = params.Args["intro"].(string)
+
+				noteOrder, _ := params.Args["noteOrder"].([]interface{})
+				for _, noteId := range noteOrder {
+					id, err := parseGraphQlId(noteId)
+					if err != nil {
+						return nil, err
+					}
+					draft.NoteOrder = append(draft.NoteOrder, models.NoteId(id))
+				}
+
+				publicationId, err := graphQlContext.env.Db.PublishIssue(graphQlContext.userId, draft)
+				if err != nil {
+					return nil, err
+				}
+
+				emitEvent(graphQlContext.env, &models.WebhookEvent{
+					Type:          models.PUBLICATION_CREATED,
+					AuthorId:      graphQlContext.userId,
+					PublicationId: publicationId,
+				})
+				graphQlContext.changed()
+
+				issues, err := graphQlContext.getIssues()
+				if err != nil {
+					return nil, err
+				}
+
+				for _, issue := range issues {
+					if issue.PublicationId == publicationId {
+						return issue, nil
+					}
+				}
+				return nil, models.NoPublicationFoundError
+			},
+		},
+		"addReply": {
+			Type:        nonNull(replyType),
+			Description: "Replies to the published note, or to one of its replies given by parentId.",
+			Args: map[string]*graphql.Argument{
+				"noteId":   {Type: nonNull(graphql.ID)},
+				"content":  {Type: nonNull(graphql.String)},
+				"parentId": {Type: graphql.ID},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				graphQlContext := graphQlContextOf(params)
+				env := graphQlContext.env
+
+				id, err := parseGraphQlId(params.Args["noteId"])
+				if err != nil {
+					return nil, err
+				}
+				noteId := models.NoteId(id)
+
+				content := strings.TrimSpace(params.Args["content"].(string))
+				if len(content) == 0 {
+					return nil, EmptyReplyContentError
+				}
+
+				if err, _ := checkPublishedNoteVisibleTo(env, noteId, graphQlContext.userId); err != nil {
+					return nil, err
+				}
+
+				reply := &models.Reply{
+					NoteId:       noteId,
+					AuthorId:     graphQlContext.userId,
+					Content:      content,
+					CreationTime: time.Now().UTC(),
+					Replies:      make([]*models.Reply, 0),
+				}
+
+				if parentId, ok := params.Args["parentId"]; ok && parentId != nil {
+					id, err := parseGraphQlId(parentId)
+					if err != nil {
+						return nil, err
+					}
+					replyParentId := models.ReplyId(id)
+					reply.ParentId = &replyParentId
+				}
+
+				replyId, err := env.Db.StoreNewReply(reply)
+				if err != nil {
+					return nil, err
+				}
+				reply.Id = replyId
+				graphQlContext.changed()
+
+				return reply, nil
+			},
+		},
+	}}
+
+	schema, err := graphql.NewSchema(queryType, mutationType)
+	if err != nil {
+		panic(err)
+	}
+	schema.FormatError = formatGraphQlError
+	schema.MaxDepth = maxGraphQlDepth
+
+	return schema
+}
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/events"
+	"github.com/atmiguel/cerealnotes/markdown"
 	"github.com/atmiguel/cerealnotes/models"
 	"github.com/atmiguel/cerealnotes/paths"
 	"github.com/dgrijalva/jwt-go"
@@ -36,6 +39,17 @@ type JwtTokenClaim struct {
 type Environment struct {
 	Db              models.Datastore
 	TokenSigningKey []byte
+	Events          events.Hub
+	// Reactions readers can react to notes with; models.DefaultReactions when empty
+	Reactions []string
+	// Markdown caches rendered note content; a nil cache renders on every request
+	Markdown *markdown.Cache
+	// Blobs holds the content of note attachments
+	Blobs blobstore.Store
+	// TrashRetention is how long deleted notes stay in the trash before they are purged
+	TrashRetention time.Duration
+	// Admins can change any category, including the ones every deployment starts with
+	Admins map[models.UserId]bool
 }
 
 type AuthenticatedRequestHandlerType func(
@@ -73,10 +87,7 @@ func AuthenticateOrRedirect(
 			}
 		} else {
 			if err, errCode := authenticatedHandlerFunc(env, responseWriter, request, userId); err != nil {
-				if errCode >= 500 {
-					log.Print(err)
-				}
-				http.Error(responseWriter, err.Error(), errCode)
+				RespondWithError(responseWriter, request, err, errCode)
 				return
 			}
 		}
@@ -91,14 +102,11 @@ func AuthenticateOrReturnUnauthorized(
 
 		if userId, err := getUserIdFromJwtToken(env, request); err != nil {
 			responseWriter.Header().Set("WWW-Authenticate", `Bearer realm="`+request.URL.Path+`"`)
-			http.Error(responseWriter, err.Error(), http.StatusUnauthorized)
+			RespondWithError(responseWriter, request, err, http.StatusUnauthorized)
 			return
 		} else {
 			if err, errCode := authenticatedHandlerFunc(env, responseWriter, request, userId); err != nil {
-				if errCode >= 500 {
-					log.Print(err)
-				}
-				http.Error(responseWriter, err.Error(), errCode)
+				RespondWithError(responseWriter, request, err, errCode)
 				return
 			}
 		}
@@ -108,10 +116,7 @@ func AuthenticateOrReturnUnauthorized(
 func WrapUnauthenticatedEndpoint(env *Environment, handler UnauthenticatedEndpointHandlerType) http.HandlerFunc {
 	return func(responseWriter http.ResponseWriter, request *http.Request) {
 		if err, errCode := handler(env, responseWriter, request); err != nil {
-			if errCode >= 500 {
-				log.Print(err)
-			}
-			http.Error(responseWriter, err.Error(), errCode)
+			RespondWithError(responseWriter, request, err, errCode)
 			return
 		}
 	}
@@ -152,41 +157,60 @@ func HandleLoginOrSignupPageRequest(
 }
 
 // API
+
+// SignupForm is the body of a POST to the user api.
+type SignupForm struct {
+	DisplayName  string `json:"displayName"`
+	EmailAddress string `json:"emailAddress"`
+	Password     string `json:"password"`
+}
+
+// LoginForm is the body of a POST to the session api.
+type LoginForm struct {
+	EmailAddress string `json:"emailAddress"`
+	Password     string `json:"password"`
+}
+
+// NoteForm is the body of a POST or PUT to the note api.
+type NoteForm struct {
+	Content string `json:"content"`
+}
+
+// NoteResponse is the response to a POST to the note api.
+type NoteResponse struct {
+	NoteId int64 `json:"noteId"`
+}
+
+// NoteCategoryForm is the body of a POST to the note category api, and its response to a GET.
+type NoteCategoryForm struct {
+	Category string `json:"category"`
+}
+
 func HandleUserApiRequest(
 	env *Environment,
 	responseWriter http.ResponseWriter,
 	request *http.Request,
 ) (error, int) {
-	type SignupForm struct {
-		DisplayName  string `json:"displayName"`
-		EmailAddress string `json:"emailAddress"`
-		Password     string `json:"password"`
-	}
-
 	switch request.Method {
 	case http.MethodPost:
 		signupForm := new(SignupForm)
 
 		if err := json.NewDecoder(request.Body).Decode(signupForm); err != nil {
-			return err, http.StatusInternalServerError
+			return err, http.StatusBadRequest
 		}
 
-		var statusCode int
 		if err := env.Db.StoreNewUser(
 			signupForm.DisplayName,
 			models.NewEmailAddress(signupForm.EmailAddress),
 			signupForm.Password,
 		); err != nil {
 			if err == models.EmailAddressAlreadyInUseError {
-				statusCode = http.StatusConflict
-			} else {
-				return err, http.StatusInternalServerError
+				return err, http.StatusConflict
 			}
-		} else {
-			statusCode = http.StatusCreated
+			return err, http.StatusInternalServerError
 		}
 
-		responseWriter.WriteHeader(statusCode)
+		responseWriter.WriteHeader(http.StatusCreated)
 
 		return nil, 0
 
@@ -206,11 +230,8 @@ func HandleUserApiRequest(
 			return err, http.StatusInternalServerError
 		}
 
-		responseWriter.Header().Set("Content-Type", "application/json")
-		responseWriter.WriteHeader(http.StatusOK)
-		fmt.Fprint(responseWriter, string(usersByIdJson))
-
-		return nil, 0
+		return respondWithConditionalJson(
+			responseWriter, request, usersByIdJson, strongETag(usersByIdJson), usersLastModified(usersById))
 
 	default:
 		return respondWithMethodNotAllowed(responseWriter, http.MethodPost, http.MethodGet)
@@ -224,11 +245,6 @@ func HandleSessionApiRequest(
 	responseWriter http.ResponseWriter,
 	request *http.Request,
 ) (error, int) {
-	type LoginForm struct {
-		EmailAddress string `json:"emailAddress"`
-		Password     string `json:"password"`
-	}
-
 	switch request.Method {
 	case http.MethodPost:
 		loginForm := new(LoginForm)
@@ -301,6 +317,9 @@ func HandleSessionApiRequest(
 	}
 }
 
+// HandlePublicationApiRequest responds to GET requests with the issues the current user can read, newest first,
+// and to POST requests by publishing the user's unpublished notes as a new issue, laid out as the optional
+// draft in the body says.
 func HandlePublicationApiRequest(
 	env *Environment,
 	responseWriter http.ResponseWriter,
@@ -308,16 +327,53 @@ func HandlePublicationApiRequest(
 	userId models.UserId,
 ) (error, int) {
 	switch request.Method {
-	case http.MethodPost:
-		if err := env.Db.PublishNotes(userId); err != nil {
+	case http.MethodGet:
+		issues, err := env.Db.GetPublishedIssuesVisibleBy(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		unreadNoteIds, err := env.Db.GetUnreadNoteIds(userId)
+		if err != nil {
 			return err, http.StatusInternalServerError
 		}
+
+		for _, issue := range issues {
+			if err := renderIssue(env, issue); err != nil {
+				return err, http.StatusInternalServerError
+			}
+
+			for noteId := range issue.Notes {
+				if unreadNoteIds[noteId] {
+					issue.UnreadCount++
+				}
+			}
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, issues)
+
+	case http.MethodPost:
+		draft, err := decodeIssueDraft(request)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		publicationId, err := env.Db.PublishIssue(userId, draft)
+		if err != nil {
+			return err, issueDraftErrorCode(err)
+		}
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:          models.PUBLICATION_CREATED,
+			AuthorId:      userId,
+			PublicationId: publicationId,
+		})
 		responseWriter.WriteHeader(http.StatusCreated)
 
 		return nil, 0
 
 	default:
-		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost)
 	}
 }
 
@@ -327,51 +383,16 @@ func HandleNoteApiRequest(
 	request *http.Request,
 	userId models.UserId,
 ) (error, int) {
-
-	type NoteForm struct {
-		Content string `json:"content"`
-	}
 	switch request.Method {
 
 	case http.MethodGet:
 
-		publishedNotes, err := env.Db.GetAllPublishedNotesVisibleBy(userId)
-		if err != nil {
-			return err, http.StatusInternalServerError
-		}
-
-		myUnpublishedNotes, err := env.Db.GetMyUnpublishedNotes(userId)
+		body, etag, lastModified, err, errCode := getNoteListing(env, request.URL.Query(), userId)
 		if err != nil {
-			return err, http.StatusInternalServerError
-		}
-
-		// fmt.Println("number of published notes")
-		// fmt.Println(len(publishedNotes))
-		// fmt.Println("number of unpublished notes")
-		// fmt.Println(len(myUnpublishedNotes))
-
-		allNotes := myUnpublishedNotes
-
-		// TODO figure out how to surface the publication number
-
-		// for publicationNumber, noteMap := range publishedNotes {
-		for _, noteMap := range publishedNotes {
-			for id, note := range noteMap {
-				allNotes[id] = note
-			}
+			return err, errCode
 		}
 
-		notesInJson, err := allNotes.ToJson()
-		if err != nil {
-			return err, http.StatusInternalServerError
-		}
-
-		responseWriter.Header().Set("Content-Type", "application/json")
-		responseWriter.WriteHeader(http.StatusOK)
-
-		fmt.Fprint(responseWriter, string(notesInJson))
-
-		return nil, 0
+		return respondWithConditionalJson(responseWriter, request, body, etag, lastModified)
 
 	case http.MethodPost:
 
@@ -396,10 +417,18 @@ func HandleNoteApiRequest(
 			return err, http.StatusInternalServerError
 		}
 
-		type NoteResponse struct {
-			NoteId int64 `json:"noteId"`
+		note.Revision = 1
+		if err := renderNote(env, noteId, note); err != nil {
+			return err, http.StatusInternalServerError
 		}
 
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_CREATED,
+			AuthorId: userId,
+			NoteId:   noteId,
+			Data:     note,
+		})
+
 		noteString, err := json.Marshal(&NoteResponse{NoteId: int64(noteId)})
 		if err != nil {
 			return err, http.StatusInternalServerError
@@ -432,7 +461,13 @@ func HandleNoteApiRequest(
 		}
 
 		if note.AuthorId != userId {
-			return NotYourNoteError, http.StatusUnauthorized
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		// If-Match holds the ETag of the note as the client last got it, so edits made since aren't lost
+		ifMatch := strings.Join(request.Header["If-Match"], ",")
+		if len(ifMatch) > 0 && !ifMatchesRevision(ifMatch, note.Revision) {
+			return PreconditionFailedError, http.StatusPreconditionFailed
 		}
 
 		content := strings.TrimSpace(noteForm.Content)
@@ -444,10 +479,29 @@ func HandleNoteApiRequest(
 			return NoChangeError, http.StatusBadRequest
 		}
 
-		if err := env.Db.UpdateNoteContent(noteId, content); err != nil {
+		if err := env.Db.UpdateNoteContent(noteId, content, note.Revision); err != nil {
+			if err == models.NoteRevisionChangedError {
+				if len(ifMatch) > 0 {
+					return PreconditionFailedError, http.StatusPreconditionFailed
+				}
+				return err, http.StatusConflict
+			}
 			return err, http.StatusInternalServerError
 		}
 
+		note.Content = content
+		note.Revision++
+		if err := renderNote(env, noteId, note); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_UPDATED,
+			AuthorId: userId,
+			NoteId:   noteId,
+			Data:     note,
+		})
+
 		responseWriter.WriteHeader(http.StatusOK)
 
 		return nil, 0
@@ -467,20 +521,27 @@ func HandleNoteApiRequest(
 		}
 
 		if _, ok := noteMap[noteId]; !ok {
-			return models.NoNoteFoundError, http.StatusInternalServerError
+			return models.NoNoteFoundError, http.StatusNotFound
 		}
 
-		err = env.Db.DeleteNoteById(noteId)
+		// deleted notes go to the trash, to be restored or purged later
+		err = env.Db.TrashNote(noteId, time.Now().UTC())
 		if err != nil {
 			return err, http.StatusInternalServerError
 		}
 
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_DELETED,
+			AuthorId: userId,
+			NoteId:   noteId,
+		})
+
 		responseWriter.WriteHeader(http.StatusOK)
 
 		return nil, 0
 
 	default:
-		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodDelete)
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
 	}
 }
 
@@ -511,11 +572,7 @@ func HandleNoteCateogryApiRequest(
 			categoryString = category.String()
 		}
 
-		type categoryObj struct {
-			Category string `json:"category"`
-		}
-
-		jsonValue, err := json.Marshal(&categoryObj{Category: categoryString})
+		jsonValue, err := json.Marshal(&NoteCategoryForm{Category: categoryString})
 		if err != nil {
 			return err, http.StatusInternalServerError
 		}
@@ -532,7 +589,7 @@ func HandleNoteCateogryApiRequest(
 		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
 		noteId := models.NoteId(id)
 
-		_, err = env.Db.GetNoteById(noteId)
+		note, err := env.Db.GetNoteById(noteId)
 		if err != nil {
 			if err == models.NoNoteFoundError {
 				return err, http.StatusBadRequest
@@ -540,8 +597,8 @@ func HandleNoteCateogryApiRequest(
 			return err, http.StatusInternalServerError
 		}
 
-		type NoteCategoryForm struct {
-			NoteCategory string `json:"category"`
+		if note.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
 		}
 
 		categoryForm := new(NoteCategoryForm)
@@ -550,7 +607,7 @@ func HandleNoteCateogryApiRequest(
 			return err, http.StatusBadRequest
 		}
 
-		category, err := models.DeserializeNoteCategory(categoryForm.NoteCategory)
+		category, err := env.Db.DeserializeNoteCategory(categoryForm.Category)
 
 		if err != nil {
 			return err, http.StatusBadRequest
@@ -560,6 +617,13 @@ func HandleNoteCateogryApiRequest(
 			return err, http.StatusInternalServerError
 		}
 
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_CATEGORIZED,
+			AuthorId: note.AuthorId,
+			NoteId:   noteId,
+			Data:     map[string]string{"category": category.String()},
+		})
+
 		responseWriter.WriteHeader(http.StatusCreated)
 		return nil, 0
 
@@ -572,16 +636,35 @@ func HandleNoteCateogryApiRequest(
 
 		noteId := models.NoteId(id)
 
+		note, err := env.Db.GetNoteById(noteId)
+		if err != nil {
+			if err == models.NoNoteFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		if note.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
 		if err := env.Db.DeleteNoteCategory(noteId); err != nil {
 			return err, http.StatusInternalServerError
 		}
 
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_CATEGORIZED,
+			AuthorId: note.AuthorId,
+			NoteId:   noteId,
+			Data:     map[string]string{"category": ""},
+		})
+
 		responseWriter.WriteHeader(http.StatusOK)
 
 		return nil, 0
 
 	default:
-		return respondWithMethodNotAllowed(responseWriter, http.MethodPost, http.MethodPut, http.MethodDelete)
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodDelete)
 	}
 }
 
@@ -599,10 +682,7 @@ func RedirectToPathHandler(
 			return
 		default:
 			err, errCode := respondWithMethodNotAllowed(responseWriter, http.MethodGet)
-			if errCode >= 500 {
-				log.Print(err)
-			}
-			http.Error(responseWriter, err.Error(), errCode)
+			RespondWithError(responseWriter, request, err, errCode)
 			return
 		}
 	}
@@ -667,3 +747,203 @@ func respondWithMethodNotAllowed(
 
 	return InvalidMethodError, http.StatusMethodNotAllowed
 }
+
+// getNotesVisibleBy returns the user's own unpublished notes along with every published note they may read.
+func getNotesVisibleBy(env *Environment, userId models.UserId) (models.NotesById, error) {
+	publishedNotes, err := env.Db.GetAllPublishedNotesVisibleBy(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	myUnpublishedNotes, err := env.Db.GetMyUnpublishedNotes(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	allNotes := myUnpublishedNotes
+
+	for _, noteMap := range publishedNotes {
+		for id, note := range noteMap {
+			allNotes[id] = note
+		}
+	}
+
+	return allNotes, nil
+}
+
+// getNoteListing renders the notes a GET to the note api returns for the query, either the single note given by
+// id or the filtered collection, along with its ETag and, for a single note, when it last changed.
+func getNoteListing(env *Environment, query url.Values, userId models.UserId) ([]byte, string, time.Time, error, int) {
+	allNotes, err := getNotesVisibleBy(env, userId)
+	if err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	// backlinks are found among every note the user can see, before any are filtered out
+	if err := addBacklinks(env, allNotes); err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	if err := markUnreadNotes(env, allNotes, userId); err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	// with an id, only that note is returned, whatever the filters
+	var singleNoteId models.NoteId
+	if idString := query.Get("id"); len(idString) > 0 {
+		id, err := strconv.ParseInt(idString, 10, 64)
+		if err != nil {
+			return nil, "", time.Time{}, err, http.StatusBadRequest
+		}
+		singleNoteId = models.NoteId(id)
+
+		note, ok := allNotes[singleNoteId]
+		if !ok {
+			return nil, "", time.Time{}, models.NoNoteFoundError, http.StatusNotFound
+		}
+		allNotes = models.NotesById{singleNoteId: note}
+	} else {
+		allNotes, err = filterNotes(env, allNotes, parseNoteFilter(query))
+		if err != nil {
+			if err == models.CannotDeserializeNoteCategoryStringError {
+				return nil, "", time.Time{}, err, http.StatusBadRequest
+			}
+			return nil, "", time.Time{}, err, http.StatusInternalServerError
+		}
+	}
+
+	if err := countReplies(env, allNotes); err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	if err := addReactions(env, allNotes, userId); err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	if err := addTags(env, allNotes); err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	if err := renderNotes(env, allNotes); err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	if singleNoteId != 0 {
+		note := allNotes[singleNoteId]
+
+		body, err := json.Marshal(note)
+		if err != nil {
+			return nil, "", time.Time{}, err, http.StatusInternalServerError
+		}
+
+		return body, noteETag(note.Revision, body), noteLastModified(note), nil, 0
+	}
+
+	body, err := allNotes.ToJson()
+	if err != nil {
+		return nil, "", time.Time{}, err, http.StatusInternalServerError
+	}
+
+	// a listing also changes when notes leave it or older ones become visible, which no note records, so it is
+	// only validated by its ETag
+	return body, strongETag(body), time.Time{}, nil, 0
+}
+
+// noteFilter narrows down the note listing. Archived notes are left out unless they are asked for, in which
+// case only archived notes are listed.
+type noteFilter struct {
+	category   string
+	unanswered bool
+	unread     bool
+	pinned     bool
+	archived   bool
+}
+
+func parseNoteFilter(query url.Values) *noteFilter {
+	return &noteFilter{
+		category:   query.Get("category"),
+		unanswered: query.Get("unanswered") == "true",
+		unread:     query.Get("unread") == "true",
+		pinned:     query.Get("pinned") == "true",
+		archived:   query.Get("archived") == "true",
+	}
+}
+
+// filterNotes keeps the notes that pass every filter given: those in the category, questions without an
+// accepted answer with unanswered, notes the user hasn't read with unread and pinned notes with pinned.
+// Archived notes are left out unless archived is given, in which case only archived notes are kept.
+func filterNotes(env *Environment, notes models.NotesById, filter *noteFilter) (models.NotesById, error) {
+	noteIds := make([]models.NoteId, 0, len(notes))
+	for noteId := range notes {
+		noteIds = append(noteIds, noteId)
+	}
+
+	categories := make(map[models.NoteId]models.NoteCategory)
+	if len(filter.category) > 0 || filter.unanswered {
+		var err error
+		categories, err = env.Db.GetNoteCategories(noteIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var category models.NoteCategory
+	if len(filter.category) > 0 {
+		var err error
+		category, err = env.Db.DeserializeNoteCategory(filter.category)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	answered := make(map[models.NoteId]bool)
+	if filter.unanswered {
+		var err error
+		answered, err = env.Db.GetAnsweredQuestionIds(noteIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filteredNotes := make(models.NotesById)
+	for noteId, note := range notes {
+		noteCategory, categorized := categories[noteId]
+
+		if len(filter.category) > 0 && (!categorized || noteCategory != category) {
+			continue
+		}
+
+		if filter.unanswered && (!categorized || noteCategory != models.QUESTION || answered[noteId]) {
+			continue
+		}
+
+		if filter.unread && !note.Unread {
+			continue
+		}
+
+		if filter.pinned && note.PinnedTime == nil {
+			continue
+		}
+
+		if (note.ArchivedTime != nil) != filter.archived {
+			continue
+		}
+
+		filteredNotes[noteId] = note
+	}
+
+	return filteredNotes, nil
+}
+
+func respondWithJson(responseWriter http.ResponseWriter, statusCode int, value interface{}) (error, int) {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(statusCode)
+	fmt.Fprint(responseWriter, string(valueJson))
+
+	return nil, 0
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+const maxImportSizeInBytes = 32 << 20
+
+var ImportTooLargeError = fmt.Errorf("Imports cannot be larger than %d bytes", maxImportSizeInBytes)
+
+var importFileExtensionsByContentType = map[string]string{
+	"text/markdown":            ".md",
+	"text/x-markdown":          ".md",
+	"application/json":         ".json",
+	"application/x-ndjson":     ".jsonl",
+	"application/jsonl":        ".jsonl",
+	"application/x-jsonlines":  ".jsonl",
+	"application/jsonlines":    ".jsonl",
+	"application/x-json-lines": ".jsonl",
+}
+
+// HandleImportApiRequest responds to POST requests by importing notes for the current user.
+// The body is either a single markdown, json or json lines document, chosen by its Content-Type,
+// or a multipart form of such files, chosen by their file extensions.
+// With dryRun=true the report is returned without storing anything.
+func HandleImportApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost:
+		dryRun := request.URL.Query().Get("dryRun") == "true"
+
+		request.Body = http.MaxBytesReader(responseWriter, request.Body, maxImportSizeInBytes)
+
+		notes, err := readImportedNotes(request)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return ImportTooLargeError, http.StatusRequestEntityTooLarge
+			}
+			return err, http.StatusBadRequest
+		}
+
+		report, err := env.Db.ImportNotes(userId, notes, dryRun)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		for _, result := range report.Results {
+			if result.Status == models.IMPORT_SUCCEEDED && !dryRun {
+				emitImportedNote(env, userId, result.NoteId)
+			}
+		}
+
+		reportJson, err := json.Marshal(report)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		statusCode := http.StatusCreated
+		if dryRun {
+			statusCode = http.StatusOK
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(statusCode)
+
+		fmt.Fprint(responseWriter, string(reportJson))
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// emitImportedNote sends the same event for an imported note as for one created through the api. The notes are
+// already stored by then, so a note that can't be read back is only logged.
+func emitImportedNote(env *Environment, userId models.UserId, noteId models.NoteId) {
+	note, err := env.Db.GetNoteById(noteId)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	if err := renderNote(env, noteId, note); err != nil {
+		log.Print(err)
+		return
+	}
+
+	emitEvent(env, &models.WebhookEvent{
+		Type:     models.NOTE_CREATED,
+		AuthorId: userId,
+		NoteId:   noteId,
+		Data:     note,
+	})
+}
+
+func readImportedNotes(request *http.Request) ([]*models.ImportedNote, error) {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, models.UnsupportedImportFormatError
+	}
+
+	if mediaType != "multipart/form-data" {
+		extension, ok := importFileExtensionsByContentType[mediaType]
+		if !ok {
+			return nil, models.UnsupportedImportFormatError
+		}
+
+		return models.ParseImportFile("body"+extension, request.Body)
+	}
+
+	multipartReader, err := request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make([]*models.ImportedNote, 0)
+	for {
+		part, err := multipartReader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(part.FileName()) == 0 {
+			continue
+		}
+
+		parsedNotes, err := models.ParseImportFile(part.FileName(), part)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", part.FileName(), err.Error())
+		}
+
+		notes = append(notes, parsedNotes...)
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/atmiguel/cerealnotes/markdown"
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// HandlePublicationPreviewApiRequest responds to POST requests with the issue the current user would publish
+// with the draft given in the body, rendered but not published.
+func HandlePublicationPreviewApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost:
+		draft, err := decodeIssueDraft(request)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		issue, err := env.Db.PreviewIssue(userId, draft)
+		if err != nil {
+			return err, issueDraftErrorCode(err)
+		}
+
+		if err := renderIssue(env, issue); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, issue)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// decodeIssueDraft reads the optional draft from the request body. Without one, the issue is untitled and
+// its notes are in the order they were written.
+func decodeIssueDraft(request *http.Request) (*models.IssueDraft, error) {
+	draft := new(models.IssueDraft)
+	if request.ContentLength != 0 {
+		if err := json.NewDecoder(request.Body).Decode(draft); err != nil {
+			return nil, err
+		}
+	}
+
+	draft.Title = strings.TrimSpace(draft.Title)
+
+	return draft, nil
+}
+
+func issueDraftErrorCode(err error) int {
+	switch err {
+	case models.NoNotesToPublishError,
+		models.IssueTitleTooLongError,
+		models.IssueIntroTooLongError,
+		models.InvalidNoteOrderError:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// renderIssue fills in the issue's intro and notes rendered as sanitised HTML. Intros aren't cached as they
+// are only rendered alongside the issue's notes.
+func renderIssue(env *Environment, issue *models.PublishedIssue) error {
+	introHtml, err := markdown.Render(issue.Intro)
+	if err != nil {
+		return err
+	}
+	issue.IntroHtml = introHtml
+
+	return renderNotes(env, issue.Notes)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/atmiguel/cerealnotes/markdown"
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// renderNote fills in the note's content rendered as sanitised HTML.
+func renderNote(env *Environment, noteId models.NoteId, note *models.Note) error {
+	// tombstones keep the revision of the content they replaced, which may still be cached
+	if note.DeletionTime != nil {
+		note.ContentHtml = ""
+		return nil
+	}
+
+	contentHtml, err := env.Markdown.Render(markdown.CacheKey{NoteId: int64(noteId), Revision: note.Revision}, note.Content)
+	if err != nil {
+		return err
+	}
+
+	note.ContentHtml = contentHtml
+
+	return nil
+}
+
+func renderNotes(env *Environment, notes models.NotesById) error {
+	for noteId, note := range notes {
+		if err := renderNote(env, noteId, note); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// HandleNoteBatchApiRequest responds to POST requests by applying the given operations to the current user's
+// notes in one go. Either every operation is applied or none are, and each gets a result in the response
+// saying whether it failed and why.
+func HandleNoteBatchApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type NoteBatchForm struct {
+		Operations []*models.NoteOperation `json:"operations"`
+	}
+
+	type NoteBatchResponse struct {
+		Applied bool                          `json:"applied"`
+		Results []*models.NoteOperationResult `json:"results"`
+	}
+
+	switch request.Method {
+	case http.MethodPost:
+		noteBatchForm := new(NoteBatchForm)
+		if err := json.NewDecoder(request.Body).Decode(noteBatchForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		results, err := env.Db.ApplyNoteOperations(userId, noteBatchForm.Operations, time.Now().UTC())
+		if err != nil {
+			switch err {
+			case models.NoteBatchFailedError:
+				return respondWithJson(responseWriter, http.StatusBadRequest, &NoteBatchResponse{Results: results})
+			case models.NoNoteOperationsError, models.TooManyNoteOperationsError:
+				return err, http.StatusBadRequest
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		// results are in the same order as the operations
+		for i, result := range results {
+			if err := emitNoteOperationEvent(env, userId, noteBatchForm.Operations[i], result); err != nil {
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, &NoteBatchResponse{Applied: true, Results: results})
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// emitNoteOperationEvent sends the same event the single note endpoints would for an applied operation.
+// Tagging has no event.
+func emitNoteOperationEvent(
+	env *Environment,
+	userId models.UserId,
+	operation *models.NoteOperation,
+	result *models.NoteOperationResult,
+) error {
+	switch result.Op {
+	case models.UPDATE_CONTENT:
+		if err := renderNote(env, result.NoteId, result.Note); err != nil {
+			return err
+		}
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_UPDATED,
+			AuthorId: userId,
+			NoteId:   result.NoteId,
+			Data:     result.Note,
+		})
+
+	case models.DELETE_NOTE:
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_DELETED,
+			AuthorId: userId,
+			NoteId:   result.NoteId,
+		})
+
+	case models.SET_CATEGORY, models.CLEAR_CATEGORY:
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_CATEGORIZED,
+			AuthorId: userId,
+			NoteId:   result.NoteId,
+			Data:     map[string]string{"category": operation.Category},
+		})
+	}
+
+	return nil
+}
+
+// addTags fills in the tags of the notes.
+func addTags(env *Environment, notes models.NotesById) error {
+	tags, err := env.Db.GetNoteTags(sortedNoteIds(notes))
+	if err != nil {
+		return err
+	}
+
+	for noteId, note := range notes {
+		note.Tags = tags[noteId]
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// GraphNode is a note in the link graph.
+type GraphNode struct {
+	Id       models.NoteId `json:"id"`
+	AuthorId models.UserId `json:"authorId"`
+	Content  string        `json:"content"`
+}
+
+// NoteGraph is every note the user can see and the [[note:ID]] links between them.
+type NoteGraph struct {
+	Nodes []*GraphNode       `json:"nodes"`
+	Edges []*models.NoteLink `json:"edges"`
+}
+
+// HandleNoteGraphApiRequest responds to GET requests with the graph of links between the notes the
+// current user can see. Links to or from notes they can't see are left out.
+func HandleNoteGraphApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		notes, err := getNotesVisibleBy(env, userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		noteIds := sortedNoteIds(notes)
+
+		links, err := env.Db.GetNoteLinksBetween(noteIds)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		graph := &NoteGraph{
+			Nodes: make([]*GraphNode, 0, len(noteIds)),
+			Edges: links,
+		}
+		for _, noteId := range noteIds {
+			note := notes[noteId]
+			graph.Nodes = append(graph.Nodes, &GraphNode{Id: noteId, AuthorId: note.AuthorId, Content: note.Content})
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, graph)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+// addBacklinks fills in which of the notes link to each of them. Only links between the given notes
+// are considered, so notes the user can't see never show up as backlinks.
+func addBacklinks(env *Environment, notes models.NotesById) error {
+	links, err := env.Db.GetNoteLinksBetween(sortedNoteIds(notes))
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		note.Backlinks = make([]models.NoteId, 0)
+	}
+
+	for _, link := range links {
+		if note, ok := notes[link.TargetNoteId]; ok {
+			note.Backlinks = append(note.Backlinks, link.SourceNoteId)
+		}
+	}
+
+	return nil
+}
+
+func sortedNoteIds(notes models.NotesById) []models.NoteId {
+	noteIds := make([]models.NoteId, 0, len(notes))
+	for noteId := range notes {
+		noteIds = append(noteIds, noteId)
+	}
+
+	sort.Slice(noteIds, func(i, j int) bool { return noteIds[i] < noteIds[j] })
+
+	return noteIds
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var MissingNoteStateError = errors.New("Either pinned or archived is required")
+
+// HandleNoteStateApiRequest responds to PUT requests by pinning, unpinning, archiving or unarchiving the
+// current user's unpublished notes given by noteIds. Either every note is updated or none are.
+func HandleNoteStateApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type NoteStateForm struct {
+		NoteIds  []models.NoteId `json:"noteIds"`
+		Pinned   *bool           `json:"pinned"`
+		Archived *bool           `json:"archived"`
+	}
+
+	switch request.Method {
+	case http.MethodPut:
+		noteStateForm := new(NoteStateForm)
+		if err := json.NewDecoder(request.Body).Decode(noteStateForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if noteStateForm.Pinned == nil && noteStateForm.Archived == nil {
+			return MissingNoteStateError, http.StatusBadRequest
+		}
+
+		if err := env.Db.UpdateNoteStates(
+			userId,
+			noteStateForm.NoteIds,
+			noteStateForm.Pinned,
+			noteStateForm.Archived,
+			time.Now().UTC(),
+		); err != nil {
+			switch err {
+			case models.PinnedAndArchivedError:
+				return err, http.StatusBadRequest
+			case models.NoNoteFoundError:
+				return err, http.StatusNotFound
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPut)
+	}
+}
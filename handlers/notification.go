@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// HandleNotificationPreferenceApiRequest responds to GET requests with the user's notification
+// preferences and to PUT requests by changing how often they are notified.
+func HandleNotificationPreferenceApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type PreferencesForm struct {
+		Frequency string `json:"frequency"`
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		preferences, err := env.Db.GetNotificationPreferences(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		preferencesJson, err := json.Marshal(preferences)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+		fmt.Fprint(responseWriter, string(preferencesJson))
+
+		return nil, 0
+
+	case http.MethodPut:
+		preferencesForm := new(PreferencesForm)
+		if err := json.NewDecoder(request.Body).Decode(preferencesForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		frequency, err := models.DeserializeNotificationFrequency(preferencesForm.Frequency)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.SetNotificationFrequency(userId, frequency); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPut)
+	}
+}
+
+// HandleUnsubscribeRequest responds to GET requests from the link in notification emails by turning notifications off.
+func HandleUnsubscribeRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		if err := env.Db.UnsubscribeFromNotifications(request.URL.Query().Get("token")); err != nil {
+			if err == models.NoNotificationPreferencesFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+		fmt.Fprint(responseWriter, "You will no longer receive notification emails from CerealNotes")
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/openapi"
+	"github.com/atmiguel/cerealnotes/paths"
+)
+
+const apiTitle = "CerealNotes"
+const apiVersion = "1"
+const sessionSecurityScheme = "session"
+
+// apiOperation describes what one method of a versioned api resource takes and responds with. The schemas
+// come from the same types the handler decodes and encodes, so the document can't drift from them.
+type apiOperation struct {
+	method string
+	path   string
+	// legacyPath is the deprecated alias, which takes the path parameters in the query string
+	legacyPath string
+	id         string
+	summary    string
+	// public operations can be called without a session
+	public bool
+	query  []*openapi.Parameter
+	// request is the type of the body, if there is one
+	request         interface{}
+	optionalRequest bool
+	status          int
+	// response is the type of the body of a successful response, if there is one
+	response     interface{}
+	textResponse bool
+	// conditional GETs send validators and answer 304 when the client's copy is current, and conditional PUTs
+	// take If-Match
+	conditional bool
+	// errors describe the error statuses the operation is known for; any other error is its default
+	errors map[int]string
+}
+
+var apiOperations = []*apiOperation{
+	{
+		method:      http.MethodGet,
+		path:        paths.UsersV1,
+		legacyPath:  paths.UserApi,
+		id:          "listUsers",
+		summary:     "Returns every user, by id.",
+		status:      http.StatusOK,
+		response:    models.UsersById{},
+		conditional: true,
+		errors:      map[int]string{http.StatusUnauthorized: "There is no session."},
+	},
+	{
+		method:     http.MethodPost,
+		path:       paths.UsersV1,
+		legacyPath: paths.UserApi,
+		id:         "createUser",
+		summary:    "Signs up a new user.",
+		public:     true,
+		request:    SignupForm{},
+		status:     http.StatusCreated,
+		errors:     map[int]string{http.StatusConflict: "The email address is already in use."},
+	},
+	{
+		method:     http.MethodPost,
+		path:       paths.SessionV1,
+		legacyPath: paths.SessionApi,
+		id:         "createSession",
+		summary:    "Logs in, setting the session cookie.",
+		public:     true,
+		request:    LoginForm{},
+		status:     http.StatusCreated,
+		errors:     map[int]string{http.StatusUnauthorized: "The email address or password is wrong."},
+	},
+	{
+		method:       http.MethodDelete,
+		path:         paths.SessionV1,
+		legacyPath:   paths.SessionApi,
+		id:           "deleteSession",
+		summary:      "Logs out, expiring the session cookie.",
+		public:       true,
+		status:       http.StatusOK,
+		textResponse: true,
+	},
+	{
+		method:     http.MethodGet,
+		path:       paths.NotesV1,
+		legacyPath: paths.NoteApi,
+		id:         "listNotes",
+		summary:    "Returns the notes the current user can see, by id.",
+		query: []*openapi.Parameter{
+			queryParameter("category", "string", "Only notes in the category"),
+			queryParameter("unanswered", "boolean", "Only questions without an accepted answer"),
+			queryParameter("unread", "boolean", "Only the published notes of others the user hasn't read"),
+			queryParameter("pinned", "boolean", "Only the user's pinned notes"),
+			queryParameter("archived", "boolean", "Only the user's archived notes"),
+		},
+		status:      http.StatusOK,
+		response:    models.NotesById{},
+		conditional: true,
+		errors:      map[int]string{http.StatusBadRequest: "The category doesn't exist."},
+	},
+	{
+		method:     http.MethodPost,
+		path:       paths.NotesV1,
+		legacyPath: paths.NoteApi,
+		id:         "createNote",
+		summary:    "Writes a new unpublished note.",
+		request:    NoteForm{},
+		status:     http.StatusCreated,
+		response:   NoteResponse{},
+		errors:     map[int]string{http.StatusBadRequest: "The content is empty."},
+	},
+	{
+		method:      http.MethodGet,
+		path:        paths.NoteV1,
+		legacyPath:  paths.NoteApi,
+		id:          "getNote",
+		summary:     "Returns one of the notes the current user can see.",
+		status:      http.StatusOK,
+		response:    models.Note{},
+		conditional: true,
+		errors:      map[int]string{http.StatusNotFound: "The user can't see a note with that id."},
+	},
+	{
+		method:      http.MethodPut,
+		path:        paths.NoteV1,
+		legacyPath:  paths.NoteApi,
+		id:          "updateNote",
+		summary:     "Changes the content of one of the current user's notes.",
+		request:     NoteForm{},
+		status:      http.StatusOK,
+		conditional: true,
+		errors: map[int]string{
+			http.StatusBadRequest:         "The content is empty or unchanged.",
+			http.StatusForbidden:          "The note is someone else's.",
+			http.StatusNotFound:           "There is no note with that id.",
+			http.StatusConflict:           "The note was changed while this change was being made.",
+			http.StatusPreconditionFailed: "The note has been edited since the ETag in If-Match.",
+		},
+	},
+	{
+		method:     http.MethodDelete,
+		path:       paths.NoteV1,
+		legacyPath: paths.NoteApi,
+		id:         "deleteNote",
+		summary:    "Moves one of the current user's notes to the trash.",
+		status:     http.StatusOK,
+		errors:     map[int]string{http.StatusNotFound: "The user has no note with that id."},
+	},
+	{
+		method:     http.MethodGet,
+		path:       paths.NoteCategoryV1,
+		legacyPath: paths.NoteCategoryApi,
+		id:         "getNoteCategory",
+		summary:    "Returns the category of a note, which is empty if it has none.",
+		status:     http.StatusOK,
+		response:   NoteCategoryForm{},
+	},
+	{
+		method:     http.MethodPost,
+		path:       paths.NoteCategoryV1,
+		legacyPath: paths.NoteCategoryApi,
+		id:         "setNoteCategory",
+		summary:    "Puts one of the current user's notes in a category.",
+		request:    NoteCategoryForm{},
+		status:     http.StatusCreated,
+		errors: map[int]string{
+			http.StatusBadRequest: "The category doesn't exist.",
+			http.StatusForbidden:  "The note is someone else's.",
+			http.StatusNotFound:   "There is no note with that id.",
+		},
+	},
+	{
+		method:     http.MethodDelete,
+		path:       paths.NoteCategoryV1,
+		legacyPath: paths.NoteCategoryApi,
+		id:         "clearNoteCategory",
+		summary:    "Takes one of the current user's notes out of its category.",
+		status:     http.StatusOK,
+		errors: map[int]string{
+			http.StatusForbidden: "The note is someone else's.",
+			http.StatusNotFound:  "There is no note with that id.",
+		},
+	},
+	{
+		method:     http.MethodGet,
+		path:       paths.PublicationsV1,
+		legacyPath: paths.PublicationApi,
+		id:         "listPublications",
+		summary:    "Returns the issues the current user can read, newest first.",
+		status:     http.StatusOK,
+		response:   []*models.PublishedIssue{},
+	},
+	{
+		method:          http.MethodPost,
+		path:            paths.PublicationsV1,
+		legacyPath:      paths.PublicationApi,
+		id:              "publishIssue",
+		summary:         "Publishes the current user's unpublished notes as a new issue, laid out as the draft says.",
+		request:         models.IssueDraft{},
+		optionalRequest: true,
+		status:          http.StatusCreated,
+		errors:          map[int]string{http.StatusBadRequest: "There are no notes to publish, or the draft is invalid."},
+	},
+}
+
+// componentDescriptions describe the schemas, which reflection can't do.
+var componentDescriptions = map[string]string{
+	"User":             "A user as other users see them.",
+	"SignupForm":       "What a new user signs up with.",
+	"LoginForm":        "The credentials to log in with.",
+	"Note":             "A note as the current user sees it.",
+	"NoteForm":         "The content of a note.",
+	"NoteResponse":     "The id of a new note.",
+	"NoteCategoryForm": "The category of a note, which is empty for none.",
+	"ReactionSummary":  "How many readers reacted to a note with one reaction.",
+	"PublishedIssue":   "An issue of published notes, with the notes keyed by id.",
+	"IssueDraft":       "How to lay out an issue.",
+	"ApiErrorEnvelope": "The body of every error response under /api/v1.",
+	"ApiError":         "An error, whose code is meant for programs and message for people.",
+}
+
+// ApiDocument returns the OpenAPI document describing the api. The unversioned paths are described as the
+// deprecated aliases they are, with their plain text errors.
+func ApiDocument() *openapi.Document {
+	document := openapi.NewDocument(apiTitle, apiVersion)
+	document.Info.Description = "Resources are under " + paths.ApiV1 + ", which responds to errors with " +
+		"an ApiErrorEnvelope. The unversioned paths are deprecated aliases that take path parameters in the " +
+		"query string and respond to errors in plain text."
+	document.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		sessionSecurityScheme: {Type: "apiKey", In: "cookie", Name: cerealNotesCookieName},
+	}
+	document.Security = []map[string][]string{{sessionSecurityScheme: {}}}
+
+	errorSchema := document.SchemaOf(ApiErrorEnvelope{})
+
+	for _, apiOperation := range apiOperations {
+		document.AddOperation(apiOperation.method, apiOperation.path, apiOperation.describe(document, errorSchema))
+	}
+
+	// several resources can share a deprecated path, as the notes and each note do, so their descriptions
+	// are merged
+	for _, apiOperation := range apiOperations {
+		legacyOperation := apiOperation.describeLegacy(document)
+
+		existing, ok := document.Paths[apiOperation.legacyPath][strings.ToLower(apiOperation.method)]
+		if !ok {
+			document.AddOperation(apiOperation.method, apiOperation.legacyPath, legacyOperation)
+			continue
+		}
+
+		mergeLegacyOperations(existing, legacyOperation)
+	}
+
+	for name, description := range componentDescriptions {
+		if schema, ok := document.Components.Schemas[name]; ok {
+			schema.Description = description
+		}
+	}
+
+	return document
+}
+
+func (apiOperation *apiOperation) describe(document *openapi.Document, errorSchema *openapi.Schema) *openapi.Operation {
+	operation := apiOperation.describeWith(document, "application/json", errorSchema)
+	operation.OperationId = apiOperation.id
+	operation.Summary = apiOperation.summary
+
+	for _, segment := range strings.Split(apiOperation.path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			operation.Parameters = append(operation.Parameters, &openapi.Parameter{
+				Name:     segment[1 : len(segment)-1],
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "integer", Format: "int64"},
+			})
+		}
+	}
+	operation.Parameters = append(operation.Parameters, apiOperation.queryParameters()...)
+	operation.Parameters = append(operation.Parameters, apiOperation.headerParameters()...)
+
+	return operation
+}
+
+// describeLegacy describes the operation at its deprecated path, where the path parameters are in the query.
+func (apiOperation *apiOperation) describeLegacy(document *openapi.Document) *openapi.Operation {
+	operation := apiOperation.describeWith(document, "text/plain", &openapi.Schema{Type: "string"})
+	operation.OperationId = apiOperation.id + "Deprecated"
+	operation.Summary = "Deprecated alias of " + apiOperation.method + " " + apiOperation.path + "."
+	operation.Deprecated = true
+
+	for _, segment := range strings.Split(apiOperation.path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			operation.Parameters = append(operation.Parameters, &openapi.Parameter{
+				Name:     segment[1 : len(segment)-1],
+				In:       "query",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "integer", Format: "int64"},
+			})
+		}
+	}
+	operation.Parameters = append(operation.Parameters, apiOperation.queryParameters()...)
+	operation.Parameters = append(operation.Parameters, apiOperation.headerParameters()...)
+
+	return operation
+}
+
+// queryParameters returns copies of the query parameters, as each path's are changed on their own.
+func (apiOperation *apiOperation) queryParameters() []*openapi.Parameter {
+	parameters := make([]*openapi.Parameter, len(apiOperation.query))
+	for i, parameter := range apiOperation.query {
+		parameterCopy := *parameter
+		parameters[i] = &parameterCopy
+	}
+
+	return parameters
+}
+
+// headerParameters returns the conditional request headers the operation takes.
+func (apiOperation *apiOperation) headerParameters() []*openapi.Parameter {
+	if !apiOperation.conditional {
+		return nil
+	}
+
+	if apiOperation.method == http.MethodGet {
+		return []*openapi.Parameter{
+			headerParameter("If-None-Match", "ETags of the copies the client has, to get 304 if one is current"),
+			headerParameter("If-Modified-Since", "The Last-Modified of the client's copy, to get 304 if nothing has changed since"),
+		}
+	}
+
+	return []*openapi.Parameter{
+		headerParameter("If-Match", "The ETag of the copy the change is based on, to get 412 if it has been edited since"),
+	}
+}
+
+// describeWith describes the operation with its errors in the given media type.
+func (apiOperation *apiOperation) describeWith(
+	document *openapi.Document,
+	errorMediaType string,
+	errorSchema *openapi.Schema,
+) *openapi.Operation {
+	operation := &openapi.Operation{Responses: make(map[string]*openapi.Response)}
+
+	if apiOperation.public {
+		// an empty requirement lets the operation be called without any
+		operation.Security = []map[string][]string{{}}
+	}
+
+	if apiOperation.request != nil {
+		operation.RequestBody = &openapi.RequestBody{
+			Required: !apiOperation.optionalRequest,
+			Content:  jsonContent(document.SchemaOf(apiOperation.request)),
+		}
+	}
+
+	success := &openapi.Response{Description: http.StatusText(apiOperation.status)}
+	switch {
+	case apiOperation.response != nil:
+		success.Content = jsonContent(document.SchemaOf(apiOperation.response))
+	case apiOperation.textResponse:
+		success.Content = map[string]*openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}}
+	}
+	operation.Responses[strconv.Itoa(apiOperation.status)] = success
+
+	if apiOperation.conditional && apiOperation.method == http.MethodGet {
+		success.Headers = validatorHeaders()
+		operation.Responses[strconv.Itoa(http.StatusNotModified)] = &openapi.Response{
+			Description: "The client's copy is current.",
+			Headers:     validatorHeaders(),
+		}
+	}
+
+	errorContent := map[string]*openapi.MediaType{errorMediaType: {Schema: errorSchema}}
+	for status, description := range apiOperation.errors {
+		operation.Responses[strconv.Itoa(status)] = &openapi.Response{Description: description, Content: errorContent}
+	}
+	operation.Responses["default"] = &openapi.Response{Description: "The request failed.", Content: errorContent}
+
+	return operation
+}
+
+// mergeLegacyOperations folds the description of another resource's method into the operation at their shared
+// deprecated path. Parameters only one of them takes become optional, and where both respond with the same
+// status, the body can be either.
+func mergeLegacyOperations(operation *openapi.Operation, other *openapi.Operation) {
+	operation.Summary = strings.TrimSuffix(operation.Summary, ".") + " and " +
+		strings.TrimPrefix(other.Summary, "Deprecated alias of ")
+
+	parameters := make(map[string]*openapi.Parameter)
+	for _, parameter := range operation.Parameters {
+		parameters[parameter.Name] = parameter
+	}
+
+	otherParameters := make(map[string]bool)
+	for _, parameter := range other.Parameters {
+		otherParameters[parameter.Name] = true
+		if _, ok := parameters[parameter.Name]; !ok {
+			parameter.Required = false
+			operation.Parameters = append(operation.Parameters, parameter)
+		}
+	}
+
+	for _, parameter := range parameters {
+		if !otherParameters[parameter.Name] {
+			parameter.Required = false
+		}
+	}
+
+	for status, response := range other.Responses {
+		existing, ok := operation.Responses[status]
+		if !ok {
+			operation.Responses[status] = response
+			continue
+		}
+
+		for mediaType, media := range response.Content {
+			existingMedia, ok := existing.Content[mediaType]
+			if !ok || reflect.DeepEqual(existingMedia.Schema, media.Schema) {
+				continue
+			}
+
+			existingMedia.Schema = &openapi.Schema{OneOf: []*openapi.Schema{existingMedia.Schema, media.Schema}}
+		}
+	}
+}
+
+func queryParameter(name string, schemaType string, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: schemaType}}
+}
+
+func headerParameter(name string, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "header", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+// validatorHeaders describes the headers conditional GETs respond with.
+func validatorHeaders() map[string]*openapi.Header {
+	return map[string]*openapi.Header{
+		"ETag": {
+			Description: "A strong entity tag for the body",
+			Schema:      &openapi.Schema{Type: "string"},
+		},
+		"Last-Modified": {
+			Description: "When the resources in the body last changed, as far as they record; note listings leave it out",
+			Schema:      &openapi.Schema{Type: "string"},
+		},
+	}
+}
+
+func jsonContent(schema *openapi.Schema) map[string]*openapi.MediaType {
+	return map[string]*openapi.MediaType{"application/json": {Schema: schema}}
+}
+
+// HandleOpenApiRequest responds to GET requests with the OpenAPI document describing the api.
+func HandleOpenApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		return respondWithJson(responseWriter, http.StatusOK, ApiDocument())
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var NotPredictionJudgeError = errors.New("Only the author or a judge of this prediction can resolve it")
+var MissingResolutionDateError = errors.New("Predictions need a resolution date")
+var UnknownJudgeError = errors.New("Judges must be existing users")
+
+// HandlePredictionApiRequest responds to GET requests with the prediction of the note given by id, if the
+// current user is its author or may read the issue it was published in, and to PUT requests by setting the
+// stated probability, resolution date and judges of the current user's note, which also categorizes it as a
+// prediction.
+func HandlePredictionApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type PredictionForm struct {
+		Probability    float64         `json:"probability"`
+		ResolutionDate time.Time       `json:"resolutionDate"`
+		JudgeIds       []models.UserId `json:"judgeIds"`
+	}
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	noteId := models.NoteId(id)
+
+	switch request.Method {
+	case http.MethodGet:
+		prediction, err := env.Db.GetPrediction(noteId)
+		if err != nil {
+			if err == models.NoPredictionFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		if prediction.AuthorId != userId {
+			if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+				return err, errCode
+			}
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, prediction)
+
+	case http.MethodPut:
+		predictionForm := new(PredictionForm)
+		if err := json.NewDecoder(request.Body).Decode(predictionForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if predictionForm.ResolutionDate.IsZero() {
+			return MissingResolutionDateError, http.StatusBadRequest
+		}
+
+		note, err := env.Db.GetNoteById(noteId)
+		if err != nil {
+			if err == models.NoNoteFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		if note.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		if len(predictionForm.JudgeIds) > 0 {
+			usersById, err := env.Db.GetAllUsersById()
+			if err != nil {
+				return err, http.StatusInternalServerError
+			}
+
+			for _, judgeId := range predictionForm.JudgeIds {
+				if _, ok := usersById[judgeId]; !ok {
+					return UnknownJudgeError, http.StatusBadRequest
+				}
+			}
+		}
+
+		prediction := &models.Prediction{
+			NoteId:         noteId,
+			AuthorId:       userId,
+			Probability:    predictionForm.Probability,
+			ResolutionDate: predictionForm.ResolutionDate,
+			JudgeIds:       predictionForm.JudgeIds,
+		}
+
+		if err := env.Db.StorePrediction(prediction); err != nil {
+			switch err {
+			case models.InvalidProbabilityError:
+				return err, http.StatusBadRequest
+			case models.PredictionLockedError:
+				return err, http.StatusConflict
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPut)
+	}
+}
+
+// HandlePredictionResolutionApiRequest responds to POST requests by resolving the prediction of the
+// note given by id as true, false or ambiguous. Only its author and judges may resolve it, and judges only once
+// they may read the issue it was published in.
+func HandlePredictionResolutionApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type ResolutionForm struct {
+		Outcome string `json:"outcome"`
+	}
+
+	switch request.Method {
+	case http.MethodPost:
+		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		noteId := models.NoteId(id)
+
+		resolutionForm := new(ResolutionForm)
+		if err := json.NewDecoder(request.Body).Decode(resolutionForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		outcome, err := models.DeserializePredictionOutcome(resolutionForm.Outcome)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		prediction, err := env.Db.GetPrediction(noteId)
+		if err != nil {
+			if err == models.NoPredictionFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		if prediction.AuthorId != userId {
+			if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+				return err, errCode
+			}
+		}
+
+		if !prediction.CanBeResolvedBy(userId) {
+			return NotPredictionJudgeError, http.StatusForbidden
+		}
+
+		resolvedTime := time.Now().UTC()
+		if err := env.Db.ResolvePrediction(noteId, userId, outcome, resolvedTime); err != nil {
+			if err == models.PredictionAlreadyResolvedError {
+				return err, http.StatusConflict
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		prediction.Outcome = outcome
+		prediction.ResolverId = userId
+		prediction.ResolvedTime = &resolvedTime
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.PREDICTION_RESOLVED,
+			AuthorId: prediction.AuthorId,
+			NoteId:   noteId,
+			Data:     prediction,
+		})
+
+		return respondWithJson(responseWriter, http.StatusOK, prediction)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// HandleCalibrationApiRequest responds to GET requests with the Brier score and calibration buckets
+// of the resolved predictions of the user given by userId, or of the current user. The predictions of others
+// only count once the current user may read the issues they were published in.
+func HandleCalibrationApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		calibratedUserId := userId
+		if userIdString := request.URL.Query().Get("userId"); len(userIdString) > 0 {
+			id, err := strconv.ParseInt(userIdString, 10, 64)
+			if err != nil {
+				return err, http.StatusBadRequest
+			}
+			calibratedUserId = models.UserId(id)
+		}
+
+		predictions, err := env.Db.GetUsersResolvedPredictions(calibratedUserId, userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, models.CalculateCalibration(predictions))
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var UnknownReactionError = errors.New("That reaction is not one of the reactions offered here")
+
+// HandleReactionApiRequest responds to GET requests with the reactions readers can use, and to POST
+// requests by toggling the current user's reaction to the published note given by noteId. POST
+// responds with the note's updated reaction counts.
+func HandleReactionApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type ReactionForm struct {
+		Reaction string `json:"reaction"`
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		return respondWithJson(responseWriter, http.StatusOK, availableReactions(env))
+
+	case http.MethodPost:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		reactionForm := new(ReactionForm)
+		if err := json.NewDecoder(request.Body).Decode(reactionForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if !isAvailableReaction(env, reactionForm.Reaction) {
+			return UnknownReactionError, http.StatusBadRequest
+		}
+
+		if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+			return err, errCode
+		}
+
+		if _, err := env.Db.ToggleReaction(noteId, userId, reactionForm.Reaction); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		summaries, err := env.Db.GetReactionSummaries([]models.NoteId{noteId}, userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		reactions := summaries[noteId]
+		if reactions == nil {
+			reactions = make([]*models.ReactionSummary, 0)
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, reactions)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost)
+	}
+}
+
+func availableReactions(env *Environment) []string {
+	if len(env.Reactions) == 0 {
+		return models.DefaultReactions
+	}
+
+	return env.Reactions
+}
+
+func isAvailableReaction(env *Environment, reaction string) bool {
+	for _, availableReaction := range availableReactions(env) {
+		if reaction == availableReaction {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addReactions fills in the reaction counts of each of the notes, counting them all in one query.
+func addReactions(env *Environment, notes models.NotesById, userId models.UserId) error {
+	noteIds := make([]models.NoteId, 0, len(notes))
+	for noteId := range notes {
+		noteIds = append(noteIds, noteId)
+	}
+
+	summaries, err := env.Db.GetReactionSummaries(noteIds, userId)
+	if err != nil {
+		return err
+	}
+
+	for noteId, note := range notes {
+		note.Reactions = summaries[noteId]
+	}
+
+	return nil
+}
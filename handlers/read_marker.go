@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// HandleNoteReadApiRequest responds to POST requests by marking the published note given by id as read by the
+// current user, and to DELETE requests by marking it unread again.
+func HandleNoteReadApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost, http.MethodDelete:
+		noteId, err := parseNoteIdParameter(request, "id")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+			return err, errCode
+		}
+
+		if request.Method == http.MethodPost {
+			err = env.Db.MarkNoteRead(userId, noteId, time.Now().UTC())
+		} else {
+			err = env.Db.MarkNoteUnread(userId, noteId)
+		}
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// HandlePublicationReadApiRequest responds to POST requests by marking every note of the issue given by id as
+// read by the current user.
+func HandlePublicationReadApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost:
+		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.MarkPublicationRead(userId, models.PublicationId(id), time.Now().UTC()); err != nil {
+			if err == models.NoPublicationFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// HandleUnreadApiRequest responds to GET requests with how many notes the current user hasn't read yet, in
+// total, by author and by category.
+func HandleUnreadApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		counts, err := env.Db.GetUnreadCounts(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, counts)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+// HandleMarkAllReadApiRequest responds to POST requests by marking every note the current user can read as
+// read.
+func HandleMarkAllReadApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost:
+		if err := env.Db.MarkAllRead(userId, time.Now().UTC()); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// markUnreadNotes flags the notes the user hasn't read yet.
+func markUnreadNotes(env *Environment, notes models.NotesById, userId models.UserId) error {
+	unreadNoteIds, err := env.Db.GetUnreadNoteIds(userId)
+	if err != nil {
+		return err
+	}
+
+	for noteId, note := range notes {
+		note.Unread = unreadNoteIds[noteId]
+	}
+
+	return nil
+}
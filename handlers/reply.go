@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var EmptyReplyContentError = errors.New("Reply content cannot be empty or just whitespace")
+var NotYourReplyError = errors.New("Only the author of a reply can change it")
+
+// HandleReplyApiRequest responds to GET requests with the threaded replies to the published note given by
+// noteId, and to POST requests by replying to it, or to one of its replies given by parentId. Replies can be
+// read and written by anyone who can read the note. PUT and DELETE requests edit and delete the current
+// user's reply given by id.
+func HandleReplyApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type ReplyForm struct {
+		Content  string          `json:"content"`
+		ParentId *models.ReplyId `json:"parentId"`
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+			return err, errCode
+		}
+
+		replies, err := env.Db.GetReplies(noteId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, models.NestReplies(replies))
+
+	case http.MethodPost:
+		noteId, err := parseNoteIdParameter(request, "noteId")
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		replyForm := new(ReplyForm)
+		if err := json.NewDecoder(request.Body).Decode(replyForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		content := strings.TrimSpace(replyForm.Content)
+		if len(content) == 0 {
+			return EmptyReplyContentError, http.StatusBadRequest
+		}
+
+		if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+			return err, errCode
+		}
+
+		reply := &models.Reply{
+			NoteId:       noteId,
+			ParentId:     replyForm.ParentId,
+			AuthorId:     userId,
+			Content:      content,
+			CreationTime: time.Now().UTC(),
+			Replies:      make([]*models.Reply, 0),
+		}
+
+		replyId, err := env.Db.StoreNewReply(reply)
+		if err != nil {
+			switch err {
+			case models.NoReplyFoundError, models.ReplyParentMismatchError, models.ReplyDeletedError:
+				return err, http.StatusBadRequest
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+		reply.Id = replyId
+
+		return respondWithJson(responseWriter, http.StatusCreated, reply)
+
+	case http.MethodPut:
+		reply, err, errCode := getOwnReply(env, request, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		replyForm := new(ReplyForm)
+		if err := json.NewDecoder(request.Body).Decode(replyForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		content := strings.TrimSpace(replyForm.Content)
+		if len(content) == 0 {
+			return EmptyReplyContentError, http.StatusBadRequest
+		}
+
+		if err := env.Db.UpdateReplyContent(reply.Id, content); err != nil {
+			if err == models.NoReplyFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	case http.MethodDelete:
+		reply, err, errCode := getOwnReply(env, request, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if err := env.Db.DeleteReply(reply.Id); err != nil {
+			if err == models.NoReplyFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func parseNoteIdParameter(request *http.Request, name string) (models.NoteId, error) {
+	id, err := strconv.ParseInt(request.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return models.NoteId(id), nil
+}
+
+// getOwnReply returns the reply given by id, as long as the user wrote it.
+func getOwnReply(env *Environment, request *http.Request, userId models.UserId) (*models.Reply, error, int) {
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return nil, err, http.StatusBadRequest
+	}
+
+	reply, err := env.Db.GetReply(models.ReplyId(id))
+	if err != nil {
+		if err == models.NoReplyFoundError {
+			return nil, err, http.StatusNotFound
+		}
+		return nil, err, http.StatusInternalServerError
+	}
+
+	if reply.AuthorId != userId {
+		return nil, NotYourReplyError, http.StatusForbidden
+	}
+
+	return reply, nil, 0
+}
+
+// countReplies fills in how many replies each of the notes has.
+func countReplies(env *Environment, notes models.NotesById) error {
+	noteIds := make([]models.NoteId, 0, len(notes))
+	for noteId := range notes {
+		noteIds = append(noteIds, noteId)
+	}
+
+	counts, err := env.Db.GetReplyCounts(noteIds)
+	if err != nil {
+		return err
+	}
+
+	for noteId, note := range notes {
+		note.ReplyCount = counts[noteId]
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// HandlePublicationRetractionApiRequest responds to POST requests by retracting the current user's issue given
+// by id. Within the grace period the latest issue is unpublished, otherwise it is marked as retracted with
+// the given reason. Responds with the outcome.
+func HandlePublicationRetractionApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type RetractionForm struct {
+		Reason string `json:"reason"`
+	}
+
+	type RetractionResponse struct {
+		Outcome models.RetractionOutcome `json:"outcome"`
+	}
+
+	switch request.Method {
+	case http.MethodPost:
+		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		publicationId := models.PublicationId(id)
+
+		retractionForm := new(RetractionForm)
+		if request.ContentLength != 0 {
+			if err := json.NewDecoder(request.Body).Decode(retractionForm); err != nil {
+				return err, http.StatusBadRequest
+			}
+		}
+
+		outcome, err := env.Db.RetractPublication(
+			userId,
+			publicationId,
+			strings.TrimSpace(retractionForm.Reason),
+			time.Now().UTC())
+		if err != nil {
+			switch err {
+			case models.NoPublicationFoundError:
+				return err, http.StatusNotFound
+			case models.PublicationAlreadyRetractedError:
+				return err, http.StatusConflict
+			case models.RetractionReasonRequiredError:
+				return err, http.StatusBadRequest
+			default:
+				return err, http.StatusInternalServerError
+			}
+		}
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:          models.PUBLICATION_RETRACTED,
+			AuthorId:      userId,
+			PublicationId: publicationId,
+			Data:          &RetractionResponse{Outcome: outcome},
+		})
+
+		return respondWithJson(responseWriter, http.StatusOK, &RetractionResponse{Outcome: outcome})
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+var MissingSourceError = errors.New("Either a sourceId or a source is required")
+
+// HandleSourceApiRequest responds to GET requests with the source given by id, and to POST requests
+// by storing a source. If the same work was already stored, that shared source is returned instead.
+func HandleSourceApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		source, err := env.Db.GetSource(models.SourceId(id))
+		if err != nil {
+			if err == models.NoSourceFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, source)
+
+	case http.MethodPost:
+		source := new(models.Source)
+		if err := json.NewDecoder(request.Body).Decode(source); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		source, err, errCode := storeSource(env, source)
+		if err != nil {
+			return err, errCode
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, source)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleNoteSourceApiRequest responds to GET requests with the source of the note given by id and
+// where in it the note applies. PUT requests attach one of the current user's notes to a source, given
+// either by sourceId or as a new source, and DELETE requests detach it.
+func HandleNoteSourceApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type LocationForm struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+
+	type NoteSourceForm struct {
+		SourceId models.SourceId `json:"sourceId"`
+		Source   *models.Source  `json:"source"`
+		Location LocationForm    `json:"location"`
+	}
+
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	noteId := models.NoteId(id)
+
+	note, err := env.Db.GetNoteById(noteId)
+	if err != nil {
+		if err == models.NoNoteFoundError {
+			return err, http.StatusNotFound
+		}
+		return err, http.StatusInternalServerError
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		if note.AuthorId != userId {
+			if err, errCode := checkPublishedNoteVisibleTo(env, noteId, userId); err != nil {
+				return err, errCode
+			}
+		}
+
+		noteSource, err := env.Db.GetNoteSource(noteId)
+		if err != nil {
+			if err == models.NoSourceFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, noteSource)
+
+	case http.MethodPut:
+		if note.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		noteSourceForm := new(NoteSourceForm)
+		if err := json.NewDecoder(request.Body).Decode(noteSourceForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		location := &models.SourceLocation{Value: noteSourceForm.Location.Value}
+		if len(noteSourceForm.Location.Type) > 0 {
+			locationType, err := models.DeserializeSourceLocationType(noteSourceForm.Location.Type)
+			if err != nil {
+				return err, http.StatusBadRequest
+			}
+			location.Type = locationType
+		}
+
+		if noteSourceForm.Source != nil {
+			storedSource, err, errCode := storeSource(env, noteSourceForm.Source)
+			if err != nil {
+				return err, errCode
+			}
+			noteSourceForm.SourceId = storedSource.Id
+		}
+
+		if noteSourceForm.SourceId == 0 {
+			return MissingSourceError, http.StatusBadRequest
+		}
+
+		source, err := env.Db.GetSource(noteSourceForm.SourceId)
+		if err != nil {
+			if err == models.NoSourceFoundError {
+				return err, http.StatusBadRequest
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		if err := env.Db.AttachNoteSource(noteId, source.Id, location); err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, &models.NoteSource{Source: source, Location: location})
+
+	case http.MethodDelete:
+		if note.AuthorId != userId {
+			return NotYourNoteError, http.StatusForbidden
+		}
+
+		if err := env.Db.DetachNoteSource(noteId); err != nil {
+			if err == models.NoSourceFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// HandleSourceNotesApiRequest responds to GET requests with the source given by id and
+// every note about it that the current user wrote or may read.
+func HandleSourceNotesApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+		sourceId := models.SourceId(id)
+
+		source, err := env.Db.GetSource(sourceId)
+		if err != nil {
+			if err == models.NoSourceFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		notes, err := env.Db.GetSourceNotesVisibleBy(sourceId, userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		type SourceNotesResponse struct {
+			Source *models.Source       `json:"source"`
+			Notes  []*models.SourceNote `json:"notes"`
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, &SourceNotesResponse{Source: source, Notes: notes})
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
+
+func storeSource(env *Environment, source *models.Source) (*models.Source, error, int) {
+	sourceId, err := env.Db.StoreSource(source)
+	if err != nil {
+		switch err {
+		case models.MissingSourceTitleError, models.InvalidIsbnError:
+			return nil, err, http.StatusBadRequest
+		default:
+			return nil, err, http.StatusInternalServerError
+		}
+	}
+
+	storedSource, err := env.Db.GetSource(sourceId)
+	if err != nil {
+		return nil, err, http.StatusInternalServerError
+	}
+
+	return storedSource, nil, 0
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+)
+
+const thumbnailSize = 256
+
+// maxThumbnailSourcePixels stops a small but huge image from exhausting memory while decoding.
+const maxThumbnailSourcePixels = 40 * 1000 * 1000
+
+var ImageTooLargeError = errors.New("The image is too large to make a thumbnail of")
+
+// makeThumbnail decodes the image and returns a PNG of it scaled to fit within thumbnailSize pixels.
+// Images already small enough keep their size.
+func makeThumbnail(content io.ReadSeeker) ([]byte, error) {
+	config, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > maxThumbnailSourcePixels {
+		return nil, ImageTooLargeError
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	source, _, err := image.Decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, scaleToFit(source, thumbnailSize)); err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// scaleToFit shrinks the image, keeping its aspect ratio, so neither side is longer than size. Each
+// thumbnail pixel is the average of the source pixels it covers.
+func scaleToFit(source image.Image, size int) image.Image {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return source
+	}
+
+	scaledWidth, scaledHeight := size, size
+	if width > height {
+		scaledHeight = maxInt(1, height*size/width)
+	} else {
+		scaledWidth = maxInt(1, width*size/height)
+	}
+
+	scaled := image.NewNRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		top := bounds.Min.Y + y*height/scaledHeight
+		bottom := maxInt(top+1, bounds.Min.Y+(y+1)*height/scaledHeight)
+
+		for x := 0; x < scaledWidth; x++ {
+			left := bounds.Min.X + x*width/scaledWidth
+			right := maxInt(left+1, bounds.Min.X+(x+1)*width/scaledWidth)
+
+			var red, green, blue, alpha, count uint64
+			for sourceY := top; sourceY < bottom; sourceY++ {
+				for sourceX := left; sourceX < right; sourceX++ {
+					r, g, b, a := source.At(sourceX, sourceY).RGBA()
+					red += uint64(r)
+					green += uint64(g)
+					blue += uint64(b)
+					alpha += uint64(a)
+					count++
+				}
+			}
+
+			// the sums are premultiplied by alpha, while NRGBA is not
+			offset := scaled.PixOffset(x, y)
+			if alpha > 0 {
+				scaled.Pix[offset] = uint8(red * 0xff / alpha)
+				scaled.Pix[offset+1] = uint8(green * 0xff / alpha)
+				scaled.Pix[offset+2] = uint8(blue * 0xff / alpha)
+			}
+			scaled.Pix[offset+3] = uint8(alpha / count >> 8)
+		}
+	}
+
+	return scaled
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func TestToken(t *testing.T) {
-	env := &handlers.Environment{nil, []byte("TheWorld")}
+	env := &handlers.Environment{Db: nil, TokenSigningKey: []byte("TheWorld")}
 
 	var num models.UserId = 32
 	bob, err := handlers.CreateTokenAsString(env, num, 1)
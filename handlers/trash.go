@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// TrashedNote is a note in the trash along with when it will be purged.
+type TrashedNote struct {
+	Id models.NoteId `json:"id"`
+	*models.Note
+	PurgeTime time.Time `json:"purgeTime"`
+}
+
+// HandleTrashApiRequest responds to GET requests with the notes in the current user's trash, most recently
+// deleted first, and to DELETE requests by purging the trashed note given by id straight away.
+func HandleTrashApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		notes, err := env.Db.GetTrashedNotes(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		trashedNotes := make([]*TrashedNote, 0, len(notes))
+		for noteId, note := range notes {
+			trashedNotes = append(trashedNotes, &TrashedNote{
+				Id:        noteId,
+				Note:      note,
+				PurgeTime: note.DeletionTime.Add(env.TrashRetention),
+			})
+		}
+
+		sort.Slice(trashedNotes, func(i, j int) bool {
+			return trashedNotes[i].DeletionTime.After(*trashedNotes[j].DeletionTime)
+		})
+
+		return respondWithJson(responseWriter, http.StatusOK, trashedNotes)
+
+	case http.MethodDelete:
+		noteId, err, errCode := getOwnTrashedNoteId(env, request, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		attachments, err := env.Db.PurgeNote(noteId, time.Now().UTC())
+		if err != nil {
+			if err == models.NoNoteFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		deleteAttachmentBlobs(env, attachments)
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// HandleTrashRestoreApiRequest responds to POST requests by taking the note given by id out of the current
+// user's trash.
+func HandleTrashRestoreApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost:
+		noteId, err, errCode := getOwnTrashedNoteId(env, request, userId)
+		if err != nil {
+			return err, errCode
+		}
+
+		if err := env.Db.RestoreNote(noteId); err != nil {
+			if err == models.NoNoteFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		emitEvent(env, &models.WebhookEvent{
+			Type:     models.NOTE_RESTORED,
+			AuthorId: userId,
+			NoteId:   noteId,
+		})
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// getOwnTrashedNoteId returns the note given by id, as long as it is in the user's trash.
+func getOwnTrashedNoteId(env *Environment, request *http.Request, userId models.UserId) (models.NoteId, error, int) {
+	id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return 0, err, http.StatusBadRequest
+	}
+	noteId := models.NoteId(id)
+
+	notes, err := env.Db.GetTrashedNotes(userId)
+	if err != nil {
+		return 0, err, http.StatusInternalServerError
+	}
+
+	if _, ok := notes[noteId]; !ok {
+		return 0, models.NoNoteFoundError, http.StatusNotFound
+	}
+
+	return noteId, nil, 0
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/webhooks"
+)
+
+var InvalidWebhookUrlError = errors.New("Webhook urls must be absolute http or https urls")
+var NoWebhookEventTypesError = errors.New("Webhook subscriptions must subscribe to at least one event type")
+
+// HandleWebhookApiRequest lists (GET), creates (POST) and deletes (DELETE) the user's webhook subscriptions.
+// The signing secret is only returned when the subscription is created.
+func HandleWebhookApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	type WebhookForm struct {
+		Url        string   `json:"url"`
+		EventTypes []string `json:"eventTypes"`
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		subscriptions, err := env.Db.GetUsersWebhookSubscriptions(userId)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, subscriptions)
+
+	case http.MethodPost:
+		webhookForm := new(WebhookForm)
+		if err := json.NewDecoder(request.Body).Decode(webhookForm); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		parsedUrl, err := url.Parse(webhookForm.Url)
+		if err != nil || !parsedUrl.IsAbs() || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+			return InvalidWebhookUrlError, http.StatusBadRequest
+		}
+
+		if err := webhooks.CheckUrl(parsedUrl); err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if len(webhookForm.EventTypes) == 0 {
+			return NoWebhookEventTypesError, http.StatusBadRequest
+		}
+
+		subscription := &models.WebhookSubscription{
+			UserId:       userId,
+			Url:          parsedUrl.String(),
+			EventTypes:   make([]models.WebhookEventType, 0, len(webhookForm.EventTypes)),
+			CreationTime: time.Now().UTC(),
+		}
+
+		for _, eventTypeString := range webhookForm.EventTypes {
+			eventType, err := models.DeserializeWebhookEventType(eventTypeString)
+			if err != nil {
+				return err, http.StatusBadRequest
+			}
+			subscription.EventTypes = append(subscription.EventTypes, eventType)
+		}
+
+		subscriptionId, err := env.Db.StoreNewWebhookSubscription(subscription)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		subscription.Id = subscriptionId
+
+		return respondWithJson(responseWriter, http.StatusCreated, subscription)
+
+	case http.MethodDelete:
+		subscriptionId, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		if err := env.Db.DeleteWebhookSubscription(userId, models.WebhookSubscriptionId(subscriptionId)); err != nil {
+			if err == models.NoWebhookSubscriptionFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		responseWriter.WriteHeader(http.StatusOK)
+
+		return nil, 0
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// HandleWebhookPingApiRequest responds to POST requests by queueing a ping to the subscription given by id.
+// The outcome shows up in the subscription's delivery log.
+func HandleWebhookPingApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodPost:
+		subscriptionId, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		deliveryId, err := env.Db.QueueWebhookPing(userId, models.WebhookSubscriptionId(subscriptionId))
+		if err != nil {
+			if err == models.NoWebhookSubscriptionFoundError {
+				return err, http.StatusNotFound
+			}
+			return err, http.StatusInternalServerError
+		}
+
+		type PingResponse struct {
+			DeliveryId models.WebhookDeliveryId `json:"deliveryId"`
+		}
+
+		return respondWithJson(responseWriter, http.StatusAccepted, &PingResponse{DeliveryId: deliveryId})
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodPost)
+	}
+}
+
+// HandleWebhookDeliveryApiRequest responds to GET requests with the delivery log of the subscription given by id.
+func HandleWebhookDeliveryApiRequest(
+	env *Environment,
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	userId models.UserId,
+) (error, int) {
+	switch request.Method {
+	case http.MethodGet:
+		subscriptionId, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			return err, http.StatusBadRequest
+		}
+
+		deliveries, err := env.Db.GetWebhookDeliveries(userId, models.WebhookSubscriptionId(subscriptionId))
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+
+		return respondWithJson(responseWriter, http.StatusOK, deliveries)
+
+	default:
+		return respondWithMethodNotAllowed(responseWriter, http.MethodGet)
+	}
+}
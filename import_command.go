@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+const importCommandName = "import"
+
+var NoImportPathsError = errors.New("usage: cerealnotes import -email EMAIL [-dry-run] PATH...")
+
+// runImportCommand imports every markdown, json and json lines file found in the given paths
+// for the user with the given email address. Directories are walked recursively.
+func runImportCommand(arguments []string) error {
+	flagSet := flag.NewFlagSet(importCommandName, flag.ExitOnError)
+	emailAddress := flagSet.String("email", "", "email address of the user who will own the imported notes")
+	dryRun := flagSet.Bool("dry-run", false, "report what would be imported without storing anything")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return err
+	}
+
+	if len(*emailAddress) == 0 || flagSet.NArg() == 0 {
+		return NoImportPathsError
+	}
+
+	databaseUrl, err := determineDatabaseUrl()
+	if err != nil {
+		return err
+	}
+
+	db, err := models.ConnectToDatabase(databaseUrl, 0)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	userId, err := db.GetIdForUserWithEmailAddress(models.NewEmailAddress(*emailAddress))
+	if err != nil {
+		return err
+	}
+
+	notes := make([]*models.ImportedNote, 0)
+	fileNames := make([]string, 0)
+
+	for _, path := range flagSet.Args() {
+		if err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			file, err := os.Open(filePath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			parsedNotes, err := models.ParseImportFile(filePath, file)
+			if err == models.UnsupportedImportFormatError && filePath != path {
+				// ignore unrelated files found while walking a directory
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %s", filePath, err.Error())
+			}
+
+			for range parsedNotes {
+				fileNames = append(fileNames, filePath)
+			}
+			notes = append(notes, parsedNotes...)
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	report, err := db.ImportNotes(userId, notes, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "FILE\tSTATUS\tNOTE\tDETAIL")
+	for _, result := range report.Results {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", fileNames[result.Index], result.Status, result.NoteId, result.Error)
+	}
+	writer.Flush()
+
+	if report.DryRun {
+		fmt.Print("dry run: ")
+	}
+	fmt.Printf("%d imported, %d duplicates, %d invalid\n", report.Imported, report.Duplicates, report.Invalid)
+
+	return nil
+}
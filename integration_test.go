@@ -1,21 +1,35 @@
 package main_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/atmiguel/cerealnotes/apiclient"
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/events"
 	"github.com/atmiguel/cerealnotes/handlers"
+	"github.com/atmiguel/cerealnotes/markdown"
 	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/openapi"
 	"github.com/atmiguel/cerealnotes/paths"
 	"github.com/atmiguel/cerealnotes/routers"
 	"github.com/atmiguel/cerealnotes/test_util"
@@ -23,7 +37,7 @@ import (
 
 func TestLoginOrSignUpPage(t *testing.T) {
 	mockDb := &MockDataStore{}
-	env := &handlers.Environment{mockDb, []byte("")}
+	env := &handlers.Environment{Db: mockDb, TokenSigningKey: []byte("")}
 
 	server := httptest.NewServer(routers.DefineRoutes(env))
 	defer server.Close()
@@ -34,14 +48,26 @@ func TestLoginOrSignUpPage(t *testing.T) {
 }
 
 func TestAuthenticatedFlow(t *testing.T) {
+	attachmentDir, err := ioutil.TempDir("", "attachments")
+	test_util.Ok(t, err)
+	defer os.RemoveAll(attachmentDir)
+
 	mockDb := &MockDataStore{}
-	env := &handlers.Environment{mockDb, []byte("")}
+	env := &handlers.Environment{
+		Db:              mockDb,
+		TokenSigningKey: []byte(""),
+		Events:          events.NewInProcessHub(16),
+		Reactions:       []string{"👍", "🎉"},
+		Markdown:        markdown.NewCache(16),
+		Blobs:           blobstore.NewLocalStore(attachmentDir),
+	}
 
 	server := httptest.NewServer(routers.DefineRoutes(env))
 	defer server.Close()
 
-	// Create testing client
-	client := &http.Client{}
+	// Create testing client, whose responses are checked against the api's OpenAPI document
+	apiTransport := &validatingTransport{document: handlers.ApiDocument(), exercised: make(map[string]bool)}
+	client := &http.Client{Transport: apiTransport}
 	{
 		jar, err := cookiejar.New(&cookiejar.Options{})
 		test_util.Ok(t, err)
@@ -49,6 +75,24 @@ func TestAuthenticatedFlow(t *testing.T) {
 		client.Jar = jar
 	}
 
+	// Record every event the handlers emit
+	emittedEvents := make([]models.WebhookEventType, 0)
+	mockDb.Func_QueueWebhookDeliveries = func(event *models.WebhookEvent) error {
+		emittedEvents = append(emittedEvents, event.Type)
+		return nil
+	}
+	mockDb.Func_GetEventAudience = func(event *models.WebhookEvent) ([]models.UserId, error) {
+		return []models.UserId{event.AuthorId}, nil
+	}
+	mockDb.Func_DeserializeNoteCategory = func(input string) (models.NoteCategory, error) {
+		for _, category := range []models.NoteCategory{models.MARGINALIA, models.META, models.QUESTION, models.PREDICTION} {
+			if input == category.String() {
+				return category, nil
+			}
+		}
+		return "", models.CannotDeserializeNoteCategoryStringError
+	}
+
 	// Test login
 	userIdAsInt := int64(1)
 
@@ -138,7 +182,7 @@ func TestAuthenticatedFlow(t *testing.T) {
 				1: models.NotesById(map[models.NoteId]*models.Note{
 					models.NoteId(44): &models.Note{
 						AuthorId:     models.UserId(99),
-						Content:      "another note",
+						Content:      "another *note*",
 						CreationTime: time.Now(),
 					},
 				}),
@@ -146,9 +190,46 @@ func TestAuthenticatedFlow(t *testing.T) {
 
 		}
 
+		mockDb.Func_GetReplyCounts = func(noteIds []models.NoteId) (map[models.NoteId]int, error) {
+			return map[models.NoteId]int{models.NoteId(44): 2}, nil
+		}
+
+		mockDb.Func_GetReactionSummaries = func(noteIds []models.NoteId, userId models.UserId) (map[models.NoteId][]*models.ReactionSummary, error) {
+			return map[models.NoteId][]*models.ReactionSummary{
+				models.NoteId(44): {{Reaction: "👍", Count: 3, ReactedByMe: true}},
+			}, nil
+		}
+
+		mockDb.Func_GetNoteLinksBetween = func(noteIds []models.NoteId) ([]*models.NoteLink, error) {
+			return []*models.NoteLink{{SourceNoteId: models.NoteId(noteIdAsInt), TargetNoteId: models.NoteId(44)}}, nil
+		}
+
+		mockDb.Func_GetUnreadNoteIds = func(userId models.UserId) (map[models.NoteId]bool, error) {
+			return map[models.NoteId]bool{models.NoteId(44): true}, nil
+		}
+
+		mockDb.Func_GetNoteTags = func(noteIds []models.NoteId) (map[models.NoteId][]string, error) {
+			return map[models.NoteId][]string{models.NoteId(44): {"book-club"}}, nil
+		}
+
 		resp, err := client.Get(server.URL + paths.NoteApi)
 		test_util.Ok(t, err)
 		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		notesById := make(map[string]models.Note)
+		err = json.NewDecoder(resp.Body).Decode(&notesById)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 2, notesById["44"].ReplyCount)
+		test_util.Equals(t, 0, notesById[strconv.FormatInt(noteIdAsInt, 10)].ReplyCount)
+		test_util.Equals(t, []*models.ReactionSummary{{Reaction: "👍", Count: 3, ReactedByMe: true}}, notesById["44"].Reactions)
+		test_util.Equals(t, []models.NoteId{models.NoteId(noteIdAsInt)}, notesById["44"].Backlinks)
+		test_util.Equals(t, "another *note*", notesById["44"].Content)
+		test_util.Equals(t, "<p>another <em>note</em></p>\n", notesById["44"].ContentHtml)
+		test_util.Assert(t, notesById["44"].Unread, "Expected the note from another author to be unread")
+		test_util.Assert(t, !notesById[strconv.FormatInt(noteIdAsInt, 10)].Unread, "Expected the user's own note to be read")
+		test_util.Equals(t, []models.NoteId{}, notesById[strconv.FormatInt(noteIdAsInt, 10)].Backlinks)
+		test_util.Equals(t, []string{"book-club"}, notesById["44"].Tags)
 	})
 
 	// Test edit notes
@@ -165,7 +246,7 @@ func TestAuthenticatedFlow(t *testing.T) {
 			}, nil
 		}
 
-		mockDb.Func_UpdateNoteContent = func(models.NoteId, string) error {
+		mockDb.Func_UpdateNoteContent = func(models.NoteId, string, int) error {
 			return nil
 		}
 
@@ -217,7 +298,7 @@ func TestAuthenticatedFlow(t *testing.T) {
 					return models.META, nil
 				}
 
-				return 0, errors.New("Incorrect data")
+				return "", errors.New("Incorrect data")
 			}
 
 			resp, err := client.Get(server.URL + paths.NoteApi + "?id=" + strconv.FormatInt(noteIdAsInt, 10))
@@ -263,172 +344,2940 @@ func TestAuthenticatedFlow(t *testing.T) {
 			test_util.Equals(t, http.StatusOK, resp.StatusCode)
 
 		})
+
+		// Change the category of someone else's note
+		t.Run("Category Of Someone Else's Note", func(t *testing.T) {
+			getNoteById := mockDb.Func_GetNoteById
+			defer func() { mockDb.Func_GetNoteById = getNoteById }()
+
+			mockDb.Func_GetNoteById = func(models.NoteId) (*models.Note, error) {
+				return &models.Note{AuthorId: models.UserId(99), Content: "another note", CreationTime: time.Now()}, nil
+			}
+			mockDb.Func_AssignNoteCategoryRelationship = func(models.NoteId, models.NoteCategory) error {
+				return errors.New("Someone else's note was categorized")
+			}
+			mockDb.Func_DeleteNoteCategory = func(models.NoteId) error {
+				return errors.New("Someone else's note was uncategorized")
+			}
+
+			noteCategoryUrl := server.URL + paths.NoteCategoryApi + "?id=" + strconv.FormatInt(noteIdAsInt, 10)
+
+			jsonValue, _ := json.Marshal(&CategoryForm{Category: models.META.String()})
+			resp, err := client.Post(noteCategoryUrl, "application/json", bytes.NewBuffer(jsonValue))
+			test_util.Ok(t, err)
+			test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+			resp, err = sendDeleteUrl(client, noteCategoryUrl)
+			test_util.Ok(t, err)
+			test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+		})
 	}
 
-	// Test publish notes
-	t.Run("Publish Notes", func(t *testing.T) {
-		mockDb.Func_PublishNotes = func(userId models.UserId) error {
-			return nil
+	// Test import notes
+	t.Run("Import Notes", func(t *testing.T) {
+		mockDb.Func_ImportNotes = func(userId models.UserId, notes []*models.ImportedNote, dryRun bool) (*models.ImportReport, error) {
+			if int64(userId) != userIdAsInt || len(notes) != 2 {
+				return nil, errors.New("Incorrect Data Arrived")
+			}
+
+			report := &models.ImportReport{DryRun: dryRun, Imported: len(notes)}
+			if !dryRun {
+				report.Results = []*models.ImportResult{
+					{Index: 0, Status: models.IMPORT_SUCCEEDED, NoteId: models.NoteId(61)},
+					{Index: 1, Status: models.IMPORT_SUCCEEDED, NoteId: models.NoteId(62)},
+				}
+			}
+			return report, nil
 		}
-		// publish new api
-		resp, err := client.Post(server.URL+paths.PublicationApi, "", nil)
+
+		jsonLines := "{\"content\": \"first\"}\n{\"content\": \"second\", \"category\": \"meta\"}\n"
+
+		resp, err := client.Post(server.URL+paths.ImportApi+"?dryRun=true", "application/x-ndjson", bytes.NewBufferString(jsonLines))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		report := &models.ImportReport{}
+		err = json.NewDecoder(resp.Body).Decode(report)
 		test_util.Ok(t, err)
+		test_util.Equals(t, 2, report.Imported)
+		defer resp.Body.Close()
+
+		// imported notes are announced the same way as notes created one at a time
+		getNoteById := mockDb.Func_GetNoteById
+		queueWebhookDeliveries := mockDb.Func_QueueWebhookDeliveries
+		defer func() {
+			mockDb.Func_GetNoteById = getNoteById
+			mockDb.Func_QueueWebhookDeliveries = queueWebhookDeliveries
+		}()
+
+		mockDb.Func_GetNoteById = func(noteId models.NoteId) (*models.Note, error) {
+			return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: "*imported*", CreationTime: time.Now().UTC(), Revision: 1}, nil
+		}
+		createdNotes := make(map[models.NoteId]*models.Note)
+		mockDb.Func_QueueWebhookDeliveries = func(event *models.WebhookEvent) error {
+			if event.Type == models.NOTE_CREATED {
+				createdNotes[event.NoteId], _ = event.Data.(*models.Note)
+			}
+			return queueWebhookDeliveries(event)
+		}
+
+		resp, err = client.Post(server.URL+paths.ImportApi, "application/x-ndjson", bytes.NewBufferString(jsonLines))
+		test_util.Ok(t, err)
+		resp.Body.Close()
 		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+		test_util.Equals(t, 2, len(createdNotes))
+		for _, noteId := range []models.NoteId{61, 62} {
+			test_util.Assert(t, createdNotes[noteId] != nil, "Expected note %d to be sent as a note", noteId)
+			test_util.Equals(t, "<p><em>imported</em></p>\n", createdNotes[noteId].ContentHtml)
+		}
+
+		tooLarge := bytes.NewBufferString("# A long note\n\n" + strings.Repeat("Far too long. ", (33<<20)/14))
+		resp, err = client.Post(server.URL+paths.ImportApi, "text/markdown", tooLarge)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		resp.Body.Close()
 	})
 
-	// Delete note
-	t.Run("Delete Note", func(t *testing.T) {
-		mockDb.Func_GetUsersNotes = func(userId models.UserId) (models.NotesById, error) {
-			return models.NotesById(map[models.NoteId]*models.Note{
-				models.NoteId(noteIdAsInt): &models.Note{
-					AuthorId:     models.UserId(userIdAsInt),
-					Content:      content,
-					CreationTime: time.Now(),
+	// Test feeds
+	t.Run("Feeds", func(t *testing.T) {
+		feedToken := "aPrivateFeedToken"
+
+		mockDb.Func_CreateFeedToken = func(userId models.UserId) (string, error) {
+			return feedToken, nil
+		}
+
+		mockDb.Func_GetUserIdForFeedToken = func(token string) (models.UserId, error) {
+			if token == feedToken {
+				return models.UserId(userIdAsInt), nil
+			}
+
+			return 0, models.NoFeedTokenFoundError
+		}
+
+		deletionTime := time.Now().UTC()
+
+		mockDb.Func_GetPublishedIssuesVisibleBy = func(userId models.UserId) ([]*models.PublishedIssue, error) {
+			return []*models.PublishedIssue{
+				&models.PublishedIssue{
+					PublicationId: models.PublicationId(7),
+					AuthorId:      models.UserId(99),
+					IssueNumber:   1,
+					CreationTime:  time.Now().UTC(),
+					Intro:         "an *intro*",
+					Notes: models.NotesById(map[models.NoteId]*models.Note{
+						models.NoteId(44): &models.Note{
+							AuthorId:     models.UserId(99),
+							Content:      "another *note*<script>alert(1)</script>",
+							CreationTime: time.Now().UTC(),
+						},
+						models.NoteId(45): &models.Note{
+							AuthorId:     models.UserId(99),
+							CreationTime: time.Now().UTC(),
+							DeletionTime: &deletionTime,
+						},
+					}),
+					NoteOrder: []models.NoteId{models.NoteId(44), models.NoteId(45)},
 				},
+			}, nil
+		}
+
+		mockDb.Func_GetAllUsersById = func() (models.UsersById, error) {
+			return models.UsersById(map[models.UserId]*models.User{
+				models.UserId(99): &models.User{DisplayName: "alice"},
 			}), nil
 		}
 
-		mockDb.Func_DeleteNoteById = func(noteid models.NoteId) error {
-			if int64(noteid) == noteIdAsInt {
-				return nil
+		resp, err := client.Post(server.URL+paths.FeedTokenApi, "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		type FeedTokenResponse struct {
+			AtomUrl string `json:"atomUrl"`
+			RssUrl  string `json:"rssUrl"`
+		}
+
+		feedTokenResponse := &FeedTokenResponse{}
+		err = json.NewDecoder(resp.Body).Decode(feedTokenResponse)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+
+		// feed readers don't send cookies
+		for _, feedUrl := range []string{feedTokenResponse.AtomUrl, feedTokenResponse.RssUrl} {
+			resp, err = http.Get(feedUrl)
+			test_util.Ok(t, err)
+			test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			test_util.Ok(t, err)
+			resp.Body.Close()
+
+			test_util.Assert(t, bytes.Contains(body, []byte("alice, issue 1")), "Expected the issue title in the feed")
+			test_util.Assert(t, bytes.Contains(body, []byte("&lt;em&gt;intro&lt;/em&gt;")), "Expected the rendered intro in the feed")
+			test_util.Assert(t, bytes.Contains(body, []byte("&lt;em&gt;note&lt;/em&gt;")), "Expected the rendered note in the feed")
+			test_util.Assert(t, !bytes.Contains(body, []byte("alert")), "Expected the note content to be sanitised")
+			test_util.Assert(t, bytes.Contains(body, []byte("This note was deleted.")), "Expected the deleted note to be labelled")
+		}
+
+		resp, err = http.Get(server.URL + paths.AtomFeed + "?token=notAToken")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = http.Get(feedTokenResponse.RssUrl + "&author=alice")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	// Test notification preferences
+	t.Run("Notification Preferences", func(t *testing.T) {
+		frequency := models.DAILY
+
+		mockDb.Func_GetNotificationPreferences = func(userId models.UserId) (*models.NotificationPreferences, error) {
+			return &models.NotificationPreferences{Frequency: frequency}, nil
+		}
+
+		mockDb.Func_SetNotificationFrequency = func(userId models.UserId, newFrequency models.NotificationFrequency) error {
+			frequency = newFrequency
+			return nil
+		}
+
+		mockDb.Func_UnsubscribeFromNotifications = func(token string) error {
+			if token != "anUnsubscribeToken" {
+				return models.NoNotificationPreferencesFoundError
 			}
 
-			return errors.New("Somehow you didn't get the correct error")
+			frequency = models.NEVER
+			return nil
 		}
 
-		resp, err := sendDeleteUrl(client, server.URL+paths.NoteApi+"?id="+strconv.FormatInt(noteIdAsInt, 10))
+		jsonValue, _ := json.Marshal(map[string]string{"frequency": "immediate"})
+		resp, err := sendPutRequest(client, server.URL+paths.NotificationPreferenceApi, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, models.IMMEDIATE, frequency)
+
+		jsonValue, _ = json.Marshal(map[string]string{"frequency": "hourly"})
+		resp, err = sendPutRequest(client, server.URL+paths.NotificationPreferenceApi, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		// unsubscribe links are followed from email clients without cookies
+		resp, err = http.Get(server.URL + paths.UnsubscribePage + "?token=anUnsubscribeToken")
 		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
 
+		resp, err = client.Get(server.URL + paths.NotificationPreferenceApi)
+		test_util.Ok(t, err)
 		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		preferences := &models.NotificationPreferences{}
+		err = json.NewDecoder(resp.Body).Decode(preferences)
+		test_util.Ok(t, err)
+		test_util.Equals(t, models.NEVER, preferences.Frequency)
+		defer resp.Body.Close()
 	})
-}
 
-func sendDeleteRequest(client *http.Client, myUrl string, contentType string, body io.Reader) (resp *http.Response, err error) {
-	req, err := http.NewRequest("DELETE", myUrl, body)
+	// Test webhooks
+	t.Run("Webhooks", func(t *testing.T) {
+		subscriptionIdAsInt := int64(5)
 
-	if err != nil {
-		return nil, err
-	}
+		mockDb.Func_StoreNewWebhookSubscription = func(subscription *models.WebhookSubscription) (models.WebhookSubscriptionId, error) {
+			if int64(subscription.UserId) != userIdAsInt || len(subscription.EventTypes) != 2 {
+				return 0, errors.New("Incorrect Data Arrived")
+			}
 
-	req.Header.Set("Content-Type", contentType)
-	return client.Do(req)
-}
-func sendDeleteUrl(client *http.Client, myUrl string) (resp *http.Response, err error) {
+			subscription.Secret = "aSigningSecret"
+			return models.WebhookSubscriptionId(subscriptionIdAsInt), nil
+		}
 
-	req, err := http.NewRequest("DELETE", myUrl, nil)
+		mockDb.Func_QueueWebhookPing = func(userId models.UserId, subscriptionId models.WebhookSubscriptionId) (models.WebhookDeliveryId, error) {
+			if int64(subscriptionId) != subscriptionIdAsInt {
+				return 0, models.NoWebhookSubscriptionFoundError
+			}
 
-	if err != nil {
-		return nil, err
-	}
+			return models.WebhookDeliveryId(9), nil
+		}
 
-	return client.Do(req)
-}
+		webhookForm := map[string]interface{}{
+			"url":        "https://chat.example.com/hooks/cerealnotes",
+			"eventTypes": []string{"note.created", "publication.created"},
+		}
+		jsonValue, _ := json.Marshal(webhookForm)
 
-func sendPutRequest(client *http.Client, myUrl string, contentType string, body io.Reader) (resp *http.Response, err error) {
-	req, err := http.NewRequest("PUT", myUrl, body)
+		resp, err := client.Post(server.URL+paths.WebhookApi, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
 
-	if err != nil {
-		return nil, err
-	}
+		subscription := &models.WebhookSubscription{}
+		err = json.NewDecoder(resp.Body).Decode(subscription)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, "aSigningSecret", subscription.Secret)
 
-	req.Header.Set("Content-Type", contentType)
-	return client.Do(req)
-}
+		webhookForm["eventTypes"] = []string{"note.exploded"}
+		jsonValue, _ = json.Marshal(webhookForm)
 
-// Used for debugging tests.
-func printBody(resp *http.Response) {
-	buf, bodyErr := ioutil.ReadAll(resp.Body)
-	if bodyErr != nil {
-		fmt.Print("bodyErr ", bodyErr.Error())
-		return
-	}
+		resp, err = client.Post(server.URL+paths.WebhookApi, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		// webhooks can't be pointed back at this server or its network
+		webhookForm["eventTypes"] = []string{"note.created", "publication.created"}
+		for _, forbiddenUrl := range []string{
+			"http://localhost:8080/hooks",
+			"http://127.0.0.1/hooks",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://10.0.0.7/hooks",
+			"http://[::1]/hooks",
+		} {
+			webhookForm["url"] = forbiddenUrl
+			jsonValue, _ = json.Marshal(webhookForm)
+
+			resp, err = client.Post(server.URL+paths.WebhookApi, "application/json", bytes.NewBuffer(jsonValue))
+			test_util.Ok(t, err)
+			resp.Body.Close()
+			test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+		}
 
-	rdr1 := ioutil.NopCloser(bytes.NewBuffer(buf))
-	rdr2 := ioutil.NopCloser(bytes.NewBuffer(buf))
-	fmt.Printf("BODY: %q", rdr1)
-	resp.Body = rdr2
-}
+		resp, err = client.Post(server.URL+paths.WebhookPingApi+"?id="+strconv.FormatInt(subscriptionIdAsInt, 10), "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusAccepted, resp.StatusCode)
 
-// Helpers
+		resp, err = client.Post(server.URL+paths.WebhookPingApi+"?id=404", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+	})
 
-type MockDataStore struct {
-	Func_StoreNewNote                   func(*models.Note) (models.NoteId, error)
-	Func_StoreNewUser                   func(string, *models.EmailAddress, string) error
-	Func_AuthenticateUserCredentials    func(*models.EmailAddress, string) error
-	Func_GetIdForUserWithEmailAddress   func(*models.EmailAddress) (models.UserId, error)
-	Func_GetUsersNotes                  func(models.UserId) (models.NotesById, error)
-	Func_DeleteNoteById                 func(models.NoteId) error
-	Func_GetMyUnpublishedNotes          func(models.UserId) (models.NotesById, error)
-	Func_GetAllUsersById                func() (models.UsersById, error)
-	Func_GetAllPublishedNotesVisibleBy  func(models.UserId) (map[int64]models.NotesById, error)
-	Func_PublishNotes                   func(models.UserId) error
-	Func_StoreNewPublication            func(*models.Publication) (models.PublicationId, error)
-	Func_GetNoteById                    func(models.NoteId) (*models.Note, error)
-	Func_UpdateNoteContent              func(models.NoteId, string) error
-	Func_AssignNoteCategoryRelationship func(models.NoteId, models.NoteCategory) error
-	Func_DeleteNoteCategory             func(models.NoteId) error
-	Func_GetNoteCategory                func(models.NoteId) (models.NoteCategory, error)
-}
+	// Test predictions
+	t.Run("Predictions", func(t *testing.T) {
+		judgeIdAsInt := int64(2)
+		var storedPrediction *models.Prediction
+		var outcome models.PredictionOutcome
 
-func (mock *MockDataStore) StoreNewNote(note *models.Note) (models.NoteId, error) {
-	return mock.Func_StoreNewNote(note)
-}
+		mockDb.Func_GetNoteById = func(noteId models.NoteId) (*models.Note, error) {
+			if int64(noteId) != noteIdAsInt {
+				return nil, models.NoNoteFoundError
+			}
+			return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: content, CreationTime: time.Now()}, nil
+		}
 
-func (mock *MockDataStore) StoreNewUser(str1 string, email *models.EmailAddress, str2 string) error {
-	return mock.Func_StoreNewUser(str1, email, str2)
-}
+		mockDb.Func_StorePrediction = func(prediction *models.Prediction) error {
+			if prediction.Probability > 1 {
+				return models.InvalidProbabilityError
+			}
+			storedPrediction = prediction
+			return nil
+		}
 
-func (mock *MockDataStore) AuthenticateUserCredentials(email *models.EmailAddress, str string) error {
-	return mock.Func_AuthenticateUserCredentials(email, str)
-}
+		mockDb.Func_GetPrediction = func(noteId models.NoteId) (*models.Prediction, error) {
+			if storedPrediction == nil || noteId != storedPrediction.NoteId {
+				return nil, models.NoPredictionFoundError
+			}
+			return storedPrediction, nil
+		}
 
-func (mock *MockDataStore) GetIdForUserWithEmailAddress(email *models.EmailAddress) (models.UserId, error) {
-	return mock.Func_GetIdForUserWithEmailAddress(email)
-}
+		mockDb.Func_ResolvePrediction = func(noteId models.NoteId, resolverId models.UserId, newOutcome models.PredictionOutcome, resolvedTime time.Time) error {
+			outcome = newOutcome
+			return nil
+		}
 
-func (mock *MockDataStore) GetUsersNotes(userId models.UserId) (models.NotesById, error) {
-	return mock.Func_GetUsersNotes(userId)
-}
+		var calibratedUserId, calibrationViewerId models.UserId
+		mockDb.Func_GetUsersResolvedPredictions = func(userId models.UserId, viewerId models.UserId) ([]*models.Prediction, error) {
+			calibratedUserId, calibrationViewerId = userId, viewerId
+			return []*models.Prediction{{Probability: 0.75, Outcome: outcome}}, nil
+		}
 
-func (mock *MockDataStore) DeleteNoteById(noteId models.NoteId) error {
-	return mock.Func_DeleteNoteById(noteId)
-}
+		mockDb.Func_GetAllUsersById = func() (models.UsersById, error) {
+			return models.UsersById{
+				models.UserId(userIdAsInt):  {DisplayName: "Writer"},
+				models.UserId(judgeIdAsInt): {DisplayName: "Judge"},
+			}, nil
+		}
 
-func (mock *MockDataStore) GetMyUnpublishedNotes(userId models.UserId) (models.NotesById, error) {
-	return mock.Func_GetMyUnpublishedNotes(userId)
-}
+		visibleToCurrentUser := true
+		mockDb.Func_IsPublishedNoteVisibleTo = func(noteId models.NoteId, userId models.UserId) (bool, error) {
+			return visibleToCurrentUser, nil
+		}
 
-func (mock *MockDataStore) GetAllUsersById() (models.UsersById, error) {
-	return mock.Func_GetAllUsersById()
-}
+		predictionUrl := server.URL + paths.PredictionApi + "?id=" + strconv.FormatInt(noteIdAsInt, 10)
 
-func (mock *MockDataStore) GetAllPublishedNotesVisibleBy(userId models.UserId) (map[int64]models.NotesById, error) {
-	return mock.Func_GetAllPublishedNotesVisibleBy(userId)
-}
+		jsonValue, _ := json.Marshal(map[string]interface{}{
+			"probability":    0.75,
+			"resolutionDate": "2019-01-01T00:00:00Z",
+			"judgeIds":       []int64{judgeIdAsInt},
+		})
+		resp, err := sendPutRequest(client, predictionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, []models.UserId{models.UserId(judgeIdAsInt)}, storedPrediction.JudgeIds)
 
-func (mock *MockDataStore) PublishNotes(userId models.UserId) error {
-	return mock.Func_PublishNotes(userId)
-}
+		jsonValue, _ = json.Marshal(map[string]interface{}{"probability": 1.5, "resolutionDate": "2019-01-01T00:00:00Z"})
+		resp, err = sendPutRequest(client, predictionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
 
-func (mock *MockDataStore) StoreNewPublication(publication *models.Publication) (models.PublicationId, error) {
-	return mock.Func_StoreNewPublication(publication)
-}
+		// judges have to be users
+		jsonValue, _ = json.Marshal(map[string]interface{}{
+			"probability":    0.75,
+			"resolutionDate": "2019-01-01T00:00:00Z",
+			"judgeIds":       []int64{judgeIdAsInt, 404},
+		})
+		resp, err = sendPutRequest(client, predictionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+		test_util.Equals(t, []models.UserId{models.UserId(judgeIdAsInt)}, storedPrediction.JudgeIds)
 
-func (mock *MockDataStore) GetNoteById(noteId models.NoteId) (*models.Note, error) {
-	return mock.Func_GetNoteById(noteId)
-}
+		resolutionUrl := server.URL + paths.PredictionResolutionApi + "?id=" + strconv.FormatInt(noteIdAsInt, 10)
 
-func (mock *MockDataStore) UpdateNoteContent(noteId models.NoteId, content string) error {
-	return mock.Func_UpdateNoteContent(noteId, content)
-}
+		jsonValue, _ = json.Marshal(map[string]string{"outcome": "maybe"})
+		resp, err = client.Post(resolutionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
 
-func (mock *MockDataStore) GetNoteCategory(noteId models.NoteId) (models.NoteCategory, error) {
-	return mock.Func_GetNoteCategory(noteId)
-}
+		jsonValue, _ = json.Marshal(map[string]string{"outcome": "true"})
+		resp, err = client.Post(resolutionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, models.OUTCOME_TRUE, outcome)
 
-func (mock *MockDataStore) AssignNoteCategoryRelationship(noteId models.NoteId, category models.NoteCategory) error {
-	return mock.Func_AssignNoteCategoryRelationship(noteId, category)
-}
-func (mock *MockDataStore) DeleteNoteCategory(noteId models.NoteId) error {
-	return mock.Func_DeleteNoteCategory(noteId)
+		resp, err = client.Get(server.URL + paths.CalibrationApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		calibration := &models.Calibration{}
+		err = json.NewDecoder(resp.Body).Decode(calibration)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 1, calibration.Count)
+		test_util.Equals(t, 0.0625, *calibration.BrierScore)
+		test_util.Equals(t, models.UserId(userIdAsInt), calibratedUserId)
+
+		// others' predictions are scored as far as the current user can read them
+		resp, err = client.Get(server.URL + paths.CalibrationApi + "?userId=" + strconv.FormatInt(judgeIdAsInt, 10))
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, models.UserId(judgeIdAsInt), calibratedUserId)
+		test_util.Equals(t, models.UserId(userIdAsInt), calibrationViewerId)
+
+		// only the author and judges can resolve a prediction
+		storedPrediction.AuthorId = models.UserId(judgeIdAsInt + 1)
+		storedPrediction.JudgeIds = []models.UserId{}
+		resp, err = client.Post(resolutionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		// and others' predictions stay hidden until their issue can be read, even from judges
+		storedPrediction.JudgeIds = []models.UserId{models.UserId(userIdAsInt)}
+		visibleToCurrentUser = false
+		resp, err = client.Post(resolutionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = client.Get(predictionUrl)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		visibleToCurrentUser = true
+		resp, err = client.Get(predictionUrl)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+	})
+
+	// Test questions and answers
+	t.Run("Questions", func(t *testing.T) {
+		questionIdAsInt := int64(40)
+		answerIdAsInt := int64(41)
+		askerIdAsInt := int64(3)
+		var acceptedAnswerId models.NoteId
+
+		mockDb.Func_GetNoteById = func(noteId models.NoteId) (*models.Note, error) {
+			if int64(noteId) != questionIdAsInt {
+				return nil, models.NoNoteFoundError
+			}
+			return &models.Note{AuthorId: models.UserId(askerIdAsInt), Content: "Why?", CreationTime: time.Now()}, nil
+		}
+
+		mockDb.Func_GetNoteCategory = func(noteId models.NoteId) (models.NoteCategory, error) {
+			return models.QUESTION, nil
+		}
+
+		mockDb.Func_IsPublishedNoteVisibleTo = func(noteId models.NoteId, userId models.UserId) (bool, error) {
+			return int64(noteId) == questionIdAsInt || int64(noteId) == answerIdAsInt, nil
+		}
+
+		mockDb.Func_StoreNewAnswer = func(questionId models.NoteId, answer *models.Note) (models.NoteId, error) {
+			if int64(questionId) != questionIdAsInt || answer.Content != "Because." {
+				return 0, errors.New("Incorrect Data Arrived")
+			}
+			return models.NoteId(answerIdAsInt), nil
+		}
+
+		mockDb.Func_GetAnswersVisibleBy = func(questionId models.NoteId, userId models.UserId) ([]*models.Answer, error) {
+			return []*models.Answer{{NoteId: models.NoteId(answerIdAsInt), QuestionId: questionId, Content: "Because."}}, nil
+		}
+
+		mockDb.Func_AcceptAnswer = func(questionId models.NoteId, answerId models.NoteId) error {
+			acceptedAnswerId = answerId
+			return nil
+		}
+
+		answerUrl := server.URL + paths.AnswerApi + "?questionId=" + strconv.FormatInt(questionIdAsInt, 10)
+
+		jsonValue, _ := json.Marshal(map[string]string{"content": "Because."})
+		resp, err := client.Post(answerUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		answer := &models.Answer{}
+		err = json.NewDecoder(resp.Body).Decode(answer)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, models.NoteId(answerIdAsInt), answer.NoteId)
+
+		// questions that haven't been published to the reader can't be answered
+		resp, err = client.Post(server.URL+paths.AnswerApi+"?questionId=404", "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = client.Get(answerUrl)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		answers := make([]*models.Answer, 0)
+		err = json.NewDecoder(resp.Body).Decode(&answers)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 1, len(answers))
+
+		// only the asker can accept an answer
+		acceptedAnswerUrl := server.URL + paths.AcceptedAnswerApi + "?questionId=" + strconv.FormatInt(questionIdAsInt, 10)
+		jsonValue, _ = json.Marshal(map[string]int64{"answerId": answerIdAsInt})
+
+		resp, err = sendPutRequest(client, acceptedAnswerUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		askerIdAsInt = userIdAsInt
+		resp, err = sendPutRequest(client, acceptedAnswerUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, models.NoteId(answerIdAsInt), acceptedAnswerId)
+
+		// unanswered questions can be listed
+		mockDb.Func_GetNoteCategories = func(noteIds []models.NoteId) (map[models.NoteId]models.NoteCategory, error) {
+			return map[models.NoteId]models.NoteCategory{
+				models.NoteId(noteIdAsInt):     models.QUESTION,
+				models.NoteId(questionIdAsInt): models.QUESTION,
+			}, nil
+		}
+
+		mockDb.Func_GetAnsweredQuestionIds = func(noteIds []models.NoteId) (map[models.NoteId]bool, error) {
+			return map[models.NoteId]bool{models.NoteId(questionIdAsInt): true}, nil
+		}
+
+		mockDb.Func_GetMyUnpublishedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById(map[models.NoteId]*models.Note{
+				models.NoteId(noteIdAsInt):     {AuthorId: userId, Content: content},
+				models.NoteId(questionIdAsInt): {AuthorId: userId, Content: "Why?"},
+				models.NoteId(answerIdAsInt):   {AuthorId: userId, Content: "Because."},
+			}), nil
+		}
+
+		resp, err = client.Get(server.URL + paths.NoteApi + "?category=question&unanswered=true")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		notes := make(map[string]*models.Note)
+		err = json.NewDecoder(resp.Body).Decode(&notes)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 1, len(notes))
+		_, ok := notes[strconv.FormatInt(noteIdAsInt, 10)]
+		test_util.Assert(t, ok, "Expected only the unanswered question")
+
+		resp, err = client.Get(server.URL + paths.NoteApi + "?category=riddle")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	// Test sources
+	t.Run("Sources", func(t *testing.T) {
+		sourceIdAsInt := int64(9)
+		var attachedLocation *models.SourceLocation
+
+		mockDb.Func_GetNoteById = func(noteId models.NoteId) (*models.Note, error) {
+			if int64(noteId) != noteIdAsInt {
+				return nil, models.NoNoteFoundError
+			}
+			return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: content, CreationTime: time.Now()}, nil
+		}
+
+		mockDb.Func_StoreSource = func(source *models.Source) (models.SourceId, error) {
+			if err := source.Normalize(); err != nil {
+				return 0, err
+			}
+			return models.SourceId(sourceIdAsInt), nil
+		}
+
+		mockDb.Func_GetSource = func(sourceId models.SourceId) (*models.Source, error) {
+			if int64(sourceId) != sourceIdAsInt {
+				return nil, models.NoSourceFoundError
+			}
+			return &models.Source{Id: sourceId, Title: "Dune", Isbn: "9780441172719"}, nil
+		}
+
+		mockDb.Func_AttachNoteSource = func(noteId models.NoteId, sourceId models.SourceId, location *models.SourceLocation) error {
+			attachedLocation = location
+			return nil
+		}
+
+		mockDb.Func_GetSourceNotesVisibleBy = func(sourceId models.SourceId, userId models.UserId) ([]*models.SourceNote, error) {
+			return []*models.SourceNote{{NoteId: models.NoteId(noteIdAsInt), Content: content, Location: attachedLocation}}, nil
+		}
+
+		noteSourceUrl := server.URL + paths.NoteSourceApi + "?id=" + strconv.FormatInt(noteIdAsInt, 10)
+
+		jsonValue, _ := json.Marshal(map[string]interface{}{
+			"source":   map[string]string{"title": "Dune", "isbn": "0-441-17271-7"},
+			"location": map[string]string{"type": "chapter", "value": "3"},
+		})
+		resp, err := sendPutRequest(client, noteSourceUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, &models.SourceLocation{Type: models.CHAPTER, Value: "3"}, attachedLocation)
+
+		jsonValue, _ = json.Marshal(map[string]interface{}{"source": map[string]string{"title": "Dune", "isbn": "123"}})
+		resp, err = sendPutRequest(client, noteSourceUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		jsonValue, _ = json.Marshal(map[string]interface{}{"location": map[string]string{"type": "page", "value": "3"}})
+		resp, err = sendPutRequest(client, noteSourceUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = client.Get(server.URL + paths.SourceNotesApi + "?id=" + strconv.FormatInt(sourceIdAsInt, 10))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		type SourceNotesResponse struct {
+			Source *models.Source       `json:"source"`
+			Notes  []*models.SourceNote `json:"notes"`
+		}
+
+		sourceNotes := &SourceNotesResponse{}
+		err = json.NewDecoder(resp.Body).Decode(sourceNotes)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, "Dune", sourceNotes.Source.Title)
+		test_util.Equals(t, 1, len(sourceNotes.Notes))
+
+		resp, err = client.Get(server.URL + paths.SourceNotesApi + "?id=404")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	// Test categories
+	t.Run("Categories", func(t *testing.T) {
+		categories := []*models.Category{
+			{Name: models.MARGINALIA, Colour: "#607d8b"},
+			{Name: models.QUESTION, Colour: "#2196f3"},
+			{Name: "film-club", Colour: "#ff0000", CreatorId: models.UserId(99)},
+		}
+
+		mockDb.Func_GetCategories = func() ([]*models.Category, error) {
+			return categories, nil
+		}
+
+		mockDb.Func_StoreNewCategory = func(category *models.Category) error {
+			if err := category.Validate(); err != nil {
+				return err
+			}
+			categories = append(categories, category)
+			return nil
+		}
+
+		mockDb.Func_UpdateCategory = func(name models.NoteCategory, category *models.Category) error {
+			if err := category.Validate(); err != nil {
+				return err
+			}
+			for i, existing := range categories {
+				if existing.Name == name {
+					category.CreatorId = existing.CreatorId
+					categories[i] = category
+					return nil
+				}
+			}
+			return models.NoCategoryFoundError
+		}
+
+		mockDb.Func_GetCategory = func(name models.NoteCategory) (*models.Category, error) {
+			for _, category := range categories {
+				if category.Name == name {
+					return category, nil
+				}
+			}
+			return nil, models.NoCategoryFoundError
+		}
+
+		mockDb.Func_DeleteCategory = func(name models.NoteCategory) error {
+			if name == models.MARGINALIA {
+				return models.CategoryInUseError
+			}
+			return nil
+		}
+
+		jsonValue, _ := json.Marshal(map[string]string{"name": "Book-Club", "colour": "#00FF00"})
+		resp, err := client.Post(server.URL+paths.CategoryApi, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		jsonValue, _ = json.Marshal(map[string]string{"name": "book club", "colour": "green"})
+		resp, err = client.Post(server.URL+paths.CategoryApi, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		jsonValue, _ = json.Marshal(map[string]interface{}{"name": "book-club", "colour": "#0000ff", "sortOrder": 7})
+		resp, err = sendPutRequest(client, server.URL+paths.CategoryApi+"?name=book-club", "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = client.Get(server.URL + paths.CategoryApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		storedCategories := make([]*models.Category, 0)
+		err = json.NewDecoder(resp.Body).Decode(&storedCategories)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 4, len(storedCategories))
+		test_util.Equals(t, "#0000ff", storedCategories[3].Colour)
+		test_util.Equals(t, 7, storedCategories[3].SortOrder)
+		test_util.Equals(t, models.UserId(userIdAsInt), storedCategories[3].CreatorId)
+
+		// only a category's creator or an admin can change it
+		jsonValue, _ = json.Marshal(map[string]string{"name": "film-club", "colour": "#000000"})
+		resp, err = sendPutRequest(client, server.URL+paths.CategoryApi+"?name=film-club", "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.CategoryApi+"?name=film-club")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.CategoryApi+"?name=marginalia")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.CategoryApi+"?name=no-such-category")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		env.Admins = map[models.UserId]bool{models.UserId(userIdAsInt): true}
+		defer func() { env.Admins = nil }()
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.CategoryApi+"?name=marginalia")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusConflict, resp.StatusCode)
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.CategoryApi+"?name=film-club")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		env.Admins = nil
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.CategoryApi+"?name=book-club")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+	})
+
+	// Test replies
+	t.Run("Replies", func(t *testing.T) {
+		publishedNoteIdAsInt := int64(44)
+		otherUserId := models.UserId(99)
+		storedReplies := make([]*models.Reply, 0)
+
+		mockDb.Func_IsPublishedNoteVisibleTo = func(noteId models.NoteId, userId models.UserId) (bool, error) {
+			return int64(noteId) == publishedNoteIdAsInt, nil
+		}
+
+		mockDb.Func_StoreNewReply = func(reply *models.Reply) (models.ReplyId, error) {
+			if reply.ParentId != nil && int(*reply.ParentId) > len(storedReplies) {
+				return 0, models.NoReplyFoundError
+			}
+			storedReplies = append(storedReplies, reply)
+			return models.ReplyId(len(storedReplies)), nil
+		}
+
+		mockDb.Func_GetReplies = func(noteId models.NoteId) ([]*models.Reply, error) {
+			return append([]*models.Reply{
+				{Id: models.ReplyId(100), NoteId: noteId, AuthorId: otherUserId, Content: "someone else's reply"},
+			}, storedReplies...), nil
+		}
+
+		mockDb.Func_GetReply = func(replyId models.ReplyId) (*models.Reply, error) {
+			if replyId == models.ReplyId(100) {
+				return &models.Reply{Id: replyId, AuthorId: otherUserId}, nil
+			}
+			if int(replyId) > len(storedReplies) {
+				return nil, models.NoReplyFoundError
+			}
+			return storedReplies[replyId-1], nil
+		}
+
+		mockDb.Func_UpdateReplyContent = func(replyId models.ReplyId, content string) error {
+			storedReplies[replyId-1].Content = content
+			return nil
+		}
+
+		mockDb.Func_DeleteReply = func(replyId models.ReplyId) error {
+			storedReplies[replyId-1].Deleted = true
+			return nil
+		}
+
+		replyUrl := server.URL + paths.ReplyApi + "?noteId=" + strconv.FormatInt(publishedNoteIdAsInt, 10)
+
+		jsonValue, _ := json.Marshal(map[string]interface{}{"content": "a reply"})
+		resp, err := client.Post(replyUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		reply := &models.Reply{}
+		err = json.NewDecoder(resp.Body).Decode(reply)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+
+		jsonValue, _ = json.Marshal(map[string]interface{}{"content": "a nested reply", "parentId": reply.Id})
+		resp, err = client.Post(replyUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		jsonValue, _ = json.Marshal(map[string]interface{}{"content": "a reply to nothing", "parentId": 404})
+		resp, err = client.Post(replyUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		// unpublished notes can't be replied to
+		jsonValue, _ = json.Marshal(map[string]interface{}{"content": "a reply"})
+		resp, err = client.Post(server.URL+paths.ReplyApi+"?noteId="+strconv.FormatInt(noteIdAsInt, 10), "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = client.Get(replyUrl)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		threads := make([]*models.Reply, 0)
+		err = json.NewDecoder(resp.Body).Decode(&threads)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 2, len(threads))
+		test_util.Equals(t, 1, len(threads[1].Replies))
+		test_util.Equals(t, "a nested reply", threads[1].Replies[0].Content)
+
+		ownReplyUrl := server.URL + paths.ReplyApi + "?id=" + strconv.FormatInt(int64(reply.Id), 10)
+
+		jsonValue, _ = json.Marshal(map[string]interface{}{"content": "an edited reply"})
+		resp, err = sendPutRequest(client, ownReplyUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, "an edited reply", storedReplies[0].Content)
+
+		resp, err = sendPutRequest(client, server.URL+paths.ReplyApi+"?id=100", "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = sendDeleteUrl(client, ownReplyUrl)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Assert(t, storedReplies[0].Deleted, "reply should have been deleted")
+	})
+
+	// Test reactions
+	t.Run("Reactions", func(t *testing.T) {
+		publishedNoteIdAsInt := int64(44)
+		reactedWith := make(map[string]bool)
+
+		mockDb.Func_IsPublishedNoteVisibleTo = func(noteId models.NoteId, userId models.UserId) (bool, error) {
+			return int64(noteId) == publishedNoteIdAsInt, nil
+		}
+
+		mockDb.Func_ToggleReaction = func(noteId models.NoteId, userId models.UserId, reaction string) (bool, error) {
+			reactedWith[reaction] = !reactedWith[reaction]
+			return reactedWith[reaction], nil
+		}
+
+		mockDb.Func_GetReactionSummaries = func(noteIds []models.NoteId, userId models.UserId) (map[models.NoteId][]*models.ReactionSummary, error) {
+			summaries := make(map[models.NoteId][]*models.ReactionSummary)
+			for reaction, reacted := range reactedWith {
+				if reacted {
+					summaries[noteIds[0]] = append(summaries[noteIds[0]], &models.ReactionSummary{Reaction: reaction, Count: 1, ReactedByMe: true})
+				}
+			}
+			return summaries, nil
+		}
+
+		resp, err := client.Get(server.URL + paths.ReactionApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		availableReactions := make([]string, 0)
+		err = json.NewDecoder(resp.Body).Decode(&availableReactions)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, []string{"👍", "🎉"}, availableReactions)
+
+		reactionUrl := server.URL + paths.ReactionApi + "?noteId=" + strconv.FormatInt(publishedNoteIdAsInt, 10)
+		jsonValue, _ := json.Marshal(map[string]string{"reaction": "🎉"})
+
+		resp, err = client.Post(reactionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		reactions := make([]*models.ReactionSummary, 0)
+		err = json.NewDecoder(resp.Body).Decode(&reactions)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, []*models.ReactionSummary{{Reaction: "🎉", Count: 1, ReactedByMe: true}}, reactions)
+
+		// reacting again takes the reaction back
+		resp, err = client.Post(reactionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		reactions = nil
+		err = json.NewDecoder(resp.Body).Decode(&reactions)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 0, len(reactions))
+
+		jsonValue, _ = json.Marshal(map[string]string{"reaction": "💩"})
+		resp, err = client.Post(reactionUrl, "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		jsonValue, _ = json.Marshal(map[string]string{"reaction": "👍"})
+		resp, err = client.Post(server.URL+paths.ReactionApi+"?noteId="+strconv.FormatInt(noteIdAsInt, 10), "application/json", bytes.NewBuffer(jsonValue))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	// Test note graph
+	t.Run("Note Graph", func(t *testing.T) {
+		hiddenNoteId := models.NoteId(77)
+
+		mockDb.Func_GetMyUnpublishedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{
+				models.NoteId(noteIdAsInt): {AuthorId: userId, Content: "see [[note:44]] and [[note:77]]"},
+			}, nil
+		}
+
+		mockDb.Func_GetAllPublishedNotesVisibleBy = func(userId models.UserId) (map[int64]models.NotesById, error) {
+			return map[int64]models.NotesById{
+				1: {models.NoteId(44): {AuthorId: models.UserId(99), Content: "another note"}},
+			}, nil
+		}
+
+		mockDb.Func_GetNoteLinksBetween = func(noteIds []models.NoteId) ([]*models.NoteLink, error) {
+			for _, noteId := range noteIds {
+				if noteId == hiddenNoteId {
+					return nil, errors.New("Notes the user can't see should not be looked up")
+				}
+			}
+			return []*models.NoteLink{{SourceNoteId: models.NoteId(noteIdAsInt), TargetNoteId: models.NoteId(44)}}, nil
+		}
+
+		resp, err := client.Get(server.URL + paths.NoteGraphApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		graph := &handlers.NoteGraph{}
+		err = json.NewDecoder(resp.Body).Decode(graph)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 2, len(graph.Nodes))
+		test_util.Equals(t, models.NoteId(noteIdAsInt), graph.Nodes[0].Id)
+		test_util.Equals(t, []*models.NoteLink{{SourceNoteId: models.NoteId(noteIdAsInt), TargetNoteId: models.NoteId(44)}}, graph.Edges)
+	})
+
+	// Test attachments
+	var storedAttachment *models.Attachment
+
+	t.Run("Attachments", func(t *testing.T) {
+		othersNoteId := models.NoteId(44)
+		hiddenNoteId := models.NoteId(55)
+
+		mockDb.Func_GetNoteById = func(noteId models.NoteId) (*models.Note, error) {
+			switch noteId {
+			case models.NoteId(noteIdAsInt):
+				return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: content}, nil
+			case othersNoteId, hiddenNoteId:
+				return &models.Note{AuthorId: models.UserId(99), Content: "another note"}, nil
+			default:
+				return nil, models.NoNoteFoundError
+			}
+		}
+
+		mockDb.Func_IsPublishedNoteVisibleTo = func(noteId models.NoteId, userId models.UserId) (bool, error) {
+			return noteId == othersNoteId, nil
+		}
+
+		mockDb.Func_StoreNewAttachment = func(attachment *models.Attachment) (models.AttachmentId, error) {
+			storedAttachment = attachment
+			return models.AttachmentId(5), nil
+		}
+
+		mockDb.Func_GetAttachment = func(attachmentId models.AttachmentId) (*models.Attachment, error) {
+			if storedAttachment == nil || attachmentId != storedAttachment.Id {
+				return nil, models.NoAttachmentFoundError
+			}
+			return storedAttachment, nil
+		}
+
+		upload := func(noteId models.NoteId, fileName string, fileContent []byte) *http.Response {
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("file", fileName)
+			test_util.Ok(t, err)
+			_, err = part.Write(fileContent)
+			test_util.Ok(t, err)
+			test_util.Ok(t, writer.Close())
+
+			resp, err := client.Post(
+				server.URL+paths.NoteAttachmentApi+"?noteId="+strconv.FormatInt(int64(noteId), 10),
+				writer.FormDataContentType(),
+				&body)
+			test_util.Ok(t, err)
+			resp.Body.Close()
+
+			return resp
+		}
+
+		// an image is stored along with a thumbnail that fits in 256 pixels
+		var imageContent bytes.Buffer
+		err := png.Encode(&imageContent, image.NewNRGBA(image.Rect(0, 0, 600, 300)))
+		test_util.Ok(t, err)
+
+		resp := upload(models.NoteId(noteIdAsInt), "C:\\pictures\\wide.png", imageContent.Bytes())
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+		test_util.Equals(t, "wide.png", storedAttachment.FileName)
+		test_util.Equals(t, "image/png", storedAttachment.ContentType)
+		test_util.Equals(t, int64(imageContent.Len()), storedAttachment.Size)
+		test_util.Assert(t, storedAttachment.HasThumbnail, "images should get a thumbnail")
+
+		attachmentUrl := server.URL + paths.NoteAttachmentContentApi + "?id=5"
+
+		resp, err = client.Get(attachmentUrl)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, "image/png", resp.Header.Get("Content-Type"))
+		test_util.Equals(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+		served, err := ioutil.ReadAll(resp.Body)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, imageContent.Bytes(), served)
+
+		resp, err = client.Get(attachmentUrl + "&thumbnail=true")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		thumbnail, err := png.DecodeConfig(resp.Body)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 256, thumbnail.Width)
+		test_util.Equals(t, 128, thumbnail.Height)
+
+		// the type is judged by content, whatever the name says
+		resp = upload(models.NoteId(noteIdAsInt), "notes.txt", []byte("<html><script>alert(1)</script></html>"))
+		test_util.Equals(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+		resp = upload(models.NoteId(noteIdAsInt), "huge.txt", bytes.Repeat([]byte("a"), 11<<20))
+		test_util.Equals(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		// only the author can attach files, and only readers of the note can see them
+		resp = upload(othersNoteId, "notes.txt", []byte("hello"))
+		test_util.Equals(t, http.StatusForbidden, resp.StatusCode)
+
+		mockDb.Func_GetNoteAttachments = func(noteId models.NoteId) ([]*models.Attachment, error) {
+			return []*models.Attachment{storedAttachment}, nil
+		}
+
+		resp, err = client.Get(server.URL + paths.NoteAttachmentApi + "?noteId=" + strconv.FormatInt(int64(othersNoteId), 10))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+
+		resp, err = client.Get(server.URL + paths.NoteAttachmentApi + "?noteId=" + strconv.FormatInt(int64(hiddenNoteId), 10))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+		resp.Body.Close()
+	})
+
+	// Test publish notes
+	t.Run("Publish Notes", func(t *testing.T) {
+		mockDb.Func_PublishIssue = func(userId models.UserId, draft *models.IssueDraft) (models.PublicationId, error) {
+			return models.PublicationId(1), nil
+		}
+		// publish new api
+		resp, err := client.Post(server.URL+paths.PublicationApi, "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	// Delete note
+	t.Run("Delete Note", func(t *testing.T) {
+		mockDb.Func_GetUsersNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById(map[models.NoteId]*models.Note{
+				models.NoteId(noteIdAsInt): &models.Note{
+					AuthorId:     models.UserId(userIdAsInt),
+					Content:      content,
+					CreationTime: time.Now(),
+				},
+			}), nil
+		}
+
+		mockDb.Func_TrashNote = func(noteid models.NoteId, deletionTime time.Time) error {
+			if int64(noteid) == noteIdAsInt {
+				return nil
+			}
+
+			return errors.New("Somehow you didn't get the correct error")
+		}
+
+		resp, err := sendDeleteUrl(client, server.URL+paths.NoteApi+"?id="+strconv.FormatInt(noteIdAsInt, 10))
+		test_util.Ok(t, err)
+
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		// the note's attachments are kept while it can still be restored
+		for _, key := range []string{storedAttachment.BlobKey, storedAttachment.ThumbnailKey} {
+			blob, err := env.Blobs.Get(key)
+			test_util.Ok(t, err)
+			blob.Close()
+		}
+	})
+
+	t.Run("Emitted Events", func(t *testing.T) {
+		test_util.Equals(t, []models.WebhookEventType{
+			models.NOTE_CREATED,
+			models.NOTE_UPDATED,
+			models.NOTE_CATEGORIZED,
+			models.NOTE_CATEGORIZED,
+			models.NOTE_CATEGORIZED,
+			// imported notes
+			models.NOTE_CREATED,
+			models.NOTE_CREATED,
+			models.PREDICTION_RESOLVED,
+			models.NOTE_CREATED,
+			models.PUBLICATION_CREATED,
+			models.NOTE_DELETED,
+		}, emittedEvents)
+	})
+
+	t.Run("Live Events", func(t *testing.T) {
+		openStream := func(lastEventId string) (*http.Response, *bufio.Reader, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			request, err := http.NewRequest(http.MethodGet, server.URL+paths.EventsApi, nil)
+			test_util.Ok(t, err)
+			request.Header.Set("Last-Event-ID", lastEventId)
+
+			resp, err := client.Do(request.WithContext(ctx))
+			test_util.Ok(t, err)
+
+			return resp, bufio.NewReader(resp.Body), cancel
+		}
+
+		readEventType := func(reader *bufio.Reader) string {
+			for {
+				line, err := reader.ReadString('\n')
+				test_util.Ok(t, err)
+
+				if strings.HasPrefix(line, "event: ") {
+					return strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+				}
+			}
+		}
+
+		// resuming replays what was missed, then streams new events
+		resp, reader, cancel := openStream(strconv.Itoa(len(emittedEvents) - 1))
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		test_util.Equals(t, string(models.NOTE_DELETED), readEventType(reader))
+
+		resp, err := client.Post(server.URL+paths.PublicationApi, "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		test_util.Equals(t, string(models.PUBLICATION_CREATED), readEventType(reader))
+		cancel()
+
+		// ids the hub no longer knows about ask the client to reload
+		resp, reader, cancel = openStream("1000")
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, "reset", readEventType(reader))
+		cancel()
+
+		resp, _, cancel = openStream("notAnId")
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+		cancel()
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		deletionTime := time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC)
+		env.TrashRetention = time.Hour * 24 * 30
+
+		mockDb.Func_GetTrashedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{
+				models.NoteId(noteIdAsInt): {AuthorId: userId, Content: content, DeletionTime: &deletionTime},
+			}, nil
+		}
+
+		resp, err := client.Get(server.URL + paths.TrashApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		trashedNotes := make([]*handlers.TrashedNote, 0)
+		err = json.NewDecoder(resp.Body).Decode(&trashedNotes)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, 1, len(trashedNotes))
+		test_util.Equals(t, models.NoteId(noteIdAsInt), trashedNotes[0].Id)
+		test_util.Equals(t, time.Date(2019, time.March, 31, 12, 0, 0, 0, time.UTC), trashedNotes[0].PurgeTime)
+
+		restored := make([]models.NoteId, 0)
+		mockDb.Func_RestoreNote = func(noteId models.NoteId) error {
+			restored = append(restored, noteId)
+			return nil
+		}
+
+		resp, err = client.Post(server.URL+paths.TrashRestoreApi+"?id="+strconv.FormatInt(noteIdAsInt, 10), "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, []models.NoteId{models.NoteId(noteIdAsInt)}, restored)
+
+		// only notes in your own trash can be restored
+		resp, err = client.Post(server.URL+paths.TrashRestoreApi+"?id=12345", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		// purging removes the note's attachments from the blob store
+		mockDb.Func_PurgeNote = func(noteId models.NoteId, purgeTime time.Time) ([]*models.Attachment, error) {
+			return []*models.Attachment{storedAttachment}, nil
+		}
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.TrashApi+"?id="+strconv.FormatInt(noteIdAsInt, 10))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		for _, key := range storedAttachment.BlobKeys() {
+			_, err = env.Blobs.Get(key)
+			test_util.Equals(t, blobstore.NoBlobFoundError, err)
+		}
+	})
+
+	t.Run("Retract Publication", func(t *testing.T) {
+		retractionReason := "  Published the wrong drafts  "
+
+		mockDb.Func_RetractPublication = func(authorId models.UserId, publicationId models.PublicationId, reason string, now time.Time) (models.RetractionOutcome, error) {
+			if authorId != models.UserId(userIdAsInt) || publicationId != models.PublicationId(1) {
+				return "", models.NoPublicationFoundError
+			}
+			if len(reason) == 0 {
+				return "", models.RetractionReasonRequiredError
+			}
+			test_util.Equals(t, "Published the wrong drafts", reason)
+			return models.RETRACTED, nil
+		}
+
+		resp, err := client.Post(server.URL+paths.PublicationRetractionApi+"?id=1", "application/json", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = client.Post(server.URL+paths.PublicationRetractionApi+"?id=2", "application/json", strings.NewReader(`{"reason": "not mine"}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		retractionJson, _ := json.Marshal(map[string]string{"reason": retractionReason})
+		resp, err = client.Post(server.URL+paths.PublicationRetractionApi+"?id=1", "application/json", bytes.NewBuffer(retractionJson))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		outcome := make(map[string]string)
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(&outcome))
+		resp.Body.Close()
+		test_util.Equals(t, string(models.RETRACTED), outcome["outcome"])
+
+		// readers see the reason in place of the issue's notes
+		retractedTime := time.Now().UTC()
+		mockDb.Func_GetPublishedIssuesVisibleBy = func(userId models.UserId) ([]*models.PublishedIssue, error) {
+			return []*models.PublishedIssue{{
+				PublicationId:    models.PublicationId(1),
+				AuthorId:         models.UserId(userIdAsInt),
+				IssueNumber:      1,
+				Notes:            models.NotesById{},
+				RetractedTime:    &retractedTime,
+				RetractionReason: "Published the wrong drafts",
+			}}, nil
+		}
+
+		resp, err = client.Get(server.URL + paths.PublicationApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		issues := make([]*models.PublishedIssue, 0)
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(&issues))
+		resp.Body.Close()
+		test_util.Equals(t, "Published the wrong drafts", issues[0].RetractionReason)
+	})
+
+	t.Run("Issue Layout", func(t *testing.T) {
+		firstNoteId, secondNoteId := models.NoteId(7), models.NoteId(8)
+		unpublishedNotes := models.NotesById{
+			firstNoteId:  &models.Note{AuthorId: models.UserId(userIdAsInt), Content: "first", CreationTime: time.Now().UTC()},
+			secondNoteId: &models.Note{AuthorId: models.UserId(userIdAsInt), Content: "second", CreationTime: time.Now().UTC()},
+		}
+
+		mockDb.Func_PreviewIssue = func(userId models.UserId, draft *models.IssueDraft) (*models.PublishedIssue, error) {
+			if err := draft.Validate(); err != nil {
+				return nil, err
+			}
+			noteOrder, err := models.OrderIssueNotes(draft, unpublishedNotes, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			return &models.PublishedIssue{
+				AuthorId:    userId,
+				IssueNumber: 2,
+				Title:       draft.Title,
+				Intro:       draft.Intro,
+				Notes:       unpublishedNotes,
+				NoteOrder:   noteOrder,
+			}, nil
+		}
+
+		resp, err := client.Post(
+			server.URL+paths.PublicationPreviewApi,
+			"application/json",
+			strings.NewReader(`{"title": " Spring ", "intro": "*Welcome*", "noteOrder": [8]}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		preview := &models.PublishedIssue{}
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(preview))
+		resp.Body.Close()
+		test_util.Equals(t, "Spring", preview.Title)
+		test_util.Equals(t, "<p><em>Welcome</em></p>\n", preview.IntroHtml)
+		test_util.Equals(t, []models.NoteId{secondNoteId, firstNoteId}, preview.NoteOrder)
+		test_util.Equals(t, "<p>second</p>\n", preview.Notes[secondNoteId].ContentHtml)
+
+		// notes can't be listed twice or be someone else's
+		resp, err = client.Post(server.URL+paths.PublicationPreviewApi, "application/json", strings.NewReader(`{"noteOrder": [8, 8]}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		mockDb.Func_PublishIssue = func(userId models.UserId, draft *models.IssueDraft) (models.PublicationId, error) {
+			if _, err := models.OrderIssueNotes(draft, unpublishedNotes, nil, nil); err != nil {
+				return 0, err
+			}
+			test_util.Equals(t, "Spring", draft.Title)
+			return models.PublicationId(2), nil
+		}
+
+		resp, err = client.Post(server.URL+paths.PublicationApi, "application/json", strings.NewReader(`{"title": "Spring", "noteOrder": [9]}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = client.Post(server.URL+paths.PublicationApi, "application/json", strings.NewReader(`{"title": "Spring", "noteOrder": [8, 7]}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Read Markers", func(t *testing.T) {
+		readNoteIds := make(map[models.NoteId]bool)
+
+		mockDb.Func_IsPublishedNoteVisibleTo = func(noteId models.NoteId, userId models.UserId) (bool, error) {
+			return noteId == models.NoteId(44), nil
+		}
+		mockDb.Func_MarkNoteRead = func(userId models.UserId, noteId models.NoteId, readTime time.Time) error {
+			readNoteIds[noteId] = true
+			return nil
+		}
+		mockDb.Func_MarkNoteUnread = func(userId models.UserId, noteId models.NoteId) error {
+			delete(readNoteIds, noteId)
+			return nil
+		}
+		mockDb.Func_GetUnreadNoteIds = func(userId models.UserId) (map[models.NoteId]bool, error) {
+			if readNoteIds[models.NoteId(44)] {
+				return map[models.NoteId]bool{}, nil
+			}
+			return map[models.NoteId]bool{models.NoteId(44): true}, nil
+		}
+
+		getNoteIds := func(url string) []string {
+			resp, err := client.Get(url)
+			test_util.Ok(t, err)
+			test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+			notesById := make(map[string]models.Note)
+			test_util.Ok(t, json.NewDecoder(resp.Body).Decode(&notesById))
+			resp.Body.Close()
+
+			noteIds := make([]string, 0, len(notesById))
+			for noteId := range notesById {
+				noteIds = append(noteIds, noteId)
+			}
+			return noteIds
+		}
+
+		test_util.Equals(t, []string{"44"}, getNoteIds(server.URL+paths.NoteApi+"?unread=true"))
+
+		resp, err := client.Post(server.URL+paths.NoteReadApi+"?id=44", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, []string{}, getNoteIds(server.URL+paths.NoteApi+"?unread=true"))
+
+		resp, err = sendDeleteRequest(client, server.URL+paths.NoteReadApi+"?id=44", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, []string{"44"}, getNoteIds(server.URL+paths.NoteApi+"?unread=true"))
+
+		// notes the user can't read can't be marked
+		resp, err = client.Post(server.URL+paths.NoteReadApi+"?id=45", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		mockDb.Func_MarkPublicationRead = func(userId models.UserId, publicationId models.PublicationId, readTime time.Time) error {
+			if publicationId != models.PublicationId(1) {
+				return models.NoPublicationFoundError
+			}
+			readNoteIds[models.NoteId(44)] = true
+			return nil
+		}
+
+		resp, err = client.Post(server.URL+paths.PublicationReadApi+"?id=2", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = client.Post(server.URL+paths.PublicationReadApi+"?id=1", "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, []string{}, getNoteIds(server.URL+paths.NoteApi+"?unread=true"))
+
+		mockDb.Func_MarkAllRead = func(userId models.UserId, readTime time.Time) error {
+			test_util.Equals(t, models.UserId(userIdAsInt), userId)
+			return nil
+		}
+
+		resp, err = client.Post(server.URL+paths.MarkAllReadApi, "", nil)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		mockDb.Func_GetUnreadCounts = func(userId models.UserId) (*models.UnreadCounts, error) {
+			return &models.UnreadCounts{
+				Total:      3,
+				ByAuthor:   map[models.UserId]int{models.UserId(99): 3},
+				ByCategory: map[models.NoteCategory]int{models.QUESTION: 2},
+			}, nil
+		}
+
+		resp, err = client.Get(server.URL + paths.UnreadApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		counts := &models.UnreadCounts{}
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(counts))
+		resp.Body.Close()
+		test_util.Equals(t, 3, counts.ByAuthor[models.UserId(99)])
+		test_util.Equals(t, 2, counts.ByCategory[models.QUESTION])
+	})
+
+	t.Run("Collections", func(t *testing.T) {
+		collectionsById := make(map[models.CollectionId]*models.Collection)
+		collectionNotes := make([]*models.CollectionNote, 0)
+
+		mockDb.Func_StoreNewCollection = func(collection *models.Collection) (models.CollectionId, error) {
+			for _, existing := range collectionsById {
+				if existing.OwnerId == collection.OwnerId && existing.Name == collection.Name {
+					return 0, models.CollectionAlreadyExistsError
+				}
+			}
+			collectionId := models.CollectionId(len(collectionsById) + 1)
+			stored := *collection
+			stored.Id = collectionId
+			collectionsById[collectionId] = &stored
+			return collectionId, nil
+		}
+		mockDb.Func_GetCollection = func(collectionId models.CollectionId) (*models.Collection, error) {
+			if collection, ok := collectionsById[collectionId]; ok {
+				return collection, nil
+			}
+			return nil, models.NoCollectionFoundError
+		}
+		mockDb.Func_GetNoteById = func(noteId models.NoteId) (*models.Note, error) {
+			switch noteId {
+			case models.NoteId(44):
+				return &models.Note{AuthorId: models.UserId(99), Content: "another *note*", CreationTime: time.Now().UTC()}, nil
+			case models.NoteId(noteIdAsInt):
+				return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: content, CreationTime: time.Now().UTC()}, nil
+			}
+			return nil, models.NoNoteFoundError
+		}
+		mockDb.Func_AddNoteToCollection = func(collectionId models.CollectionId, noteId models.NoteId, annotation string, addedTime time.Time) error {
+			note, _ := mockDb.Func_GetNoteById(noteId)
+			collectionNotes = append(collectionNotes, &models.CollectionNote{
+				NoteId:     noteId,
+				Note:       note,
+				Annotation: annotation,
+				Position:   len(collectionNotes) + 1,
+				AddedTime:  addedTime,
+			})
+			return nil
+		}
+		mockDb.Func_GetCollectionNotes = func(collectionId models.CollectionId) ([]*models.CollectionNote, error) {
+			return collectionNotes, nil
+		}
+		mockDb.Func_GetAllUsersById = func() (models.UsersById, error) {
+			return models.UsersById(map[models.UserId]*models.User{
+				models.UserId(99): &models.User{DisplayName: "alice"},
+			}), nil
+		}
+
+		resp, err := client.Post(server.URL+paths.CollectionApi, "application/json", strings.NewReader(`{"name": "  "}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = client.Post(server.URL+paths.CollectionApi, "application/json", strings.NewReader(`{"name": " Favourite   quotes "}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		collection := &models.Collection{}
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(collection))
+		resp.Body.Close()
+		test_util.Equals(t, "Favourite quotes", collection.Name)
+
+		resp, err = client.Post(server.URL+paths.CollectionApi, "application/json", strings.NewReader(`{"name": "Favourite quotes"}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusConflict, resp.StatusCode)
+
+		collectionNoteUrl := server.URL + paths.CollectionNoteApi + "?collectionId=" + strconv.FormatInt(int64(collection.Id), 10)
+
+		// only other authors' notes the user can see can be collected
+		resp, err = client.Post(collectionNoteUrl, "application/json", strings.NewReader(`{"noteId": `+strconv.FormatInt(noteIdAsInt, 10)+`}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = client.Post(collectionNoteUrl, "application/json", strings.NewReader(`{"noteId": 45}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = client.Post(collectionNoteUrl, "application/json", strings.NewReader(`{"noteId": 44, "annotation": " Worth rereading "}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusCreated, resp.StatusCode)
+
+		resp, err = client.Get(collectionNoteUrl)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		notes := make([]*models.CollectionNote, 0)
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(&notes))
+		resp.Body.Close()
+		test_util.Equals(t, 1, len(notes))
+		test_util.Equals(t, "Worth rereading", notes[0].Annotation)
+		test_util.Equals(t, "<p>another <em>note</em></p>\n", notes[0].Note.ContentHtml)
+
+		resp, err = client.Get(server.URL + paths.CollectionExportApi + "?id=" + strconv.FormatInt(int64(collection.Id), 10))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		export, err := ioutil.ReadAll(resp.Body)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Assert(t, bytes.Contains(export, []byte("> Worth rereading")), "Expected the annotation in the export")
+
+		// collections are private
+		collectionsById[models.CollectionId(99)] = &models.Collection{Id: models.CollectionId(99), OwnerId: models.UserId(99), Name: "alice's"}
+
+		resp, err = client.Get(server.URL + paths.CollectionNoteApi + "?collectionId=99")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Note States", func(t *testing.T) {
+		draftNoteId := models.NoteId(noteIdAsInt)
+		archivedNoteId := models.NoteId(noteIdAsInt + 1)
+		pinnedTime := time.Now().UTC()
+
+		unpublishedNotes := models.NotesById{
+			draftNoteId:    &models.Note{AuthorId: models.UserId(userIdAsInt), Content: content, CreationTime: time.Now().UTC()},
+			archivedNoteId: &models.Note{AuthorId: models.UserId(userIdAsInt), Content: "old draft", CreationTime: time.Now().UTC()},
+		}
+
+		mockDb.Func_GetMyUnpublishedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return unpublishedNotes, nil
+		}
+		mockDb.Func_UpdateNoteStates = func(authorId models.UserId, noteIds []models.NoteId, pinned *bool, archived *bool, now time.Time) error {
+			for _, noteId := range noteIds {
+				note, ok := unpublishedNotes[noteId]
+				if !ok {
+					return models.NoNoteFoundError
+				}
+				if pinned != nil && *pinned {
+					note.PinnedTime = &pinnedTime
+				}
+				if archived != nil && *archived {
+					note.ArchivedTime = &now
+				}
+			}
+			return nil
+		}
+
+		getNoteIds := func(url string) []string {
+			resp, err := client.Get(url)
+			test_util.Ok(t, err)
+			test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+			notesById := make(map[string]models.Note)
+			test_util.Ok(t, json.NewDecoder(resp.Body).Decode(&notesById))
+			resp.Body.Close()
+
+			noteIds := make([]string, 0, len(notesById))
+			for noteId, note := range notesById {
+				if note.AuthorId == models.UserId(userIdAsInt) {
+					noteIds = append(noteIds, noteId)
+				}
+			}
+			sort.Strings(noteIds)
+			return noteIds
+		}
+
+		putNoteStates := func(body string) int {
+			request, err := http.NewRequest(http.MethodPut, server.URL+paths.NoteStateApi, strings.NewReader(body))
+			test_util.Ok(t, err)
+			resp, err := client.Do(request)
+			test_util.Ok(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		test_util.Equals(t, http.StatusBadRequest, putNoteStates(`{"noteIds": [1]}`))
+		test_util.Equals(t, http.StatusNotFound, putNoteStates(`{"noteIds": [44], "pinned": true}`))
+
+		test_util.Equals(t, http.StatusOK, putNoteStates(`{"noteIds": [`+strconv.FormatInt(int64(archivedNoteId), 10)+`], "archived": true}`))
+		test_util.Equals(t, http.StatusOK, putNoteStates(`{"noteIds": [`+strconv.FormatInt(int64(draftNoteId), 10)+`], "pinned": true}`))
+
+		// archived notes only show up when asked for
+		test_util.Equals(t, []string{strconv.FormatInt(int64(draftNoteId), 10)}, getNoteIds(server.URL+paths.NoteApi))
+		test_util.Equals(t, []string{strconv.FormatInt(int64(archivedNoteId), 10)}, getNoteIds(server.URL+paths.NoteApi+"?archived=true"))
+		test_util.Equals(t, []string{strconv.FormatInt(int64(draftNoteId), 10)}, getNoteIds(server.URL+paths.NoteApi+"?pinned=true"))
+	})
+
+	t.Run("Note Batch", func(t *testing.T) {
+		var appliedOperations []*models.NoteOperation
+		mockDb.Func_ApplyNoteOperations = func(authorId models.UserId, operations []*models.NoteOperation, now time.Time) ([]*models.NoteOperationResult, error) {
+			if len(operations) == 0 {
+				return nil, models.NoNoteOperationsError
+			}
+
+			results := make([]*models.NoteOperationResult, len(operations))
+			for i, operation := range operations {
+				results[i] = &models.NoteOperationResult{Op: operation.Op, NoteId: operation.NoteId}
+				if operation.NoteId == models.NoteId(44) {
+					results[i].Error = models.NoNoteFoundError.Error()
+				}
+				if operation.Op == models.UPDATE_CONTENT {
+					results[i].Note = &models.Note{AuthorId: authorId, Content: operation.Content, Revision: 2}
+				}
+			}
+
+			for _, result := range results {
+				if len(result.Error) > 0 {
+					return results, models.NoteBatchFailedError
+				}
+			}
+
+			appliedOperations = operations
+			return results, nil
+		}
+
+		type NoteBatchResponse struct {
+			Applied bool                          `json:"applied"`
+			Results []*models.NoteOperationResult `json:"results"`
+		}
+
+		postBatch := func(body string) (int, *NoteBatchResponse) {
+			resp, err := client.Post(server.URL+paths.NoteBatchApi, "application/json", strings.NewReader(body))
+			test_util.Ok(t, err)
+			defer resp.Body.Close()
+
+			batchResponse := new(NoteBatchResponse)
+			if resp.Header.Get("Content-Type") == "application/json" {
+				test_util.Ok(t, json.NewDecoder(resp.Body).Decode(batchResponse))
+			}
+			return resp.StatusCode, batchResponse
+		}
+
+		noteId := strconv.FormatInt(noteIdAsInt, 10)
+		eventCount := len(emittedEvents)
+
+		statusCode, batchResponse := postBatch(`{"operations": [{"op": "delete", "noteId": ` + noteId + `}, {"op": "delete", "noteId": 44}]}`)
+		test_util.Equals(t, http.StatusBadRequest, statusCode)
+		test_util.Assert(t, !batchResponse.Applied, "Expected the batch not to be applied")
+		test_util.Equals(t, models.NoNoteFoundError.Error(), batchResponse.Results[1].Error)
+		test_util.Equals(t, eventCount, len(emittedEvents))
+
+		statusCode, _ = postBatch(`{"operations": []}`)
+		test_util.Equals(t, http.StatusBadRequest, statusCode)
+
+		statusCode, batchResponse = postBatch(`{"operations": [
+			{"op": "update", "noteId": ` + noteId + `, "content": "batched *edit*"},
+			{"op": "setCategory", "noteId": ` + noteId + `, "category": "meta"},
+			{"op": "tag", "noteId": ` + noteId + `, "tags": ["Book Club"]},
+			{"op": "delete", "noteId": ` + noteId + `}
+		]}`)
+		test_util.Equals(t, http.StatusOK, statusCode)
+		test_util.Assert(t, batchResponse.Applied, "Expected the batch to be applied")
+		test_util.Equals(t, 4, len(batchResponse.Results))
+		test_util.Equals(t, "<p>batched <em>edit</em></p>\n", batchResponse.Results[0].Note.ContentHtml)
+		test_util.Equals(t, models.ADD_TAGS, appliedOperations[2].Op)
+		test_util.Equals(t, []models.WebhookEventType{
+			models.NOTE_UPDATED,
+			models.NOTE_CATEGORIZED,
+			models.NOTE_DELETED,
+		}, emittedEvents[eventCount:])
+	})
+
+	t.Run("Versioned Api", func(t *testing.T) {
+		type ErrorEnvelope struct {
+			Error handlers.ApiError `json:"error"`
+		}
+
+		decodeError := func(resp *http.Response) handlers.ApiError {
+			defer resp.Body.Close()
+			test_util.Equals(t, "application/json", resp.Header.Get("Content-Type"))
+
+			envelope := new(ErrorEnvelope)
+			test_util.Ok(t, json.NewDecoder(resp.Body).Decode(envelope))
+			return envelope.Error
+		}
+
+		noteId := models.NoteId(noteIdAsInt)
+
+		// path parameters reach the handlers shared with the deprecated routes
+		resp, err := client.Get(server.URL + paths.Expand(paths.NoteV1, noteId))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, "", resp.Header.Get("Deprecation"))
+
+		note := new(models.Note)
+		test_util.Ok(t, json.NewDecoder(resp.Body).Decode(note))
+		resp.Body.Close()
+		test_util.Equals(t, models.UserId(userIdAsInt), note.AuthorId)
+
+		// missing notes are not found, with the same code wherever they're missing
+		mockDb.Func_GetUsersNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{}, nil
+		}
+
+		resp, err = sendDeleteUrl(client, server.URL+paths.Expand(paths.NoteV1, 404))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+		apiError := decodeError(resp)
+		test_util.Equals(t, "note_not_found", apiError.Code)
+		test_util.Equals(t, models.NoNoteFoundError.Error(), apiError.Message)
+
+		resp, err = client.Get(server.URL + paths.Expand(paths.NoteV1, 404))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+		test_util.Equals(t, "note_not_found", decodeError(resp).Code)
+
+		// the deprecated route keeps its plain text errors
+		resp, err = sendDeleteUrl(client, server.URL+paths.NoteApi+"?id=404")
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+		test_util.Assert(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain"), "Expected a plain text error")
+		test_util.Equals(t, "true", resp.Header.Get("Deprecation"))
+		test_util.Equals(t, `<`+paths.Expand(paths.NoteV1, 404)+`>; rel="successor-version"`, resp.Header.Get("Link"))
+
+		resp, err = client.Get(server.URL + paths.NoteApi)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, `<`+paths.NotesV1+`>; rel="successor-version"`, resp.Header.Get("Link"))
+
+		// each resource only answers its own methods
+		request, err := http.NewRequest(http.MethodPost, server.URL+paths.Expand(paths.NoteV1, noteId), nil)
+		test_util.Ok(t, err)
+		resp, err = client.Do(request)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		test_util.Equals(t, "GET, PUT, DELETE", resp.Header.Get("Allow"))
+		apiError = decodeError(resp)
+		test_util.Equals(t, "method_not_allowed", apiError.Code)
+		test_util.Equals(t, map[string]interface{}{"allowedMethods": []interface{}{"GET", "PUT", "DELETE"}}, apiError.Details)
+
+		resp, err = client.Get(server.URL + paths.ApiV1 + "/nothing/here")
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusNotFound, resp.StatusCode)
+		test_util.Equals(t, "route_not_found", decodeError(resp).Code)
+
+		// fixed segments win over parameters
+		resp, err = client.Post(server.URL+paths.NoteBatchV1, "application/json", strings.NewReader(`{"operations": []}`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+		test_util.Equals(t, "no_note_operations", decodeError(resp).Code)
+
+		// malformed requests and unexpected failures get generic codes
+		resp, err = client.Post(server.URL+paths.NoteBatchV1, "application/json", strings.NewReader(`{`))
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusBadRequest, resp.StatusCode)
+		test_util.Equals(t, "bad_request", decodeError(resp).Code)
+
+		mockDb.Func_GetUnreadCounts = func(userId models.UserId) (*models.UnreadCounts, error) {
+			return nil, errors.New("connection reset by peer")
+		}
+
+		resp, err = client.Get(server.URL + paths.UnreadV1)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusInternalServerError, resp.StatusCode)
+		apiError = decodeError(resp)
+		test_util.Equals(t, "internal_error", apiError.Code)
+		test_util.Equals(t, http.StatusText(http.StatusInternalServerError), apiError.Message)
+
+		resp, err = http.Get(server.URL + paths.NotesV1)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusUnauthorized, resp.StatusCode)
+		test_util.Equals(t, "unauthorized", decodeError(resp).Code)
+	})
+
+	t.Run("GraphQL", func(t *testing.T) {
+		type GraphQlResponse struct {
+			Data   json.RawMessage `json:"data"`
+			Errors []struct {
+				Message    string            `json:"message"`
+				Path       []interface{}     `json:"path"`
+				Extensions map[string]string `json:"extensions"`
+			} `json:"errors"`
+		}
+
+		postQuery := func(query string, variables map[string]interface{}) (int, *GraphQlResponse) {
+			body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+			test_util.Ok(t, err)
+
+			resp, err := client.Post(server.URL+paths.GraphQlApi, "application/json", bytes.NewReader(body))
+			test_util.Ok(t, err)
+			defer resp.Body.Close()
+
+			graphQlResponse := new(GraphQlResponse)
+			test_util.Ok(t, json.NewDecoder(resp.Body).Decode(graphQlResponse))
+			return resp.StatusCode, graphQlResponse
+		}
+
+		myNoteId := models.NoteId(noteIdAsInt)
+		calls := make(map[string]int)
+		batchedNoteIds := make(map[string][]models.NoteId)
+
+		mockDb.Func_GetAllUsersById = func() (models.UsersById, error) {
+			calls["users"]++
+			return models.UsersById{
+				models.UserId(userIdAsInt): {DisplayName: "Writer"},
+				models.UserId(99):          {DisplayName: "Other"},
+			}, nil
+		}
+		mockDb.Func_GetMyUnpublishedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{myNoteId: {
+				AuthorId:     models.UserId(userIdAsInt),
+				Content:      content,
+				CreationTime: time.Now().UTC(),
+				Revision:     1,
+			}}, nil
+		}
+		mockDb.Func_GetAllPublishedNotesVisibleBy = func(userId models.UserId) (map[int64]models.NotesById, error) {
+			return map[int64]models.NotesById{1: {
+				models.NoteId(44): {AuthorId: models.UserId(99), Content: "another *note*", IssueNumber: 1, Position: 1},
+				models.NoteId(45): {AuthorId: models.UserId(99), Content: "", IssueNumber: 1, Position: 2, DeletionTime: &time.Time{}},
+			}}, nil
+		}
+		mockDb.Func_GetUnreadNoteIds = func(userId models.UserId) (map[models.NoteId]bool, error) {
+			calls["unread"]++
+			return map[models.NoteId]bool{models.NoteId(44): true}, nil
+		}
+		mockDb.Func_GetNoteCategories = func(noteIds []models.NoteId) (map[models.NoteId]models.NoteCategory, error) {
+			calls["categories"]++
+			batchedNoteIds["categories"] = noteIds
+			return map[models.NoteId]models.NoteCategory{models.NoteId(44): models.QUESTION}, nil
+		}
+		mockDb.Func_GetReplyCounts = func(noteIds []models.NoteId) (map[models.NoteId]int, error) {
+			calls["replyCounts"]++
+			return map[models.NoteId]int{models.NoteId(44): 2}, nil
+		}
+		mockDb.Func_GetRepliesForNotes = func(noteIds []models.NoteId) (map[models.NoteId][]*models.Reply, error) {
+			calls["replies"]++
+			batchedNoteIds["replies"] = noteIds
+
+			parentId := models.ReplyId(1)
+			return map[models.NoteId][]*models.Reply{models.NoteId(44): {
+				{Id: 1, NoteId: 44, AuthorId: models.UserId(userIdAsInt), Content: "", Deleted: true},
+				{Id: 2, NoteId: 44, ParentId: &parentId, AuthorId: models.UserId(99), Content: "Why?"},
+			}}, nil
+		}
+		mockDb.Func_GetNoteTags = func(noteIds []models.NoteId) (map[models.NoteId][]string, error) {
+			calls["tags"]++
+			batchedNoteIds["tags"] = noteIds
+			return map[models.NoteId][]string{myNoteId: {"drafts"}}, nil
+		}
+		mockDb.Func_GetReactionSummaries = func(noteIds []models.NoteId, userId models.UserId) (map[models.NoteId][]*models.ReactionSummary, error) {
+			calls["reactions"]++
+			return map[models.NoteId][]*models.ReactionSummary{models.NoteId(44): {{Reaction: "👍", Count: 3}}}, nil
+		}
+
+		statusCode, response := postQuery(`
+			query Dashboard {
+				viewer { displayName }
+				notes { ...NoteFields }
+			}
+
+			fragment NoteFields on Note {
+				id
+				author { displayName }
+				content
+				category
+				issueNumber
+				unread
+				replyCount
+				replies { content author { displayName } replies { content author { displayName } } }
+				reactions { reaction count }
+				tags
+			}`,
+			nil)
+		test_util.Equals(t, http.StatusOK, statusCode)
+		test_util.Equals(t, 0, len(response.Errors))
+
+		type Reply struct {
+			Content *string `json:"content"`
+			Author  struct {
+				DisplayName string `json:"displayName"`
+			} `json:"author"`
+			Replies []*Reply `json:"replies"`
+		}
+		dashboard := new(struct {
+			Viewer struct {
+				DisplayName string `json:"displayName"`
+			} `json:"viewer"`
+			Notes []struct {
+				Id     string `json:"id"`
+				Author struct {
+					DisplayName string `json:"displayName"`
+				} `json:"author"`
+				Content     *string  `json:"content"`
+				Category    *string  `json:"category"`
+				IssueNumber *int64   `json:"issueNumber"`
+				Unread      bool     `json:"unread"`
+				ReplyCount  int      `json:"replyCount"`
+				Replies     []*Reply `json:"replies"`
+				Reactions   []struct {
+					Reaction string `json:"reaction"`
+					Count    int    `json:"count"`
+				} `json:"reactions"`
+				Tags []string `json:"tags"`
+			} `json:"notes"`
+		})
+		test_util.Ok(t, json.Unmarshal(response.Data, dashboard))
+
+		test_util.Equals(t, "Writer", dashboard.Viewer.DisplayName)
+		test_util.Equals(t, 3, len(dashboard.Notes))
+
+		mine, other, tombstone := dashboard.Notes[0], dashboard.Notes[1], dashboard.Notes[2]
+		test_util.Equals(t, strconv.FormatInt(noteIdAsInt, 10), mine.Id)
+		test_util.Equals(t, []string{"drafts"}, mine.Tags)
+		test_util.Assert(t, mine.IssueNumber == nil && mine.Category == nil, "Expected an unpublished, uncategorized note")
+
+		test_util.Equals(t, "44", other.Id)
+		test_util.Equals(t, "Other", other.Author.DisplayName)
+		test_util.Equals(t, models.QUESTION.String(), *other.Category)
+		test_util.Equals(t, int64(1), *other.IssueNumber)
+		test_util.Assert(t, other.Unread, "Expected the note of another author to be unread")
+		test_util.Equals(t, 2, other.ReplyCount)
+		test_util.Equals(t, 1, len(other.Replies))
+		test_util.Assert(t, other.Replies[0].Content == nil, "Expected the deleted reply to have no content")
+		test_util.Equals(t, "Why?", *other.Replies[0].Replies[0].Content)
+		test_util.Equals(t, "Other", other.Replies[0].Replies[0].Author.DisplayName)
+		test_util.Equals(t, 3, other.Reactions[0].Count)
+		test_util.Assert(t, other.Tags == nil, "Expected the tags of another author's note to be hidden")
+
+		test_util.Assert(t, tombstone.Content == nil, "Expected the deleted note to have no content")
+
+		// each kind of data was fetched once for every note, rather than once a note
+		for name, count := range calls {
+			test_util.Assert(t, count == 1, "Expected %s to be fetched once, not %d times", name, count)
+		}
+		test_util.Equals(t, 7, len(calls))
+		test_util.Equals(t, []models.NoteId{myNoteId, 44, 45}, batchedNoteIds["replies"])
+		test_util.Equals(t, []models.NoteId{myNoteId}, batchedNoteIds["tags"])
+
+		statusCode, response = postQuery(
+			`query ($category: String) { notes(category: $category) { id } }`,
+			map[string]interface{}{"category": models.QUESTION.String()})
+		test_util.Equals(t, http.StatusOK, statusCode)
+		test_util.Equals(t, `{"notes":[{"id":"44"}]}`, string(response.Data))
+
+		// mutations apply their operations the way the batch endpoint does
+		var appliedOperations []*models.NoteOperation
+		mockDb.Func_ApplyNoteOperations = func(authorId models.UserId, operations []*models.NoteOperation, now time.Time) ([]*models.NoteOperationResult, error) {
+			result := &models.NoteOperationResult{Op: operations[0].Op, NoteId: operations[0].NoteId}
+			if operations[0].NoteId != myNoteId {
+				result.Error = models.NoNoteFoundError.Error()
+				return []*models.NoteOperationResult{result}, models.NoteBatchFailedError
+			}
+
+			appliedOperations = append(appliedOperations, operations...)
+			return []*models.NoteOperationResult{result}, nil
+		}
+
+		statusCode, response = postQuery(`mutation ($id: ID!) {
+			tagNote(id: $id, tags: ["Book Club"]) { id tags }
+			missing: setNoteCategory(id: 44, category: "meta") { id }
+		}`, map[string]interface{}{"id": noteIdAsInt})
+		test_util.Equals(t, http.StatusOK, statusCode)
+		test_util.Equals(t, `null`, string(response.Data))
+		test_util.Equals(t, 1, len(response.Errors))
+		test_util.Equals(t, "note_not_found", response.Errors[0].Extensions["code"])
+		test_util.Equals(t, []interface{}{"missing"}, response.Errors[0].Path)
+		test_util.Equals(t, 1, len(appliedOperations))
+		test_util.Equals(t, models.ADD_TAGS, appliedOperations[0].Op)
+		test_util.Equals(t, []string{"Book Club"}, appliedOperations[0].Tags)
+		test_util.Equals(t, 2, calls["tags"])
+
+		statusCode, response = postQuery(`{ note(id: "forty-four") { id } }`, nil)
+		test_util.Equals(t, http.StatusOK, statusCode)
+		test_util.Equals(t, "invalid_id", response.Errors[0].Extensions["code"])
+
+		statusCode, response = postQuery(`{ notes { password } }`, nil)
+		test_util.Equals(t, http.StatusBadRequest, statusCode)
+		test_util.Equals(t, `Cannot query field "password" on type "Note".`, response.Errors[0].Message)
+
+		// authors and their notes refer to each other, so how deep a query goes is limited
+		statusCode, response = postQuery(`{ notes { author { notes { author { notes { author { notes { id } } } } } } } }`, nil)
+		test_util.Equals(t, http.StatusBadRequest, statusCode)
+		test_util.Equals(t, `Field "notes" is nested more than 6 levels deep.`, response.Errors[0].Message)
+
+		tooLarge, err := json.Marshal(map[string]string{"query": "{ notes { id } }" + strings.Repeat(" ", 1<<20)})
+		test_util.Ok(t, err)
+		resp, err := client.Post(server.URL+paths.GraphQlApi, "application/json", bytes.NewReader(tooLarge))
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Equals(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+		resp, err = client.Get(server.URL + paths.GraphQlApi)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		schema, err := ioutil.ReadAll(resp.Body)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+		test_util.Assert(t, strings.Contains(string(schema), "type Note {"), "Expected the schema, not %s", schema)
+	})
+
+	t.Run("Conditional Requests", func(t *testing.T) {
+		noteId := models.NoteId(noteIdAsInt)
+		noteUrl := server.URL + paths.Expand(paths.NoteV1, noteId)
+		creationTime := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+		editTime := creationTime.Add(time.Hour)
+		currentContent := content
+		currentRevision := 2
+
+		mockDb.Func_GetMyUnpublishedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{noteId: {
+				AuthorId:     models.UserId(userIdAsInt),
+				Content:      currentContent,
+				CreationTime: creationTime,
+				LastEditTime: &editTime,
+				Revision:     currentRevision,
+			}}, nil
+		}
+		mockDb.Func_GetAllPublishedNotesVisibleBy = func(userId models.UserId) (map[int64]models.NotesById, error) {
+			return map[int64]models.NotesById{}, nil
+		}
+		mockDb.Func_GetAllUsersById = func() (models.UsersById, error) {
+			return models.UsersById{
+				models.UserId(userIdAsInt): {DisplayName: "Writer", CreationTime: creationTime},
+				models.UserId(99):          {DisplayName: "Other", CreationTime: editTime},
+			}, nil
+		}
+
+		send := func(method string, url string, body string, headers map[string]string) (*http.Response, []byte) {
+			request, err := http.NewRequest(method, url, strings.NewReader(body))
+			test_util.Ok(t, err)
+			for name, value := range headers {
+				request.Header.Set(name, value)
+			}
+
+			resp, err := client.Do(request)
+			test_util.Ok(t, err)
+			defer resp.Body.Close()
+
+			responseBody, err := ioutil.ReadAll(resp.Body)
+			test_util.Ok(t, err)
+			return resp, responseBody
+		}
+
+		resp, _ := send(http.MethodGet, noteUrl, "", nil)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get("ETag")
+		test_util.Assert(t, strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`), "Expected a strong ETag, not %s", etag)
+		test_util.Equals(t, editTime.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+
+		// a current copy isn't sent again
+		for _, ifNoneMatch := range []string{etag, "W/" + etag, `"something else", ` + etag, "*"} {
+			resp, body := send(http.MethodGet, noteUrl, "", map[string]string{"If-None-Match": ifNoneMatch})
+			test_util.Equals(t, http.StatusNotModified, resp.StatusCode)
+			test_util.Equals(t, etag, resp.Header.Get("ETag"))
+			test_util.Equals(t, 0, len(body))
+		}
+
+		resp, _ = send(http.MethodGet, noteUrl, "", map[string]string{"If-Modified-Since": editTime.Format(http.TimeFormat)})
+		test_util.Equals(t, http.StatusNotModified, resp.StatusCode)
+
+		resp, _ = send(http.MethodGet, noteUrl, "", map[string]string{"If-Modified-Since": creationTime.Format(http.TimeFormat)})
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		// If-None-Match wins over If-Modified-Since
+		resp, _ = send(http.MethodGet, noteUrl, "", map[string]string{
+			"If-None-Match":     `"something else"`,
+			"If-Modified-Since": editTime.Format(http.TimeFormat),
+		})
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		// the collection has validators of its own, shared with the deprecated route
+		resp, _ = send(http.MethodGet, server.URL+paths.NotesV1, "", nil)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		collectionEtag := resp.Header.Get("ETag")
+		test_util.Assert(t, collectionEtag != etag, "Expected the collection and the note to have different ETags")
+		test_util.Equals(t, "", resp.Header.Get("Last-Modified"))
+
+		// notes can leave the listing without any time recording it, so If-Modified-Since is never enough
+		resp, _ = send(http.MethodGet, server.URL+paths.NotesV1, "", map[string]string{"If-Modified-Since": time.Now().UTC().Format(http.TimeFormat)})
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = send(http.MethodGet, server.URL+paths.NoteApi, "", map[string]string{"If-None-Match": collectionEtag})
+		test_util.Equals(t, http.StatusNotModified, resp.StatusCode)
+
+		resp, _ = send(http.MethodGet, server.URL+paths.UsersV1, "", nil)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, editTime.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+
+		resp, _ = send(http.MethodGet, server.URL+paths.UsersV1, "", map[string]string{"If-None-Match": resp.Header.Get("ETag")})
+		test_util.Equals(t, http.StatusNotModified, resp.StatusCode)
+
+		// edits are only made to the copy the client last saw
+		mockDb.Func_GetNoteById = func(models.NoteId) (*models.Note, error) {
+			return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: currentContent, CreationTime: creationTime, Revision: currentRevision}, nil
+		}
+		updatedRevisions := make([]int, 0)
+		var updateErr error
+		mockDb.Func_UpdateNoteContent = func(noteId models.NoteId, content string, revision int) error {
+			updatedRevisions = append(updatedRevisions, revision)
+			return updateErr
+		}
+
+		decodeErrorCode := func(body []byte) string {
+			envelope := new(handlers.ApiErrorEnvelope)
+			test_util.Ok(t, json.Unmarshal(body, envelope))
+			return envelope.Error.Code
+		}
+
+		currentContent = "edited in another tab"
+		currentRevision = 3
+		resp, body := send(http.MethodPut, noteUrl, `{"content": "edited here"}`, map[string]string{"If-Match": etag})
+		test_util.Equals(t, http.StatusPreconditionFailed, resp.StatusCode)
+		test_util.Equals(t, "precondition_failed", decodeErrorCode(body))
+		test_util.Equals(t, 0, len(updatedRevisions))
+
+		resp, _ = send(http.MethodGet, noteUrl, "", map[string]string{"If-None-Match": etag})
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		etag = resp.Header.Get("ETag")
+
+		// someone else reacting changes the note's ETag, but not what an edit is checked against
+		getReactionSummaries := mockDb.Func_GetReactionSummaries
+		mockDb.Func_GetReactionSummaries = func(noteIds []models.NoteId, userId models.UserId) (map[models.NoteId][]*models.ReactionSummary, error) {
+			return map[models.NoteId][]*models.ReactionSummary{noteId: {{Reaction: "👍", Count: 1}}}, nil
+		}
+		defer func() { mockDb.Func_GetReactionSummaries = getReactionSummaries }()
+
+		resp, _ = send(http.MethodGet, noteUrl, "", map[string]string{"If-None-Match": etag})
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Assert(t, resp.Header.Get("ETag") != etag, "Expected the reaction to change the ETag")
+
+		resp, _ = send(http.MethodPut, noteUrl, `{"content": "edited here"}`, map[string]string{"If-Match": etag})
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+		test_util.Equals(t, []int{3}, updatedRevisions)
+
+		// weak tags never match If-Match
+		resp, _ = send(http.MethodPut, noteUrl, `{"content": "edited here"}`, map[string]string{"If-Match": "W/" + etag})
+		test_util.Equals(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		// an edit that lands between the check and the update is caught too
+		updateErr = models.NoteRevisionChangedError
+		resp, body = send(http.MethodPut, noteUrl, `{"content": "edited here"}`, map[string]string{"If-Match": etag})
+		test_util.Equals(t, http.StatusPreconditionFailed, resp.StatusCode)
+		test_util.Equals(t, "precondition_failed", decodeErrorCode(body))
+
+		resp, body = send(http.MethodPut, noteUrl, `{"content": "edited here"}`, nil)
+		test_util.Equals(t, http.StatusConflict, resp.StatusCode)
+		test_util.Equals(t, "note_changed", decodeErrorCode(body))
+	})
+
+	t.Run("Open Api", func(t *testing.T) {
+		resp, err := client.Get(server.URL + paths.OpenApiDocument)
+		test_util.Ok(t, err)
+		test_util.Equals(t, http.StatusOK, resp.StatusCode)
+
+		served, err := ioutil.ReadAll(resp.Body)
+		test_util.Ok(t, err)
+		resp.Body.Close()
+
+		expected, err := json.Marshal(handlers.ApiDocument())
+		test_util.Ok(t, err)
+		test_util.Equals(t, string(expected), string(served))
+
+		// the generated client shares the test client's session
+		apiClient := &apiclient.Client{BaseUrl: server.URL, HttpClient: client}
+		noteId := noteIdAsInt
+
+		mockDb.Func_StoreNewUser = func(displayName string, email *models.EmailAddress, password string) error {
+			if email.String() == "taken@example.com" {
+				return models.EmailAddressAlreadyInUseError
+			}
+			return nil
+		}
+		test_util.Ok(t, apiClient.CreateUser(&apiclient.SignupForm{
+			DisplayName:  "Reader",
+			EmailAddress: "reader@example.com",
+			Password:     "worldsBestPassword",
+		}))
+
+		err = apiClient.CreateUser(&apiclient.SignupForm{EmailAddress: "taken@example.com"})
+		apiError, ok := err.(*apiclient.Error)
+		test_util.Assert(t, ok, "Expected an api error, not %v", err)
+		test_util.Equals(t, http.StatusConflict, apiError.StatusCode)
+		test_util.Equals(t, "email_address_in_use", apiError.Body.Error.Code)
+
+		test_util.Ok(t, apiClient.CreateSession(&apiclient.LoginForm{
+			EmailAddress: "justsomeemail@gmail.com",
+			Password:     "worldsBestPassword",
+		}))
+
+		mockDb.Func_GetAllUsersById = func() (models.UsersById, error) {
+			return models.UsersById{models.UserId(userIdAsInt): {DisplayName: "Writer"}}, nil
+		}
+		users, err := apiClient.ListUsers()
+		test_util.Ok(t, err)
+		test_util.Equals(t, map[string]apiclient.User{"1": {DisplayName: "Writer"}}, users)
+
+		mockDb.Func_StoreNewNote = func(note *models.Note) (models.NoteId, error) {
+			return models.NoteId(noteId), nil
+		}
+		created, err := apiClient.CreateNote(&apiclient.NoteForm{Content: content})
+		test_util.Ok(t, err)
+		test_util.Equals(t, noteId, created.NoteId)
+
+		mockDb.Func_GetMyUnpublishedNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{models.NoteId(noteId): {
+				AuthorId:     models.UserId(userIdAsInt),
+				Content:      content,
+				CreationTime: time.Now().UTC(),
+				Revision:     1,
+			}}, nil
+		}
+		mockDb.Func_GetAllPublishedNotesVisibleBy = func(userId models.UserId) (map[int64]models.NotesById, error) {
+			return map[int64]models.NotesById{}, nil
+		}
+		mockDb.Func_GetNoteCategories = func(noteIds []models.NoteId) (map[models.NoteId]models.NoteCategory, error) {
+			return map[models.NoteId]models.NoteCategory{models.NoteId(noteId): models.META}, nil
+		}
+		notes, err := apiClient.ListNotes(&apiclient.ListNotesParams{Category: models.META.String()})
+		test_util.Ok(t, err)
+		test_util.Equals(t, content, notes[strconv.FormatInt(noteId, 10)].Content)
+
+		note, err := apiClient.GetNote(noteId)
+		test_util.Ok(t, err)
+		test_util.Equals(t, int64(1), note.Revision)
+
+		_, err = apiClient.GetNote(404)
+		apiError, ok = err.(*apiclient.Error)
+		test_util.Assert(t, ok, "Expected an api error")
+		test_util.Equals(t, "note_not_found", apiError.Body.Error.Code)
+
+		mockDb.Func_GetNoteById = func(models.NoteId) (*models.Note, error) {
+			return &models.Note{AuthorId: models.UserId(userIdAsInt), Content: content, CreationTime: time.Now().UTC()}, nil
+		}
+		mockDb.Func_UpdateNoteContent = func(models.NoteId, string, int) error {
+			return nil
+		}
+		test_util.Ok(t, apiClient.UpdateNote(noteId, &apiclient.NoteForm{Content: "something else entirely"}))
+
+		mockDb.Func_AssignNoteCategoryRelationship = func(models.NoteId, models.NoteCategory) error {
+			return nil
+		}
+		mockDb.Func_GetNoteCategory = func(models.NoteId) (models.NoteCategory, error) {
+			return models.QUESTION, nil
+		}
+		mockDb.Func_DeleteNoteCategory = func(models.NoteId) error {
+			return nil
+		}
+		test_util.Ok(t, apiClient.SetNoteCategory(noteId, &apiclient.NoteCategoryForm{Category: models.QUESTION.String()}))
+		category, err := apiClient.GetNoteCategory(noteId)
+		test_util.Ok(t, err)
+		test_util.Equals(t, models.QUESTION.String(), category.Category)
+		test_util.Ok(t, apiClient.ClearNoteCategory(noteId))
+
+		mockDb.Func_PublishIssue = func(userId models.UserId, draft *models.IssueDraft) (models.PublicationId, error) {
+			return models.PublicationId(1), nil
+		}
+		test_util.Ok(t, apiClient.PublishIssue(nil))
+		test_util.Ok(t, apiClient.PublishIssue(&apiclient.IssueDraft{Title: "Week one"}))
+
+		mockDb.Func_GetPublishedIssuesVisibleBy = func(userId models.UserId) ([]*models.PublishedIssue, error) {
+			return []*models.PublishedIssue{{
+				PublicationId: models.PublicationId(1),
+				AuthorId:      models.UserId(99),
+				IssueNumber:   1,
+				CreationTime:  time.Now().UTC(),
+				Title:         "Week one",
+				Notes: models.NotesById{models.NoteId(44): {
+					AuthorId:     models.UserId(99),
+					Content:      "another *note*",
+					CreationTime: time.Now().UTC(),
+					IssueNumber:  1,
+					Position:     1,
+				}},
+				NoteOrder: []models.NoteId{models.NoteId(44)},
+			}}, nil
+		}
+		issues, err := apiClient.ListPublications()
+		test_util.Ok(t, err)
+		test_util.Equals(t, 1, len(issues))
+		test_util.Equals(t, "<p>another <em>note</em></p>\n", issues[0].Notes["44"].ContentHtml)
+
+		mockDb.Func_GetUsersNotes = func(userId models.UserId) (models.NotesById, error) {
+			return models.NotesById{models.NoteId(noteId): {AuthorId: models.UserId(userIdAsInt)}}, nil
+		}
+		mockDb.Func_TrashNote = func(models.NoteId, time.Time) error {
+			return nil
+		}
+		test_util.Ok(t, apiClient.DeleteNote(noteId))
+
+		test_util.Ok(t, apiClient.DeleteSession())
+
+		// every versioned operation the document describes was called, and answered as it says
+		apiTransport.document.Operations(func(method string, path string, operation *openapi.Operation) {
+			if !operation.Deprecated && !apiTransport.exercised[operation.OperationId] {
+				t.Errorf("%s %s was never called", method, path)
+			}
+		})
+	})
+}
+
+// validatingTransport checks every response to an operation the api's OpenAPI document describes against it,
+// failing the request if the response doesn't match. It records the operations it saw answered.
+type validatingTransport struct {
+	document *openapi.Document
+
+	mutex     sync.Mutex
+	exercised map[string]bool
+}
+
+func (transport *validatingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	operation, _, ok := transport.document.FindOperation(request.Method, request.URL.Path)
+	if !ok {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := transport.document.ValidateResponse(
+		request.Method,
+		request.URL.Path,
+		resp.StatusCode,
+		resp.Header.Get("Content-Type"),
+		body,
+	); err != nil {
+		return nil, err
+	}
+
+	transport.mutex.Lock()
+	transport.exercised[operation.OperationId] = true
+	transport.mutex.Unlock()
+
+	return resp, nil
+}
+
+func sendDeleteRequest(client *http.Client, myUrl string, contentType string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequest("DELETE", myUrl, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	return client.Do(req)
+}
+func sendDeleteUrl(client *http.Client, myUrl string) (resp *http.Response, err error) {
+
+	req, err := http.NewRequest("DELETE", myUrl, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Do(req)
+}
+
+func sendPutRequest(client *http.Client, myUrl string, contentType string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequest("PUT", myUrl, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	return client.Do(req)
+}
+
+// Used for debugging tests.
+func printBody(resp *http.Response) {
+	buf, bodyErr := ioutil.ReadAll(resp.Body)
+	if bodyErr != nil {
+		fmt.Print("bodyErr ", bodyErr.Error())
+		return
+	}
+
+	rdr1 := ioutil.NopCloser(bytes.NewBuffer(buf))
+	rdr2 := ioutil.NopCloser(bytes.NewBuffer(buf))
+	fmt.Printf("BODY: %q", rdr1)
+	resp.Body = rdr2
+}
+
+// Helpers
+
+type MockDataStore struct {
+	Func_StoreNewNote                   func(*models.Note) (models.NoteId, error)
+	Func_StoreNewUser                   func(string, *models.EmailAddress, string) error
+	Func_AuthenticateUserCredentials    func(*models.EmailAddress, string) error
+	Func_GetIdForUserWithEmailAddress   func(*models.EmailAddress) (models.UserId, error)
+	Func_GetUsersNotes                  func(models.UserId) (models.NotesById, error)
+	Func_DeleteNoteById                 func(models.NoteId) error
+	Func_GetMyUnpublishedNotes          func(models.UserId) (models.NotesById, error)
+	Func_GetAllUsersById                func() (models.UsersById, error)
+	Func_GetAllPublishedNotesVisibleBy  func(models.UserId) (map[int64]models.NotesById, error)
+	Func_PublishNotes                   func(models.UserId) (models.PublicationId, error)
+	Func_StoreNewPublication            func(*models.Publication) (models.PublicationId, error)
+	Func_GetNoteById                    func(models.NoteId) (*models.Note, error)
+	Func_UpdateNoteContent              func(models.NoteId, string, int) error
+	Func_AssignNoteCategoryRelationship func(models.NoteId, models.NoteCategory) error
+	Func_DeleteNoteCategory             func(models.NoteId) error
+	Func_GetNoteCategory                func(models.NoteId) (models.NoteCategory, error)
+	Func_ImportNotes                    func(models.UserId, []*models.ImportedNote, bool) (*models.ImportReport, error)
+	Func_GetPublishedIssuesVisibleBy    func(models.UserId) ([]*models.PublishedIssue, error)
+	Func_CreateFeedToken                func(models.UserId) (string, error)
+	Func_GetFeedToken                   func(models.UserId) (string, error)
+	Func_GetUserIdForFeedToken          func(string) (models.UserId, error)
+	Func_DeleteFeedToken                func(models.UserId) error
+	Func_GetNotificationPreferences     func(models.UserId) (*models.NotificationPreferences, error)
+	Func_SetNotificationFrequency       func(models.UserId, models.NotificationFrequency) error
+	Func_UnsubscribeFromNotifications   func(string) error
+	Func_GetNotificationRecipients      func() ([]*models.NotificationRecipient, error)
+	Func_MarkNotificationsSent          func(models.UserId, []models.PublicationId, time.Time) error
+	Func_StoreNewWebhookSubscription    func(*models.WebhookSubscription) (models.WebhookSubscriptionId, error)
+	Func_GetUsersWebhookSubscriptions   func(models.UserId) ([]*models.WebhookSubscription, error)
+	Func_DeleteWebhookSubscription      func(models.UserId, models.WebhookSubscriptionId) error
+	Func_QueueWebhookDeliveries         func(*models.WebhookEvent) error
+	Func_GetEventAudience               func(*models.WebhookEvent) ([]models.UserId, error)
+	Func_QueueWebhookPing               func(models.UserId, models.WebhookSubscriptionId) (models.WebhookDeliveryId, error)
+	Func_GetDueWebhookDeliveries        func(time.Time, int) ([]*models.WebhookDelivery, error)
+	Func_GetWebhookDeliveries           func(models.UserId, models.WebhookSubscriptionId) ([]*models.WebhookDelivery, error)
+	Func_UpdateWebhookDelivery          func(*models.WebhookDelivery) error
+	Func_StorePrediction                func(*models.Prediction) error
+	Func_GetPrediction                  func(models.NoteId) (*models.Prediction, error)
+	Func_GetUsersResolvedPredictions    func(models.UserId, models.UserId) ([]*models.Prediction, error)
+	Func_ResolvePrediction              func(models.NoteId, models.UserId, models.PredictionOutcome, time.Time) error
+	Func_GetDuePredictionReminders      func(time.Time) ([]*models.PredictionReminder, error)
+	Func_MarkPredictionReminderSent     func(models.NoteId, time.Time) error
+	Func_GetNoteCategories              func([]models.NoteId) (map[models.NoteId]models.NoteCategory, error)
+	Func_IsPublishedNoteVisibleTo       func(models.NoteId, models.UserId) (bool, error)
+	Func_StoreNewAnswer                 func(models.NoteId, *models.Note) (models.NoteId, error)
+	Func_GetAnswersVisibleBy            func(models.NoteId, models.UserId) ([]*models.Answer, error)
+	Func_AcceptAnswer                   func(models.NoteId, models.NoteId) error
+	Func_ClearAcceptedAnswer            func(models.NoteId) error
+	Func_GetAnsweredQuestionIds         func([]models.NoteId) (map[models.NoteId]bool, error)
+	Func_StoreSource                    func(*models.Source) (models.SourceId, error)
+	Func_GetSource                      func(models.SourceId) (*models.Source, error)
+	Func_AttachNoteSource               func(models.NoteId, models.SourceId, *models.SourceLocation) error
+	Func_DetachNoteSource               func(models.NoteId) error
+	Func_GetNoteSource                  func(models.NoteId) (*models.NoteSource, error)
+	Func_GetSourceNotesVisibleBy        func(models.SourceId, models.UserId) ([]*models.SourceNote, error)
+	Func_DeserializeNoteCategory        func(string) (models.NoteCategory, error)
+	Func_GetCategories                  func() ([]*models.Category, error)
+	Func_GetCategory                    func(models.NoteCategory) (*models.Category, error)
+	Func_StoreNewCategory               func(*models.Category) error
+	Func_UpdateCategory                 func(models.NoteCategory, *models.Category) error
+	Func_DeleteCategory                 func(models.NoteCategory) error
+	Func_StoreNewReply                  func(*models.Reply) (models.ReplyId, error)
+	Func_GetReply                       func(models.ReplyId) (*models.Reply, error)
+	Func_GetReplies                     func(models.NoteId) ([]*models.Reply, error)
+	Func_UpdateReplyContent             func(models.ReplyId, string) error
+	Func_DeleteReply                    func(models.ReplyId) error
+	Func_GetReplyCounts                 func([]models.NoteId) (map[models.NoteId]int, error)
+	Func_ToggleReaction                 func(models.NoteId, models.UserId, string) (bool, error)
+	Func_GetReactionSummaries           func([]models.NoteId, models.UserId) (map[models.NoteId][]*models.ReactionSummary, error)
+	Func_GetNoteLinksBetween            func([]models.NoteId) ([]*models.NoteLink, error)
+	Func_StoreNewAttachment             func(*models.Attachment) (models.AttachmentId, error)
+	Func_GetAttachment                  func(models.AttachmentId) (*models.Attachment, error)
+	Func_GetNoteAttachments             func(models.NoteId) ([]*models.Attachment, error)
+	Func_DeleteAttachment               func(models.AttachmentId) error
+	Func_TrashNote                      func(models.NoteId, time.Time) error
+	Func_GetTrashedNotes                func(models.UserId) (models.NotesById, error)
+	Func_RestoreNote                    func(models.NoteId) error
+	Func_PurgeNote                      func(models.NoteId, time.Time) ([]*models.Attachment, error)
+	Func_PurgeTrashedNotes              func(time.Time, time.Time) ([]*models.Attachment, error)
+	Func_RetractPublication             func(models.UserId, models.PublicationId, string, time.Time) (models.RetractionOutcome, error)
+	Func_PublishIssue                   func(models.UserId, *models.IssueDraft) (models.PublicationId, error)
+	Func_PreviewIssue                   func(models.UserId, *models.IssueDraft) (*models.PublishedIssue, error)
+	Func_MarkNoteRead                   func(models.UserId, models.NoteId, time.Time) error
+	Func_MarkNoteUnread                 func(models.UserId, models.NoteId) error
+	Func_MarkPublicationRead            func(models.UserId, models.PublicationId, time.Time) error
+	Func_MarkAllRead                    func(models.UserId, time.Time) error
+	Func_GetUnreadNoteIds               func(models.UserId) (map[models.NoteId]bool, error)
+	Func_GetUnreadCounts                func(models.UserId) (*models.UnreadCounts, error)
+	Func_StoreNewCollection             func(*models.Collection) (models.CollectionId, error)
+	Func_GetCollections                 func(models.UserId) ([]*models.Collection, error)
+	Func_GetCollection                  func(models.CollectionId) (*models.Collection, error)
+	Func_RenameCollection               func(models.CollectionId, string) error
+	Func_DeleteCollection               func(models.CollectionId) error
+	Func_AddNoteToCollection            func(models.CollectionId, models.NoteId, string, time.Time) error
+	Func_UpdateCollectionAnnotation     func(models.CollectionId, models.NoteId, string) error
+	Func_RemoveNoteFromCollection       func(models.CollectionId, models.NoteId) error
+	Func_ReorderCollection              func(models.CollectionId, []models.NoteId) error
+	Func_GetCollectionNotes             func(models.CollectionId) ([]*models.CollectionNote, error)
+	Func_UpdateNoteStates               func(models.UserId, []models.NoteId, *bool, *bool, time.Time) error
+	Func_ApplyNoteOperations            func(models.UserId, []*models.NoteOperation, time.Time) ([]*models.NoteOperationResult, error)
+	Func_GetNoteTags                    func([]models.NoteId) (map[models.NoteId][]string, error)
+	Func_GetRepliesForNotes             func([]models.NoteId) (map[models.NoteId][]*models.Reply, error)
+}
+
+func (mock *MockDataStore) StoreNewNote(note *models.Note) (models.NoteId, error) {
+	return mock.Func_StoreNewNote(note)
+}
+
+func (mock *MockDataStore) StoreNewUser(str1 string, email *models.EmailAddress, str2 string) error {
+	return mock.Func_StoreNewUser(str1, email, str2)
+}
+
+func (mock *MockDataStore) AuthenticateUserCredentials(email *models.EmailAddress, str string) error {
+	return mock.Func_AuthenticateUserCredentials(email, str)
+}
+
+func (mock *MockDataStore) GetIdForUserWithEmailAddress(email *models.EmailAddress) (models.UserId, error) {
+	return mock.Func_GetIdForUserWithEmailAddress(email)
+}
+
+func (mock *MockDataStore) GetUsersNotes(userId models.UserId) (models.NotesById, error) {
+	return mock.Func_GetUsersNotes(userId)
+}
+
+func (mock *MockDataStore) DeleteNoteById(noteId models.NoteId) error {
+	return mock.Func_DeleteNoteById(noteId)
+}
+
+func (mock *MockDataStore) GetMyUnpublishedNotes(userId models.UserId) (models.NotesById, error) {
+	return mock.Func_GetMyUnpublishedNotes(userId)
+}
+
+func (mock *MockDataStore) GetAllUsersById() (models.UsersById, error) {
+	return mock.Func_GetAllUsersById()
+}
+
+func (mock *MockDataStore) GetAllPublishedNotesVisibleBy(userId models.UserId) (map[int64]models.NotesById, error) {
+	return mock.Func_GetAllPublishedNotesVisibleBy(userId)
+}
+
+func (mock *MockDataStore) PublishNotes(userId models.UserId) (models.PublicationId, error) {
+	return mock.Func_PublishNotes(userId)
+}
+
+func (mock *MockDataStore) StoreNewPublication(publication *models.Publication) (models.PublicationId, error) {
+	return mock.Func_StoreNewPublication(publication)
+}
+
+func (mock *MockDataStore) GetNoteById(noteId models.NoteId) (*models.Note, error) {
+	return mock.Func_GetNoteById(noteId)
+}
+
+func (mock *MockDataStore) UpdateNoteContent(noteId models.NoteId, content string, revision int) error {
+	return mock.Func_UpdateNoteContent(noteId, content, revision)
+}
+
+func (mock *MockDataStore) GetNoteCategory(noteId models.NoteId) (models.NoteCategory, error) {
+	return mock.Func_GetNoteCategory(noteId)
+}
+
+func (mock *MockDataStore) AssignNoteCategoryRelationship(noteId models.NoteId, category models.NoteCategory) error {
+	return mock.Func_AssignNoteCategoryRelationship(noteId, category)
+}
+func (mock *MockDataStore) DeleteNoteCategory(noteId models.NoteId) error {
+	return mock.Func_DeleteNoteCategory(noteId)
+}
+
+func (mock *MockDataStore) ImportNotes(userId models.UserId, notes []*models.ImportedNote, dryRun bool) (*models.ImportReport, error) {
+	return mock.Func_ImportNotes(userId, notes, dryRun)
+}
+
+func (mock *MockDataStore) GetPublishedIssuesVisibleBy(userId models.UserId) ([]*models.PublishedIssue, error) {
+	return mock.Func_GetPublishedIssuesVisibleBy(userId)
+}
+
+func (mock *MockDataStore) CreateFeedToken(userId models.UserId) (string, error) {
+	return mock.Func_CreateFeedToken(userId)
+}
+
+func (mock *MockDataStore) GetFeedToken(userId models.UserId) (string, error) {
+	return mock.Func_GetFeedToken(userId)
+}
+
+func (mock *MockDataStore) GetUserIdForFeedToken(token string) (models.UserId, error) {
+	return mock.Func_GetUserIdForFeedToken(token)
+}
+
+func (mock *MockDataStore) DeleteFeedToken(userId models.UserId) error {
+	return mock.Func_DeleteFeedToken(userId)
+}
+
+func (mock *MockDataStore) GetNotificationPreferences(userId models.UserId) (*models.NotificationPreferences, error) {
+	return mock.Func_GetNotificationPreferences(userId)
+}
+
+func (mock *MockDataStore) SetNotificationFrequency(userId models.UserId, frequency models.NotificationFrequency) error {
+	return mock.Func_SetNotificationFrequency(userId, frequency)
+}
+
+func (mock *MockDataStore) UnsubscribeFromNotifications(token string) error {
+	return mock.Func_UnsubscribeFromNotifications(token)
+}
+
+func (mock *MockDataStore) GetNotificationRecipients() ([]*models.NotificationRecipient, error) {
+	return mock.Func_GetNotificationRecipients()
+}
+
+func (mock *MockDataStore) MarkNotificationsSent(userId models.UserId, publicationIds []models.PublicationId, sentTime time.Time) error {
+	return mock.Func_MarkNotificationsSent(userId, publicationIds, sentTime)
+}
+
+func (mock *MockDataStore) StoreNewWebhookSubscription(subscription *models.WebhookSubscription) (models.WebhookSubscriptionId, error) {
+	return mock.Func_StoreNewWebhookSubscription(subscription)
+}
+
+func (mock *MockDataStore) GetUsersWebhookSubscriptions(userId models.UserId) ([]*models.WebhookSubscription, error) {
+	return mock.Func_GetUsersWebhookSubscriptions(userId)
+}
+
+func (mock *MockDataStore) DeleteWebhookSubscription(userId models.UserId, subscriptionId models.WebhookSubscriptionId) error {
+	return mock.Func_DeleteWebhookSubscription(userId, subscriptionId)
+}
+
+func (mock *MockDataStore) QueueWebhookDeliveries(event *models.WebhookEvent) error {
+	return mock.Func_QueueWebhookDeliveries(event)
+}
+
+func (mock *MockDataStore) GetEventAudience(event *models.WebhookEvent) ([]models.UserId, error) {
+	return mock.Func_GetEventAudience(event)
+}
+
+func (mock *MockDataStore) QueueWebhookPing(userId models.UserId, subscriptionId models.WebhookSubscriptionId) (models.WebhookDeliveryId, error) {
+	return mock.Func_QueueWebhookPing(userId, subscriptionId)
+}
+
+func (mock *MockDataStore) GetDueWebhookDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return mock.Func_GetDueWebhookDeliveries(now, limit)
+}
+
+func (mock *MockDataStore) GetWebhookDeliveries(userId models.UserId, subscriptionId models.WebhookSubscriptionId) ([]*models.WebhookDelivery, error) {
+	return mock.Func_GetWebhookDeliveries(userId, subscriptionId)
+}
+
+func (mock *MockDataStore) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return mock.Func_UpdateWebhookDelivery(delivery)
+}
+
+func (mock *MockDataStore) StorePrediction(prediction *models.Prediction) error {
+	return mock.Func_StorePrediction(prediction)
+}
+
+func (mock *MockDataStore) GetPrediction(noteId models.NoteId) (*models.Prediction, error) {
+	return mock.Func_GetPrediction(noteId)
+}
+
+func (mock *MockDataStore) GetUsersResolvedPredictions(userId models.UserId, viewerId models.UserId) ([]*models.Prediction, error) {
+	return mock.Func_GetUsersResolvedPredictions(userId, viewerId)
+}
+
+func (mock *MockDataStore) ResolvePrediction(noteId models.NoteId, resolverId models.UserId, outcome models.PredictionOutcome, resolvedTime time.Time) error {
+	return mock.Func_ResolvePrediction(noteId, resolverId, outcome, resolvedTime)
+}
+
+func (mock *MockDataStore) GetDuePredictionReminders(now time.Time) ([]*models.PredictionReminder, error) {
+	return mock.Func_GetDuePredictionReminders(now)
+}
+
+func (mock *MockDataStore) MarkPredictionReminderSent(noteId models.NoteId, sentTime time.Time) error {
+	return mock.Func_MarkPredictionReminderSent(noteId, sentTime)
+}
+
+func (mock *MockDataStore) GetNoteCategories(noteIds []models.NoteId) (map[models.NoteId]models.NoteCategory, error) {
+	return mock.Func_GetNoteCategories(noteIds)
+}
+
+func (mock *MockDataStore) IsPublishedNoteVisibleTo(noteId models.NoteId, userId models.UserId) (bool, error) {
+	return mock.Func_IsPublishedNoteVisibleTo(noteId, userId)
+}
+
+func (mock *MockDataStore) StoreNewAnswer(questionId models.NoteId, answer *models.Note) (models.NoteId, error) {
+	return mock.Func_StoreNewAnswer(questionId, answer)
+}
+
+func (mock *MockDataStore) GetAnswersVisibleBy(questionId models.NoteId, userId models.UserId) ([]*models.Answer, error) {
+	return mock.Func_GetAnswersVisibleBy(questionId, userId)
+}
+
+func (mock *MockDataStore) AcceptAnswer(questionId models.NoteId, answerId models.NoteId) error {
+	return mock.Func_AcceptAnswer(questionId, answerId)
+}
+
+func (mock *MockDataStore) ClearAcceptedAnswer(questionId models.NoteId) error {
+	return mock.Func_ClearAcceptedAnswer(questionId)
+}
+
+func (mock *MockDataStore) GetAnsweredQuestionIds(questionIds []models.NoteId) (map[models.NoteId]bool, error) {
+	return mock.Func_GetAnsweredQuestionIds(questionIds)
+}
+
+func (mock *MockDataStore) StoreSource(source *models.Source) (models.SourceId, error) {
+	return mock.Func_StoreSource(source)
+}
+
+func (mock *MockDataStore) GetSource(sourceId models.SourceId) (*models.Source, error) {
+	return mock.Func_GetSource(sourceId)
+}
+
+func (mock *MockDataStore) AttachNoteSource(noteId models.NoteId, sourceId models.SourceId, location *models.SourceLocation) error {
+	return mock.Func_AttachNoteSource(noteId, sourceId, location)
+}
+
+func (mock *MockDataStore) DetachNoteSource(noteId models.NoteId) error {
+	return mock.Func_DetachNoteSource(noteId)
+}
+
+func (mock *MockDataStore) GetNoteSource(noteId models.NoteId) (*models.NoteSource, error) {
+	return mock.Func_GetNoteSource(noteId)
+}
+
+func (mock *MockDataStore) GetSourceNotesVisibleBy(sourceId models.SourceId, userId models.UserId) ([]*models.SourceNote, error) {
+	return mock.Func_GetSourceNotesVisibleBy(sourceId, userId)
+}
+
+func (mock *MockDataStore) DeserializeNoteCategory(input string) (models.NoteCategory, error) {
+	return mock.Func_DeserializeNoteCategory(input)
+}
+
+func (mock *MockDataStore) GetCategories() ([]*models.Category, error) {
+	return mock.Func_GetCategories()
+}
+
+func (mock *MockDataStore) GetCategory(name models.NoteCategory) (*models.Category, error) {
+	return mock.Func_GetCategory(name)
+}
+
+func (mock *MockDataStore) StoreNewCategory(category *models.Category) error {
+	return mock.Func_StoreNewCategory(category)
+}
+
+func (mock *MockDataStore) UpdateCategory(name models.NoteCategory, category *models.Category) error {
+	return mock.Func_UpdateCategory(name, category)
+}
+
+func (mock *MockDataStore) DeleteCategory(name models.NoteCategory) error {
+	return mock.Func_DeleteCategory(name)
+}
+
+func (mock *MockDataStore) StoreNewReply(reply *models.Reply) (models.ReplyId, error) {
+	return mock.Func_StoreNewReply(reply)
+}
+
+func (mock *MockDataStore) GetReply(replyId models.ReplyId) (*models.Reply, error) {
+	return mock.Func_GetReply(replyId)
+}
+
+func (mock *MockDataStore) GetReplies(noteId models.NoteId) ([]*models.Reply, error) {
+	return mock.Func_GetReplies(noteId)
+}
+
+func (mock *MockDataStore) UpdateReplyContent(replyId models.ReplyId, content string) error {
+	return mock.Func_UpdateReplyContent(replyId, content)
+}
+
+func (mock *MockDataStore) DeleteReply(replyId models.ReplyId) error {
+	return mock.Func_DeleteReply(replyId)
+}
+
+func (mock *MockDataStore) GetReplyCounts(noteIds []models.NoteId) (map[models.NoteId]int, error) {
+	return mock.Func_GetReplyCounts(noteIds)
+}
+
+func (mock *MockDataStore) ToggleReaction(noteId models.NoteId, userId models.UserId, reaction string) (bool, error) {
+	return mock.Func_ToggleReaction(noteId, userId, reaction)
+}
+
+func (mock *MockDataStore) GetReactionSummaries(noteIds []models.NoteId, userId models.UserId) (map[models.NoteId][]*models.ReactionSummary, error) {
+	return mock.Func_GetReactionSummaries(noteIds, userId)
+}
+
+func (mock *MockDataStore) GetNoteLinksBetween(noteIds []models.NoteId) ([]*models.NoteLink, error) {
+	return mock.Func_GetNoteLinksBetween(noteIds)
+}
+
+func (mock *MockDataStore) StoreNewAttachment(attachment *models.Attachment) (models.AttachmentId, error) {
+	return mock.Func_StoreNewAttachment(attachment)
+}
+
+func (mock *MockDataStore) GetAttachment(attachmentId models.AttachmentId) (*models.Attachment, error) {
+	return mock.Func_GetAttachment(attachmentId)
+}
+
+func (mock *MockDataStore) GetNoteAttachments(noteId models.NoteId) ([]*models.Attachment, error) {
+	return mock.Func_GetNoteAttachments(noteId)
+}
+
+func (mock *MockDataStore) DeleteAttachment(attachmentId models.AttachmentId) error {
+	return mock.Func_DeleteAttachment(attachmentId)
+}
+
+func (mock *MockDataStore) TrashNote(noteId models.NoteId, deletionTime time.Time) error {
+	return mock.Func_TrashNote(noteId, deletionTime)
+}
+
+func (mock *MockDataStore) GetTrashedNotes(userId models.UserId) (models.NotesById, error) {
+	return mock.Func_GetTrashedNotes(userId)
+}
+
+func (mock *MockDataStore) RestoreNote(noteId models.NoteId) error {
+	return mock.Func_RestoreNote(noteId)
+}
+
+func (mock *MockDataStore) PurgeNote(noteId models.NoteId, purgeTime time.Time) ([]*models.Attachment, error) {
+	return mock.Func_PurgeNote(noteId, purgeTime)
+}
+
+func (mock *MockDataStore) PurgeTrashedNotes(trashedBefore time.Time, purgeTime time.Time) ([]*models.Attachment, error) {
+	return mock.Func_PurgeTrashedNotes(trashedBefore, purgeTime)
+}
+
+func (mock *MockDataStore) RetractPublication(authorId models.UserId, publicationId models.PublicationId, reason string, now time.Time) (models.RetractionOutcome, error) {
+	return mock.Func_RetractPublication(authorId, publicationId, reason, now)
+}
+
+func (mock *MockDataStore) PublishIssue(userId models.UserId, draft *models.IssueDraft) (models.PublicationId, error) {
+	return mock.Func_PublishIssue(userId, draft)
+}
+
+func (mock *MockDataStore) PreviewIssue(userId models.UserId, draft *models.IssueDraft) (*models.PublishedIssue, error) {
+	return mock.Func_PreviewIssue(userId, draft)
+}
+
+func (mock *MockDataStore) MarkNoteRead(userId models.UserId, noteId models.NoteId, readTime time.Time) error {
+	return mock.Func_MarkNoteRead(userId, noteId, readTime)
+}
+
+func (mock *MockDataStore) MarkNoteUnread(userId models.UserId, noteId models.NoteId) error {
+	return mock.Func_MarkNoteUnread(userId, noteId)
+}
+
+func (mock *MockDataStore) MarkPublicationRead(userId models.UserId, publicationId models.PublicationId, readTime time.Time) error {
+	return mock.Func_MarkPublicationRead(userId, publicationId, readTime)
+}
+
+func (mock *MockDataStore) MarkAllRead(userId models.UserId, readTime time.Time) error {
+	return mock.Func_MarkAllRead(userId, readTime)
+}
+
+func (mock *MockDataStore) GetUnreadNoteIds(userId models.UserId) (map[models.NoteId]bool, error) {
+	return mock.Func_GetUnreadNoteIds(userId)
+}
+
+func (mock *MockDataStore) GetUnreadCounts(userId models.UserId) (*models.UnreadCounts, error) {
+	return mock.Func_GetUnreadCounts(userId)
+}
+
+func (mock *MockDataStore) StoreNewCollection(collection *models.Collection) (models.CollectionId, error) {
+	return mock.Func_StoreNewCollection(collection)
+}
+
+func (mock *MockDataStore) GetCollections(ownerId models.UserId) ([]*models.Collection, error) {
+	return mock.Func_GetCollections(ownerId)
+}
+
+func (mock *MockDataStore) GetCollection(collectionId models.CollectionId) (*models.Collection, error) {
+	return mock.Func_GetCollection(collectionId)
+}
+
+func (mock *MockDataStore) RenameCollection(collectionId models.CollectionId, name string) error {
+	return mock.Func_RenameCollection(collectionId, name)
+}
+
+func (mock *MockDataStore) DeleteCollection(collectionId models.CollectionId) error {
+	return mock.Func_DeleteCollection(collectionId)
+}
+
+func (mock *MockDataStore) AddNoteToCollection(collectionId models.CollectionId, noteId models.NoteId, annotation string, addedTime time.Time) error {
+	return mock.Func_AddNoteToCollection(collectionId, noteId, annotation, addedTime)
+}
+
+func (mock *MockDataStore) UpdateCollectionAnnotation(collectionId models.CollectionId, noteId models.NoteId, annotation string) error {
+	return mock.Func_UpdateCollectionAnnotation(collectionId, noteId, annotation)
+}
+
+func (mock *MockDataStore) RemoveNoteFromCollection(collectionId models.CollectionId, noteId models.NoteId) error {
+	return mock.Func_RemoveNoteFromCollection(collectionId, noteId)
+}
+
+func (mock *MockDataStore) ReorderCollection(collectionId models.CollectionId, noteOrder []models.NoteId) error {
+	return mock.Func_ReorderCollection(collectionId, noteOrder)
+}
+
+func (mock *MockDataStore) GetCollectionNotes(collectionId models.CollectionId) ([]*models.CollectionNote, error) {
+	return mock.Func_GetCollectionNotes(collectionId)
+}
+
+func (mock *MockDataStore) UpdateNoteStates(authorId models.UserId, noteIds []models.NoteId, pinned *bool, archived *bool, now time.Time) error {
+	return mock.Func_UpdateNoteStates(authorId, noteIds, pinned, archived, now)
+}
+
+func (mock *MockDataStore) ApplyNoteOperations(authorId models.UserId, operations []*models.NoteOperation, now time.Time) ([]*models.NoteOperationResult, error) {
+	return mock.Func_ApplyNoteOperations(authorId, operations, now)
+}
+
+func (mock *MockDataStore) GetNoteTags(noteIds []models.NoteId) (map[models.NoteId][]string, error) {
+	return mock.Func_GetNoteTags(noteIds)
+}
+
+func (mock *MockDataStore) GetRepliesForNotes(noteIds []models.NoteId) (map[models.NoteId][]*models.Reply, error) {
+	return mock.Func_GetRepliesForNotes(noteIds)
 }
@@ -3,14 +3,27 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/events"
 	"github.com/atmiguel/cerealnotes/handlers"
+	"github.com/atmiguel/cerealnotes/markdown"
 	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/notifications"
 	"github.com/atmiguel/cerealnotes/routers"
+	"github.com/atmiguel/cerealnotes/trash"
+	"github.com/atmiguel/cerealnotes/webhooks"
 )
 
+const defaultTrashRetentionDays = 30
+
 // Get the current listening address
 func determineListenPort() (string, error) {
 	portEnvironmentVariableName := "PORT"
@@ -52,7 +65,134 @@ func determineTokenSigningKey() ([]byte, error) {
 	return []byte(tokenSigningKey), nil
 }
 
+// determineBaseUrl returns the public url of the site, used for links in emails.
+func determineBaseUrl(port string) string {
+	baseUrl := os.Getenv("BASE_URL")
+
+	if len(baseUrl) == 0 {
+		return "http://localhost" + port
+	}
+
+	return strings.TrimSuffix(baseUrl, "/")
+}
+
+// determineReactions returns the reactions readers can use, configured as a comma separated REACTIONS list.
+func determineReactions() []string {
+	reactions := models.ParseReactions(os.Getenv("REACTIONS"))
+
+	if len(reactions) == 0 {
+		return models.DefaultReactions
+	}
+
+	return reactions
+}
+
+// determineAdmins returns the users who can change every category, configured as a comma separated
+// ADMIN_USER_IDS list.
+func determineAdmins() (map[models.UserId]bool, error) {
+	admins := make(map[models.UserId]bool)
+
+	for _, field := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("environment variable ADMIN_USER_IDS must list user ids, not %q", field)
+		}
+
+		admins[models.UserId(id)] = true
+	}
+
+	return admins, nil
+}
+
+// determineBlobStore keeps attachments in the S3-compatible bucket S3_BUCKET when it is set, and in the
+// local directory ATTACHMENT_DIR otherwise.
+func determineBlobStore() blobstore.Store {
+	bucket := os.Getenv("S3_BUCKET")
+
+	if len(bucket) == 0 {
+		attachmentDir := os.Getenv("ATTACHMENT_DIR")
+		if len(attachmentDir) == 0 {
+			attachmentDir = "attachments"
+		}
+
+		log.Print("environment variable S3_BUCKET not set, attachments will be stored in " + attachmentDir)
+		return blobstore.NewLocalStore(attachmentDir)
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	region := os.Getenv("S3_REGION")
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+	if len(endpoint) == 0 {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	return blobstore.NewS3Store(
+		endpoint,
+		bucket,
+		region,
+		os.Getenv("S3_ACCESS_KEY_ID"),
+		os.Getenv("S3_SECRET_ACCESS_KEY"),
+	)
+}
+
+// determineTrashRetention returns how long deleted notes stay in the trash, configured in days as
+// TRASH_RETENTION_DAYS.
+func determineTrashRetention() (time.Duration, error) {
+	retentionDays := os.Getenv("TRASH_RETENTION_DAYS")
+
+	if len(retentionDays) == 0 {
+		return defaultTrashRetentionDays * time.Hour * 24, nil
+	}
+
+	days, err := strconv.Atoi(retentionDays)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("environment variable TRASH_RETENTION_DAYS must be a number of days, not %q", retentionDays)
+	}
+
+	return time.Duration(days) * time.Hour * 24, nil
+}
+
+// determineMailSender sends mail through SMTP when SMTP_ADDRESS is set, and logs it otherwise.
+func determineMailSender() notifications.MailSender {
+	smtpAddress := os.Getenv("SMTP_ADDRESS")
+
+	if len(smtpAddress) == 0 {
+		log.Print("environment variable SMTP_ADDRESS not set, notification emails will only be logged")
+		return &notifications.LogMailSender{}
+	}
+
+	sender := &notifications.SmtpMailSender{
+		Address: smtpAddress,
+		From:    os.Getenv("MAIL_FROM"),
+	}
+
+	if username := os.Getenv("SMTP_USERNAME"); len(username) > 0 {
+		host, _, err := net.SplitHostPort(smtpAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sender.Auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return sender
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == importCommandName {
+		if err := runImportCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Set up db
 
 	env := &handlers.Environment{}
@@ -81,13 +221,66 @@ func main() {
 		env.TokenSigningKey = tokenSigningKey
 	}
 
-	// Start server
+	env.Reactions = determineReactions()
+
+	// Set up admins
+	{
+		admins, err := determineAdmins()
+		if err != nil {
+			log.Fatal(err)
+		}
+		env.Admins = admins
+	}
+
+	// Cache rendered note content, which only changes along with the note's revision
+	env.Markdown = markdown.NewCache(4096)
+
+	env.Blobs = determineBlobStore()
+
+	// Set up the trash
 	{
-		port, err := determineListenPort()
+		trashRetention, err := determineTrashRetention()
 		if err != nil {
 			log.Fatal(err)
 		}
+		env.TrashRetention = trashRetention
+	}
+
+	// Set up live events, remembering enough history for clients to resume after a reconnect
+	env.Events = events.NewInProcessHub(1024)
+
+	port, err := determineListenPort()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Start notifying users about new issues
+	{
+		notifier := &notifications.Notifier{
+			Db:      env.Db,
+			Sender:  determineMailSender(),
+			BaseUrl: determineBaseUrl(port),
+		}
 
+		go notifier.Run(time.Minute)
+	}
+
+	// Start purging expired notes from the trash
+	{
+		purger := &trash.Purger{
+			Db:        env.Db,
+			Blobs:     env.Blobs,
+			Retention: env.TrashRetention,
+		}
+
+		go purger.Run(time.Hour)
+	}
+
+	// Start delivering webhooks
+	go webhooks.NewDispatcher(env.Db).Run(time.Second * 10)
+
+	// Start server
+	{
 		log.Printf("Listening on %s...\n", port)
 
 		if err := http.ListenAndServe(port, routers.DefineRoutes(env)); err != nil {
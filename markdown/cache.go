@@ -0,0 +1,96 @@
+package markdown
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheKey identifies one revision of a note's content.
+type CacheKey struct {
+	NoteId   int64
+	Revision int
+}
+
+type cacheEntry struct {
+	key  CacheKey
+	html string
+}
+
+// Cache remembers the rendered HTML of the most recently used note revisions.
+type Cache struct {
+	size int
+
+	mutex   sync.Mutex
+	entries map[CacheKey]*list.Element
+	recency *list.List
+}
+
+// NewCache returns a cache holding at most size rendered revisions.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		entries: make(map[CacheKey]*list.Element),
+		recency: list.New(),
+	}
+}
+
+// Render returns the HTML of the revision, rendering the content only if it isn't cached.
+// A nil cache renders every time.
+func (cache *Cache) Render(key CacheKey, content string) (string, error) {
+	if cache == nil {
+		return Render(content)
+	}
+
+	if html, ok := cache.get(key); ok {
+		return html, nil
+	}
+
+	html, err := Render(content)
+	if err != nil {
+		return "", err
+	}
+
+	cache.put(key, html)
+
+	return html, nil
+}
+
+// Len returns how many revisions are cached.
+func (cache *Cache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.recency.Len()
+}
+
+func (cache *Cache) get(key CacheKey) (string, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return "", false
+	}
+
+	cache.recency.MoveToFront(element)
+
+	return element.Value.(*cacheEntry).html, true
+}
+
+func (cache *Cache) put(key CacheKey, html string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.recency.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.recency.PushFront(&cacheEntry{key: key, html: html})
+
+	for cache.recency.Len() > cache.size {
+		oldest := cache.recency.Back()
+		cache.recency.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package markdown renders note content to sanitised HTML.
+
+Content is parsed as CommonMark with tables, task lists and strikethrough, then
+run through a strict allowlist sanitiser so that no user supplied markup can
+script the page. Rendering is cached by note revision, since content only
+changes when the revision does.
+*/
+package markdown
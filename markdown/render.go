@@ -0,0 +1,48 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var converter = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.TaskList,
+		extension.Strikethrough,
+	),
+)
+
+var policy = newPolicy()
+
+// newPolicy allows the markup Markdown produces and nothing else.
+func newPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+
+	// links open in a new tab without passing on ranking or a handle to this page
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	// fenced code blocks carry their language for client side highlighting
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+-]+$`)).OnElements("code")
+
+	// task list items render as disabled checkboxes
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+
+	return policy
+}
+
+// Render converts the Markdown to sanitised HTML.
+func Render(content string) (string, error) {
+	var html bytes.Buffer
+	if err := converter.Convert([]byte(content), &html); err != nil {
+		return "", err
+	}
+
+	return policy.Sanitize(html.String()), nil
+}
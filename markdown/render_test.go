@@ -0,0 +1,60 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/markdown"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+var renderTests = []struct {
+	name     string
+	content  string
+	expected string
+}{
+	{"paragraph", "plain *text*", "<p>plain <em>text</em></p>\n"},
+	{"script", "hello <script>alert(1)</script>", "<p>hello alert(1)</p>\n"},
+	{"event handler", "hi <img src=x onerror=alert(1)>", "<p>hi </p>\n"},
+	{"raw link", "<a href='https://example.com'>x</a>", "<p>x</p>\n"},
+	{"javascript link", "[click](javascript:alert(1))", "<p>click</p>\n"},
+	{"link", "[site](https://example.com)", `<p><a href="https://example.com" rel="nofollow noopener" target="_blank">site</a></p>` + "\n"},
+	{"fenced code", "```go\nfmt.Println()\n```", `<pre><code class="language-go">fmt.Println()` + "\n</code></pre>\n"},
+	{"task list", "- [x] done", `<ul>` + "\n" + `<li><input checked="" disabled="" type="checkbox"> done</li>` + "\n</ul>\n"},
+	{"table", "| a |\n|---|\n| b |", "<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>b</td>\n</tr>\n</tbody>\n</table>\n"},
+}
+
+func TestRender(t *testing.T) {
+	for _, test := range renderTests {
+		t.Run(test.name, func(t *testing.T) {
+			html, err := markdown.Render(test.content)
+			test_util.Ok(t, err)
+			test_util.Equals(t, test.expected, html)
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := markdown.NewCache(2)
+
+	html, err := cache.Render(markdown.CacheKey{NoteId: 1, Revision: 1}, "*one*")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "<p><em>one</em></p>\n", html)
+
+	// a cached revision isn't rendered again, even if asked with different content
+	html, err = cache.Render(markdown.CacheKey{NoteId: 1, Revision: 1}, "*changed*")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "<p><em>one</em></p>\n", html)
+
+	html, err = cache.Render(markdown.CacheKey{NoteId: 1, Revision: 2}, "*changed*")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "<p><em>changed</em></p>\n", html)
+
+	_, err = cache.Render(markdown.CacheKey{NoteId: 2, Revision: 1}, "two")
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, cache.Len())
+
+	// the least recently used revision was evicted
+	html, err = cache.Render(markdown.CacheKey{NoteId: 1, Revision: 1}, "*changed*")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "<p><em>changed</em></p>\n", html)
+}
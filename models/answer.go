@@ -0,0 +1,209 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var NoAnswerFoundError = errors.New("No answer with that information could be found")
+
+// Answer is a note posted in answer to a question note. Answers are published and
+// become visible like any other note.
+type Answer struct {
+	NoteId       NoteId    `json:"noteId"`
+	QuestionId   NoteId    `json:"questionId"`
+	AuthorId     UserId    `json:"authorId"`
+	Content      string    `json:"content"`
+	CreationTime time.Time `json:"creationTime"`
+	Accepted     bool      `json:"accepted"`
+}
+
+//  DB methods
+
+// StoreNewAnswer stores the answer as a new unpublished note linked to the question.
+func (db *DB) StoreNewAnswer(questionId NoteId, answer *Note) (NoteId, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sqlQueryNote := `
+		INSERT INTO note (author_id, content, creation_time)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	var answerId int64
+	if err := tx.QueryRow(sqlQueryNote, int64(answer.AuthorId), answer.Content, answer.CreationTime).Scan(&answerId); err != nil {
+		return 0, convertPostgresError(err)
+	}
+
+	sqlQueryAnswer := `
+		INSERT INTO question_answer (answer_id, question_id)
+		VALUES ($1, $2)`
+
+	if _, err := tx.Exec(sqlQueryAnswer, answerId, int64(questionId)); err != nil {
+		return 0, convertPostgresError(err)
+	}
+
+	if err := storeNoteLinks(tx, NoteId(answerId), answer.Content); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return NoteId(answerId), nil
+}
+
+// GetAnswersVisibleBy returns the answers to the question that the user wrote or is allowed to read,
+// oldest first.
+func (db *DB) GetAnswersVisibleBy(questionId NoteId, userId UserId) ([]*Answer, error) {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT
+		note.id,
+		answer.question_id,
+		note.author_id,
+		note.content,
+		note.creation_time,
+		answer.accepted
+		FROM   question_answer AS answer
+			   INNER JOIN note
+					   ON note.id = answer.answer_id
+			   LEFT OUTER JOIN note_to_publication_relationship AS note2pub
+							ON note2pub.note_id = note.id
+			   LEFT OUTER JOIN (` + rankedPublicationsSql + `) ranked_pubs
+							ON ranked_pubs.id = note2pub.publication_id
+		WHERE  answer.question_id = $1
+			   AND note.deleted_time IS NULL
+			   AND (note.author_id = $2 OR (ranked_pubs.rank <= $3 AND ranked_pubs.retracted_time IS NULL))
+		ORDER BY note.creation_time`
+
+	rows, err := db.Query(sqlQuery, int64(questionId), int64(userId), publicationCount)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	answers := make([]*Answer, 0)
+	for rows.Next() {
+		answer := &Answer{}
+		var noteId int64
+		var questionId int64
+		if err := rows.Scan(
+			&noteId,
+			&questionId,
+			&answer.AuthorId,
+			&answer.Content,
+			&answer.CreationTime,
+			&answer.Accepted,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		answer.NoteId = NoteId(noteId)
+		answer.QuestionId = NoteId(questionId)
+
+		answers = append(answers, answer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return answers, nil
+}
+
+// AcceptAnswer marks the answer as the question's accepted answer, replacing any previous one.
+func (db *DB) AcceptAnswer(questionId NoteId, answerId NoteId) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlQueryClear := `
+		UPDATE question_answer SET accepted = false
+		WHERE question_id = $1 AND accepted`
+
+	if _, err := tx.Exec(sqlQueryClear, int64(questionId)); err != nil {
+		return convertPostgresError(err)
+	}
+
+	sqlQueryAccept := `
+		UPDATE question_answer SET accepted = true
+		WHERE question_id = $1 AND answer_id = $2`
+
+	result, err := tx.Exec(sqlQueryAccept, int64(questionId), int64(answerId))
+	if err != nil {
+		return convertPostgresError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoAnswerFoundError
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) ClearAcceptedAnswer(questionId NoteId) error {
+	sqlQuery := `
+		UPDATE question_answer SET accepted = false
+		WHERE question_id = $1 AND accepted`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(questionId))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoAnswerFoundError
+	}
+
+	return nil
+}
+
+// GetAnsweredQuestionIds returns which of the given questions have an accepted answer.
+func (db *DB) GetAnsweredQuestionIds(questionIds []NoteId) (map[NoteId]bool, error) {
+	ids := make([]int64, len(questionIds))
+	for i, questionId := range questionIds {
+		ids[i] = int64(questionId)
+	}
+
+	sqlQuery := `
+		SELECT question_id FROM question_answer
+		WHERE question_id = ANY($1) AND accepted`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	answered := make(map[NoteId]bool)
+	for rows.Next() {
+		var questionId int64
+		if err := rows.Scan(&questionId); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		answered[NoteId(questionId)] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return answered, nil
+}
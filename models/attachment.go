@@ -0,0 +1,159 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type AttachmentId int64
+
+var NoAttachmentFoundError = errors.New("No attachment with that information could be found")
+
+// Attachment is a file uploaded to a note. Its content, and the thumbnail of images, live in the blob store.
+type Attachment struct {
+	Id           AttachmentId `json:"id"`
+	NoteId       NoteId       `json:"noteId"`
+	UploaderId   UserId       `json:"uploaderId"`
+	FileName     string       `json:"fileName"`
+	ContentType  string       `json:"contentType"`
+	Size         int64        `json:"size"`
+	HasThumbnail bool         `json:"hasThumbnail"`
+	CreationTime time.Time    `json:"creationTime"`
+	BlobKey      string       `json:"-"`
+	ThumbnailKey string       `json:"-"`
+}
+
+// BlobKeys returns the keys of everything the attachment keeps in the blob store.
+func (attachment *Attachment) BlobKeys() []string {
+	if attachment.HasThumbnail {
+		return []string{attachment.BlobKey, attachment.ThumbnailKey}
+	}
+
+	return []string{attachment.BlobKey}
+}
+
+//  DB methods
+
+func (db *DB) StoreNewAttachment(attachment *Attachment) (AttachmentId, error) {
+	var thumbnailKey sql.NullString
+	if len(attachment.ThumbnailKey) > 0 {
+		thumbnailKey = sql.NullString{String: attachment.ThumbnailKey, Valid: true}
+	}
+
+	sqlQuery := `
+		INSERT INTO note_attachment (note_id, uploader_id, file_name, content_type, size, blob_key, thumbnail_key, creation_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	var attachmentId int64
+	if err := db.execOneResult(
+		sqlQuery,
+		&attachmentId,
+		int64(attachment.NoteId),
+		int64(attachment.UploaderId),
+		attachment.FileName,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.BlobKey,
+		thumbnailKey,
+		attachment.CreationTime,
+	); err != nil {
+		return 0, err
+	}
+
+	return AttachmentId(attachmentId), nil
+}
+
+func (db *DB) GetAttachment(attachmentId AttachmentId) (*Attachment, error) {
+	sqlQuery := `
+		SELECT id, note_id, uploader_id, file_name, content_type, size, blob_key, thumbnail_key, creation_time
+		FROM note_attachment
+		WHERE id = $1`
+
+	rows, err := db.Query(sqlQuery, int64(attachmentId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	attachments, err := scanAttachments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attachments) == 0 {
+		return nil, NoAttachmentFoundError
+	}
+
+	return attachments[0], nil
+}
+
+// GetNoteAttachments returns the note's attachments, oldest first.
+func (db *DB) GetNoteAttachments(noteId NoteId) ([]*Attachment, error) {
+	sqlQuery := `
+		SELECT id, note_id, uploader_id, file_name, content_type, size, blob_key, thumbnail_key, creation_time
+		FROM note_attachment
+		WHERE note_id = $1
+		ORDER BY creation_time, id`
+
+	rows, err := db.Query(sqlQuery, int64(noteId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	return scanAttachments(rows)
+}
+
+func scanAttachments(rows *sql.Rows) ([]*Attachment, error) {
+	attachments := make([]*Attachment, 0)
+	for rows.Next() {
+		attachment := &Attachment{}
+		var id int64
+		var noteId int64
+		var thumbnailKey sql.NullString
+		if err := rows.Scan(
+			&id,
+			&noteId,
+			&attachment.UploaderId,
+			&attachment.FileName,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.BlobKey,
+			&thumbnailKey,
+			&attachment.CreationTime,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		attachment.Id = AttachmentId(id)
+		attachment.NoteId = NoteId(noteId)
+		attachment.ThumbnailKey = thumbnailKey.String
+		attachment.HasThumbnail = thumbnailKey.Valid
+
+		attachments = append(attachments, attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return attachments, nil
+}
+
+func (db *DB) DeleteAttachment(attachmentId AttachmentId) error {
+	sqlQuery := `
+		DELETE FROM note_attachment
+		WHERE id = $1`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(attachmentId))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoAttachmentFoundError
+	}
+
+	return nil
+}
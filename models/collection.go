@@ -0,0 +1,382 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+type CollectionId int64
+
+const maxCollectionNameLength = 100
+const maxCollectionAnnotationLength = 5000
+
+var NoCollectionFoundError = errors.New("No collection with that information could be found")
+var CollectionNameRequiredError = errors.New("Collections need a name")
+var CollectionNameTooLongError = fmt.Errorf("Collection names cannot be longer than %d characters", maxCollectionNameLength)
+var CollectionAlreadyExistsError = errors.New("You already have a collection with that name")
+var CollectionAnnotationTooLongError = fmt.Errorf("Annotations cannot be longer than %d characters", maxCollectionAnnotationLength)
+var NoteAlreadyInCollectionError = errors.New("The note is already in the collection")
+var NoteNotInCollectionError = errors.New("The note is not in the collection")
+var InvalidCollectionOrderError = errors.New("The order must list each of the collection's notes once")
+
+// Collection is a named, private list of other authors' published notes that a reader wants to keep.
+type Collection struct {
+	Id           CollectionId `json:"id"`
+	OwnerId      UserId       `json:"ownerId"`
+	Name         string       `json:"name"`
+	CreationTime time.Time    `json:"creationTime"`
+	NoteCount    int          `json:"noteCount"`
+}
+
+// CollectionNote is a note kept in a collection, along with the owner's private annotation on it.
+type CollectionNote struct {
+	NoteId     NoteId    `json:"noteId"`
+	Note       *Note     `json:"note"`
+	Annotation string    `json:"annotation"`
+	Position   int       `json:"position"`
+	AddedTime  time.Time `json:"addedTime"`
+}
+
+// NormalizeCollectionName collapses the whitespace in a collection's name and checks it isn't empty or too long.
+func NormalizeCollectionName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+
+	if len(name) == 0 {
+		return "", CollectionNameRequiredError
+	}
+
+	if utf8.RuneCountInString(name) > maxCollectionNameLength {
+		return "", CollectionNameTooLongError
+	}
+
+	return name, nil
+}
+
+// NormalizeCollectionAnnotation trims an annotation and checks it isn't too long.
+func NormalizeCollectionAnnotation(annotation string) (string, error) {
+	annotation = strings.TrimSpace(annotation)
+
+	if utf8.RuneCountInString(annotation) > maxCollectionAnnotationLength {
+		return "", CollectionAnnotationTooLongError
+	}
+
+	return annotation, nil
+}
+
+// WriteCollectionMarkdown exports the collection as a Markdown document, with each note under a heading
+// naming its author and the owner's annotation quoted after it.
+func WriteCollectionMarkdown(writer io.Writer, collection *Collection, notes []*CollectionNote, usersById UsersById) error {
+	if _, err := fmt.Fprintf(writer, "# %s\n", collection.Name); err != nil {
+		return err
+	}
+
+	for _, collectionNote := range notes {
+		authorName := "Unknown"
+		if author, ok := usersById[collectionNote.Note.AuthorId]; ok {
+			authorName = author.DisplayName
+		}
+
+		if _, err := fmt.Fprintf(
+			writer,
+			"\n## %s, %s\n\n%s\n",
+			authorName,
+			collectionNote.Note.CreationTime.Format("2006-01-02"),
+			strings.TrimSpace(collectionNote.Note.Content),
+		); err != nil {
+			return err
+		}
+
+		if len(collectionNote.Annotation) > 0 {
+			quoted := "> " + strings.Replace(collectionNote.Annotation, "\n", "\n> ", -1)
+			if _, err := fmt.Fprintf(writer, "\n%s\n", quoted); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//  DB methods
+
+func (db *DB) StoreNewCollection(collection *Collection) (CollectionId, error) {
+	sqlQuery := `
+		INSERT INTO collection (owner_id, name, creation_time)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	var collectionId int64
+	if err := db.execOneResult(
+		sqlQuery,
+		&collectionId,
+		int64(collection.OwnerId),
+		collection.Name,
+		collection.CreationTime,
+	); err != nil {
+		if err == UniqueConstraintError {
+			return 0, CollectionAlreadyExistsError
+		}
+		return 0, err
+	}
+
+	return CollectionId(collectionId), nil
+}
+
+// GetCollections returns the user's collections, most recently created first.
+func (db *DB) GetCollections(ownerId UserId) ([]*Collection, error) {
+	sqlQuery := `
+		SELECT collection.id, collection.owner_id, collection.name, collection.creation_time, COUNT(collection_note.note_id)
+		FROM collection
+			LEFT OUTER JOIN collection_note
+						 ON collection_note.collection_id = collection.id
+		WHERE collection.owner_id = $1
+		GROUP BY collection.id
+		ORDER BY collection.creation_time DESC, collection.id DESC`
+
+	rows, err := db.Query(sqlQuery, int64(ownerId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	collections := make([]*Collection, 0)
+	for rows.Next() {
+		collection := &Collection{}
+		if err := rows.Scan(
+			&collection.Id,
+			&collection.OwnerId,
+			&collection.Name,
+			&collection.CreationTime,
+			&collection.NoteCount,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		collections = append(collections, collection)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return collections, nil
+}
+
+func (db *DB) GetCollection(collectionId CollectionId) (*Collection, error) {
+	sqlQuery := `
+		SELECT collection.id, collection.owner_id, collection.name, collection.creation_time, COUNT(collection_note.note_id)
+		FROM collection
+			LEFT OUTER JOIN collection_note
+						 ON collection_note.collection_id = collection.id
+		WHERE collection.id = $1
+		GROUP BY collection.id`
+
+	collection := &Collection{}
+	if err := db.QueryRow(sqlQuery, int64(collectionId)).Scan(
+		&collection.Id,
+		&collection.OwnerId,
+		&collection.Name,
+		&collection.CreationTime,
+		&collection.NoteCount,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoCollectionFoundError
+		}
+		return nil, convertPostgresError(err)
+	}
+
+	return collection, nil
+}
+
+func (db *DB) RenameCollection(collectionId CollectionId, name string) error {
+	sqlQuery := `
+		UPDATE collection SET name = $2
+		WHERE id = $1`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(collectionId), name)
+	if err != nil {
+		if err == UniqueConstraintError {
+			return CollectionAlreadyExistsError
+		}
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoCollectionFoundError
+	}
+
+	return nil
+}
+
+// DeleteCollection deletes the collection along with its annotations. The notes themselves are untouched.
+func (db *DB) DeleteCollection(collectionId CollectionId) error {
+	sqlQuery := `
+		DELETE FROM collection
+		WHERE id = $1`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(collectionId))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoCollectionFoundError
+	}
+
+	return nil
+}
+
+// AddNoteToCollection puts the note at the end of the collection.
+func (db *DB) AddNoteToCollection(collectionId CollectionId, noteId NoteId, annotation string, addedTime time.Time) error {
+	sqlQuery := `
+		INSERT INTO collection_note (collection_id, note_id, annotation, position, added_time)
+		SELECT $1, $2, $3, COALESCE(MAX(position), 0) + 1, $4
+		FROM collection_note
+		WHERE collection_id = $1`
+
+	if _, err := db.execNoResults(sqlQuery, int64(collectionId), int64(noteId), annotation, addedTime); err != nil {
+		switch err {
+		case UniqueConstraintError:
+			return NoteAlreadyInCollectionError
+		case ForeignKeyConstraintError:
+			return NoCollectionFoundError
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) UpdateCollectionAnnotation(collectionId CollectionId, noteId NoteId, annotation string) error {
+	sqlQuery := `
+		UPDATE collection_note SET annotation = $3
+		WHERE collection_id = $1 AND note_id = $2`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(collectionId), int64(noteId), annotation)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoteNotInCollectionError
+	}
+
+	return nil
+}
+
+func (db *DB) RemoveNoteFromCollection(collectionId CollectionId, noteId NoteId) error {
+	sqlQuery := `
+		DELETE FROM collection_note
+		WHERE collection_id = $1 AND note_id = $2`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(collectionId), int64(noteId))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoteNotInCollectionError
+	}
+
+	return nil
+}
+
+// ReorderCollection puts the collection's notes in the given order, which must list each of them once.
+func (db *DB) ReorderCollection(collectionId CollectionId, noteOrder []NoteId) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlQuerySelect := `
+		SELECT note_id FROM collection_note
+		WHERE collection_id = $1
+		FOR UPDATE`
+
+	noteIds, err := selectNoteIds(tx, sqlQuerySelect, int64(collectionId))
+	if err != nil {
+		return err
+	}
+
+	inCollection := make(map[NoteId]bool, len(noteIds))
+	for _, noteId := range noteIds {
+		inCollection[NoteId(noteId)] = true
+	}
+
+	if len(noteOrder) != len(noteIds) {
+		return InvalidCollectionOrderError
+	}
+
+	orderedIds := make([]int64, len(noteOrder))
+	for index, noteId := range noteOrder {
+		if !inCollection[noteId] {
+			return InvalidCollectionOrderError
+		}
+		// each note can only take one place
+		delete(inCollection, noteId)
+		orderedIds[index] = int64(noteId)
+	}
+
+	sqlQueryUpdate := `
+		UPDATE collection_note SET position = ordered.position
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS ordered(note_id, position)
+		WHERE collection_note.collection_id = $1 AND collection_note.note_id = ordered.note_id`
+
+	if _, err := tx.Exec(sqlQueryUpdate, int64(collectionId), pq.Array(orderedIds)); err != nil {
+		return convertPostgresError(err)
+	}
+
+	return tx.Commit()
+}
+
+// GetCollectionNotes returns the notes in the collection in the owner's order.
+func (db *DB) GetCollectionNotes(collectionId CollectionId) ([]*CollectionNote, error) {
+	sqlQuery := `
+		SELECT note.id, note.author_id, note.content, note.creation_time, note.revision,
+			collection_note.annotation, collection_note.position, collection_note.added_time
+		FROM collection_note
+			INNER JOIN note
+					ON note.id = collection_note.note_id
+		WHERE collection_note.collection_id = $1
+		ORDER BY collection_note.position, collection_note.added_time`
+
+	rows, err := db.Query(sqlQuery, int64(collectionId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	notes := make([]*CollectionNote, 0)
+	for rows.Next() {
+		collectionNote := &CollectionNote{Note: &Note{}}
+		if err := rows.Scan(
+			&collectionNote.NoteId,
+			&collectionNote.Note.AuthorId,
+			&collectionNote.Note.Content,
+			&collectionNote.Note.CreationTime,
+			&collectionNote.Note.Revision,
+			&collectionNote.Annotation,
+			&collectionNote.Position,
+			&collectionNote.AddedTime,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		notes = append(notes, collectionNote)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return notes, nil
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestNormalizeCollectionName(t *testing.T) {
+	name, err := models.NormalizeCollectionName("  Favourite \n quotes ")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "Favourite quotes", name)
+
+	_, err = models.NormalizeCollectionName(" \t ")
+	test_util.Equals(t, models.CollectionNameRequiredError, err)
+
+	_, err = models.NormalizeCollectionName(strings.Repeat("a", 101))
+	test_util.Equals(t, models.CollectionNameTooLongError, err)
+}
+
+func TestWriteCollectionMarkdown(t *testing.T) {
+	notes := []*models.CollectionNote{
+		{
+			Note: &models.Note{
+				AuthorId:     models.UserId(1),
+				Content:      "The ending was *earned*.\n",
+				CreationTime: time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+			},
+			Annotation: "Agree.\nSee chapter 3.",
+		},
+		{
+			Note: &models.Note{
+				AuthorId:     models.UserId(2),
+				Content:      "Too long.",
+				CreationTime: time.Date(2018, 9, 2, 12, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	usersById := models.UsersById{models.UserId(1): &models.User{DisplayName: "alice"}}
+
+	var markdown bytes.Buffer
+	test_util.Ok(t, models.WriteCollectionMarkdown(&markdown, &models.Collection{Name: "Endings"}, notes, usersById))
+
+	test_util.Equals(
+		t,
+		"# Endings\n\n"+
+			"## alice, 2018-09-01\n\nThe ending was *earned*.\n\n> Agree.\n> See chapter 3.\n\n"+
+			"## Unknown, 2018-09-02\n\nToo long.\n",
+		markdown.String())
+}
@@ -15,15 +15,22 @@ var QueryResultContainedMultipleRowsError = errors.New("query result unexpectedl
 // QueryResultContainedNoRowsError is returned when a query unexpectedly returns no rows.
 var QueryResultContainedNoRowsError = errors.New("query result unexpectedly contained no rows")
 
+// ForeignKeyConstraintError is returned when a row is still referenced by another table.
+var ForeignKeyConstraintError = errors.New("postgres: foreign key constraint violation")
+
 var TooManyRowsAffectedError = errors.New("There were too many rows affected by the query")
 
 func convertPostgresError(err error) error {
 	const uniqueConstraintErrorCode = "23505"
+	const foreignKeyConstraintErrorCode = "23503"
 
 	if postgresErr, ok := err.(*pq.Error); ok {
 		if postgresErr.Code == uniqueConstraintErrorCode {
 			return UniqueConstraintError
 		}
+		if postgresErr.Code == foreignKeyConstraintErrorCode {
+			return ForeignKeyConstraintError
+		}
 	}
 
 	return err
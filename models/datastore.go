@@ -39,6 +39,13 @@ type Datastore interface {
 	AssignNoteCategoryRelationship(NoteId, NoteCategory) error
 	DeleteNoteCategory(NoteId) error
 	GetNoteCategory(NoteId) (NoteCategory, error)
+	GetNoteCategories([]NoteId) (map[NoteId]NoteCategory, error)
+	DeserializeNoteCategory(string) (NoteCategory, error)
+	GetCategories() ([]*Category, error)
+	GetCategory(NoteCategory) (*Category, error)
+	StoreNewCategory(*Category) error
+	UpdateCategory(NoteCategory, *Category) error
+	DeleteCategory(NoteCategory) error
 
 	// Note Actions
 	GetUsersNotes(UserId) (NotesById, error)
@@ -47,11 +54,116 @@ type Datastore interface {
 	StoreNewNote(*Note) (NoteId, error)
 	GetAllPublishedNotesVisibleBy(UserId) (map[int64]NotesById, error)
 	GetNoteById(NoteId) (*Note, error)
-	UpdateNoteContent(NoteId, string) error
+	UpdateNoteContent(NoteId, string, int) error
+	UpdateNoteStates(UserId, []NoteId, *bool, *bool, time.Time) error
+	ImportNotes(UserId, []*ImportedNote, bool) (*ImportReport, error)
+	GetNoteLinksBetween([]NoteId) ([]*NoteLink, error)
+
+	// Trash Actions
+	TrashNote(NoteId, time.Time) error
+	GetTrashedNotes(UserId) (NotesById, error)
+	RestoreNote(NoteId) error
+	PurgeNote(NoteId, time.Time) ([]*Attachment, error)
+	PurgeTrashedNotes(time.Time, time.Time) ([]*Attachment, error)
 
 	// Publication Actions
-	PublishNotes(UserId) error
+	PublishNotes(UserId) (PublicationId, error)
+	PublishIssue(UserId, *IssueDraft) (PublicationId, error)
+	PreviewIssue(UserId, *IssueDraft) (*PublishedIssue, error)
 	StoreNewPublication(*Publication) (PublicationId, error)
+	GetPublishedIssuesVisibleBy(UserId) ([]*PublishedIssue, error)
+	IsPublishedNoteVisibleTo(NoteId, UserId) (bool, error)
+	RetractPublication(UserId, PublicationId, string, time.Time) (RetractionOutcome, error)
+
+	// Answer Actions
+	StoreNewAnswer(NoteId, *Note) (NoteId, error)
+	GetAnswersVisibleBy(NoteId, UserId) ([]*Answer, error)
+	AcceptAnswer(NoteId, NoteId) error
+	ClearAcceptedAnswer(NoteId) error
+	GetAnsweredQuestionIds([]NoteId) (map[NoteId]bool, error)
+
+	// Source Actions
+	StoreSource(*Source) (SourceId, error)
+	GetSource(SourceId) (*Source, error)
+	AttachNoteSource(NoteId, SourceId, *SourceLocation) error
+	DetachNoteSource(NoteId) error
+	GetNoteSource(NoteId) (*NoteSource, error)
+	GetSourceNotesVisibleBy(SourceId, UserId) ([]*SourceNote, error)
+
+	// Reply Actions
+	StoreNewReply(*Reply) (ReplyId, error)
+	GetReply(ReplyId) (*Reply, error)
+	GetReplies(NoteId) ([]*Reply, error)
+	GetRepliesForNotes([]NoteId) (map[NoteId][]*Reply, error)
+	UpdateReplyContent(ReplyId, string) error
+	DeleteReply(ReplyId) error
+	GetReplyCounts([]NoteId) (map[NoteId]int, error)
+
+	// Reaction Actions
+	ToggleReaction(NoteId, UserId, string) (bool, error)
+	GetReactionSummaries([]NoteId, UserId) (map[NoteId][]*ReactionSummary, error)
+
+	// Attachment Actions
+	StoreNewAttachment(*Attachment) (AttachmentId, error)
+	GetAttachment(AttachmentId) (*Attachment, error)
+	GetNoteAttachments(NoteId) ([]*Attachment, error)
+	DeleteAttachment(AttachmentId) error
+
+	// Read Marker Actions
+	MarkNoteRead(UserId, NoteId, time.Time) error
+	MarkNoteUnread(UserId, NoteId) error
+	MarkPublicationRead(UserId, PublicationId, time.Time) error
+	MarkAllRead(UserId, time.Time) error
+	GetUnreadNoteIds(UserId) (map[NoteId]bool, error)
+	GetUnreadCounts(UserId) (*UnreadCounts, error)
+
+	// Collection Actions
+	StoreNewCollection(*Collection) (CollectionId, error)
+	GetCollections(UserId) ([]*Collection, error)
+	GetCollection(CollectionId) (*Collection, error)
+	RenameCollection(CollectionId, string) error
+	DeleteCollection(CollectionId) error
+	AddNoteToCollection(CollectionId, NoteId, string, time.Time) error
+	UpdateCollectionAnnotation(CollectionId, NoteId, string) error
+	RemoveNoteFromCollection(CollectionId, NoteId) error
+	ReorderCollection(CollectionId, []NoteId) error
+	GetCollectionNotes(CollectionId) ([]*CollectionNote, error)
+
+	// Note Batch Actions
+	ApplyNoteOperations(UserId, []*NoteOperation, time.Time) ([]*NoteOperationResult, error)
+	GetNoteTags([]NoteId) (map[NoteId][]string, error)
+
+	// Prediction Actions
+	StorePrediction(*Prediction) error
+	GetPrediction(NoteId) (*Prediction, error)
+	GetUsersResolvedPredictions(UserId, UserId) ([]*Prediction, error)
+	ResolvePrediction(NoteId, UserId, PredictionOutcome, time.Time) error
+	GetDuePredictionReminders(time.Time) ([]*PredictionReminder, error)
+	MarkPredictionReminderSent(NoteId, time.Time) error
+
+	// Feed Actions
+	CreateFeedToken(UserId) (string, error)
+	GetFeedToken(UserId) (string, error)
+	GetUserIdForFeedToken(string) (UserId, error)
+	DeleteFeedToken(UserId) error
+
+	// Notification Actions
+	GetNotificationPreferences(UserId) (*NotificationPreferences, error)
+	SetNotificationFrequency(UserId, NotificationFrequency) error
+	UnsubscribeFromNotifications(string) error
+	GetNotificationRecipients() ([]*NotificationRecipient, error)
+	MarkNotificationsSent(UserId, []PublicationId, time.Time) error
+
+	// Webhook Actions
+	StoreNewWebhookSubscription(*WebhookSubscription) (WebhookSubscriptionId, error)
+	GetUsersWebhookSubscriptions(UserId) ([]*WebhookSubscription, error)
+	DeleteWebhookSubscription(UserId, WebhookSubscriptionId) error
+	QueueWebhookDeliveries(*WebhookEvent) error
+	GetEventAudience(*WebhookEvent) ([]UserId, error)
+	QueueWebhookPing(UserId, WebhookSubscriptionId) (WebhookDeliveryId, error)
+	GetDueWebhookDeliveries(time.Time, int) ([]*WebhookDelivery, error)
+	GetWebhookDeliveries(UserId, WebhookSubscriptionId) ([]*WebhookDelivery, error)
+	UpdateWebhookDelivery(*WebhookDelivery) error
 }
 
 type DB struct {
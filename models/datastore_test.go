@@ -17,9 +17,47 @@ const noteTable = "note"
 const publicationTable = "publication"
 const noteToPublicationTable = "note_to_publication_relationship"
 const noteToCategoryTable = "note_to_category_relationship"
+const noteImportTable = "note_import"
+const feedTokenTable = "feed_token"
+const notificationPreferenceTable = "notification_preference"
+const publicationNotificationTable = "publication_notification"
+const webhookSubscriptionTable = "webhook_subscription"
+const webhookDeliveryTable = "webhook_delivery"
+const predictionTable = "prediction"
+const predictionJudgeTable = "prediction_judge"
+const questionAnswerTable = "question_answer"
+const sourceTable = "source"
+const noteToSourceTable = "note_to_source_relationship"
+const noteReplyTable = "note_reply"
+const noteReactionTable = "note_reaction"
+const noteLinkTable = "note_link"
+const noteAttachmentTable = "note_attachment"
+const noteReadTable = "note_read"
+const collectionNoteTable = "collection_note"
+const collectionTable = "collection"
+const noteTagTable = "note_tag"
 const userTable = "app_user"
 
 var tables = []string{
+	noteTagTable,
+	collectionNoteTable,
+	collectionTable,
+	noteReadTable,
+	noteAttachmentTable,
+	noteLinkTable,
+	noteReactionTable,
+	noteReplyTable,
+	noteToSourceTable,
+	sourceTable,
+	questionAnswerTable,
+	predictionJudgeTable,
+	predictionTable,
+	webhookDeliveryTable,
+	webhookSubscriptionTable,
+	publicationNotificationTable,
+	notificationPreferenceTable,
+	feedTokenTable,
+	noteImportTable,
 	noteToPublicationTable,
 	publicationTable,
 	noteToCategoryTable,
@@ -106,15 +144,23 @@ func TestNote(t *testing.T) {
 
 	test_util.Equals(t, note.AuthorId, retrievedNote.AuthorId)
 	test_util.Equals(t, note.Content, retrievedNote.Content)
+	test_util.Equals(t, 1, retrievedNote.Revision)
+	test_util.Assert(t, retrievedNote.LastEditTime == nil, "A new note shouldn't have an edit time")
 
 	updatedContent := "some new coolenss"
-	err = db.UpdateNoteContent(id, updatedContent)
+	err = db.UpdateNoteContent(id, updatedContent, 1)
 	test_util.Ok(t, err)
 
 	newNote, err := db.GetNoteById(id)
 	test_util.Ok(t, err)
 	test_util.Equals(t, updatedContent, newNote.Content)
 	test_util.Equals(t, note.AuthorId, newNote.AuthorId)
+	test_util.Equals(t, 2, newNote.Revision)
+	test_util.Assert(t, newNote.LastEditTime != nil, "An edited note should have an edit time")
+
+	// an edit based on the first revision would undo the one just made
+	test_util.Equals(t, models.NoteRevisionChangedError, db.UpdateNoteContent(id, "stale content", 1))
+	test_util.Equals(t, models.NoNoteFoundError, db.UpdateNoteContent(id+1000, "no such note", 1))
 
 	err = db.DeleteNoteById(id)
 	test_util.Ok(t, err)
@@ -149,7 +195,7 @@ func TestPublication(t *testing.T) {
 
 	test_util.Equals(t, 0, len(publicationToNotesById))
 
-	err = db.PublishNotes(userId)
+	_, err = db.PublishNotes(userId)
 	test_util.Ok(t, err)
 
 	publicationToNotesById, err = db.GetAllPublishedNotesVisibleBy(userId)
@@ -195,4 +241,1464 @@ func TestCategory(t *testing.T) {
 
 	err = db.DeleteNoteCategory(noteId)
 	test_util.Ok(t, err)
+
+	for _, category := range []models.NoteCategory{models.MARGINALIA, models.META, models.QUESTION, models.PREDICTION} {
+		deserializedCategory, err := db.DeserializeNoteCategory(category.String())
+		test_util.Ok(t, err)
+		test_util.Equals(t, category, deserializedCategory)
+	}
+
+	_, err = db.DeserializeNoteCategory("book-club")
+	test_util.Equals(t, models.CannotDeserializeNoteCategoryStringError, err)
+
+	bookClub := &models.Category{Name: "book-club", Colour: "#00ff00", Description: "For the book club", SortOrder: 10, CreationTime: time.Now(), CreatorId: userId}
+	err = db.StoreNewCategory(bookClub)
+	test_util.Ok(t, err)
+
+	err = db.StoreNewCategory(bookClub)
+	test_util.Equals(t, models.CategoryAlreadyExistsError, err)
+
+	deserializedCategory, err := db.DeserializeNoteCategory("book-club")
+	test_util.Ok(t, err)
+	test_util.Equals(t, bookClub.Name, deserializedCategory)
+
+	err = db.AssignNoteCategoryRelationship(noteId, bookClub.Name)
+	test_util.Ok(t, err)
+
+	err = db.UpdateCategory(bookClub.Name, &models.Category{Name: "reading-group", Colour: "#0000ff", SortOrder: 11})
+	test_util.Ok(t, err)
+
+	// renaming a category moves its notes along with it
+	renamedCategory, err := db.GetNoteCategory(noteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.NoteCategory("reading-group"), renamedCategory)
+
+	// but keeps who created it
+	readingGroup, err := db.GetCategory("reading-group")
+	test_util.Ok(t, err)
+	test_util.Equals(t, userId, readingGroup.CreatorId)
+
+	err = db.DeleteCategory("reading-group")
+	test_util.Equals(t, models.CategoryInUseError, err)
+
+	err = db.UpdateCategory(models.QUESTION, &models.Category{Name: "query", Colour: "#0000ff"})
+	test_util.Equals(t, models.ReservedCategoryError, err)
+
+	err = db.DeleteCategory(models.PREDICTION)
+	test_util.Equals(t, models.ReservedCategoryError, err)
+
+	categories, err := db.GetCategories()
+	test_util.Ok(t, err)
+	test_util.Equals(t, 5, len(categories))
+	test_util.Equals(t, models.MARGINALIA, categories[0].Name)
+	test_util.Equals(t, models.UserId(0), categories[0].CreatorId)
+	test_util.Equals(t, models.NoteCategory("reading-group"), categories[4].Name)
+
+	err = db.DeleteNoteCategory(noteId)
+	test_util.Ok(t, err)
+
+	err = db.DeleteCategory("reading-group")
+	test_util.Ok(t, err)
+
+	err = db.DeleteCategory("reading-group")
+	test_util.Equals(t, models.NoCategoryFoundError, err)
+}
+
+func TestImport(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearTable(db, userTable)
+	ClearTable(db, noteTable)
+	ClearTable(db, noteImportTable)
+
+	displayName := "bob"
+	password := "aPassword"
+	emailAddress := models.NewEmailAddress("importingEmail@gmail.com")
+
+	err = db.StoreNewUser(displayName, emailAddress, password)
+	test_util.Ok(t, err)
+
+	userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	notes := []*models.ImportedNote{
+		{Content: "an imported note", Category: "meta"},
+		{Content: "an imported note"},
+		{Content: "   "},
+		{Content: "another imported note", Category: "not a category"},
+	}
+
+	report, err := db.ImportNotes(userId, notes, true)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, report.Imported)
+	test_util.Equals(t, 1, report.Duplicates)
+	test_util.Equals(t, 2, report.Invalid)
+
+	unpublishedNotes, err := db.GetMyUnpublishedNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(unpublishedNotes))
+
+	report, err = db.ImportNotes(userId, notes, false)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, report.Imported)
+
+	noteId := report.Results[0].NoteId
+	category, err := db.GetNoteCategory(noteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.META, category)
+
+	// importing again must not duplicate anything
+	report, err = db.ImportNotes(userId, notes, false)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, report.Imported)
+	test_util.Equals(t, 2, report.Duplicates)
+
+	unpublishedNotes, err = db.GetMyUnpublishedNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(unpublishedNotes))
+}
+
+func TestFeedToken(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearTable(db, userTable)
+	ClearTable(db, feedTokenTable)
+
+	emailAddress := models.NewEmailAddress("feedReader@gmail.com")
+	err = db.StoreNewUser("bob", emailAddress, "aPassword")
+	test_util.Ok(t, err)
+
+	userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	_, err = db.GetFeedToken(userId)
+	test_util.Equals(t, models.NoFeedTokenFoundError, err)
+
+	token, err := db.CreateFeedToken(userId)
+	test_util.Ok(t, err)
+
+	tokenOwner, err := db.GetUserIdForFeedToken(token)
+	test_util.Ok(t, err)
+	test_util.Equals(t, userId, tokenOwner)
+
+	// regenerating revokes the old token
+	newToken, err := db.CreateFeedToken(userId)
+	test_util.Ok(t, err)
+	test_util.Assert(t, token != newToken, "Expected a new token")
+
+	_, err = db.GetUserIdForFeedToken(token)
+	test_util.Equals(t, models.NoFeedTokenFoundError, err)
+
+	err = db.DeleteFeedToken(userId)
+	test_util.Ok(t, err)
+
+	_, err = db.GetUserIdForFeedToken(newToken)
+	test_util.Equals(t, models.NoFeedTokenFoundError, err)
+}
+
+func TestPublishedIssuesVisibility(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	publishNote := func(userId models.UserId, content string) {
+		_, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		_, err = db.PublishNotes(userId)
+		test_util.Ok(t, err)
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	publishNote(alice, "alice's first issue")
+	publishNote(alice, "alice's second issue")
+
+	issues, err := db.GetPublishedIssuesVisibleBy(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(issues))
+
+	publishNote(bob, "bob's first issue")
+
+	issues, err = db.GetPublishedIssuesVisibleBy(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(issues))
+
+	issues, err = db.GetPublishedIssuesVisibleBy(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 3, len(issues))
+
+	// newest first
+	test_util.Equals(t, bob, issues[0].AuthorId)
+	test_util.Equals(t, int64(1), issues[0].IssueNumber)
+	test_util.Equals(t, int64(2), issues[1].IssueNumber)
+}
+
+func TestPublicationNotifications(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	publishNote := func(userId models.UserId) {
+		_, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: "a note", CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		_, err = db.PublishNotes(userId)
+		test_util.Ok(t, err)
+	}
+
+	notificationCounts := func() map[models.UserId]int {
+		recipients, err := db.GetNotificationRecipients()
+		test_util.Ok(t, err)
+
+		counts := make(map[models.UserId]int)
+		for _, recipient := range recipients {
+			counts[recipient.UserId] = len(recipient.Notifications)
+		}
+		return counts
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	// nobody else has published, so alice's first issue is readable by nobody new
+	publishNote(alice)
+	test_util.Equals(t, map[models.UserId]int{}, notificationCounts())
+
+	// bob's first issue unlocks alice's first issue for bob and is readable by alice
+	publishNote(bob)
+	test_util.Equals(t, map[models.UserId]int{alice: 1, bob: 1}, notificationCounts())
+
+	preferences, err := db.GetNotificationPreferences(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.DefaultNotificationFrequency, preferences.Frequency)
+
+	recipients, err := db.GetNotificationRecipients()
+	test_util.Ok(t, err)
+	for _, recipient := range recipients {
+		if recipient.UserId == alice {
+			err = db.MarkNotificationsSent(alice, []models.PublicationId{recipient.Notifications[0].PublicationId}, time.Now())
+			test_util.Ok(t, err)
+		}
+	}
+	test_util.Equals(t, map[models.UserId]int{bob: 1}, notificationCounts())
+
+	err = db.UnsubscribeFromNotifications(preferences.UnsubscribeToken)
+	test_util.Ok(t, err)
+
+	preferences, err = db.GetNotificationPreferences(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.NEVER, preferences.Frequency)
+
+	err = db.UnsubscribeFromNotifications("notAToken")
+	test_util.Equals(t, models.NoNotificationPreferencesFoundError, err)
+}
+
+func TestWebhooks(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	subscribe := func(userId models.UserId, eventTypes ...models.WebhookEventType) models.WebhookSubscriptionId {
+		subscriptionId, err := db.StoreNewWebhookSubscription(&models.WebhookSubscription{
+			UserId:       userId,
+			Url:          "https://example.com/hook",
+			EventTypes:   eventTypes,
+			CreationTime: time.Now(),
+		})
+		test_util.Ok(t, err)
+
+		return subscriptionId
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	aliceSubscription := subscribe(alice, models.NOTE_CREATED, models.PUBLICATION_CREATED)
+	bobSubscription := subscribe(bob, models.NOTE_CREATED, models.PUBLICATION_CREATED)
+
+	noteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "a note", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	// unpublished notes only concern their author
+	err = db.QueueWebhookDeliveries(&models.WebhookEvent{Type: models.NOTE_CREATED, AuthorId: alice, NoteId: noteId})
+	test_util.Ok(t, err)
+
+	// bob hasn't published, so he can't read alice's first issue yet
+	publicationId, err := db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	err = db.QueueWebhookDeliveries(&models.WebhookEvent{Type: models.PUBLICATION_CREATED, AuthorId: alice, PublicationId: publicationId})
+	test_util.Ok(t, err)
+
+	audience, err := db.GetEventAudience(&models.WebhookEvent{Type: models.PUBLICATION_CREATED, AuthorId: alice, PublicationId: publicationId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, []models.UserId{alice}, audience)
+
+	aliceDeliveries, err := db.GetWebhookDeliveries(alice, aliceSubscription)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(aliceDeliveries))
+
+	bobDeliveries, err := db.GetWebhookDeliveries(bob, bobSubscription)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(bobDeliveries))
+
+	// nobody can read another user's delivery log
+	bobDeliveries, err = db.GetWebhookDeliveries(bob, aliceSubscription)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(bobDeliveries))
+
+	deliveryId, err := db.QueueWebhookPing(alice, aliceSubscription)
+	test_util.Ok(t, err)
+
+	_, err = db.QueueWebhookPing(bob, aliceSubscription)
+	test_util.Equals(t, models.NoWebhookSubscriptionFoundError, err)
+
+	dueDeliveries, err := db.GetDueWebhookDeliveries(time.Now().Add(time.Minute), 10)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 3, len(dueDeliveries))
+
+	for _, delivery := range dueDeliveries {
+		if delivery.Id == deliveryId {
+			test_util.Equals(t, models.PING, delivery.EventType)
+
+			deliveredTime := time.Now().UTC()
+			delivery.Status = models.DELIVERY_DELIVERED
+			delivery.Attempts = 1
+			delivery.LastStatusCode = 200
+			delivery.DeliveredTime = &deliveredTime
+			test_util.Ok(t, db.UpdateWebhookDelivery(delivery))
+		}
+	}
+
+	dueDeliveries, err = db.GetDueWebhookDeliveries(time.Now().Add(time.Minute), 10)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(dueDeliveries))
+
+	err = db.DeleteWebhookSubscription(bob, aliceSubscription)
+	test_util.Equals(t, models.NoWebhookSubscriptionFoundError, err)
+
+	err = db.DeleteWebhookSubscription(alice, aliceSubscription)
+	test_util.Ok(t, err)
+}
+
+func TestPredictions(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	noteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "It will rain tomorrow", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	resolutionDate := time.Now().Add(time.Hour * 24).UTC().Truncate(time.Second)
+	prediction := &models.Prediction{
+		NoteId:         noteId,
+		AuthorId:       alice,
+		Probability:    0.7,
+		ResolutionDate: resolutionDate,
+		JudgeIds:       []models.UserId{bob},
+	}
+
+	test_util.Ok(t, db.StorePrediction(prediction))
+
+	category, err := db.GetNoteCategory(noteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.PREDICTION, category)
+
+	prediction.Probability = 1.5
+	test_util.Equals(t, models.InvalidProbabilityError, db.StorePrediction(prediction))
+
+	storedPrediction, err := db.GetPrediction(noteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0.7, storedPrediction.Probability)
+	test_util.Equals(t, []models.UserId{bob}, storedPrediction.JudgeIds)
+	test_util.Assert(t, !storedPrediction.IsResolved(), "Expected the prediction to be unresolved")
+
+	// reminders are due once the resolution date passes
+	reminders, err := db.GetDuePredictionReminders(time.Now())
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(reminders))
+
+	reminders, err = db.GetDuePredictionReminders(resolutionDate.Add(time.Minute))
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(reminders))
+	test_util.Equals(t, "It will rain tomorrow", reminders[0].Content)
+
+	test_util.Ok(t, db.MarkPredictionReminderSent(noteId, time.Now()))
+
+	reminders, err = db.GetDuePredictionReminders(resolutionDate.Add(time.Minute))
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(reminders))
+
+	// published predictions are locked
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	prediction.Probability = 0.9
+	test_util.Equals(t, models.PredictionLockedError, db.StorePrediction(prediction))
+
+	test_util.Ok(t, db.ResolvePrediction(noteId, bob, models.OUTCOME_TRUE, time.Now()))
+	test_util.Equals(t, models.PredictionAlreadyResolvedError, db.ResolvePrediction(noteId, alice, models.OUTCOME_FALSE, time.Now()))
+	test_util.Equals(t, models.NoPredictionFoundError, db.ResolvePrediction(noteId+1, alice, models.OUTCOME_FALSE, time.Now()))
+
+	resolvedPredictions, err := db.GetUsersResolvedPredictions(alice, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(resolvedPredictions))
+	test_util.Equals(t, models.OUTCOME_TRUE, resolvedPredictions[0].Outcome)
+	test_util.Equals(t, bob, resolvedPredictions[0].ResolverId)
+
+	resolvedPredictions, err = db.GetUsersResolvedPredictions(bob, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(resolvedPredictions))
+
+	// others only see predictions in issues they can read
+	resolvedPredictions, err = db.GetUsersResolvedPredictions(alice, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(resolvedPredictions))
+
+	_, err = db.StoreNewNote(&models.Note{AuthorId: bob, Content: "bob's first", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	resolvedPredictions, err = db.GetUsersResolvedPredictions(alice, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(resolvedPredictions))
+
+	// trashed notes don't count for anyone
+	test_util.Ok(t, db.TrashNote(noteId, time.Now().UTC()))
+
+	resolvedPredictions, err = db.GetUsersResolvedPredictions(alice, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(resolvedPredictions))
+
+	resolvedPredictions, err = db.GetUsersResolvedPredictions(alice, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(resolvedPredictions))
+}
+
+func TestAnswers(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	storeNote := func(userId models.UserId, content string) models.NoteId {
+		noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		return noteId
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	questionId := storeNote(alice, "What should we read next?")
+	test_util.Ok(t, db.AssignNoteCategoryRelationship(questionId, models.QUESTION))
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	// bob hasn't published, so alice's first issue isn't visible to him yet
+	visible, err := db.IsPublishedNoteVisibleTo(questionId, bob)
+	test_util.Ok(t, err)
+	test_util.Assert(t, !visible, "Expected the question to be hidden from bob")
+
+	storeNote(bob, "My first note")
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	visible, err = db.IsPublishedNoteVisibleTo(questionId, bob)
+	test_util.Ok(t, err)
+	test_util.Assert(t, visible, "Expected the question to be visible to bob")
+
+	answerId, err := db.StoreNewAnswer(questionId, &models.Note{AuthorId: bob, Content: "Dune", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	categories, err := db.GetNoteCategories([]models.NoteId{questionId, answerId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, map[models.NoteId]models.NoteCategory{questionId: models.QUESTION}, categories)
+
+	// unpublished answers are only visible to their author
+	answers, err := db.GetAnswersVisibleBy(questionId, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(answers))
+
+	answers, err = db.GetAnswersVisibleBy(questionId, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(answers))
+
+	// bob's second issue needs alice to have published twice too
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	answers, err = db.GetAnswersVisibleBy(questionId, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(answers))
+
+	storeNote(alice, "My second note")
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	answers, err = db.GetAnswersVisibleBy(questionId, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(answers))
+	test_util.Equals(t, "Dune", answers[0].Content)
+
+	answered, err := db.GetAnsweredQuestionIds([]models.NoteId{questionId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(answered))
+
+	test_util.Ok(t, db.AcceptAnswer(questionId, answerId))
+	test_util.Equals(t, models.NoAnswerFoundError, db.AcceptAnswer(questionId, questionId))
+
+	answered, err = db.GetAnsweredQuestionIds([]models.NoteId{questionId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, map[models.NoteId]bool{questionId: true}, answered)
+
+	test_util.Ok(t, db.ClearAcceptedAnswer(questionId))
+	test_util.Equals(t, models.NoAnswerFoundError, db.ClearAcceptedAnswer(questionId))
+}
+
+func TestSources(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	// both readers annotating the same book share its source
+	aliceSourceId, err := db.StoreSource(&models.Source{Title: "Dune", Isbn: "0441172717"})
+	test_util.Ok(t, err)
+
+	bobSourceId, err := db.StoreSource(&models.Source{Title: "Dune", Author: "Frank Herbert", Isbn: "978-0-441-17271-9"})
+	test_util.Ok(t, err)
+	test_util.Equals(t, aliceSourceId, bobSourceId)
+
+	source, err := db.GetSource(aliceSourceId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, "Frank Herbert", source.Author)
+
+	_, err = db.GetSource(aliceSourceId + 1)
+	test_util.Equals(t, models.NoSourceFoundError, err)
+
+	aliceNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "The spice must flow", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	test_util.Ok(t, db.AttachNoteSource(aliceNoteId, aliceSourceId, &models.SourceLocation{Type: models.PAGE, Value: "12"}))
+
+	bobNoteId, err := db.StoreNewNote(&models.Note{AuthorId: bob, Content: "Fear is the mind-killer", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	test_util.Ok(t, db.AttachNoteSource(bobNoteId, bobSourceId, &models.SourceLocation{}))
+
+	noteSource, err := db.GetNoteSource(aliceNoteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, aliceSourceId, noteSource.Source.Id)
+	test_util.Equals(t, &models.SourceLocation{Type: models.PAGE, Value: "12"}, noteSource.Location)
+
+	// unpublished notes about the source are only visible to their author
+	notes, err := db.GetSourceNotesVisibleBy(aliceSourceId, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(notes))
+
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	notes, err = db.GetSourceNotesVisibleBy(aliceSourceId, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(notes))
+
+	test_util.Ok(t, db.DetachNoteSource(bobNoteId))
+	test_util.Equals(t, models.NoSourceFoundError, db.DetachNoteSource(bobNoteId))
+
+	notes, err = db.GetSourceNotesVisibleBy(aliceSourceId, alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(notes))
+}
+
+func TestReplies(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	noteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "Published thoughts", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	otherNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "More thoughts", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	replyId, err := db.StoreNewReply(&models.Reply{NoteId: noteId, AuthorId: bob, Content: "I disagree", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	nestedReplyId, err := db.StoreNewReply(&models.Reply{NoteId: noteId, ParentId: &replyId, AuthorId: alice, Content: "Why?", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	_, err = db.StoreNewReply(&models.Reply{NoteId: otherNoteId, ParentId: &replyId, AuthorId: alice, Content: "Wrong thread", CreationTime: time.Now()})
+	test_util.Equals(t, models.ReplyParentMismatchError, err)
+
+	nestedReply, err := db.GetReply(nestedReplyId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, replyId, *nestedReply.ParentId)
+	test_util.Assert(t, nestedReply.LastEditTime == nil, "new replies should not have been edited")
+
+	test_util.Ok(t, db.UpdateReplyContent(nestedReplyId, "Why do you disagree?"))
+
+	nestedReply, err = db.GetReply(nestedReplyId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, "Why do you disagree?", nestedReply.Content)
+	test_util.Assert(t, nestedReply.LastEditTime != nil, "edited replies should have an edit time")
+
+	counts, err := db.GetReplyCounts([]models.NoteId{noteId, otherNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, map[models.NoteId]int{noteId: 2}, counts)
+
+	// deleted replies stay in the thread without their content
+	test_util.Ok(t, db.DeleteReply(replyId))
+	test_util.Equals(t, models.NoReplyFoundError, db.DeleteReply(replyId))
+	test_util.Equals(t, models.NoReplyFoundError, db.UpdateReplyContent(replyId, "Never mind"))
+
+	_, err = db.StoreNewReply(&models.Reply{NoteId: noteId, ParentId: &replyId, AuthorId: alice, Content: "Hello?", CreationTime: time.Now()})
+	test_util.Equals(t, models.ReplyDeletedError, err)
+
+	replies, err := db.GetReplies(noteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(replies))
+	test_util.Assert(t, replies[0].Deleted, "reply should have been deleted")
+	test_util.Equals(t, "", replies[0].Content)
+
+	counts, err = db.GetReplyCounts([]models.NoteId{noteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, counts[noteId])
+
+	repliesByNote, err := db.GetRepliesForNotes([]models.NoteId{noteId, otherNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(repliesByNote))
+	test_util.Equals(t, replies, repliesByNote[noteId])
+}
+
+func TestReactions(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(emailAddressAsString string) models.UserId {
+		emailAddress := models.NewEmailAddress(emailAddressAsString)
+		test_util.Ok(t, db.StoreNewUser("someone", emailAddress, "aPassword"))
+
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+
+		return userId
+	}
+
+	alice := storeUser("alice@gmail.com")
+	bob := storeUser("bob@gmail.com")
+
+	noteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "Something worth reacting to", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	otherNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "Something less so", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	reacted, err := db.ToggleReaction(noteId, bob, "👍")
+	test_util.Ok(t, err)
+	test_util.Assert(t, reacted, "bob should be reacting")
+
+	reacted, err = db.ToggleReaction(noteId, alice, "👍")
+	test_util.Ok(t, err)
+	test_util.Assert(t, reacted, "alice should be reacting")
+
+	_, err = db.ToggleReaction(noteId, bob, "💡")
+	test_util.Ok(t, err)
+
+	summaries, err := db.GetReactionSummaries([]models.NoteId{noteId, otherNoteId}, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, map[models.NoteId][]*models.ReactionSummary{
+		noteId: {
+			{Reaction: "👍", Count: 2, ReactedByMe: true},
+			{Reaction: "💡", Count: 1, ReactedByMe: true},
+		},
+	}, summaries)
+
+	reacted, err = db.ToggleReaction(noteId, bob, "👍")
+	test_util.Ok(t, err)
+	test_util.Assert(t, !reacted, "bob should have taken the reaction back")
+
+	summaries, err = db.GetReactionSummaries([]models.NoteId{noteId}, bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, &models.ReactionSummary{Reaction: "👍", Count: 1, ReactedByMe: false}, summaries[noteId][0])
+}
+
+func TestNoteLinks(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	emailAddress := models.NewEmailAddress("linker@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("linker", emailAddress, "aPassword"))
+	userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	storeNote := func(content string) models.NoteId {
+		noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		return noteId
+	}
+
+	firstNoteId := storeNote("the first note")
+	secondNoteId := storeNote("builds on [[note:" + strconv.FormatInt(int64(firstNoteId), 10) + "]] and a note that doesn't exist [[note:0]]")
+
+	links, err := db.GetNoteLinksBetween([]models.NoteId{firstNoteId, secondNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, []*models.NoteLink{{SourceNoteId: secondNoteId, TargetNoteId: firstNoteId}}, links)
+
+	// links to notes outside the given set are left out
+	links, err = db.GetNoteLinksBetween([]models.NoteId{secondNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(links))
+
+	test_util.Ok(t, db.UpdateNoteContent(firstNoteId, "now links back to [[note:"+strconv.FormatInt(int64(secondNoteId), 10)+"]]", 1))
+	test_util.Ok(t, db.UpdateNoteContent(secondNoteId, "no longer links anywhere", 1))
+
+	links, err = db.GetNoteLinksBetween([]models.NoteId{firstNoteId, secondNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, []*models.NoteLink{{SourceNoteId: firstNoteId, TargetNoteId: secondNoteId}}, links)
+}
+
+func TestAttachments(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	emailAddress := models.NewEmailAddress("attacher@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("attacher", emailAddress, "aPassword"))
+	userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: "see attached", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	image := &models.Attachment{
+		NoteId:       noteId,
+		UploaderId:   userId,
+		FileName:     "picture.png",
+		ContentType:  "image/png",
+		Size:         1024,
+		CreationTime: time.Now().UTC().Truncate(time.Millisecond),
+		BlobKey:      "attachments/picture",
+		ThumbnailKey: "attachments/picture-thumbnail",
+	}
+	image.Id, err = db.StoreNewAttachment(image)
+	test_util.Ok(t, err)
+	image.HasThumbnail = true
+
+	text := &models.Attachment{
+		NoteId:       noteId,
+		UploaderId:   userId,
+		FileName:     "notes.txt",
+		ContentType:  "text/plain; charset=utf-8",
+		Size:         12,
+		CreationTime: image.CreationTime.Add(time.Second),
+		BlobKey:      "attachments/notes",
+	}
+	text.Id, err = db.StoreNewAttachment(text)
+	test_util.Ok(t, err)
+
+	storedImage, err := db.GetAttachment(image.Id)
+	test_util.Ok(t, err)
+	test_util.Equals(t, image.ThumbnailKey, storedImage.ThumbnailKey)
+	test_util.Assert(t, storedImage.HasThumbnail, "the thumbnail should be remembered")
+
+	attachments, err := db.GetNoteAttachments(noteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(attachments))
+	test_util.Equals(t, image.Id, attachments[0].Id)
+	test_util.Equals(t, "", attachments[1].ThumbnailKey)
+	test_util.Assert(t, !attachments[1].HasThumbnail, "text has no thumbnail")
+
+	// blob keys are unique
+	_, err = db.StoreNewAttachment(image)
+	test_util.Equals(t, models.UniqueConstraintError, err)
+
+	test_util.Ok(t, db.DeleteAttachment(text.Id))
+	test_util.Equals(t, models.NoAttachmentFoundError, db.DeleteAttachment(text.Id))
+
+	// deleting the note deletes its attachments
+	test_util.Ok(t, db.DeleteNoteById(noteId))
+	_, err = db.GetAttachment(image.Id)
+	test_util.Equals(t, models.NoAttachmentFoundError, err)
+}
+
+func TestTrash(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	emailAddress := models.NewEmailAddress("trasher@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("trasher", emailAddress, "aPassword"))
+	userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	storeNote := func(content string) models.NoteId {
+		noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		return noteId
+	}
+
+	publishedNoteId := storeNote("published, then regretted")
+	_, err = db.PublishNotes(userId)
+	test_util.Ok(t, err)
+	unpublishedNoteId := storeNote("never published")
+
+	deletionTime := time.Now().UTC().Add(-time.Hour * 24 * 40)
+	test_util.Ok(t, db.TrashNote(publishedNoteId, deletionTime))
+	test_util.Ok(t, db.TrashNote(unpublishedNoteId, deletionTime))
+	test_util.Equals(t, models.NoNoteFoundError, db.TrashNote(unpublishedNoteId, deletionTime))
+
+	// trashed notes are gone from the author's notes, but published ones remain as tombstones
+	notes, err := db.GetUsersNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(notes))
+
+	notes, err = db.GetMyUnpublishedNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(notes))
+
+	_, err = db.GetNoteById(unpublishedNoteId)
+	test_util.Equals(t, models.NoNoteFoundError, err)
+
+	publishedNotes, err := db.GetAllPublishedNotesVisibleBy(userId)
+	test_util.Ok(t, err)
+	tombstone := publishedNotes[1][publishedNoteId]
+	test_util.Equals(t, "", tombstone.Content)
+	test_util.Assert(t, tombstone.DeletionTime != nil, "the tombstone should say when the note was deleted")
+
+	visible, err := db.IsPublishedNoteVisibleTo(publishedNoteId, userId)
+	test_util.Ok(t, err)
+	test_util.Assert(t, !visible, "tombstones can't be interacted with")
+
+	// trashed notes can be restored
+	trashedNotes, err := db.GetTrashedNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(trashedNotes))
+
+	test_util.Ok(t, db.RestoreNote(unpublishedNoteId))
+	note, err := db.GetNoteById(unpublishedNoteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, "never published", note.Content)
+	test_util.Equals(t, models.NoNoteFoundError, db.RestoreNote(unpublishedNoteId))
+
+	// expired notes are purged, leaving only a tombstone of published ones
+	test_util.Ok(t, db.TrashNote(unpublishedNoteId, deletionTime))
+	_, err = db.PurgeTrashedNotes(time.Now().Add(-time.Hour*24*30), time.Now().UTC())
+	test_util.Ok(t, err)
+
+	trashedNotes, err = db.GetTrashedNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(trashedNotes))
+	test_util.Equals(t, models.NoNoteFoundError, db.RestoreNote(publishedNoteId))
+
+	publishedNotes, err = db.GetAllPublishedNotesVisibleBy(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(publishedNotes[1]))
+
+	// recently trashed notes are only purged when asked for
+	recentNoteId := storeNote("recently trashed")
+	test_util.Ok(t, db.TrashNote(recentNoteId, time.Now().UTC()))
+
+	_, err = db.PurgeTrashedNotes(time.Now().Add(-time.Hour*24*30), time.Now().UTC())
+	test_util.Ok(t, err)
+	trashedNotes, err = db.GetTrashedNotes(userId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(trashedNotes))
+
+	_, err = db.PurgeNote(recentNoteId, time.Now().UTC())
+	test_util.Ok(t, err)
+	_, err = db.PurgeNote(recentNoteId, time.Now().UTC())
+	test_util.Equals(t, models.NoNoteFoundError, err)
+}
+
+func TestRetractPublication(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(displayName string) models.UserId {
+		emailAddress := models.NewEmailAddress(displayName + "@gmail.com")
+		test_util.Ok(t, db.StoreNewUser(displayName, emailAddress, "aPassword"))
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+		return userId
+	}
+
+	publishNote := func(userId models.UserId, content string) (models.NoteId, models.PublicationId) {
+		noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		publicationId, err := db.PublishNotes(userId)
+		test_util.Ok(t, err)
+		return noteId, publicationId
+	}
+
+	alice := storeUser("alice")
+	bob := storeUser("bob")
+
+	aliceFirstNoteId, aliceFirstIssue := publishNote(alice, "alice's first")
+	aliceSecondNoteId, aliceSecondIssue := publishNote(alice, "alice's second")
+	publishNote(bob, "bob's first")
+	bobSecondNoteId, bobSecondIssue := publishNote(bob, "bob's second")
+
+	now := time.Now().UTC()
+
+	// an issue with a later issue after it needs a reason, even within the grace period
+	_, err = db.RetractPublication(alice, aliceFirstIssue, "", now)
+	test_util.Equals(t, models.RetractionReasonRequiredError, err)
+
+	outcome, err := db.RetractPublication(alice, aliceFirstIssue, "posted by mistake", now)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.RETRACTED, outcome)
+
+	_, err = db.RetractPublication(alice, aliceFirstIssue, "again", now)
+	test_util.Equals(t, models.PublicationAlreadyRetractedError, err)
+
+	// the later issue keeps its number and readers see why the first is gone
+	publishedNotes, err := db.GetAllPublishedNotesVisibleBy(bob)
+	test_util.Ok(t, err)
+	_, ok := publishedNotes[1][aliceFirstNoteId]
+	test_util.Assert(t, !ok, "notes of retracted issues should be withheld")
+	_, ok = publishedNotes[2][aliceSecondNoteId]
+	test_util.Assert(t, ok, "later issues should keep their numbers")
+
+	visible, err := db.IsPublishedNoteVisibleTo(aliceFirstNoteId, bob)
+	test_util.Ok(t, err)
+	test_util.Assert(t, !visible, "notes of retracted issues should not be visible")
+
+	// so changes to them only go out to their author
+	audience, err := db.GetEventAudience(&models.WebhookEvent{Type: models.NOTE_UPDATED, AuthorId: alice, NoteId: aliceFirstNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, []models.UserId{alice}, audience)
+
+	audience, err = db.GetEventAudience(&models.WebhookEvent{Type: models.NOTE_UPDATED, AuthorId: alice, NoteId: aliceSecondNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(audience))
+
+	issues, err := db.GetPublishedIssuesVisibleBy(bob)
+	test_util.Ok(t, err)
+	for _, issue := range issues {
+		if issue.PublicationId == aliceFirstIssue {
+			test_util.Equals(t, "posted by mistake", issue.RetractionReason)
+			test_util.Equals(t, int64(1), issue.IssueNumber)
+			test_util.Equals(t, 0, len(issue.Notes))
+		}
+	}
+
+	// a retracted issue still counts towards its author's publication count
+	visible, err = db.IsPublishedNoteVisibleTo(bobSecondNoteId, alice)
+	test_util.Ok(t, err)
+	test_util.Assert(t, visible, "alice has still published two issues")
+
+	// the latest issue is unpublished within the grace period, which also lowers its author's count
+	visible, err = db.IsPublishedNoteVisibleTo(aliceSecondNoteId, bob)
+	test_util.Ok(t, err)
+	test_util.Assert(t, visible, "bob's second issue unlocks alice's second")
+	test_util.Ok(t, db.MarkNoteRead(bob, aliceSecondNoteId, now))
+
+	outcome, err = db.RetractPublication(bob, bobSecondIssue, "", now)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.UNPUBLISHED, outcome)
+
+	unpublishedNotes, err := db.GetMyUnpublishedNotes(bob)
+	test_util.Ok(t, err)
+	_, ok = unpublishedNotes[bobSecondNoteId]
+	test_util.Assert(t, ok, "notes of an unpublished issue should be unpublished again")
+
+	// so the issues it unlocked are locked again, even ones bob has read
+	visible, err = db.IsPublishedNoteVisibleTo(aliceSecondNoteId, bob)
+	test_util.Ok(t, err)
+	test_util.Assert(t, !visible, "bob has only published one issue now")
+
+	publishedNotes, err = db.GetAllPublishedNotesVisibleBy(bob)
+	test_util.Ok(t, err)
+	_, ok = publishedNotes[2][aliceSecondNoteId]
+	test_util.Assert(t, !ok, "alice's second issue should be locked for bob again")
+
+	// until bob publishes again
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	visible, err = db.IsPublishedNoteVisibleTo(aliceSecondNoteId, bob)
+	test_util.Ok(t, err)
+	test_util.Assert(t, visible, "bob has published two issues again")
+
+	// after the grace period even the latest issue needs a reason
+	_, err = db.RetractPublication(alice, aliceSecondIssue, "", now.Add(models.PublicationGracePeriod*2))
+	test_util.Equals(t, models.RetractionReasonRequiredError, err)
+
+	// only the author can retract an issue
+	_, err = db.RetractPublication(bob, aliceSecondIssue, "not mine", now)
+	test_util.Equals(t, models.NoPublicationFoundError, err)
+}
+
+func TestIssueLayout(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	emailAddress := models.NewEmailAddress("alice@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("alice", emailAddress, "aPassword"))
+	alice, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	_, err = db.PreviewIssue(alice, &models.IssueDraft{})
+	test_util.Equals(t, models.NoNotesToPublishError, err)
+
+	start := time.Now().UTC()
+	firstNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "first", CreationTime: start})
+	test_util.Ok(t, err)
+	secondNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "second", CreationTime: start.Add(time.Minute)})
+	test_util.Ok(t, err)
+	thirdNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "third", CreationTime: start.Add(2 * time.Minute)})
+	test_util.Ok(t, err)
+
+	draft := &models.IssueDraft{
+		Title:     "Spring",
+		Intro:     "Welcome back",
+		NoteOrder: []models.NoteId{thirdNoteId, firstNoteId},
+	}
+
+	preview, err := db.PreviewIssue(alice, draft)
+	test_util.Ok(t, err)
+	test_util.Equals(t, int64(1), preview.IssueNumber)
+	test_util.Equals(t, []models.NoteId{thirdNoteId, firstNoteId, secondNoteId}, preview.NoteOrder)
+
+	// previewing publishes nothing
+	unpublishedNotes, err := db.GetMyUnpublishedNotes(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 3, len(unpublishedNotes))
+
+	_, err = db.PublishIssue(alice, &models.IssueDraft{NoteOrder: []models.NoteId{firstNoteId, firstNoteId}})
+	test_util.Equals(t, models.InvalidNoteOrderError, err)
+
+	publicationId, err := db.PublishIssue(alice, draft)
+	test_util.Ok(t, err)
+
+	issues, err := db.GetPublishedIssuesVisibleBy(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(issues))
+	test_util.Equals(t, publicationId, issues[0].PublicationId)
+	test_util.Equals(t, "Spring", issues[0].Title)
+	test_util.Equals(t, "Welcome back", issues[0].Intro)
+	test_util.Equals(t, []models.NoteId{thirdNoteId, firstNoteId, secondNoteId}, issues[0].NoteOrder)
+
+	publishedNotes, err := db.GetAllPublishedNotesVisibleBy(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, publishedNotes[1][thirdNoteId].Position)
+	test_util.Equals(t, 3, publishedNotes[1][secondNoteId].Position)
+	test_util.Equals(t, int64(1), publishedNotes[1][secondNoteId].IssueNumber)
+}
+
+func TestPublishIssueConcurrently(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	emailAddress := models.NewEmailAddress("alice@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("alice", emailAddress, "aPassword"))
+	alice, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	_, err = db.StoreNewNote(&models.Note{AuthorId: alice, Content: "only once", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	// both publishes lay out the same note, but only one of them gets to publish it
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := db.PublishIssue(alice, &models.IssueDraft{})
+			errs <- err
+		}()
+	}
+
+	published := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			published++
+		} else {
+			test_util.Equals(t, models.NoNotesToPublishError, err)
+		}
+	}
+	test_util.Equals(t, 1, published)
+
+	issues, err := db.GetPublishedIssuesVisibleBy(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(issues))
+	test_util.Equals(t, 1, len(issues[0].NoteOrder))
+}
+
+func TestReadMarkers(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(displayName string) models.UserId {
+		emailAddress := models.NewEmailAddress(displayName + "@gmail.com")
+		test_util.Ok(t, db.StoreNewUser(displayName, emailAddress, "aPassword"))
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+		return userId
+	}
+
+	storeNote := func(userId models.UserId, content string) models.NoteId {
+		noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		return noteId
+	}
+
+	alice := storeUser("alice")
+	bob := storeUser("bob")
+
+	aliceQuestionId := storeNote(alice, "alice's question")
+	test_util.Ok(t, db.AssignNoteCategoryRelationship(aliceQuestionId, models.QUESTION))
+	aliceNoteId := storeNote(alice, "alice's note")
+	aliceIssue, err := db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	storeNote(bob, "bob's note")
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	// bob's own notes are never unread
+	counts, err := db.GetUnreadCounts(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, counts.Total)
+	test_util.Equals(t, 2, counts.ByAuthor[alice])
+	test_util.Equals(t, 1, counts.ByCategory[models.QUESTION])
+
+	test_util.Ok(t, db.MarkNoteRead(bob, aliceQuestionId, time.Now().UTC()))
+	test_util.Ok(t, db.MarkNoteRead(bob, aliceQuestionId, time.Now().UTC()))
+
+	unreadNoteIds, err := db.GetUnreadNoteIds(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, map[models.NoteId]bool{aliceNoteId: true}, unreadNoteIds)
+
+	test_util.Ok(t, db.MarkNoteUnread(bob, aliceQuestionId))
+	test_util.Ok(t, db.MarkPublicationRead(bob, aliceIssue, time.Now().UTC()))
+
+	counts, err = db.GetUnreadCounts(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, counts.Total)
+
+	// issues the reader hasn't unlocked can't be marked
+	storeNote(alice, "alice's second note")
+	aliceSecondIssue, err := db.PublishNotes(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.NoPublicationFoundError, db.MarkPublicationRead(bob, aliceSecondIssue, time.Now().UTC()))
+
+	storeNote(bob, "bob's second note")
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	unreadNoteIds, err = db.GetUnreadNoteIds(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(unreadNoteIds))
+
+	test_util.Ok(t, db.MarkAllRead(bob, time.Now().UTC()))
+
+	unreadNoteIds, err = db.GetUnreadNoteIds(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 0, len(unreadNoteIds))
+}
+
+func TestCollections(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	storeUser := func(displayName string) models.UserId {
+		emailAddress := models.NewEmailAddress(displayName + "@gmail.com")
+		test_util.Ok(t, db.StoreNewUser(displayName, emailAddress, "aPassword"))
+		userId, err := db.GetIdForUserWithEmailAddress(emailAddress)
+		test_util.Ok(t, err)
+		return userId
+	}
+
+	storeNote := func(userId models.UserId, content string) models.NoteId {
+		noteId, err := db.StoreNewNote(&models.Note{AuthorId: userId, Content: content, CreationTime: time.Now()})
+		test_util.Ok(t, err)
+		return noteId
+	}
+
+	alice := storeUser("alice")
+	bob := storeUser("bob")
+
+	firstNoteId := storeNote(alice, "first")
+	secondNoteId := storeNote(alice, "second")
+	thirdNoteId := storeNote(alice, "third")
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	collectionId, err := db.StoreNewCollection(&models.Collection{OwnerId: bob, Name: "Keepers", CreationTime: time.Now().UTC()})
+	test_util.Ok(t, err)
+
+	_, err = db.StoreNewCollection(&models.Collection{OwnerId: bob, Name: "Keepers", CreationTime: time.Now().UTC()})
+	test_util.Equals(t, models.CollectionAlreadyExistsError, err)
+
+	for _, noteId := range []models.NoteId{firstNoteId, secondNoteId, thirdNoteId} {
+		test_util.Ok(t, db.AddNoteToCollection(collectionId, noteId, "", time.Now().UTC()))
+	}
+	test_util.Equals(t, models.NoteAlreadyInCollectionError, db.AddNoteToCollection(collectionId, firstNoteId, "", time.Now().UTC()))
+
+	test_util.Ok(t, db.UpdateCollectionAnnotation(collectionId, secondNoteId, "best of the lot"))
+	test_util.Equals(t, models.InvalidCollectionOrderError, db.ReorderCollection(collectionId, []models.NoteId{thirdNoteId, thirdNoteId, firstNoteId}))
+	test_util.Ok(t, db.ReorderCollection(collectionId, []models.NoteId{thirdNoteId, secondNoteId, firstNoteId}))
+
+	collectionNotes, err := db.GetCollectionNotes(collectionId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 3, len(collectionNotes))
+	test_util.Equals(t, thirdNoteId, collectionNotes[0].NoteId)
+	test_util.Equals(t, "best of the lot", collectionNotes[1].Annotation)
+	test_util.Equals(t, "first", collectionNotes[2].Note.Content)
+
+	// trashed notes leave every collection
+	test_util.Ok(t, db.TrashNote(thirdNoteId, time.Now().UTC()))
+
+	collections, err := db.GetCollections(bob)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(collections))
+	test_util.Equals(t, 2, collections[0].NoteCount)
+
+	// as do the notes of retracted issues
+	laterNoteId := storeNote(alice, "later")
+	laterIssue, err := db.PublishNotes(alice)
+	test_util.Ok(t, err)
+	storeNote(bob, "bob's first")
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+	storeNote(bob, "bob's second")
+	_, err = db.PublishNotes(bob)
+	test_util.Ok(t, err)
+
+	test_util.Ok(t, db.AddNoteToCollection(collectionId, laterNoteId, "", time.Now().UTC()))
+	_, err = db.RetractPublication(alice, laterIssue, "", time.Now().UTC())
+	test_util.Ok(t, err)
+
+	collectionNotes, err = db.GetCollectionNotes(collectionId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(collectionNotes))
+
+	test_util.Ok(t, db.RemoveNoteFromCollection(collectionId, firstNoteId))
+	test_util.Equals(t, models.NoteNotInCollectionError, db.RemoveNoteFromCollection(collectionId, firstNoteId))
+
+	test_util.Ok(t, db.DeleteCollection(collectionId))
+	_, err = db.GetCollection(collectionId)
+	test_util.Equals(t, models.NoCollectionFoundError, err)
+}
+
+func TestNoteStates(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	emailAddress := models.NewEmailAddress("alice@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("alice", emailAddress, "aPassword"))
+	alice, err := db.GetIdForUserWithEmailAddress(emailAddress)
+	test_util.Ok(t, err)
+
+	publishedNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "published", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	draftNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "draft", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	oldNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "old", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	yes, no := true, false
+	now := time.Now().UTC()
+
+	test_util.Equals(t, models.PinnedAndArchivedError, db.UpdateNoteStates(alice, []models.NoteId{draftNoteId}, &yes, &yes, now))
+
+	// published notes can't be pinned, and then nothing is
+	test_util.Equals(t, models.NoNoteFoundError, db.UpdateNoteStates(alice, []models.NoteId{draftNoteId, publishedNoteId}, &yes, nil, now))
+
+	test_util.Ok(t, db.UpdateNoteStates(alice, []models.NoteId{draftNoteId, oldNoteId, oldNoteId}, &yes, nil, now))
+	test_util.Ok(t, db.UpdateNoteStates(alice, []models.NoteId{oldNoteId}, nil, &yes, now))
+
+	notes, err := db.GetMyUnpublishedNotes(alice)
+	test_util.Ok(t, err)
+	test_util.Assert(t, notes[draftNoteId].PinnedTime != nil, "Expected the draft to be pinned")
+	test_util.Assert(t, notes[oldNoteId].PinnedTime == nil, "Expected archiving to unpin the note")
+	test_util.Assert(t, notes[oldNoteId].ArchivedTime != nil, "Expected the old note to be archived")
+
+	// archived notes aren't published
+	publicationId, err := db.PublishNotes(alice)
+	test_util.Ok(t, err)
+
+	notes, err = db.GetMyUnpublishedNotes(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(notes))
+	_, ok := notes[oldNoteId]
+	test_util.Assert(t, ok, "Expected the archived note to stay unpublished")
+	test_util.Assert(t, publicationId != 0, "Expected the draft to be published")
+
+	test_util.Ok(t, db.UpdateNoteStates(alice, []models.NoteId{oldNoteId}, nil, &no, now))
+	_, err = db.PublishNotes(alice)
+	test_util.Ok(t, err)
+}
+
+func TestNoteBatch(t *testing.T) {
+	db, err := models.ConnectToDatabase(postgresUrl, 10)
+	test_util.Ok(t, err)
+	ClearDatabase(db)
+
+	aliceEmail := models.NewEmailAddress("alice@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("alice", aliceEmail, "aPassword"))
+	alice, err := db.GetIdForUserWithEmailAddress(aliceEmail)
+	test_util.Ok(t, err)
+
+	bobEmail := models.NewEmailAddress("bob@gmail.com")
+	test_util.Ok(t, db.StoreNewUser("bob", bobEmail, "aPassword"))
+	bob, err := db.GetIdForUserWithEmailAddress(bobEmail)
+	test_util.Ok(t, err)
+
+	firstNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "first", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	secondNoteId, err := db.StoreNewNote(&models.Note{AuthorId: alice, Content: "second", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+	bobsNoteId, err := db.StoreNewNote(&models.Note{AuthorId: bob, Content: "bob's", CreationTime: time.Now()})
+	test_util.Ok(t, err)
+
+	now := time.Now().UTC()
+
+	// one of bob's notes spoils the whole batch
+	results, err := db.ApplyNoteOperations(alice, []*models.NoteOperation{
+		{Op: models.UPDATE_CONTENT, NoteId: firstNoteId, Content: "changed"},
+		{Op: models.DELETE_NOTE, NoteId: bobsNoteId},
+	}, now)
+	test_util.Equals(t, models.NoteBatchFailedError, err)
+	test_util.Equals(t, "", results[0].Error)
+	test_util.Equals(t, models.NoNoteFoundError.Error(), results[1].Error)
+
+	note, err := db.GetNoteById(firstNoteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, "first", note.Content)
+
+	// as does an unknown category, or an operation on a note deleted earlier in the batch
+	results, err = db.ApplyNoteOperations(alice, []*models.NoteOperation{
+		{Op: models.SET_CATEGORY, NoteId: firstNoteId, Category: "unknown"},
+		{Op: models.DELETE_NOTE, NoteId: secondNoteId},
+		{Op: models.ADD_TAGS, NoteId: secondNoteId, Tags: []string{"gone"}},
+	}, now)
+	test_util.Equals(t, models.NoteBatchFailedError, err)
+	test_util.Equals(t, models.CannotDeserializeNoteCategoryStringError.Error(), results[0].Error)
+	test_util.Equals(t, "", results[1].Error)
+	test_util.Equals(t, models.NoNoteFoundError.Error(), results[2].Error)
+
+	notes, err := db.GetUsersNotes(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(notes))
+
+	results, err = db.ApplyNoteOperations(alice, []*models.NoteOperation{
+		{Op: models.UPDATE_CONTENT, NoteId: firstNoteId, Content: " changed "},
+		{Op: models.SET_CATEGORY, NoteId: firstNoteId, Category: models.META.String()},
+		{Op: models.ADD_TAGS, NoteId: firstNoteId, Tags: []string{"Book Club", "later", "book-club"}},
+		{Op: models.REMOVE_TAGS, NoteId: firstNoteId, Tags: []string{"later"}},
+		{Op: models.DELETE_NOTE, NoteId: secondNoteId},
+	}, now)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 5, len(results))
+	test_util.Equals(t, "changed", results[0].Note.Content)
+	test_util.Equals(t, 2, results[0].Note.Revision)
+
+	category, err := db.GetNoteCategory(firstNoteId)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.META, category)
+
+	tags, err := db.GetNoteTags([]models.NoteId{firstNoteId, secondNoteId})
+	test_util.Ok(t, err)
+	test_util.Equals(t, map[models.NoteId][]string{firstNoteId: {"book-club"}}, tags)
+
+	notes, err = db.GetUsersNotes(alice)
+	test_util.Ok(t, err)
+	test_util.Equals(t, 1, len(notes))
+
+	_, err = db.ApplyNoteOperations(alice, []*models.NoteOperation{
+		{Op: models.CLEAR_CATEGORY, NoteId: firstNoteId},
+	}, now)
+	test_util.Ok(t, err)
+
+	_, err = db.GetNoteCategory(firstNoteId)
+	test_util.Equals(t, models.QueryResultContainedNoRowsError, err)
 }
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const randomTokenSizeInBytes = 32
+
+var NoFeedTokenFoundError = errors.New("No feed token with that information could be found")
+
+// generateRandomToken returns a hex encoded secret suitable for use in urls.
+func generateRandomToken() (string, error) {
+	randomBytes := make([]byte, randomTokenSizeInBytes)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+//  DB methods
+
+// CreateFeedToken stores a new private feed token for the user, revoking any previous one.
+func (db *DB) CreateFeedToken(userId UserId) (string, error) {
+	token, err := generateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	sqlQuery := `
+		INSERT INTO feed_token (user_id, token, creation_time)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO
+		UPDATE SET token = ($2), creation_time = ($3)`
+
+	if _, err := db.execNoResults(sqlQuery, int64(userId), token, time.Now().UTC()); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (db *DB) GetFeedToken(userId UserId) (string, error) {
+	sqlQuery := `
+		SELECT token FROM feed_token
+		WHERE user_id = $1`
+
+	var token string
+	if err := db.execOneResult(sqlQuery, &token, int64(userId)); err != nil {
+		if err == QueryResultContainedNoRowsError {
+			return "", NoFeedTokenFoundError
+		}
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (db *DB) GetUserIdForFeedToken(token string) (UserId, error) {
+	sqlQuery := `
+		SELECT user_id FROM feed_token
+		WHERE token = $1`
+
+	var userId int64
+	if err := db.execOneResult(sqlQuery, &userId, token); err != nil {
+		if err == QueryResultContainedNoRowsError {
+			return 0, NoFeedTokenFoundError
+		}
+		return 0, err
+	}
+
+	return UserId(userId), nil
+}
+
+func (db *DB) DeleteFeedToken(userId UserId) error {
+	sqlQuery := `
+		DELETE FROM feed_token
+		WHERE user_id = $1`
+
+	num, err := db.execNoResults(sqlQuery, int64(userId))
+	if err != nil {
+		return err
+	}
+
+	if num == 0 {
+		return NoFeedTokenFoundError
+	}
+
+	return nil
+}
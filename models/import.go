@@ -0,0 +1,351 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ImportedNote is a note read from an import file that has not been stored yet.
+type ImportedNote struct {
+	Content      string    `json:"content"`
+	Category     string    `json:"category"`
+	CreationTime time.Time `json:"creationTime"`
+}
+
+type ImportStatus string
+
+const (
+	IMPORT_SUCCEEDED ImportStatus = "imported"
+	IMPORT_DUPLICATE ImportStatus = "duplicate"
+	IMPORT_INVALID   ImportStatus = "invalid"
+)
+
+// ImportResult describes what happened (or, on a dry run, what would happen) to a single imported note.
+type ImportResult struct {
+	Index       int          `json:"index"`
+	Status      ImportStatus `json:"status"`
+	ContentHash string       `json:"contentHash"`
+	NoteId      NoteId       `json:"noteId,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun     bool            `json:"dryRun"`
+	Imported   int             `json:"imported"`
+	Duplicates int             `json:"duplicates"`
+	Invalid    int             `json:"invalid"`
+	Results    []*ImportResult `json:"results"`
+}
+
+var UnsupportedImportFormatError = errors.New("The import file is not markdown, json or json lines")
+var MalformedFrontMatterError = errors.New("The markdown front matter could not be read")
+
+var markdownFrontMatterDelimiter = "---"
+
+var frontMatterTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ContentHash identifies a note's content for the purposes of import deduplication.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseImportFile reads notes from a markdown (.md), json (.json) or json lines (.jsonl) file.
+// The file extension decides the format.
+func ParseImportFile(fileName string, reader io.Reader) ([]*ImportedNote, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".md", ".markdown":
+		note, err := ParseMarkdownNote(reader)
+		if err != nil {
+			return nil, err
+		}
+		return []*ImportedNote{note}, nil
+
+	case ".json":
+		return ParseJsonNotes(reader)
+
+	case ".jsonl", ".ndjson":
+		return ParseJsonLinesNotes(reader)
+
+	default:
+		return nil, UnsupportedImportFormatError
+	}
+}
+
+// ParseMarkdownNote reads a single note from markdown with optional front matter, e.g.
+//
+//	---
+//	category: question
+//	created: 2018-09-01
+//	---
+//	What did the author mean by the ending?
+func ParseMarkdownNote(reader io.Reader) (*ImportedNote, error) {
+	bytesRead, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.Replace(string(bytesRead), "\r\n", "\n", -1)
+	note := &ImportedNote{}
+
+	if !strings.HasPrefix(text, markdownFrontMatterDelimiter+"\n") {
+		note.Content = strings.TrimSpace(text)
+		return note, nil
+	}
+
+	lines := strings.Split(text, "\n")
+
+	closingLine := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == markdownFrontMatterDelimiter {
+			closingLine = i
+			break
+		}
+	}
+
+	if closingLine == -1 {
+		return nil, MalformedFrontMatterError
+	}
+
+	for _, line := range lines[1:closingLine] {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		keyAndValue := strings.SplitN(line, ":", 2)
+		if len(keyAndValue) != 2 {
+			return nil, MalformedFrontMatterError
+		}
+
+		key := strings.ToLower(strings.TrimSpace(keyAndValue[0]))
+		value := strings.Trim(strings.TrimSpace(keyAndValue[1]), `"'`)
+
+		switch key {
+		case "category":
+			note.Category = value
+		case "created", "date", "creationtime":
+			creationTime, err := parseFrontMatterTime(value)
+			if err != nil {
+				return nil, err
+			}
+			note.CreationTime = creationTime
+		}
+	}
+
+	note.Content = strings.TrimSpace(strings.Join(lines[closingLine+1:], "\n"))
+
+	return note, nil
+}
+
+// ParseJsonNotes reads either a json array of notes or the id to note object returned by GET /api/note.
+func ParseJsonNotes(reader io.Reader) ([]*ImportedNote, error) {
+	bytesRead, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(bytesRead)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		notesById := make(map[string]*ImportedNote)
+		if err := json.Unmarshal(trimmed, &notesById); err != nil {
+			return nil, err
+		}
+
+		notes := make([]*ImportedNote, 0, len(notesById))
+		for _, note := range notesById {
+			notes = append(notes, note)
+		}
+
+		// keep the report stable between runs of the same file
+		sort.Slice(notes, func(i, j int) bool {
+			return notes[i].CreationTime.Before(notes[j].CreationTime)
+		})
+
+		return notes, nil
+	}
+
+	notes := make([]*ImportedNote, 0)
+	if err := json.Unmarshal(trimmed, &notes); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// ParseJsonLinesNotes reads one json note per line, skipping blank lines.
+func ParseJsonLinesNotes(reader io.Reader) ([]*ImportedNote, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	notes := make([]*ImportedNote, 0)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		note := &ImportedNote{}
+		if err := json.Unmarshal(line, note); err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, note)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func parseFrontMatterTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range frontMatterTimeLayouts {
+		var parsedTime time.Time
+		if parsedTime, err = time.Parse(layout, value); err == nil {
+			return parsedTime.UTC(), nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+//  DB methods
+
+// ImportNotes stores every note that is neither invalid nor already present for the user.
+// A note is already present when a previous import had the same content hash or
+// when the user has a note with identical content. On a dry run nothing is stored.
+func (db *DB) ImportNotes(userId UserId, notes []*ImportedNote, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Results: make([]*ImportResult, 0, len(notes))}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer tx.Rollback()
+
+	sqlQueryExists := `
+		SELECT EXISTS (
+			SELECT 1 FROM note_import
+			WHERE author_id = $1 AND content_hash = $2
+		) OR EXISTS (
+			SELECT 1 FROM note
+			WHERE author_id = $1 AND content = $3
+		)`
+
+	sqlQueryStoreNote := `
+		INSERT INTO note (author_id, content, creation_time)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	sqlQueryStoreImport := `
+		INSERT INTO note_import (note_id, author_id, content_hash)
+		VALUES ($1, $2, $3)`
+
+	sqlQueryStoreCategory := `
+		INSERT INTO note_to_category_relationship (note_id, category)
+		VALUES ($1, $2)`
+
+	seenHashes := make(map[string]bool)
+
+	for index, importedNote := range notes {
+		content := strings.TrimSpace(importedNote.Content)
+		result := &ImportResult{Index: index, ContentHash: ContentHash(content)}
+		report.Results = append(report.Results, result)
+
+		if len(content) == 0 {
+			result.Status = IMPORT_INVALID
+			result.Error = "note content cannot be empty"
+			report.Invalid++
+			continue
+		}
+
+		var category NoteCategory
+		hasCategory := len(strings.TrimSpace(importedNote.Category)) > 0
+		if hasCategory {
+			category, err = db.DeserializeNoteCategory(strings.ToLower(strings.TrimSpace(importedNote.Category)))
+			if err != nil {
+				result.Status = IMPORT_INVALID
+				result.Error = err.Error()
+				report.Invalid++
+				continue
+			}
+		}
+
+		if seenHashes[result.ContentHash] {
+			result.Status = IMPORT_DUPLICATE
+			report.Duplicates++
+			continue
+		}
+		seenHashes[result.ContentHash] = true
+
+		var alreadyExists bool
+		if err := tx.QueryRow(sqlQueryExists, int64(userId), result.ContentHash, content).Scan(&alreadyExists); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		if alreadyExists {
+			result.Status = IMPORT_DUPLICATE
+			report.Duplicates++
+			continue
+		}
+
+		result.Status = IMPORT_SUCCEEDED
+		report.Imported++
+
+		if dryRun {
+			continue
+		}
+
+		creationTime := importedNote.CreationTime
+		if creationTime.IsZero() {
+			creationTime = time.Now()
+		}
+
+		var noteId int64
+		if err := tx.QueryRow(sqlQueryStoreNote, int64(userId), content, creationTime.UTC()).Scan(&noteId); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		result.NoteId = NoteId(noteId)
+
+		if _, err := tx.Exec(sqlQueryStoreImport, noteId, int64(userId), result.ContentHash); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		if err := storeNoteLinks(tx, NoteId(noteId), content); err != nil {
+			return nil, err
+		}
+
+		if hasCategory {
+			if _, err := tx.Exec(sqlQueryStoreCategory, noteId, category.String()); err != nil {
+				return nil, convertPostgresError(err)
+			}
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,64 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestParseMarkdownNote(t *testing.T) {
+	markdown := "---\ncategory: question\ncreated: 2018-09-01\n---\n\nWhat did the author mean by the ending?\n"
+
+	note, err := models.ParseMarkdownNote(strings.NewReader(markdown))
+	test_util.Ok(t, err)
+
+	test_util.Equals(t, "question", note.Category)
+	test_util.Equals(t, time.Date(2018, 9, 1, 0, 0, 0, 0, time.UTC), note.CreationTime)
+	test_util.Equals(t, "What did the author mean by the ending?", note.Content)
+}
+
+func TestParseMarkdownNoteWithoutFrontMatter(t *testing.T) {
+	note, err := models.ParseMarkdownNote(strings.NewReader("just a note\n"))
+	test_util.Ok(t, err)
+
+	test_util.Equals(t, "just a note", note.Content)
+	test_util.Equals(t, "", note.Category)
+	test_util.Assert(t, note.CreationTime.IsZero(), "Expected no creation time")
+}
+
+func TestParseMarkdownNoteWithUnclosedFrontMatter(t *testing.T) {
+	_, err := models.ParseMarkdownNote(strings.NewReader("---\ncategory: meta\nno closing line"))
+	test_util.Equals(t, models.MalformedFrontMatterError, err)
+}
+
+func TestParseImportFile(t *testing.T) {
+	jsonLines := `{"content": "first", "category": "meta"}
+
+{"content": "second", "creationTime": "2018-09-01T10:00:00Z"}
+`
+	notes, err := models.ParseImportFile("notes.jsonl", strings.NewReader(jsonLines))
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(notes))
+	test_util.Equals(t, "meta", notes[0].Category)
+	test_util.Equals(t, "second", notes[1].Content)
+
+	exportedNotes := `{
+		"2": {"authorId": 1, "content": "later", "creationTime": "2018-09-02T10:00:00Z"},
+		"1": {"authorId": 1, "content": "earlier", "creationTime": "2018-09-01T10:00:00Z"}
+	}`
+	notes, err = models.ParseImportFile("notes.json", strings.NewReader(exportedNotes))
+	test_util.Ok(t, err)
+	test_util.Equals(t, 2, len(notes))
+	test_util.Equals(t, "earlier", notes[0].Content)
+
+	_, err = models.ParseImportFile("notes.txt", strings.NewReader("anything"))
+	test_util.Equals(t, models.UnsupportedImportFormatError, err)
+}
+
+func TestContentHashIgnoresSurroundingWhitespace(t *testing.T) {
+	test_util.Equals(t, models.ContentHash("a note"), models.ContentHash("  a note\n"))
+	test_util.Assert(t, models.ContentHash("a note") != models.ContentHash("another note"), "Expected different hashes")
+}
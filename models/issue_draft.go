@@ -0,0 +1,291 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const maxIssueTitleLength = 200
+const maxIssueIntroLength = 10000
+
+var IssueTitleTooLongError = fmt.Errorf("Issue titles cannot be longer than %d characters", maxIssueTitleLength)
+var IssueIntroTooLongError = fmt.Errorf("Issue intros cannot be longer than %d characters", maxIssueIntroLength)
+var InvalidNoteOrderError = errors.New("The note order can only list each of your unpublished notes once")
+
+// IssueDraft is how the author wants their next issue laid out: its title, an introduction and the order of
+// its notes. Notes left out of NoteOrder follow the listed ones, oldest first. When GroupByCategory is set,
+// notes are grouped by category first, in the categories' own order, with uncategorised notes last.
+type IssueDraft struct {
+	Title           string   `json:"title"`
+	Intro           string   `json:"intro"`
+	NoteOrder       []NoteId `json:"noteOrder"`
+	GroupByCategory bool     `json:"groupByCategory"`
+}
+
+func (draft *IssueDraft) Validate() error {
+	if utf8.RuneCountInString(draft.Title) > maxIssueTitleLength {
+		return IssueTitleTooLongError
+	}
+
+	if utf8.RuneCountInString(draft.Intro) > maxIssueIntroLength {
+		return IssueIntroTooLongError
+	}
+
+	return nil
+}
+
+// OrderIssueNotes returns the ids of the notes in the order the draft lays them out. The categories give
+// the order of the groups when notes are grouped by category.
+func OrderIssueNotes(
+	draft *IssueDraft,
+	notes NotesById,
+	noteCategories map[NoteId]NoteCategory,
+	categories []*Category,
+) ([]NoteId, error) {
+	explicitPositions := make(map[NoteId]int, len(draft.NoteOrder))
+	for position, noteId := range draft.NoteOrder {
+		if _, ok := notes[noteId]; !ok {
+			return nil, InvalidNoteOrderError
+		}
+		if _, ok := explicitPositions[noteId]; ok {
+			return nil, InvalidNoteOrderError
+		}
+		explicitPositions[noteId] = position
+	}
+
+	groupPositions := make(map[NoteCategory]int, len(categories))
+	for position, category := range categories {
+		groupPositions[category.Name] = position
+	}
+
+	groupOf := func(noteId NoteId) int {
+		if !draft.GroupByCategory {
+			return 0
+		}
+		if category, ok := noteCategories[noteId]; ok {
+			if position, ok := groupPositions[category]; ok {
+				return position
+			}
+		}
+		return len(categories)
+	}
+
+	explicitPositionOf := func(noteId NoteId) int {
+		if position, ok := explicitPositions[noteId]; ok {
+			return position
+		}
+		return len(draft.NoteOrder)
+	}
+
+	noteIds := make([]NoteId, 0, len(notes))
+	for noteId := range notes {
+		noteIds = append(noteIds, noteId)
+	}
+
+	sort.Slice(noteIds, func(i, j int) bool {
+		first, second := noteIds[i], noteIds[j]
+
+		if groupOf(first) != groupOf(second) {
+			return groupOf(first) < groupOf(second)
+		}
+
+		if explicitPositionOf(first) != explicitPositionOf(second) {
+			return explicitPositionOf(first) < explicitPositionOf(second)
+		}
+
+		if !notes[first].CreationTime.Equal(notes[second].CreationTime) {
+			return notes[first].CreationTime.Before(notes[second].CreationTime)
+		}
+
+		return first < second
+	})
+
+	return noteIds, nil
+}
+
+//  DB methods
+
+// PreviewIssue returns the issue the author would publish with the draft, without publishing it.
+func (db *DB) PreviewIssue(userId UserId, draft *IssueDraft) (*PublishedIssue, error) {
+	notes, noteOrder, err := db.layOutIssue(userId, draft)
+	if err != nil {
+		return nil, err
+	}
+
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for position, noteId := range noteOrder {
+		notes[noteId].IssueNumber = publicationCount + 1
+		notes[noteId].Position = position + 1
+	}
+
+	return &PublishedIssue{
+		AuthorId:     userId,
+		IssueNumber:  publicationCount + 1,
+		CreationTime: time.Now().UTC(),
+		Title:        draft.Title,
+		Intro:        draft.Intro,
+		Notes:        notes,
+		NoteOrder:    noteOrder,
+	}, nil
+}
+
+// PublishIssue publishes all of the author's unpublished notes, other than archived ones, as a new issue laid
+// out as the draft says. The issue, its notes and its notifications are stored together or not at all, so a
+// failure can't leave an empty issue behind to unlock other authors' issues.
+func (db *DB) PublishIssue(userId UserId, draft *IssueDraft) (PublicationId, error) {
+	_, noteOrder, err := db.layOutIssue(userId, draft)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// publishing twice at once would number both issues the same and publish the same notes in both
+	sqlQueryLockAuthor := `
+		SELECT id FROM app_user
+		WHERE id = $1
+		FOR UPDATE`
+
+	var lockedUserId int64
+	if err := tx.QueryRow(sqlQueryLockAuthor, int64(userId)).Scan(&lockedUserId); err != nil {
+		return 0, convertPostgresError(err)
+	}
+
+	// notes published, trashed or archived since they were laid out are left out, and ones written since
+	// wait for the next issue
+	sqlQueryNotes := `
+		SELECT note.id FROM note
+		LEFT OUTER JOIN note_to_publication_relationship AS note2pub
+			ON note.id = note2pub.note_id
+		WHERE note2pub.note_id IS NULL
+			AND note.author_id = $1
+			AND note.deleted_time IS NULL
+			AND note.archived_time IS NULL
+		FOR UPDATE OF note`
+
+	rows, err := tx.Query(sqlQueryNotes, int64(userId))
+	if err != nil {
+		return 0, convertPostgresError(err)
+	}
+
+	unpublished := make(map[NoteId]bool)
+	for rows.Next() {
+		var noteId int64
+		if err := rows.Scan(&noteId); err != nil {
+			rows.Close()
+			return 0, convertPostgresError(err)
+		}
+		unpublished[NoteId(noteId)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, convertPostgresError(err)
+	}
+
+	notesToPublish := make([]NoteId, 0, len(noteOrder))
+	for _, noteId := range noteOrder {
+		if unpublished[noteId] {
+			notesToPublish = append(notesToPublish, noteId)
+		}
+	}
+
+	if len(notesToPublish) == 0 {
+		return 0, NoNotesToPublishError
+	}
+
+	publicationId, err := storeNewPublication(tx, &Publication{
+		AuthorId:     userId,
+		CreationTime: time.Now().UTC(),
+		Title:        draft.Title,
+		Intro:        draft.Intro,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sqlQuery := `
+		INSERT INTO note_to_publication_relationship (publication_id, note_id, position)
+		VALUES `
+
+	values := make([]interface{}, 0, len(notesToPublish)*3)
+	for index, noteId := range notesToPublish {
+		if index > 0 {
+			sqlQuery += ", "
+		}
+		sqlQuery += "($" + strconv.Itoa(3*index+1) + ", $" + strconv.Itoa(3*index+2) + ", $" + strconv.Itoa(3*index+3) + ")"
+		values = append(values, int64(publicationId), int64(noteId), index+1)
+	}
+
+	if _, err := tx.Exec(sqlQuery, values...); err != nil {
+		return 0, convertPostgresError(err)
+	}
+
+	if err := storePublicationNotifications(tx, userId, publicationId); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return publicationId, nil
+}
+
+// layOutIssue returns the author's unpublished notes and the order the draft puts them in.
+func (db *DB) layOutIssue(userId UserId, draft *IssueDraft) (NotesById, []NoteId, error) {
+	if err := draft.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	notes, err := db.GetMyUnpublishedNotes(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// archived notes stay behind until they're unarchived
+	for noteId, note := range notes {
+		if note.ArchivedTime != nil {
+			delete(notes, noteId)
+		}
+	}
+
+	if len(notes) == 0 {
+		return nil, nil, NoNotesToPublishError
+	}
+
+	noteCategories := make(map[NoteId]NoteCategory)
+	categories := make([]*Category, 0)
+	if draft.GroupByCategory {
+		noteIds := make([]NoteId, 0, len(notes))
+		for noteId := range notes {
+			noteIds = append(noteIds, noteId)
+		}
+
+		if noteCategories, err = db.GetNoteCategories(noteIds); err != nil {
+			return nil, nil, err
+		}
+
+		if categories, err = db.GetCategories(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	noteOrder, err := OrderIssueNotes(draft, notes, noteCategories, categories)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return notes, noteOrder, nil
+}
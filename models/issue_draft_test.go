@@ -0,0 +1,58 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestOrderIssueNotes(t *testing.T) {
+	start := time.Date(2018, 9, 1, 0, 0, 0, 0, time.UTC)
+	notes := models.NotesById{
+		1: &models.Note{CreationTime: start},
+		2: &models.Note{CreationTime: start.Add(time.Hour)},
+		3: &models.Note{CreationTime: start.Add(2 * time.Hour)},
+		4: &models.Note{CreationTime: start.Add(3 * time.Hour)},
+	}
+
+	noteOrder, err := models.OrderIssueNotes(&models.IssueDraft{}, notes, nil, nil)
+	test_util.Ok(t, err)
+	test_util.Equals(t, []models.NoteId{1, 2, 3, 4}, noteOrder)
+
+	// listed notes come first, the rest follow oldest first
+	noteOrder, err = models.OrderIssueNotes(&models.IssueDraft{NoteOrder: []models.NoteId{3, 1}}, notes, nil, nil)
+	test_util.Ok(t, err)
+	test_util.Equals(t, []models.NoteId{3, 1, 2, 4}, noteOrder)
+
+	// groups follow the categories' order, with uncategorised notes last
+	noteCategories := map[models.NoteId]models.NoteCategory{
+		1: models.QUESTION,
+		2: models.MARGINALIA,
+		4: models.QUESTION,
+	}
+	categories := []*models.Category{{Name: models.MARGINALIA}, {Name: models.QUESTION}}
+
+	noteOrder, err = models.OrderIssueNotes(
+		&models.IssueDraft{NoteOrder: []models.NoteId{4}, GroupByCategory: true},
+		notes,
+		noteCategories,
+		categories)
+	test_util.Ok(t, err)
+	test_util.Equals(t, []models.NoteId{2, 4, 1, 3}, noteOrder)
+
+	_, err = models.OrderIssueNotes(&models.IssueDraft{NoteOrder: []models.NoteId{5}}, notes, nil, nil)
+	test_util.Equals(t, models.InvalidNoteOrderError, err)
+
+	_, err = models.OrderIssueNotes(&models.IssueDraft{NoteOrder: []models.NoteId{2, 2}}, notes, nil, nil)
+	test_util.Equals(t, models.InvalidNoteOrderError, err)
+}
+
+func TestValidateIssueDraft(t *testing.T) {
+	test_util.Ok(t, (&models.IssueDraft{Title: strings.Repeat("é", 200)}).Validate())
+
+	test_util.Equals(t, models.IssueTitleTooLongError, (&models.IssueDraft{Title: strings.Repeat("a", 201)}).Validate())
+	test_util.Equals(t, models.IssueIntroTooLongError, (&models.IssueDraft{Intro: strings.Repeat("a", 10001)}).Validate())
+}
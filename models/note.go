@@ -1,25 +1,49 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type NoteId int64
 
 type Note struct {
-	AuthorId     UserId    `json:"authorId"`
-	Content      string    `json:"content"`
-	CreationTime time.Time `json:"creationTime"`
+	AuthorId     UserId             `json:"authorId"`
+	Content      string             `json:"content"`
+	CreationTime time.Time          `json:"creationTime"`
+	ReplyCount   int                `json:"replyCount"`
+	Reactions    []*ReactionSummary `json:"reactions,omitempty"`
+	Backlinks    []NoteId           `json:"backlinks"`
+	Revision     int                `json:"revision"`
+	ContentHtml  string             `json:"contentHtml"`
+	// DeletionTime is set on published notes that were deleted, which readers see as tombstones
+	DeletionTime *time.Time `json:"deletionTime,omitempty"`
+	// IssueNumber and Position place published notes in their issue, both counting from 1
+	IssueNumber int64 `json:"issueNumber,omitempty"`
+	Position    int   `json:"position,omitempty"`
+	// Unread is set on published notes of others the current user hasn't read yet
+	Unread bool `json:"unread,omitempty"`
+	// PinnedTime and ArchivedTime are set on unpublished notes their author pinned to the top or archived
+	PinnedTime   *time.Time `json:"pinnedTime,omitempty"`
+	ArchivedTime *time.Time `json:"archivedTime,omitempty"`
+	// Tags are the author's own labels for the note, in alphabetical order
+	Tags []string `json:"tags,omitempty"`
+	// LastEditTime is set on notes whose content was changed after they were created
+	LastEditTime *time.Time `json:"lastEditTime,omitempty"`
 }
 
 var NoNoteFoundError = errors.New("No note with that information could be found")
+var PinnedAndArchivedError = errors.New("A note can't be both pinned and archived")
+var NoteRevisionChangedError = errors.New("The note was changed since that revision")
 
 //  DB methods
 func (db *DB) GetUsersNotes(userId UserId) (NotesById, error) {
 	sqlQuery := `
-		SELECT id, author_id, content, creation_time FROM note
-		WHERE author_id = $1`
+		SELECT id, author_id, content, creation_time, revision, last_edit_time, pinned_time, archived_time FROM note
+		WHERE author_id = $1 AND deleted_time IS NULL`
 
 	noteMap, err := db.getNotesById(sqlQuery, int64(userId))
 	if err != nil {
@@ -31,12 +55,8 @@ func (db *DB) GetUsersNotes(userId UserId) (NotesById, error) {
 
 func (db *DB) GetAllPublishedNotesVisibleBy(userId UserId) (map[int64]NotesById, error) {
 
-	sqlQueryIssueNumber := `
-		SELECT COUNT(*) AS IssueNumber FROM publication
-		WHERE publication.author_id = $1`
-
-	var publictionIssueNumber int64
-	if err := db.execOneResult(sqlQueryIssueNumber, &publictionIssueNumber, int64(userId)); err != nil {
+	publictionIssueNumber, err := db.getPublicationCount(userId)
+	if err != nil {
 		return nil, err
 	}
 
@@ -44,20 +64,19 @@ func (db *DB) GetAllPublishedNotesVisibleBy(userId UserId) (map[int64]NotesById,
 		SELECT
 		note.id,
 		note.author_id,
-		note.content,
+		CASE WHEN note.deleted_time IS NULL THEN note.content ELSE '' END,
 		note.creation_time,
-		filtered_pubs.rank AS publication_issue
-		FROM   (SELECT *,
-					   Rank()
-						 OVER(
-						   partition BY pub.author_id
-						   ORDER BY pub.creation_time)
-				FROM   publication AS pub) filtered_pubs
+		note.revision,
+		note.last_edit_time,
+		note.deleted_time,
+		ranked_pubs.rank AS publication_issue,
+		note2pub.position
+		FROM   (` + rankedPublicationsSql + `) ranked_pubs
 			   INNER JOIN note_to_publication_relationship AS note2pub
-					   ON note2pub.publication_id = filtered_pubs.id
+					   ON note2pub.publication_id = ranked_pubs.id
 			   INNER JOIN note
 					   ON note.id = note2pub.note_id
-		WHERE  rank <= ($1)`
+		WHERE  ranked_pubs.rank <= ($1) AND ranked_pubs.retracted_time IS NULL`
 
 	// sqlQueryGetNotes := `
 	// 	SELECT
@@ -93,10 +112,13 @@ func (db *DB) GetAllPublishedNotesVisibleBy(userId UserId) (map[int64]NotesById,
 	for rows.Next() {
 		var publicationNumber int64
 		var noteId int64
+		var position sql.NullInt64
 		note := &Note{}
-		if err := rows.Scan(&noteId, &note.AuthorId, &note.Content, &note.CreationTime, &publicationNumber); err != nil {
+		if err := rows.Scan(&noteId, &note.AuthorId, &note.Content, &note.CreationTime, &note.Revision, &note.LastEditTime, &note.DeletionTime, &publicationNumber, &position); err != nil {
 			return nil, err
 		}
+		note.IssueNumber = publicationNumber
+		note.Position = int(position.Int64)
 
 		noteMap, ok := pubToNotesById[publicationNumber]
 		if !ok {
@@ -110,12 +132,13 @@ func (db *DB) GetAllPublishedNotesVisibleBy(userId UserId) (map[int64]NotesById,
 	return pubToNotesById, nil
 }
 
+// GetMyUnpublishedNotes returns the author's unpublished notes, archived ones included.
 func (db *DB) GetMyUnpublishedNotes(userId UserId) (NotesById, error) {
 	sqlQuery := `
-		SELECT id, author_id, content, creation_time FROM note
+		SELECT id, author_id, content, creation_time, revision, last_edit_time, pinned_time, archived_time FROM note
 		LEFT OUTER JOIN note_to_publication_relationship AS note2pub
 			ON note.id = note2pub.note_id
-		WHERE note2pub.note_id is NULL AND note.author_id = $1`
+		WHERE note2pub.note_id is NULL AND note.author_id = $1 AND note.deleted_time IS NULL`
 
 	noteMap, err := db.getNotesById(sqlQuery, int64(userId))
 	if err != nil {
@@ -137,7 +160,16 @@ func (db *DB) getNotesById(sqlQuery string, args ...interface{}) (NotesById, err
 	for rows.Next() {
 		var tempId int64
 		tempNote := &Note{}
-		if err := rows.Scan(&tempId, &tempNote.AuthorId, &tempNote.Content, &tempNote.CreationTime); err != nil {
+		if err := rows.Scan(
+			&tempId,
+			&tempNote.AuthorId,
+			&tempNote.Content,
+			&tempNote.CreationTime,
+			&tempNote.Revision,
+			&tempNote.LastEditTime,
+			&tempNote.PinnedTime,
+			&tempNote.ArchivedTime,
+		); err != nil {
 			return nil, convertPostgresError(err)
 		}
 
@@ -166,6 +198,11 @@ func (db *DB) StoreNewNote(
 	if err := db.execOneResult(sqlQuery, &noteId, authorId, content, creationTime); err != nil {
 		return 0, err
 	}
+
+	if err := storeNoteLinks(db, NoteId(noteId), content); err != nil {
+		return 0, err
+	}
+
 	return NoteId(noteId), nil
 }
 
@@ -182,7 +219,7 @@ func (db *DB) getNoteMap(sqlQuery string, args ...interface{}) (NoteMap, error)
 	for rows.Next() {
 		var tempId int64
 		tempNote := &Note{}
-		if err := rows.Scan(&tempId, &tempNote.AuthorId, &tempNote.Content, &tempNote.CreationTime); err != nil {
+		if err := rows.Scan(&tempId, &tempNote.AuthorId, &tempNote.Content, &tempNote.CreationTime, &tempNote.Revision, &tempNote.LastEditTime); err != nil {
 			return nil, convertPostgresError(err)
 		}
 
@@ -195,8 +232,8 @@ func (db *DB) getNoteMap(sqlQuery string, args ...interface{}) (NoteMap, error)
 func (db *DB) GetNoteById(noteId NoteId) (*Note, error) {
 
 	sqlQuery := `
-		SELECT * FROM note
-		WHERE note.id = ($1)`
+		SELECT id, author_id, content, creation_time, revision, last_edit_time FROM note
+		WHERE note.id = ($1) AND note.deleted_time IS NULL`
 
 	noteMap, err := db.getNoteMap(sqlQuery, int64(noteId))
 	if err != nil {
@@ -211,25 +248,31 @@ func (db *DB) GetNoteById(noteId NoteId) (*Note, error) {
 	return note, nil
 }
 
-func (db *DB) UpdateNoteContent(noteId NoteId, content string) error {
+// UpdateNoteContent replaces the note's content as long as it is still at the given revision, so that an edit
+// based on an older revision can't silently undo one made since.
+func (db *DB) UpdateNoteContent(noteId NoteId, content string, revision int) error {
 	sqlQuery := `
-		UPDATE note SET content = ($2)
-		WHERE id = ($1)`
+		UPDATE note SET content = ($2), revision = revision + 1, last_edit_time = ($4)
+		WHERE id = ($1) AND revision = ($3) AND deleted_time IS NULL`
 
-	rowsAffected, err := db.execNoResults(sqlQuery, int64(noteId), content)
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(noteId), content, revision, time.Now().UTC())
 	if err != nil {
 		return err
 	}
 
 	if rowsAffected == 0 {
-		return NoNoteFoundError
+		// either the note is gone or someone got there first
+		if _, err := db.GetNoteById(noteId); err != nil {
+			return err
+		}
+		return NoteRevisionChangedError
 	}
 
 	if rowsAffected > 1 {
 		return TooManyRowsAffectedError
 	}
 
-	return nil
+	return storeNoteLinks(db, noteId, content)
 }
 
 func (db *DB) DeleteNoteById(noteId NoteId) error {
@@ -252,3 +295,60 @@ func (db *DB) DeleteNoteById(noteId NoteId) error {
 
 	return nil
 }
+
+// UpdateNoteStates pins, unpins, archives or unarchives the author's unpublished notes, leaving alone whichever
+// of pinned and archived is nil. Pinning a note brings it out of the archive and archiving it unpins it.
+// Nothing changes unless every note is one of the author's unpublished notes.
+func (db *DB) UpdateNoteStates(authorId UserId, noteIds []NoteId, pinned *bool, archived *bool, now time.Time) error {
+	if pinned != nil && archived != nil && *pinned && *archived {
+		return PinnedAndArchivedError
+	}
+
+	ids := make([]int64, 0, len(noteIds))
+	seen := make(map[NoteId]bool, len(noteIds))
+	for _, noteId := range noteIds {
+		if !seen[noteId] {
+			seen[noteId] = true
+			ids = append(ids, int64(noteId))
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlQuery := `
+		UPDATE note SET
+			pinned_time = CASE
+				WHEN $4::boolean IS TRUE THEN NULL
+				WHEN $3::boolean IS NULL THEN pinned_time
+				WHEN $3::boolean THEN COALESCE(pinned_time, $5)
+				ELSE NULL END,
+			archived_time = CASE
+				WHEN $3::boolean IS TRUE THEN NULL
+				WHEN $4::boolean IS NULL THEN archived_time
+				WHEN $4::boolean THEN COALESCE(archived_time, $5)
+				ELSE NULL END
+		WHERE id = ANY($1) AND author_id = $2 AND deleted_time IS NULL AND NOT EXISTS (
+			SELECT 1 FROM note_to_publication_relationship AS note2pub
+			WHERE note2pub.note_id = note.id
+		)`
+
+	result, err := tx.Exec(sqlQuery, pq.Array(ids), int64(authorId), pinned, archived, now)
+	if err != nil {
+		return convertPostgresError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return convertPostgresError(err)
+	}
+
+	if rowsAffected != int64(len(ids)) {
+		return NoNoteFoundError
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type NoteOperationType string
+
+const (
+	UPDATE_CONTENT NoteOperationType = "update"
+	SET_CATEGORY   NoteOperationType = "setCategory"
+	CLEAR_CATEGORY NoteOperationType = "clearCategory"
+	DELETE_NOTE    NoteOperationType = "delete"
+	ADD_TAGS       NoteOperationType = "tag"
+	REMOVE_TAGS    NoteOperationType = "untag"
+)
+
+const MaxNoteOperations = 1000
+
+var NoNoteOperationsError = errors.New("A batch needs at least one operation")
+var NoteBatchFailedError = errors.New("None of the operations were applied as some of them failed")
+var TooManyNoteOperationsError = fmt.Errorf("A batch can have at most %d operations", MaxNoteOperations)
+var UnknownNoteOperationError = errors.New("Operations must be update, setCategory, clearCategory, delete, tag or untag")
+var NoteOperationContentRequiredError = errors.New("Note content cannot be empty or just whitespace")
+var NoteOperationTagsRequiredError = errors.New("Tagging needs at least one tag")
+
+// NoteOperation is one change to one of the author's notes in a batch.
+type NoteOperation struct {
+	Op       NoteOperationType `json:"op"`
+	NoteId   NoteId            `json:"noteId"`
+	Content  string            `json:"content,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+}
+
+// NoteOperationResult is the outcome of one operation in a batch. Note is the updated note after an update.
+type NoteOperationResult struct {
+	Op     NoteOperationType `json:"op"`
+	NoteId NoteId            `json:"noteId"`
+	Error  string            `json:"error,omitempty"`
+	Note   *Note             `json:"note,omitempty"`
+}
+
+// Normalize checks the operation makes sense on its own and tidies up its content and tags. Whether the note
+// exists and belongs to the author, and whether the category exists, is only known once the batch runs.
+func (operation *NoteOperation) Normalize() error {
+	switch operation.Op {
+	case UPDATE_CONTENT:
+		operation.Content = strings.TrimSpace(operation.Content)
+		if len(operation.Content) == 0 {
+			return NoteOperationContentRequiredError
+		}
+
+	case SET_CATEGORY:
+		if len(operation.Category) == 0 {
+			return CannotDeserializeNoteCategoryStringError
+		}
+
+	case ADD_TAGS, REMOVE_TAGS:
+		if len(operation.Tags) == 0 {
+			return NoteOperationTagsRequiredError
+		}
+
+		for i, tag := range operation.Tags {
+			normalizedTag, err := NormalizeTag(tag)
+			if err != nil {
+				return err
+			}
+			operation.Tags[i] = normalizedTag
+		}
+
+	case CLEAR_CATEGORY:
+		operation.Category = ""
+
+	case DELETE_NOTE:
+
+	default:
+		return UnknownNoteOperationError
+	}
+
+	return nil
+}
+
+//  DB methods
+
+// ApplyNoteOperations applies the batch of operations to the author's notes in one transaction, in order.
+// Every operation gets a result. If any of them fails, none are applied and NoteBatchFailedError is returned
+// along with the results saying which failed.
+func (db *DB) ApplyNoteOperations(
+	authorId UserId,
+	operations []*NoteOperation,
+	now time.Time,
+) ([]*NoteOperationResult, error) {
+	if len(operations) == 0 {
+		return nil, NoNoteOperationsError
+	}
+
+	if len(operations) > MaxNoteOperations {
+		return nil, TooManyNoteOperationsError
+	}
+
+	results := make([]*NoteOperationResult, len(operations))
+	failed := false
+	for i, operation := range operations {
+		results[i] = &NoteOperationResult{Op: operation.Op, NoteId: operation.NoteId}
+		if err := operation.Normalize(); err != nil {
+			results[i].Error = err.Error()
+			failed = true
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	noteIds := make([]int64, len(operations))
+	for i, operation := range operations {
+		noteIds[i] = int64(operation.NoteId)
+	}
+
+	// locking the notes keeps them from changing between the ownership check and the operations
+	sqlQueryOwned := `
+		SELECT id FROM note
+		WHERE id = ANY($1) AND author_id = $2 AND deleted_time IS NULL
+		FOR UPDATE`
+
+	ownedNoteIds, err := selectNoteIds(tx, sqlQueryOwned, pq.Array(noteIds), int64(authorId))
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make(map[NoteId]bool, len(ownedNoteIds))
+	for _, noteId := range ownedNoteIds {
+		owned[NoteId(noteId)] = true
+	}
+
+	for i, operation := range operations {
+		if len(results[i].Error) > 0 {
+			continue
+		}
+
+		// notes of other authors are reported as missing, so as not to reveal them, as are notes deleted
+		// earlier in the batch
+		if !owned[operation.NoteId] {
+			results[i].Error = NoNoteFoundError.Error()
+			failed = true
+			continue
+		}
+
+		if err := applyNoteOperation(tx, operation, results[i], now); err != nil {
+			if err != CannotDeserializeNoteCategoryStringError {
+				return nil, err
+			}
+			results[i].Error = err.Error()
+			failed = true
+			continue
+		}
+
+		if operation.Op == DELETE_NOTE {
+			delete(owned, operation.NoteId)
+		}
+	}
+
+	if failed {
+		// nothing is kept, so there are no updated notes to report
+		for _, result := range results {
+			result.Note = nil
+		}
+		return results, NoteBatchFailedError
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func applyNoteOperation(tx *sql.Tx, operation *NoteOperation, result *NoteOperationResult, now time.Time) error {
+	noteId := int64(operation.NoteId)
+
+	switch operation.Op {
+	case UPDATE_CONTENT:
+		sqlQuery := `
+			UPDATE note SET content = $2, revision = revision + 1, last_edit_time = $3
+			WHERE id = $1
+			RETURNING author_id, content, creation_time, revision, last_edit_time`
+
+		note := &Note{}
+		if err := tx.QueryRow(sqlQuery, noteId, operation.Content, now).Scan(
+			&note.AuthorId,
+			&note.Content,
+			&note.CreationTime,
+			&note.Revision,
+			&note.LastEditTime,
+		); err != nil {
+			return convertPostgresError(err)
+		}
+		result.Note = note
+
+		return storeNoteLinks(tx, operation.NoteId, operation.Content)
+
+	case SET_CATEGORY:
+		sqlQueryCategory := `
+			SELECT name FROM category
+			WHERE name = $1`
+
+		var name string
+		if err := tx.QueryRow(sqlQueryCategory, operation.Category).Scan(&name); err != nil {
+			if err == sql.ErrNoRows {
+				return CannotDeserializeNoteCategoryStringError
+			}
+			return convertPostgresError(err)
+		}
+
+		sqlQuery := `
+			INSERT INTO note_to_category_relationship (note_id, category)
+			VALUES ($1, $2)
+			ON CONFLICT (note_id) DO
+			UPDATE SET category = ($2)`
+
+		if _, err := tx.Exec(sqlQuery, noteId, name); err != nil {
+			return convertPostgresError(err)
+		}
+
+	case CLEAR_CATEGORY:
+		sqlQuery := `
+			DELETE FROM note_to_category_relationship
+			WHERE note_id = $1`
+
+		if _, err := tx.Exec(sqlQuery, noteId); err != nil {
+			return convertPostgresError(err)
+		}
+
+	case DELETE_NOTE:
+		return trashNote(tx, operation.NoteId, now)
+
+	case ADD_TAGS:
+		sqlQuery := `
+			INSERT INTO note_tag (note_id, tag)
+			SELECT $1, unnest($2::text[])
+			ON CONFLICT DO NOTHING`
+
+		if _, err := tx.Exec(sqlQuery, noteId, pq.Array(operation.Tags)); err != nil {
+			return convertPostgresError(err)
+		}
+
+	case REMOVE_TAGS:
+		sqlQuery := `
+			DELETE FROM note_tag
+			WHERE note_id = $1 AND tag = ANY($2)`
+
+		if _, err := tx.Exec(sqlQuery, noteId, pq.Array(operation.Tags)); err != nil {
+			return convertPostgresError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tag, err := models.NormalizeTag("  Book \t Club ")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "book-club", tag)
+
+	tag, err = models.NormalizeTag("--to--read-")
+	test_util.Ok(t, err)
+	test_util.Equals(t, "to-read", tag)
+
+	_, err = models.NormalizeTag(" - ")
+	test_util.Equals(t, models.EmptyTagError, err)
+
+	_, err = models.NormalizeTag(strings.Repeat("a", 51))
+	test_util.Equals(t, models.TagTooLongError, err)
+}
+
+func TestNormalizeNoteOperation(t *testing.T) {
+	update := &models.NoteOperation{Op: models.UPDATE_CONTENT, Content: "  changed \n"}
+	test_util.Ok(t, update.Normalize())
+	test_util.Equals(t, "changed", update.Content)
+
+	tag := &models.NoteOperation{Op: models.ADD_TAGS, Tags: []string{"To Read"}}
+	test_util.Ok(t, tag.Normalize())
+	test_util.Equals(t, []string{"to-read"}, tag.Tags)
+
+	clear := &models.NoteOperation{Op: models.CLEAR_CATEGORY, Category: "meta"}
+	test_util.Ok(t, clear.Normalize())
+	test_util.Equals(t, "", clear.Category)
+
+	test_util.Equals(t, models.NoteOperationContentRequiredError, (&models.NoteOperation{Op: models.UPDATE_CONTENT, Content: " "}).Normalize())
+	test_util.Equals(t, models.NoteOperationTagsRequiredError, (&models.NoteOperation{Op: models.REMOVE_TAGS}).Normalize())
+	test_util.Equals(t, models.CannotDeserializeNoteCategoryStringError, (&models.NoteOperation{Op: models.SET_CATEGORY}).Normalize())
+	test_util.Equals(t, models.UnknownNoteOperationError, (&models.NoteOperation{Op: "archive"}).Normalize())
+}
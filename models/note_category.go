@@ -1,44 +1,242 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
-type NoteCategory int
+// NoteCategory is the name of a category. Categories are stored in the database so they can be
+// added and edited without a deploy.
+type NoteCategory string
 
+// The categories every deployment starts with. Questions and predictions have behaviour of their own
+// and cannot be renamed or deleted.
 const (
-	MARGINALIA NoteCategory = iota
-	META
-	QUESTION
-	PREDICTION
+	MARGINALIA NoteCategory = "marginalia"
+	META       NoteCategory = "meta"
+	QUESTION   NoteCategory = "question"
+	PREDICTION NoteCategory = "prediction"
 )
 
-var categoryStrings = [...]string{
-	"marginalia",
-	"meta",
-	"question",
-	"prediction",
-}
-
 var CannotDeserializeNoteCategoryStringError = errors.New("String does not correspond to a Note Category")
 var NoteAlreadyContainsCategoryError = errors.New("NoteId already has a category stored for it")
+var NoCategoryFoundError = errors.New("No category with that name could be found")
+var CategoryAlreadyExistsError = errors.New("A category with that name already exists")
+var CategoryInUseError = errors.New("Categories cannot be deleted while notes are still in them")
+var ReservedCategoryError = errors.New("The question and prediction categories cannot be renamed or deleted")
+var InvalidCategoryNameError = errors.New("Category names must be lowercase letters, digits and dashes")
+var InvalidColourError = errors.New("Colours must be of the form #rrggbb")
+
+var categoryNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+var colourPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (category NoteCategory) String() string {
+	return string(category)
+}
+
+// IsReserved reports whether other features depend on the category existing under its name.
+func (category NoteCategory) IsReserved() bool {
+	return category == QUESTION || category == PREDICTION
+}
+
+// Category describes a category notes can be put in.
+type Category struct {
+	Name         NoteCategory `json:"name"`
+	Colour       string       `json:"colour"`
+	Description  string       `json:"description"`
+	SortOrder    int          `json:"sortOrder"`
+	CreationTime time.Time    `json:"creationTime"`
+	// CreatorId is who created the category, which is zero for the categories every deployment starts with
+	CreatorId UserId `json:"creatorId,omitempty"`
+}
+
+// Validate normalizes the category's fields and checks that they can be stored.
+func (category *Category) Validate() error {
+	category.Name = NoteCategory(strings.ToLower(strings.TrimSpace(string(category.Name))))
+	category.Colour = strings.ToLower(strings.TrimSpace(category.Colour))
+	category.Description = strings.TrimSpace(category.Description)
+
+	if !categoryNamePattern.MatchString(string(category.Name)) {
+		return InvalidCategoryNameError
+	}
+
+	if !colourPattern.MatchString(category.Colour) {
+		return InvalidColourError
+	}
+
+	return nil
+}
+
+//  DB methods
 
-func DeserializeNoteCategory(input string) (NoteCategory, error) {
-	for i := 0; i < len(categoryStrings); i++ {
-		if input == categoryStrings[i] {
-			return NoteCategory(i), nil
+// DeserializeNoteCategory returns the stored category with the given name.
+func (db *DB) DeserializeNoteCategory(input string) (NoteCategory, error) {
+	sqlQuery := `
+		SELECT name FROM category
+		WHERE name = $1`
+
+	var name string
+	if err := db.execOneResult(sqlQuery, &name, input); err != nil {
+		if err == QueryResultContainedNoRowsError {
+			return "", CannotDeserializeNoteCategoryStringError
 		}
+		return "", err
 	}
-	return 0, CannotDeserializeNoteCategoryStringError
+
+	return NoteCategory(name), nil
 }
 
-func (category NoteCategory) String() string {
+// GetCategories returns every category in display order.
+func (db *DB) GetCategories() ([]*Category, error) {
+	sqlQuery := `
+		SELECT name, colour, description, sort_order, creation_time, COALESCE(creator_id, 0) FROM category
+		ORDER BY sort_order, name`
+
+	rows, err := db.Query(sqlQuery)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	categories := make([]*Category, 0)
+	for rows.Next() {
+		category := &Category{}
+		var name string
+		if err := rows.Scan(
+			&name,
+			&category.Colour,
+			&category.Description,
+			&category.SortOrder,
+			&category.CreationTime,
+			&category.CreatorId,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		category.Name = NoteCategory(name)
+
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return categories, nil
+}
+
+func (db *DB) GetCategory(name NoteCategory) (*Category, error) {
+	sqlQuery := `
+		SELECT colour, description, sort_order, creation_time, COALESCE(creator_id, 0) FROM category
+		WHERE name = $1`
+
+	category := &Category{Name: name}
+	if err := db.QueryRow(sqlQuery, string(name)).Scan(
+		&category.Colour,
+		&category.Description,
+		&category.SortOrder,
+		&category.CreationTime,
+		&category.CreatorId,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoCategoryFoundError
+		}
+		return nil, convertPostgresError(err)
+	}
+
+	return category, nil
+}
+
+func (db *DB) StoreNewCategory(category *Category) error {
+	if err := category.Validate(); err != nil {
+		return err
+	}
+
+	sqlQuery := `
+		INSERT INTO category (name, colour, description, sort_order, creation_time, creator_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6::bigint, 0))`
+
+	if _, err := db.execNoResults(
+		sqlQuery,
+		string(category.Name),
+		category.Colour,
+		category.Description,
+		category.SortOrder,
+		category.CreationTime,
+		int64(category.CreatorId),
+	); err != nil {
+		if err == UniqueConstraintError {
+			return CategoryAlreadyExistsError
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdateCategory replaces the named category's details, but not who created it. Renaming a category moves its
+// notes along with it.
+func (db *DB) UpdateCategory(name NoteCategory, category *Category) error {
+	if err := category.Validate(); err != nil {
+		return err
+	}
+
+	if name.IsReserved() && category.Name != name {
+		return ReservedCategoryError
+	}
+
+	sqlQuery := `
+		UPDATE category SET name = $2, colour = $3, description = $4, sort_order = $5
+		WHERE name = $1`
+
+	rowsAffected, err := db.execNoResults(
+		sqlQuery,
+		string(name),
+		string(category.Name),
+		category.Colour,
+		category.Description,
+		category.SortOrder,
+	)
+	if err != nil {
+		if err == UniqueConstraintError {
+			return CategoryAlreadyExistsError
+		}
+		return err
+	}
 
-	if category < MARGINALIA || category > PREDICTION {
-		return "Unknown"
+	if rowsAffected == 0 {
+		return NoCategoryFoundError
 	}
 
-	return categoryStrings[category]
+	return nil
+}
+
+func (db *DB) DeleteCategory(name NoteCategory) error {
+	if name.IsReserved() {
+		return ReservedCategoryError
+	}
+
+	sqlQuery := `
+		DELETE FROM category
+		WHERE name = $1`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, string(name))
+	if err != nil {
+		if err == ForeignKeyConstraintError {
+			return CategoryInUseError
+		}
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoCategoryFoundError
+	}
+
+	return nil
 }
 
 func (db *DB) GetNoteCategory(noteId NoteId) (NoteCategory, error) {
@@ -47,13 +245,9 @@ func (db *DB) GetNoteCategory(noteId NoteId) (NoteCategory, error) {
 		WHERE note_id = $1`
 	var categoryString string
 	if err := db.execOneResult(sqlQuery, &categoryString, int64(noteId)); err != nil {
-		return 0, err
-	}
-	category, err := DeserializeNoteCategory(categoryString)
-	if err != nil {
-		return 0, err
+		return "", err
 	}
-	return category, nil
+	return NoteCategory(categoryString), nil
 }
 
 func (db *DB) AssignNoteCategoryRelationship(noteId NoteId, category NoteCategory) error {
@@ -93,3 +287,37 @@ func (db *DB) DeleteNoteCategory(noteId NoteId) error {
 	}
 	return nil
 }
+
+// GetNoteCategories returns the categories of whichever of the given notes have one.
+func (db *DB) GetNoteCategories(noteIds []NoteId) (map[NoteId]NoteCategory, error) {
+	ids := make([]int64, len(noteIds))
+	for i, noteId := range noteIds {
+		ids[i] = int64(noteId)
+	}
+
+	sqlQuery := `
+		SELECT note_id, category FROM note_to_category_relationship
+		WHERE note_id = ANY($1)`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	categories := make(map[NoteId]NoteCategory)
+	for rows.Next() {
+		var noteId int64
+		var categoryString string
+		if err := rows.Scan(&noteId, &categoryString); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		categories[NoteId(noteId)] = NoteCategory(categoryString)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// noteLinkPattern matches links to other notes written as [[note:123]].
+var noteLinkPattern = regexp.MustCompile(`\[\[note:(\d+)\]\]`)
+
+// NoteLink is a link from one note's content to another note.
+type NoteLink struct {
+	SourceNoteId NoteId `json:"source"`
+	TargetNoteId NoteId `json:"target"`
+}
+
+// ParseNoteLinks returns the notes linked to from the content, in the order they are first linked.
+func ParseNoteLinks(content string) []NoteId {
+	noteIds := make([]NoteId, 0)
+	seen := make(map[NoteId]bool)
+
+	for _, match := range noteLinkPattern.FindAllStringSubmatch(content, -1) {
+		id, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		noteId := NoteId(id)
+		if !seen[noteId] {
+			seen[noteId] = true
+			noteIds = append(noteIds, noteId)
+		}
+	}
+
+	return noteIds
+}
+
+// sqlExecer is satisfied by both *DB and *sql.Tx, so links can be stored inside other transactions.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// storeNoteLinks replaces the note's links with the ones in its content. Links to notes that don't
+// exist, and to the note itself, are dropped.
+func storeNoteLinks(execer sqlExecer, noteId NoteId, content string) error {
+	sqlQueryClear := `
+		DELETE FROM note_link
+		WHERE source_note_id = $1`
+
+	if _, err := execer.Exec(sqlQueryClear, int64(noteId)); err != nil {
+		return convertPostgresError(err)
+	}
+
+	linkedNoteIds := ParseNoteLinks(content)
+	if len(linkedNoteIds) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(linkedNoteIds))
+	for i, linkedNoteId := range linkedNoteIds {
+		ids[i] = int64(linkedNoteId)
+	}
+
+	sqlQueryStore := `
+		INSERT INTO note_link (source_note_id, target_note_id)
+		SELECT $1, id FROM note
+		WHERE id = ANY($2) AND id <> $1`
+
+	if _, err := execer.Exec(sqlQueryStore, int64(noteId), pq.Array(ids)); err != nil {
+		return convertPostgresError(err)
+	}
+
+	return nil
+}
+
+//  DB methods
+
+// GetNoteLinksBetween returns the links whose source and target are both among the given notes,
+// so that links to notes a reader can't see are never returned to them. Links from deleted notes are left out.
+func (db *DB) GetNoteLinksBetween(noteIds []NoteId) ([]*NoteLink, error) {
+	ids := make([]int64, len(noteIds))
+	for i, noteId := range noteIds {
+		ids[i] = int64(noteId)
+	}
+
+	sqlQuery := `
+		SELECT source_note_id, target_note_id FROM note_link
+			INNER JOIN note
+				ON note.id = note_link.source_note_id
+		WHERE source_note_id = ANY($1) AND target_note_id = ANY($1) AND note.deleted_time IS NULL
+		ORDER BY source_note_id, target_note_id`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	links := make([]*NoteLink, 0)
+	for rows.Next() {
+		var sourceNoteId int64
+		var targetNoteId int64
+		if err := rows.Scan(&sourceNoteId, &targetNoteId); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		links = append(links, &NoteLink{SourceNoteId: NoteId(sourceNoteId), TargetNoteId: NoteId(targetNoteId)})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return links, nil
+}
@@ -0,0 +1,27 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+var parseNoteLinksTests = []struct {
+	content string
+	noteIds []models.NoteId
+}{
+	{"no links here", []models.NoteId{}},
+	{"see [[note:123]]", []models.NoteId{123}},
+	{"[[note:2]] before [[note:1]] and [[note:2]] again", []models.NoteId{2, 1}},
+	{"[[note:abc]] [note:4] [[note: 5]] [[Note:6]]", []models.NoteId{}},
+	{"[[note:99999999999999999999]]", []models.NoteId{}},
+}
+
+func TestParseNoteLinks(t *testing.T) {
+	for _, test := range parseNoteLinksTests {
+		t.Run(test.content, func(t *testing.T) {
+			test_util.Equals(t, test.noteIds, models.ParseNoteLinks(test.content))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+const maxTagLength = 50
+
+var EmptyTagError = errors.New("Tags cannot be empty")
+var TagTooLongError = fmt.Errorf("Tags cannot be longer than %d characters", maxTagLength)
+
+// NormalizeTag lower cases the tag and joins its words with hyphens, so "Book Club" and "book-club" are the
+// same tag.
+func NormalizeTag(tag string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(tag), func(character rune) bool {
+		return unicode.IsSpace(character) || character == '-'
+	})
+	tag = strings.Join(words, "-")
+
+	if len(tag) == 0 {
+		return "", EmptyTagError
+	}
+
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", TagTooLongError
+	}
+
+	return tag, nil
+}
+
+//  DB methods
+
+// GetNoteTags returns the tags of whichever of the given notes have any, in alphabetical order.
+func (db *DB) GetNoteTags(noteIds []NoteId) (map[NoteId][]string, error) {
+	ids := make([]int64, len(noteIds))
+	for i, noteId := range noteIds {
+		ids[i] = int64(noteId)
+	}
+
+	sqlQuery := `
+		SELECT note_id, tag FROM note_tag
+		WHERE note_id = ANY($1)
+		ORDER BY note_id, tag`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	tags := make(map[NoteId][]string)
+	for rows.Next() {
+		var noteId int64
+		var tag string
+		if err := rows.Scan(&noteId, &tag); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		tags[NoteId(noteId)] = append(tags[NoteId(noteId)], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return tags, nil
+}
@@ -7,20 +7,33 @@ import (
 	"github.com/atmiguel/cerealnotes/test_util"
 )
 
-var deserializationTests = []models.NoteCategory{
-	models.MARGINALIA,
-	models.META,
-	models.QUESTION,
-	models.PREDICTION,
+var categoryValidationTests = []struct {
+	category *models.Category
+	name     models.NoteCategory
+	colour   string
+	err      error
+}{
+	{&models.Category{Name: " Book-Club ", Colour: "#00FF00"}, "book-club", "#00ff00", nil},
+	{&models.Category{Name: "meta", Colour: "#9c27b0"}, models.META, "#9c27b0", nil},
+	{&models.Category{Name: "book club", Colour: "#00ff00"}, "book club", "#00ff00", models.InvalidCategoryNameError},
+	{&models.Category{Name: "", Colour: "#00ff00"}, "", "#00ff00", models.InvalidCategoryNameError},
+	{&models.Category{Name: "green", Colour: "green"}, "green", "green", models.InvalidColourError},
+	{&models.Category{Name: "green", Colour: "#0f0"}, "green", "#0f0", models.InvalidColourError},
 }
 
-func TestDeserialization(t *testing.T) {
-	for _, val := range deserializationTests {
-		t.Run(val.String(), func(t *testing.T) {
-			cat, err := models.DeserializeNoteCategory(val.String())
-			test_util.Ok(t, err)
-			test_util.Equals(t, val, cat)
+func TestCategoryValidation(t *testing.T) {
+	for _, test := range categoryValidationTests {
+		t.Run(string(test.category.Name), func(t *testing.T) {
+			err := test.category.Validate()
+			test_util.Equals(t, test.err, err)
+			test_util.Equals(t, test.name, test.category.Name)
+			test_util.Equals(t, test.colour, test.category.Colour)
 		})
 	}
+}
 
+func TestReservedCategories(t *testing.T) {
+	test_util.Assert(t, models.QUESTION.IsReserved(), "questions should be reserved")
+	test_util.Assert(t, models.PREDICTION.IsReserved(), "predictions should be reserved")
+	test_util.Assert(t, !models.META.IsReserved(), "meta should not be reserved")
 }
@@ -0,0 +1,269 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type NotificationFrequency string
+
+const (
+	IMMEDIATE NotificationFrequency = "immediate"
+	DAILY     NotificationFrequency = "daily"
+	NEVER     NotificationFrequency = "never"
+)
+
+// DefaultNotificationFrequency is used for users who never chose a frequency.
+const DefaultNotificationFrequency = DAILY
+
+var CannotDeserializeNotificationFrequencyStringError = errors.New("String does not correspond to a Notification Frequency")
+var NoNotificationPreferencesFoundError = errors.New("No notification preferences with that information could be found")
+
+func DeserializeNotificationFrequency(input string) (NotificationFrequency, error) {
+	for _, frequency := range []NotificationFrequency{IMMEDIATE, DAILY, NEVER} {
+		if input == string(frequency) {
+			return frequency, nil
+		}
+	}
+	return "", CannotDeserializeNotificationFrequencyStringError
+}
+
+type NotificationPreferences struct {
+	Frequency        NotificationFrequency `json:"frequency"`
+	UnsubscribeToken string                `json:"-"`
+	LastDigestTime   time.Time             `json:"-"`
+}
+
+// PublicationNotification records that a publication became readable by a user.
+type PublicationNotification struct {
+	PublicationId     PublicationId `json:"publicationId"`
+	AuthorId          UserId        `json:"authorId"`
+	AuthorDisplayName string        `json:"authorDisplayName"`
+	IssueNumber       int64         `json:"issueNumber"`
+	CreationTime      time.Time     `json:"creationTime"`
+}
+
+// NotificationRecipient is a user with notifications that have not been sent yet.
+type NotificationRecipient struct {
+	UserId        UserId
+	DisplayName   string
+	EmailAddress  string
+	Preferences   *NotificationPreferences
+	Notifications []*PublicationNotification
+}
+
+//  DB methods
+
+// storePublicationNotifications records every user who can read something new because of
+// the given publication: readers who have published at least as many issues as the new issue's
+// number, and the author themself, who unlocks every other author's issue with that number.
+func storePublicationNotifications(execer sqlExecer, authorId UserId, publicationId PublicationId) error {
+	sqlQuery := `
+		WITH ranked_pubs AS (` + rankedPublicationsSql + `
+		), publication_counts AS (` + publicationCountsSql + `
+		)
+		INSERT INTO publication_notification (user_id, publication_id, creation_time)
+		SELECT publication_counts.user_id, ranked_pubs.id, $3
+		FROM   ranked_pubs
+			   CROSS JOIN publication_counts
+		WHERE  (ranked_pubs.id = $2
+				AND publication_counts.user_id <> $1
+				AND publication_counts.publication_count >= ranked_pubs.rank)
+			OR (publication_counts.user_id = $1
+				AND ranked_pubs.author_id <> $1
+				AND ranked_pubs.retracted_time IS NULL
+				AND ranked_pubs.rank = publication_counts.publication_count)
+		ON CONFLICT DO NOTHING`
+
+	if _, err := execer.Exec(sqlQuery, int64(authorId), int64(publicationId), time.Now().UTC()); err != nil {
+		return convertPostgresError(err)
+	}
+
+	return nil
+}
+
+// GetNotificationPreferences returns the user's preferences, storing the defaults if there are none yet.
+func (db *DB) GetNotificationPreferences(userId UserId) (*NotificationPreferences, error) {
+	unsubscribeToken, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQueryDefaults := `
+		INSERT INTO notification_preference (user_id, frequency, unsubscribe_token)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING`
+
+	if _, err := db.execNoResults(sqlQueryDefaults, int64(userId), string(DefaultNotificationFrequency), unsubscribeToken); err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT frequency, unsubscribe_token, last_digest_time FROM notification_preference
+		WHERE user_id = $1`
+
+	preferences := &NotificationPreferences{}
+	var frequency string
+	var lastDigestTime pq.NullTime
+	if err := db.QueryRow(sqlQuery, int64(userId)).Scan(&frequency, &preferences.UnsubscribeToken, &lastDigestTime); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoNotificationPreferencesFoundError
+		}
+		return nil, convertPostgresError(err)
+	}
+
+	preferences.Frequency = NotificationFrequency(frequency)
+	if lastDigestTime.Valid {
+		preferences.LastDigestTime = lastDigestTime.Time
+	}
+
+	return preferences, nil
+}
+
+func (db *DB) SetNotificationFrequency(userId UserId, frequency NotificationFrequency) error {
+	if _, err := db.GetNotificationPreferences(userId); err != nil {
+		return err
+	}
+
+	sqlQuery := `
+		UPDATE notification_preference SET frequency = ($2)
+		WHERE user_id = ($1)`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(userId), string(frequency))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoNotificationPreferencesFoundError
+	}
+
+	return nil
+}
+
+// UnsubscribeFromNotifications turns off notifications for whoever owns the unsubscribe token.
+func (db *DB) UnsubscribeFromNotifications(unsubscribeToken string) error {
+	sqlQuery := `
+		UPDATE notification_preference SET frequency = ($2)
+		WHERE unsubscribe_token = ($1)`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, unsubscribeToken, string(NEVER))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoNotificationPreferencesFoundError
+	}
+
+	return nil
+}
+
+// GetNotificationRecipients returns every user with unsent notifications, along with those notifications.
+func (db *DB) GetNotificationRecipients() ([]*NotificationRecipient, error) {
+	sqlQuery := `
+		SELECT
+		recipient.id,
+		recipient.display_name,
+		recipient.email_address,
+		ranked_pubs.id,
+		ranked_pubs.author_id,
+		author.display_name,
+		ranked_pubs.rank AS publication_issue,
+		notification.creation_time
+		FROM   publication_notification AS notification
+			   INNER JOIN (` + rankedPublicationsSql + `) ranked_pubs
+					   ON ranked_pubs.id = notification.publication_id
+			   INNER JOIN app_user AS recipient
+					   ON recipient.id = notification.user_id
+			   INNER JOIN app_user AS author
+					   ON author.id = ranked_pubs.author_id
+		WHERE  notification.sent_time IS NULL
+		ORDER BY recipient.id, notification.creation_time`
+
+	rows, err := db.Query(sqlQuery)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	recipients := make([]*NotificationRecipient, 0)
+	var recipient *NotificationRecipient
+
+	for rows.Next() {
+		var userId int64
+		var displayName string
+		var emailAddress string
+		var publicationId int64
+		notification := &PublicationNotification{}
+		if err := rows.Scan(
+			&userId,
+			&displayName,
+			&emailAddress,
+			&publicationId,
+			&notification.AuthorId,
+			&notification.AuthorDisplayName,
+			&notification.IssueNumber,
+			&notification.CreationTime,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		notification.PublicationId = PublicationId(publicationId)
+
+		if recipient == nil || recipient.UserId != UserId(userId) {
+			recipient = &NotificationRecipient{
+				UserId:        UserId(userId),
+				DisplayName:   displayName,
+				EmailAddress:  emailAddress,
+				Notifications: make([]*PublicationNotification, 0),
+			}
+			recipients = append(recipients, recipient)
+		}
+
+		recipient.Notifications = append(recipient.Notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+	rows.Close()
+
+	for _, recipient := range recipients {
+		preferences, err := db.GetNotificationPreferences(recipient.UserId)
+		if err != nil {
+			return nil, err
+		}
+		recipient.Preferences = preferences
+	}
+
+	return recipients, nil
+}
+
+// MarkNotificationsSent records that the user was notified about the given publications at sentTime.
+func (db *DB) MarkNotificationsSent(userId UserId, publicationIds []PublicationId, sentTime time.Time) error {
+	ids := make([]int64, len(publicationIds))
+	for i, publicationId := range publicationIds {
+		ids[i] = int64(publicationId)
+	}
+
+	sqlQuery := `
+		UPDATE publication_notification SET sent_time = ($3)
+		WHERE user_id = ($1) AND publication_id = ANY($2)`
+
+	if _, err := db.execNoResults(sqlQuery, int64(userId), pq.Array(ids), sentTime.UTC()); err != nil {
+		return err
+	}
+
+	sqlQueryDigest := `
+		UPDATE notification_preference SET last_digest_time = ($2)
+		WHERE user_id = ($1)`
+
+	if _, err := db.execNoResults(sqlQueryDigest, int64(userId), sentTime.UTC()); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,440 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PredictionOutcome string
+
+const (
+	OUTCOME_TRUE      PredictionOutcome = "true"
+	OUTCOME_FALSE     PredictionOutcome = "false"
+	OUTCOME_AMBIGUOUS PredictionOutcome = "ambiguous"
+)
+
+// calibrationBucketCount splits stated probabilities into buckets of 10%.
+const calibrationBucketCount = 10
+
+var CannotDeserializePredictionOutcomeStringError = errors.New("String does not correspond to a Prediction Outcome")
+var NoPredictionFoundError = errors.New("No prediction with that information could be found")
+var InvalidProbabilityError = errors.New("Probabilities must be between 0 and 1")
+var PredictionLockedError = errors.New("Predictions cannot be changed once they are published or resolved")
+var PredictionAlreadyResolvedError = errors.New("This prediction has already been resolved")
+
+func DeserializePredictionOutcome(input string) (PredictionOutcome, error) {
+	for _, outcome := range []PredictionOutcome{OUTCOME_TRUE, OUTCOME_FALSE, OUTCOME_AMBIGUOUS} {
+		if input == string(outcome) {
+			return outcome, nil
+		}
+	}
+	return "", CannotDeserializePredictionOutcomeStringError
+}
+
+// Prediction is the stated probability of a prediction note, and its outcome once resolved.
+type Prediction struct {
+	NoteId         NoteId            `json:"noteId"`
+	AuthorId       UserId            `json:"authorId"`
+	Probability    float64           `json:"probability"`
+	ResolutionDate time.Time         `json:"resolutionDate"`
+	JudgeIds       []UserId          `json:"judgeIds"`
+	Outcome        PredictionOutcome `json:"outcome,omitempty"`
+	ResolverId     UserId            `json:"resolverId,omitempty"`
+	ResolvedTime   *time.Time        `json:"resolvedTime,omitempty"`
+}
+
+func (prediction *Prediction) IsResolved() bool {
+	return len(prediction.Outcome) > 0
+}
+
+// CanBeResolvedBy reports whether the user is the prediction's author or one of its judges.
+func (prediction *Prediction) CanBeResolvedBy(userId UserId) bool {
+	if prediction.AuthorId == userId {
+		return true
+	}
+
+	for _, judgeId := range prediction.JudgeIds {
+		if judgeId == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PredictionReminder is an unresolved prediction whose resolution date has passed.
+type PredictionReminder struct {
+	NoteId         NoteId
+	AuthorId       UserId
+	DisplayName    string
+	EmailAddress   string
+	Content        string
+	Probability    float64
+	ResolutionDate time.Time
+}
+
+// CalibrationBucket groups resolved predictions whose stated probability is in [MinProbability, MaxProbability).
+type CalibrationBucket struct {
+	MinProbability    float64 `json:"minProbability"`
+	MaxProbability    float64 `json:"maxProbability"`
+	Count             int     `json:"count"`
+	MeanProbability   float64 `json:"meanProbability"`
+	ObservedFrequency float64 `json:"observedFrequency"`
+}
+
+// Calibration summarizes how well a user's stated probabilities matched what happened.
+// Ambiguous outcomes are left out.
+type Calibration struct {
+	Count int `json:"count"`
+	// BrierScore is the mean squared difference between the stated probabilities and the outcomes,
+	// from 0 (perfect) to 1. It is nil until a prediction has resolved.
+	BrierScore *float64             `json:"brierScore"`
+	Buckets    []*CalibrationBucket `json:"buckets"`
+}
+
+// CalculateCalibration scores the resolved predictions, ignoring unresolved and ambiguous ones.
+// Only buckets containing predictions are returned.
+func CalculateCalibration(predictions []*Prediction) *Calibration {
+	calibration := &Calibration{Buckets: make([]*CalibrationBucket, 0)}
+
+	buckets := make([]*CalibrationBucket, calibrationBucketCount)
+	trueCounts := make([]int, calibrationBucketCount)
+	probabilitySums := make([]float64, calibrationBucketCount)
+	squaredErrorSum := 0.0
+
+	for _, prediction := range predictions {
+		var outcome float64
+		switch prediction.Outcome {
+		case OUTCOME_TRUE:
+			outcome = 1
+		case OUTCOME_FALSE:
+			outcome = 0
+		default:
+			continue
+		}
+
+		calibration.Count++
+		squaredErrorSum += math.Pow(prediction.Probability-outcome, 2)
+
+		index := int(prediction.Probability * calibrationBucketCount)
+		if index >= calibrationBucketCount {
+			index = calibrationBucketCount - 1
+		}
+
+		if buckets[index] == nil {
+			buckets[index] = &CalibrationBucket{
+				MinProbability: float64(index) / calibrationBucketCount,
+				MaxProbability: float64(index+1) / calibrationBucketCount,
+			}
+		}
+
+		buckets[index].Count++
+		probabilitySums[index] += prediction.Probability
+		trueCounts[index] += int(outcome)
+	}
+
+	if calibration.Count == 0 {
+		return calibration
+	}
+
+	brierScore := squaredErrorSum / float64(calibration.Count)
+	calibration.BrierScore = &brierScore
+
+	for index, bucket := range buckets {
+		if bucket == nil {
+			continue
+		}
+
+		bucket.MeanProbability = probabilitySums[index] / float64(bucket.Count)
+		bucket.ObservedFrequency = float64(trueCounts[index]) / float64(bucket.Count)
+		calibration.Buckets = append(calibration.Buckets, bucket)
+	}
+
+	return calibration
+}
+
+//  DB methods
+
+// StorePrediction stores or replaces the prediction of a note along with its judges, and categorizes the note
+// as a prediction. Predictions are locked once their note is published or they are resolved.
+func (db *DB) StorePrediction(prediction *Prediction) error {
+	if prediction.Probability < 0 || prediction.Probability > 1 {
+		return InvalidProbabilityError
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlQueryLocked := `
+		SELECT EXISTS (SELECT 1 FROM note_to_publication_relationship WHERE note_id = $1)
+			OR EXISTS (SELECT 1 FROM prediction WHERE note_id = $1 AND outcome IS NOT NULL)`
+
+	var locked bool
+	if err := tx.QueryRow(sqlQueryLocked, int64(prediction.NoteId)).Scan(&locked); err != nil {
+		return convertPostgresError(err)
+	}
+
+	if locked {
+		return PredictionLockedError
+	}
+
+	sqlQuery := `
+		INSERT INTO prediction (note_id, probability, resolution_date)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (note_id) DO
+		UPDATE SET probability = ($2), resolution_date = ($3), reminder_sent_time = NULL`
+
+	if _, err := tx.Exec(sqlQuery, int64(prediction.NoteId), prediction.Probability, prediction.ResolutionDate.UTC()); err != nil {
+		return convertPostgresError(err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM prediction_judge WHERE note_id = $1`, int64(prediction.NoteId)); err != nil {
+		return convertPostgresError(err)
+	}
+
+	for _, judgeId := range prediction.JudgeIds {
+		sqlQueryJudge := `
+			INSERT INTO prediction_judge (note_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`
+
+		if _, err := tx.Exec(sqlQueryJudge, int64(prediction.NoteId), int64(judgeId)); err != nil {
+			return convertPostgresError(err)
+		}
+	}
+
+	sqlQueryCategory := `
+		INSERT INTO note_to_category_relationship (note_id, category)
+		VALUES ($1, $2)
+		ON CONFLICT (note_id) DO
+		UPDATE SET category = ($2)`
+
+	if _, err := tx.Exec(sqlQueryCategory, int64(prediction.NoteId), PREDICTION.String()); err != nil {
+		return convertPostgresError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) GetPrediction(noteId NoteId) (*Prediction, error) {
+	sqlQuery := `
+		SELECT
+		prediction.note_id,
+		note.author_id,
+		prediction.probability,
+		prediction.resolution_date,
+		prediction.outcome,
+		prediction.resolver_id,
+		prediction.resolved_time,
+		ARRAY(SELECT user_id FROM prediction_judge
+			  WHERE prediction_judge.note_id = prediction.note_id
+			  ORDER BY user_id)
+		FROM   prediction
+			   INNER JOIN note
+					   ON note.id = prediction.note_id
+		WHERE  prediction.note_id = $1 AND note.deleted_time IS NULL`
+
+	predictions, err := db.getPredictions(sqlQuery, int64(noteId))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(predictions) == 0 {
+		return nil, NoPredictionFoundError
+	}
+
+	return predictions[0], nil
+}
+
+// GetUsersResolvedPredictions returns the resolved predictions the user authored on notes that aren't in the
+// trash. When someone else is viewing them, only predictions on notes published in issues the viewer is allowed
+// to read are returned.
+func (db *DB) GetUsersResolvedPredictions(userId UserId, viewerId UserId) ([]*Prediction, error) {
+	publicationCount, err := db.getPublicationCount(viewerId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT
+		prediction.note_id,
+		note.author_id,
+		prediction.probability,
+		prediction.resolution_date,
+		prediction.outcome,
+		prediction.resolver_id,
+		prediction.resolved_time,
+		ARRAY(SELECT user_id FROM prediction_judge
+			  WHERE prediction_judge.note_id = prediction.note_id
+			  ORDER BY user_id)
+		FROM   prediction
+			   INNER JOIN note
+					   ON note.id = prediction.note_id
+		WHERE  note.author_id = $1
+			   AND prediction.outcome IS NOT NULL
+			   AND note.deleted_time IS NULL
+			   AND (note.author_id = $2 OR EXISTS (
+					SELECT 1
+					FROM   (` + rankedPublicationsSql + `) ranked_pubs
+						   INNER JOIN note_to_publication_relationship AS note2pub
+								   ON note2pub.publication_id = ranked_pubs.id
+					WHERE  note2pub.note_id = note.id
+						   AND ranked_pubs.rank <= $3
+						   AND ranked_pubs.retracted_time IS NULL))
+		ORDER BY prediction.resolved_time`
+
+	return db.getPredictions(sqlQuery, int64(userId), int64(viewerId), publicationCount)
+}
+
+func (db *DB) getPredictions(sqlQuery string, args ...interface{}) ([]*Prediction, error) {
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	predictions := make([]*Prediction, 0)
+	for rows.Next() {
+		prediction := &Prediction{}
+		var noteId int64
+		var outcome sql.NullString
+		var resolverId sql.NullInt64
+		var resolvedTime pq.NullTime
+		var judgeIds pq.Int64Array
+
+		if err := rows.Scan(
+			&noteId,
+			&prediction.AuthorId,
+			&prediction.Probability,
+			&prediction.ResolutionDate,
+			&outcome,
+			&resolverId,
+			&resolvedTime,
+			&judgeIds,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		prediction.NoteId = NoteId(noteId)
+		prediction.Outcome = PredictionOutcome(outcome.String)
+		prediction.ResolverId = UserId(resolverId.Int64)
+		if resolvedTime.Valid {
+			prediction.ResolvedTime = &resolvedTime.Time
+		}
+
+		prediction.JudgeIds = make([]UserId, len(judgeIds))
+		for i, judgeId := range judgeIds {
+			prediction.JudgeIds[i] = UserId(judgeId)
+		}
+
+		predictions = append(predictions, prediction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return predictions, nil
+}
+
+// ResolvePrediction records the outcome of a prediction. Whether the resolver is allowed to
+// resolve it is up to the caller.
+func (db *DB) ResolvePrediction(noteId NoteId, resolverId UserId, outcome PredictionOutcome, resolvedTime time.Time) error {
+	sqlQuery := `
+		UPDATE prediction SET outcome = ($2), resolver_id = ($3), resolved_time = ($4)
+		WHERE note_id = ($1) AND outcome IS NULL`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(noteId), string(outcome), int64(resolverId), resolvedTime.UTC())
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		if _, err := db.GetPrediction(noteId); err != nil {
+			return err
+		}
+		return PredictionAlreadyResolvedError
+	}
+
+	return nil
+}
+
+// GetDuePredictionReminders returns the unresolved predictions whose resolution date has passed
+// and whose author has not been reminded yet.
+func (db *DB) GetDuePredictionReminders(now time.Time) ([]*PredictionReminder, error) {
+	sqlQuery := `
+		SELECT
+		prediction.note_id,
+		note.author_id,
+		author.display_name,
+		author.email_address,
+		note.content,
+		prediction.probability,
+		prediction.resolution_date
+		FROM   prediction
+			   INNER JOIN note
+					   ON note.id = prediction.note_id
+			   INNER JOIN app_user AS author
+					   ON author.id = note.author_id
+		WHERE  prediction.outcome IS NULL
+			   AND note.deleted_time IS NULL
+			   AND prediction.reminder_sent_time IS NULL
+			   AND prediction.resolution_date <= $1
+		ORDER BY prediction.resolution_date`
+
+	rows, err := db.Query(sqlQuery, now.UTC())
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	reminders := make([]*PredictionReminder, 0)
+	for rows.Next() {
+		reminder := &PredictionReminder{}
+		var noteId int64
+		if err := rows.Scan(
+			&noteId,
+			&reminder.AuthorId,
+			&reminder.DisplayName,
+			&reminder.EmailAddress,
+			&reminder.Content,
+			&reminder.Probability,
+			&reminder.ResolutionDate,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		reminder.NoteId = NoteId(noteId)
+
+		reminders = append(reminders, reminder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return reminders, nil
+}
+
+func (db *DB) MarkPredictionReminderSent(noteId NoteId, sentTime time.Time) error {
+	sqlQuery := `
+		UPDATE prediction SET reminder_sent_time = ($2)
+		WHERE note_id = ($1)`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(noteId), sentTime.UTC())
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoPredictionFoundError
+	}
+
+	return nil
+}
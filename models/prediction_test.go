@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestCalculateCalibration(t *testing.T) {
+	predictions := []*models.Prediction{
+		{Probability: 0.9, Outcome: models.OUTCOME_TRUE},
+		{Probability: 0.95, Outcome: models.OUTCOME_FALSE},
+		{Probability: 1, Outcome: models.OUTCOME_TRUE},
+		{Probability: 0.2, Outcome: models.OUTCOME_FALSE},
+		{Probability: 0.5, Outcome: models.OUTCOME_AMBIGUOUS},
+		{Probability: 0.5},
+	}
+
+	calibration := models.CalculateCalibration(predictions)
+
+	// ambiguous and unresolved predictions don't count
+	test_util.Equals(t, 4, calibration.Count)
+
+	expectedBrierScore := (0.01 + 0.9025 + 0 + 0.04) / 4
+	test_util.Assert(t, math.Abs(*calibration.BrierScore-expectedBrierScore) < 1e-9,
+		"Expected a Brier score of %f but got %f", expectedBrierScore, *calibration.BrierScore)
+
+	test_util.Equals(t, 2, len(calibration.Buckets))
+
+	low := calibration.Buckets[0]
+	test_util.Equals(t, 0.2, low.MinProbability)
+	test_util.Equals(t, 1, low.Count)
+	test_util.Equals(t, 0.0, low.ObservedFrequency)
+
+	// certainty shares the top bucket
+	high := calibration.Buckets[1]
+	test_util.Equals(t, 0.9, high.MinProbability)
+	test_util.Equals(t, 1.0, high.MaxProbability)
+	test_util.Equals(t, 3, high.Count)
+	test_util.Assert(t, math.Abs(high.ObservedFrequency-2.0/3) < 1e-9, "Unexpected observed frequency %f", high.ObservedFrequency)
+}
+
+func TestCalculateCalibrationWithoutResolvedPredictions(t *testing.T) {
+	calibration := models.CalculateCalibration([]*models.Prediction{{Probability: 0.5}})
+
+	test_util.Equals(t, 0, calibration.Count)
+	test_util.Assert(t, calibration.BrierScore == nil, "Expected no Brier score")
+	test_util.Equals(t, 0, len(calibration.Buckets))
+}
@@ -1,8 +1,8 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
-	"strconv"
 	"time"
 )
 
@@ -11,75 +11,344 @@ type PublicationId int64
 type Publication struct {
 	AuthorId     UserId    `json:"authorId"`
 	CreationTime time.Time `json:"creationTime"`
+	Title        string    `json:"title"`
+	Intro        string    `json:"intro"`
 }
 
+// PublishedIssue is a publication together with its issue number and notes.
+type PublishedIssue struct {
+	PublicationId PublicationId `json:"publicationId"`
+	AuthorId      UserId        `json:"authorId"`
+	IssueNumber   int64         `json:"issueNumber"`
+	CreationTime  time.Time     `json:"creationTime"`
+	Title         string        `json:"title"`
+	Intro         string        `json:"intro"`
+	IntroHtml     string        `json:"introHtml,omitempty"`
+	Notes         NotesById     `json:"notes"`
+	// NoteOrder lists the ids of the issue's notes in the order the author laid them out
+	NoteOrder []NoteId `json:"noteOrder"`
+	// UnreadCount is how many of the issue's notes the current user hasn't read yet
+	UnreadCount int `json:"unreadCount"`
+	// RetractedTime is set once the author retracted the issue, whose notes are then withheld
+	RetractedTime    *time.Time `json:"retractedTime,omitempty"`
+	RetractionReason string     `json:"retractionReason,omitempty"`
+}
+
+type RetractionOutcome string
+
+const (
+	// UNPUBLISHED issues were removed entirely and their notes returned to the author's unpublished notes
+	UNPUBLISHED RetractionOutcome = "unpublished"
+	// RETRACTED issues keep their number but their notes are withheld from readers
+	RETRACTED RetractionOutcome = "retracted"
+)
+
+// PublicationGracePeriod is how long after publishing an author can take back their latest issue as if it
+// had never been published.
+const PublicationGracePeriod = time.Minute * 15
+
 var NoNotesToPublishError = errors.New("There are no unpublished notes to publish")
+var NoPublicationFoundError = errors.New("No publication with that information could be found")
+var PublicationAlreadyRetractedError = errors.New("The publication has already been retracted")
+var RetractionReasonRequiredError = errors.New("Retracting an issue after the grace period requires a reason, which readers will see")
+
+// rankedPublicationsSql selects every publication along with its issue number, its rank among its author's publications.
+const rankedPublicationsSql = `
+	SELECT pub.id, pub.author_id, pub.creation_time, pub.retracted_time,
+		   Rank()
+			 OVER(
+			   partition BY pub.author_id
+			   ORDER BY pub.creation_time) AS rank
+	FROM   publication AS pub`
 
-func (db *DB) PublishNotes(userId UserId) error {
+// publicationCountsSql selects how many issues each user has published.
+// A user can read every issue whose number is at most their own count.
+const publicationCountsSql = `
+	SELECT app_user.id AS user_id, COUNT(publication.id) AS publication_count
+	FROM   app_user
+		   LEFT OUTER JOIN publication
+						ON publication.author_id = app_user.id
+	GROUP BY app_user.id`
+
+// PublishNotes publishes all of the author's unpublished notes, other than archived ones, as a new issue,
+// oldest first.
+func (db *DB) PublishNotes(userId UserId) (PublicationId, error) {
+	return db.PublishIssue(userId, &IssueDraft{})
+}
 
-	myUnpublishedNotes, err := db.GetMyUnpublishedNotes(userId)
+// RetractPublication takes back one of the author's issues. Within PublicationGracePeriod the latest issue is
+// removed and its notes become unpublished again. Any other issue could have unlocked later issues for its
+// readers, and removing it would renumber the author's later issues, so it is only marked as retracted, with
+// a reason readers are shown. A retracted issue still counts towards its author's publication count, but an
+// unpublished one doesn't: its author can no longer read other authors' issues with its number, even ones they
+// have already read, until they publish again.
+func (db *DB) RetractPublication(
+	authorId UserId,
+	publicationId PublicationId,
+	reason string,
+	now time.Time,
+) (RetractionOutcome, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer tx.Rollback()
 
-	if len(myUnpublishedNotes) == 0 {
-		return NoNotesToPublishError
+	sqlQuerySelect := `
+		SELECT pub.creation_time, pub.retracted_time IS NOT NULL, NOT EXISTS (
+			SELECT 1 FROM publication AS later
+			WHERE later.author_id = pub.author_id AND later.creation_time > pub.creation_time
+		)
+		FROM publication AS pub
+		WHERE pub.id = $1 AND pub.author_id = $2
+		FOR UPDATE`
+
+	var creationTime time.Time
+	var retracted bool
+	var latest bool
+	if err := tx.QueryRow(sqlQuerySelect, int64(publicationId), int64(authorId)).Scan(&creationTime, &retracted, &latest); err != nil {
+		if err == sql.ErrNoRows {
+			return "", NoPublicationFoundError
+		}
+		return "", convertPostgresError(err)
 	}
 
-	publicationId, err := db.StoreNewPublication(&Publication{AuthorId: userId, CreationTime: time.Now().UTC()})
+	if retracted {
+		return "", PublicationAlreadyRetractedError
+	}
 
-	sqlQuery := `
-		INSERT INTO note_to_publication_relationship (publication_id, note_id) 
-		VALUES ($1, $2)`
+	outcome := RETRACTED
+	if latest && now.Sub(creationTime) <= PublicationGracePeriod {
+		outcome = UNPUBLISHED
+	} else if len(reason) == 0 {
+		return "", RetractionReasonRequiredError
+	}
 
-	noteIds := make([]int64, len(myUnpublishedNotes))
+	// either way readers can no longer see the notes, so they can't keep them in their collections
+	sqlQueryCollections := `
+		DELETE FROM collection_note
+		WHERE note_id IN (
+			SELECT note_id FROM note_to_publication_relationship
+			WHERE publication_id = $1)`
 
-	{
-		i := 0
-		for noteId := range myUnpublishedNotes {
-			noteIds[i] = int64(noteId)
-			i++
-		}
+	if _, err := tx.Exec(sqlQueryCollections, int64(publicationId)); err != nil {
+		return "", convertPostgresError(err)
 	}
 
-	values := make([]interface{}, len(noteIds)*2, len(noteIds)*2)
-	{
-		values[0] = publicationId
-		values[1] = noteIds[0]
-		for index, noteId := range noteIds {
-			if index == 0 {
-				continue
-			}
-			sqlQuery += ", ($" + strconv.Itoa(2*index+1) + ", $" + strconv.Itoa((2*index)+2) + ")"
-			values[2*index] = publicationId
-			values[(2*index)+1] = noteId
+	switch outcome {
+	case UNPUBLISHED:
+		// the notes and notifications of the publication go with it
+		sqlQueryDelete := `
+			DELETE FROM publication
+			WHERE id = $1`
+
+		if _, err := tx.Exec(sqlQueryDelete, int64(publicationId)); err != nil {
+			return "", convertPostgresError(err)
 		}
-	}
 
-	rowAffected, err := db.execNoResults(sqlQuery, values...)
-	if err != nil {
-		return err
-	}
+	case RETRACTED:
+		sqlQueryRetract := `
+			UPDATE publication SET retracted_time = $2, retraction_reason = $3
+			WHERE id = $1`
+
+		if _, err := tx.Exec(sqlQueryRetract, int64(publicationId), now, reason); err != nil {
+			return "", convertPostgresError(err)
+		}
 
-	if rowAffected < 1 {
-		return errors.New("No values were inserted")
+		// nobody should be told about an issue they can no longer read
+		sqlQueryNotifications := `
+			DELETE FROM publication_notification
+			WHERE publication_id = $1 AND sent_time IS NULL`
+
+		if _, err := tx.Exec(sqlQueryNotifications, int64(publicationId)); err != nil {
+			return "", convertPostgresError(err)
+		}
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
 
+	return outcome, nil
 }
 
 func (db *DB) StoreNewPublication(publication *Publication) (PublicationId, error) {
+	return storeNewPublication(db, publication)
+}
 
+// sqlRowQueryer is satisfied by both *DB and *sql.Tx, so publications can be stored inside other transactions.
+type sqlRowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func storeNewPublication(queryer sqlRowQueryer, publication *Publication) (PublicationId, error) {
 	sqlQuery := `
-		INSERT INTO publication (author_id, creation_time)
-		VALUES ($1, $2)
+		INSERT INTO publication (author_id, creation_time, title, intro)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id`
 
 	var publicationId int64 = 0
-	if err := db.execOneResult(sqlQuery, &publicationId, int64(publication.AuthorId), publication.CreationTime); err != nil {
-		return 0, err
+	if err := queryer.QueryRow(
+		sqlQuery,
+		int64(publication.AuthorId),
+		publication.CreationTime,
+		publication.Title,
+		publication.Intro,
+	).Scan(&publicationId); err != nil {
+		return 0, convertPostgresError(err)
 	}
 
 	return PublicationId(publicationId), nil
 }
+
+// getPublicationCount returns how many issues the user has published.
+// A user can read every other author's issues up to and including this number.
+func (db *DB) getPublicationCount(userId UserId) (int64, error) {
+	sqlQuery := `
+		SELECT COUNT(*) AS IssueNumber FROM publication
+		WHERE publication.author_id = $1`
+
+	var publicationCount int64
+	if err := db.execOneResult(sqlQuery, &publicationCount, int64(userId)); err != nil {
+		return 0, err
+	}
+
+	return publicationCount, nil
+}
+
+// IsPublishedNoteVisibleTo reports whether the note is published in an issue the user is allowed to read.
+// Deleted notes are only tombstones and retracted issues are withdrawn, so neither are visible.
+func (db *DB) IsPublishedNoteVisibleTo(noteId NoteId, userId UserId) (bool, error) {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return false, err
+	}
+
+	sqlQuery := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM   (` + rankedPublicationsSql + `) ranked_pubs
+				   INNER JOIN note_to_publication_relationship AS note2pub
+						   ON note2pub.publication_id = ranked_pubs.id
+				   INNER JOIN note
+						   ON note.id = note2pub.note_id
+			WHERE  note2pub.note_id = $1
+				   AND ranked_pubs.rank <= $2
+				   AND ranked_pubs.retracted_time IS NULL
+				   AND note.deleted_time IS NULL)`
+
+	var visible bool
+	if err := db.execOneResult(sqlQuery, &visible, int64(noteId), publicationCount); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
+
+// GetPublishedIssuesVisibleBy returns the issues the user is allowed to read, newest first.
+// It follows the same rule as GetAllPublishedNotesVisibleBy, except that retracted issues are
+// included, without their notes, so that readers can see why they were retracted.
+func (db *DB) GetPublishedIssuesVisibleBy(userId UserId) ([]*PublishedIssue, error) {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT
+		ranked_pubs.id,
+		ranked_pubs.author_id,
+		ranked_pubs.rank AS publication_issue,
+		ranked_pubs.creation_time,
+		ranked_pubs.retracted_time,
+		COALESCE(pub.retraction_reason, ''),
+		pub.title,
+		pub.intro,
+		note.id,
+		note.author_id,
+		CASE WHEN note.deleted_time IS NULL THEN note.content ELSE '' END,
+		note.creation_time,
+		note.revision,
+		note.deleted_time,
+		note2pub.position
+		FROM   (` + rankedPublicationsSql + `) ranked_pubs
+			   INNER JOIN publication AS pub
+					   ON pub.id = ranked_pubs.id
+			   LEFT OUTER JOIN note_to_publication_relationship AS note2pub
+							ON note2pub.publication_id = ranked_pubs.id
+							   AND ranked_pubs.retracted_time IS NULL
+			   LEFT OUTER JOIN note
+							ON note.id = note2pub.note_id
+		WHERE  ranked_pubs.rank <= ($1)
+		ORDER BY ranked_pubs.creation_time DESC, note2pub.position NULLS LAST, note.creation_time, note.id`
+
+	rows, err := db.Query(sqlQuery, publicationCount)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	issues := make([]*PublishedIssue, 0)
+	issuesById := make(map[PublicationId]*PublishedIssue)
+
+	for rows.Next() {
+		var publicationId int64
+		var noteId sql.NullInt64
+		var noteAuthorId sql.NullInt64
+		var noteContent sql.NullString
+		var noteCreationTime *time.Time
+		var noteRevision sql.NullInt64
+		var notePosition sql.NullInt64
+		issue := &PublishedIssue{}
+		note := &Note{}
+		if err := rows.Scan(
+			&publicationId,
+			&issue.AuthorId,
+			&issue.IssueNumber,
+			&issue.CreationTime,
+			&issue.RetractedTime,
+			&issue.RetractionReason,
+			&issue.Title,
+			&issue.Intro,
+			&noteId,
+			&noteAuthorId,
+			&noteContent,
+			&noteCreationTime,
+			&noteRevision,
+			&note.DeletionTime,
+			&notePosition,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		if existingIssue, ok := issuesById[PublicationId(publicationId)]; ok {
+			issue = existingIssue
+		} else {
+			issue.PublicationId = PublicationId(publicationId)
+			issue.Notes = make(NotesById)
+			issue.NoteOrder = make([]NoteId, 0)
+			issuesById[issue.PublicationId] = issue
+			issues = append(issues, issue)
+		}
+
+		// retracted issues have a single row without a note
+		if !noteId.Valid {
+			continue
+		}
+
+		note.AuthorId = UserId(noteAuthorId.Int64)
+		note.Content = noteContent.String
+		note.CreationTime = *noteCreationTime
+		note.Revision = int(noteRevision.Int64)
+		note.IssueNumber = issue.IssueNumber
+		note.Position = len(issue.NoteOrder) + 1
+		issue.Notes[NoteId(noteId.Int64)] = note
+		issue.NoteOrder = append(issue.NoteOrder, NoteId(noteId.Int64))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return issues, nil
+}
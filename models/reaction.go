@@ -0,0 +1,105 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultReactions are offered when a deployment doesn't configure its own.
+var DefaultReactions = []string{"👍", "🤔", "💡"}
+
+// ReactionSummary is how many readers reacted to a note with a reaction, and whether the
+// current user was one of them.
+type ReactionSummary struct {
+	Reaction    string `json:"reaction"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reactedByMe"`
+}
+
+// ParseReactions reads a comma or space separated list of reactions, dropping duplicates.
+func ParseReactions(input string) []string {
+	reactions := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, reaction := range strings.FieldsFunc(input, func(character rune) bool {
+		return character == ',' || character == ' '
+	}) {
+		if !seen[reaction] {
+			seen[reaction] = true
+			reactions = append(reactions, reaction)
+		}
+	}
+
+	return reactions
+}
+
+//  DB methods
+
+// ToggleReaction adds the user's reaction to the note, or takes it back if they had already reacted
+// with it. It returns whether the user is now reacting.
+func (db *DB) ToggleReaction(noteId NoteId, userId UserId, reaction string) (bool, error) {
+	sqlQueryRemove := `
+		DELETE FROM note_reaction
+		WHERE note_id = $1 AND user_id = $2 AND reaction = $3`
+
+	rowsAffected, err := db.execNoResults(sqlQueryRemove, int64(noteId), int64(userId), reaction)
+	if err != nil {
+		return false, err
+	}
+
+	if rowsAffected > 0 {
+		return false, nil
+	}
+
+	sqlQueryAdd := `
+		INSERT INTO note_reaction (note_id, user_id, reaction, creation_time)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`
+
+	if _, err := db.execNoResults(sqlQueryAdd, int64(noteId), int64(userId), reaction, time.Now().UTC()); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetReactionSummaries counts the reactions to each of the given notes, in the order they were first used.
+// Notes without reactions are left out.
+func (db *DB) GetReactionSummaries(noteIds []NoteId, userId UserId) (map[NoteId][]*ReactionSummary, error) {
+	ids := make([]int64, len(noteIds))
+	for i, noteId := range noteIds {
+		ids[i] = int64(noteId)
+	}
+
+	sqlQuery := `
+		SELECT note_id, reaction, count(*), bool_or(user_id = $2)
+		FROM note_reaction
+		WHERE note_id = ANY($1)
+		GROUP BY note_id, reaction
+		ORDER BY note_id, min(creation_time)`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids), int64(userId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	summaries := make(map[NoteId][]*ReactionSummary)
+	for rows.Next() {
+		summary := &ReactionSummary{}
+		var noteId int64
+		if err := rows.Scan(&noteId, &summary.Reaction, &summary.Count, &summary.ReactedByMe); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		summaries[NoteId(noteId)] = append(summaries[NoteId(noteId)], summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,26 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+var parseReactionsTests = []struct {
+	input     string
+	reactions []string
+}{
+	{"👍,🤔,💡", []string{"👍", "🤔", "💡"}},
+	{" 👍, 🎉 ,,👍 ", []string{"👍", "🎉"}},
+	{"+1 heart", []string{"+1", "heart"}},
+	{"", []string{}},
+}
+
+func TestParseReactions(t *testing.T) {
+	for _, test := range parseReactionsTests {
+		t.Run(test.input, func(t *testing.T) {
+			test_util.Equals(t, test.reactions, models.ParseReactions(test.input))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UnreadCounts is how many of the notes a user can read they haven't read yet, in total, by author and by
+// category. Uncategorised notes are only counted in the total.
+type UnreadCounts struct {
+	Total      int                  `json:"total"`
+	ByAuthor   map[UserId]int       `json:"byAuthor"`
+	ByCategory map[NoteCategory]int `json:"byCategory"`
+}
+
+// unreadNotesSql selects the notes the user given by $1 has not read yet, among the notes of others published
+// in issues they can read. $2 is the user's publication count. Deleted notes have nothing left to read.
+const unreadNotesSql = `
+	SELECT note.id, note.author_id, ranked_pubs.id AS publication_id
+	FROM   (` + rankedPublicationsSql + `) ranked_pubs
+		   INNER JOIN note_to_publication_relationship AS note2pub
+				   ON note2pub.publication_id = ranked_pubs.id
+		   INNER JOIN note
+				   ON note.id = note2pub.note_id
+	WHERE  ranked_pubs.rank <= $2
+		   AND ranked_pubs.retracted_time IS NULL
+		   AND note.deleted_time IS NULL
+		   AND note.author_id <> $1
+		   AND NOT EXISTS (
+			   SELECT 1 FROM note_read
+			   WHERE note_read.user_id = $1 AND note_read.note_id = note.id)`
+
+//  DB methods
+
+// MarkNoteRead records that the user has read the note. Notes already read keep when they were first read.
+func (db *DB) MarkNoteRead(userId UserId, noteId NoteId, readTime time.Time) error {
+	sqlQuery := `
+		INSERT INTO note_read (user_id, note_id, read_time)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`
+
+	if _, err := db.execNoResults(sqlQuery, int64(userId), int64(noteId), readTime); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkNoteUnread takes back the user's read marker on the note.
+func (db *DB) MarkNoteUnread(userId UserId, noteId NoteId) error {
+	sqlQuery := `
+		DELETE FROM note_read
+		WHERE user_id = $1 AND note_id = $2`
+
+	if _, err := db.execNoResults(sqlQuery, int64(userId), int64(noteId)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkPublicationRead marks every note of the issue as read by the user, as long as they can read the issue.
+func (db *DB) MarkPublicationRead(userId UserId, publicationId PublicationId, readTime time.Time) error {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return err
+	}
+
+	sqlQueryVisible := `
+		SELECT EXISTS (
+			SELECT 1 FROM (` + rankedPublicationsSql + `) ranked_pubs
+			WHERE ranked_pubs.id = $1 AND ranked_pubs.rank <= $2)`
+
+	var visible bool
+	if err := db.execOneResult(sqlQueryVisible, &visible, int64(publicationId), publicationCount); err != nil {
+		return err
+	}
+
+	if !visible {
+		return NoPublicationFoundError
+	}
+
+	sqlQuery := `
+		INSERT INTO note_read (user_id, note_id, read_time)
+		SELECT $1, unread.id, $4
+		FROM   (` + unreadNotesSql + `) unread
+		WHERE  unread.publication_id = $3
+		ON CONFLICT DO NOTHING`
+
+	if _, err := db.execNoResults(sqlQuery, int64(userId), publicationCount, int64(publicationId), readTime); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every note the user can read as read.
+func (db *DB) MarkAllRead(userId UserId, readTime time.Time) error {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return err
+	}
+
+	sqlQuery := `
+		INSERT INTO note_read (user_id, note_id, read_time)
+		SELECT $1, unread.id, $3
+		FROM   (` + unreadNotesSql + `) unread
+		ON CONFLICT DO NOTHING`
+
+	if _, err := db.execNoResults(sqlQuery, int64(userId), publicationCount, readTime); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUnreadNoteIds returns the notes the user can read but hasn't yet.
+func (db *DB) GetUnreadNoteIds(userId UserId) (map[NoteId]bool, error) {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT unread.id FROM (`+unreadNotesSql+`) unread`, int64(userId), publicationCount)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	unreadNoteIds := make(map[NoteId]bool)
+	for rows.Next() {
+		var noteId int64
+		if err := rows.Scan(&noteId); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		unreadNoteIds[NoteId(noteId)] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return unreadNoteIds, nil
+}
+
+// GetUnreadCounts counts the notes the user can read but hasn't yet.
+func (db *DB) GetUnreadCounts(userId UserId) (*UnreadCounts, error) {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT unread.author_id, note2cat.category
+		FROM   (` + unreadNotesSql + `) unread
+			   LEFT OUTER JOIN note_to_category_relationship AS note2cat
+							ON note2cat.note_id = unread.id`
+
+	rows, err := db.Query(sqlQuery, int64(userId), publicationCount)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	counts := &UnreadCounts{
+		ByAuthor:   make(map[UserId]int),
+		ByCategory: make(map[NoteCategory]int),
+	}
+	for rows.Next() {
+		var authorId int64
+		var category sql.NullString
+		if err := rows.Scan(&authorId, &category); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		counts.Total++
+		counts.ByAuthor[UserId(authorId)]++
+		if category.Valid {
+			counts.ByCategory[NoteCategory(category.String)]++
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return counts, nil
+}
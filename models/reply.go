@@ -0,0 +1,278 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ReplyId int64
+
+var NoReplyFoundError = errors.New("No reply with that information could be found")
+var ReplyParentMismatchError = errors.New("Replies can only answer other replies to the same note")
+var ReplyDeletedError = errors.New("Deleted replies cannot be edited or replied to")
+
+// Reply is a comment on a published note, or on another reply to it. Deleted replies keep
+// their place in the thread so the replies to them still make sense, but lose their content.
+type Reply struct {
+	Id           ReplyId    `json:"id"`
+	NoteId       NoteId     `json:"noteId"`
+	ParentId     *ReplyId   `json:"parentId,omitempty"`
+	AuthorId     UserId     `json:"authorId"`
+	Content      string     `json:"content"`
+	CreationTime time.Time  `json:"creationTime"`
+	LastEditTime *time.Time `json:"lastEditTime,omitempty"`
+	Deleted      bool       `json:"deleted"`
+	Replies      []*Reply   `json:"replies"`
+}
+
+// NestReplies arranges replies, ordered oldest first, into threads under the replies they answer.
+func NestReplies(replies []*Reply) []*Reply {
+	repliesById := make(map[ReplyId]*Reply, len(replies))
+	for _, reply := range replies {
+		reply.Replies = make([]*Reply, 0)
+		repliesById[reply.Id] = reply
+	}
+
+	threads := make([]*Reply, 0)
+	for _, reply := range replies {
+		if reply.ParentId != nil {
+			if parent, ok := repliesById[*reply.ParentId]; ok {
+				parent.Replies = append(parent.Replies, reply)
+				continue
+			}
+		}
+		threads = append(threads, reply)
+	}
+
+	return threads
+}
+
+//  DB methods
+
+// StoreNewReply stores the reply, making sure any reply it answers is about the same note.
+func (db *DB) StoreNewReply(reply *Reply) (ReplyId, error) {
+	var parentId sql.NullInt64
+	if reply.ParentId != nil {
+		parent, err := db.GetReply(*reply.ParentId)
+		if err != nil {
+			return 0, err
+		}
+
+		if parent.NoteId != reply.NoteId {
+			return 0, ReplyParentMismatchError
+		}
+
+		if parent.Deleted {
+			return 0, ReplyDeletedError
+		}
+
+		parentId = sql.NullInt64{Int64: int64(parent.Id), Valid: true}
+	}
+
+	sqlQuery := `
+		INSERT INTO note_reply (note_id, parent_id, author_id, content, creation_time)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var replyId int64
+	if err := db.execOneResult(
+		sqlQuery,
+		&replyId,
+		int64(reply.NoteId),
+		parentId,
+		int64(reply.AuthorId),
+		reply.Content,
+		reply.CreationTime,
+	); err != nil {
+		return 0, err
+	}
+
+	return ReplyId(replyId), nil
+}
+
+func (db *DB) GetReply(replyId ReplyId) (*Reply, error) {
+	sqlQuery := `
+		SELECT id, note_id, parent_id, author_id, content, creation_time, last_edit_time, deleted_time IS NOT NULL
+		FROM note_reply
+		WHERE id = $1`
+
+	rows, err := db.Query(sqlQuery, int64(replyId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	replies, err := scanReplies(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(replies) == 0 {
+		return nil, NoReplyFoundError
+	}
+
+	return replies[0], nil
+}
+
+// GetReplies returns every reply to the note, oldest first.
+func (db *DB) GetReplies(noteId NoteId) ([]*Reply, error) {
+	sqlQuery := `
+		SELECT id, note_id, parent_id, author_id, content, creation_time, last_edit_time, deleted_time IS NOT NULL
+		FROM note_reply
+		WHERE note_id = $1
+		ORDER BY creation_time, id`
+
+	rows, err := db.Query(sqlQuery, int64(noteId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	return scanReplies(rows)
+}
+
+// GetRepliesForNotes returns the replies to each of the notes, oldest first, in one query.
+func (db *DB) GetRepliesForNotes(noteIds []NoteId) (map[NoteId][]*Reply, error) {
+	ids := make([]int64, len(noteIds))
+	for i, noteId := range noteIds {
+		ids[i] = int64(noteId)
+	}
+
+	sqlQuery := `
+		SELECT id, note_id, parent_id, author_id, content, creation_time, last_edit_time, deleted_time IS NOT NULL
+		FROM note_reply
+		WHERE note_id = ANY($1)
+		ORDER BY creation_time, id`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	replies, err := scanReplies(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	repliesByNote := make(map[NoteId][]*Reply)
+	for _, reply := range replies {
+		repliesByNote[reply.NoteId] = append(repliesByNote[reply.NoteId], reply)
+	}
+
+	return repliesByNote, nil
+}
+
+func scanReplies(rows *sql.Rows) ([]*Reply, error) {
+	replies := make([]*Reply, 0)
+	for rows.Next() {
+		reply := &Reply{}
+		var id int64
+		var noteId int64
+		var parentId sql.NullInt64
+		var lastEditTime pq.NullTime
+		if err := rows.Scan(
+			&id,
+			&noteId,
+			&parentId,
+			&reply.AuthorId,
+			&reply.Content,
+			&reply.CreationTime,
+			&lastEditTime,
+			&reply.Deleted,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		reply.Id = ReplyId(id)
+		reply.NoteId = NoteId(noteId)
+		if parentId.Valid {
+			replyParentId := ReplyId(parentId.Int64)
+			reply.ParentId = &replyParentId
+		}
+		if lastEditTime.Valid {
+			reply.LastEditTime = &lastEditTime.Time
+		}
+
+		replies = append(replies, reply)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return replies, nil
+}
+
+func (db *DB) UpdateReplyContent(replyId ReplyId, content string) error {
+	sqlQuery := `
+		UPDATE note_reply SET content = $2, last_edit_time = $3
+		WHERE id = $1 AND deleted_time IS NULL`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(replyId), content, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoReplyFoundError
+	}
+
+	return nil
+}
+
+// DeleteReply clears the reply's content and marks it deleted, leaving the replies to it in place.
+func (db *DB) DeleteReply(replyId ReplyId) error {
+	sqlQuery := `
+		UPDATE note_reply SET content = '', deleted_time = $2
+		WHERE id = $1 AND deleted_time IS NULL`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(replyId), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoReplyFoundError
+	}
+
+	return nil
+}
+
+// GetReplyCounts returns how many replies that haven't been deleted each of the given notes has.
+// Notes without replies are left out.
+func (db *DB) GetReplyCounts(noteIds []NoteId) (map[NoteId]int, error) {
+	ids := make([]int64, len(noteIds))
+	for i, noteId := range noteIds {
+		ids[i] = int64(noteId)
+	}
+
+	sqlQuery := `
+		SELECT note_id, count(*) FROM note_reply
+		WHERE note_id = ANY($1) AND deleted_time IS NULL
+		GROUP BY note_id`
+
+	rows, err := db.Query(sqlQuery, pq.Array(ids))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	counts := make(map[NoteId]int)
+	for rows.Next() {
+		var noteId int64
+		var count int
+		if err := rows.Scan(&noteId, &count); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		counts[NoteId(noteId)] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,39 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestNestReplies(t *testing.T) {
+	replyId := func(id int64) *models.ReplyId {
+		replyId := models.ReplyId(id)
+		return &replyId
+	}
+
+	replies := []*models.Reply{
+		{Id: 1},
+		{Id: 2, ParentId: replyId(1)},
+		{Id: 3},
+		{Id: 4, ParentId: replyId(2)},
+		{Id: 5, ParentId: replyId(1)},
+		// the reply this answered is missing, so it starts its own thread
+		{Id: 6, ParentId: replyId(42)},
+	}
+
+	threads := models.NestReplies(replies)
+
+	test_util.Equals(t, 3, len(threads))
+	test_util.Equals(t, models.ReplyId(1), threads[0].Id)
+	test_util.Equals(t, models.ReplyId(3), threads[1].Id)
+	test_util.Equals(t, models.ReplyId(6), threads[2].Id)
+
+	test_util.Equals(t, 2, len(threads[0].Replies))
+	test_util.Equals(t, models.ReplyId(2), threads[0].Replies[0].Id)
+	test_util.Equals(t, models.ReplyId(5), threads[0].Replies[1].Id)
+	test_util.Equals(t, models.ReplyId(4), threads[0].Replies[0].Replies[0].Id)
+
+	test_util.Equals(t, 0, len(threads[1].Replies))
+}
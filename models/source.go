@@ -0,0 +1,374 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type SourceId int64
+
+type SourceLocationType string
+
+const (
+	PAGE      SourceLocationType = "page"
+	CHAPTER   SourceLocationType = "chapter"
+	TIMESTAMP SourceLocationType = "timestamp"
+)
+
+var CannotDeserializeSourceLocationTypeStringError = errors.New("String does not correspond to a Source Location Type")
+var NoSourceFoundError = errors.New("No source with that information could be found")
+var MissingSourceTitleError = errors.New("Sources need a title")
+var InvalidIsbnError = errors.New("ISBNs must have 10 or 13 digits and a valid check digit")
+
+func DeserializeSourceLocationType(input string) (SourceLocationType, error) {
+	for _, locationType := range []SourceLocationType{PAGE, CHAPTER, TIMESTAMP} {
+		if input == string(locationType) {
+			return locationType, nil
+		}
+	}
+	return "", CannotDeserializeSourceLocationTypeStringError
+}
+
+// Source is something notes are written about, such as a book, paper or video.
+// Readers annotating the same thing share a single source.
+type Source struct {
+	Id           SourceId  `json:"id"`
+	Title        string    `json:"title"`
+	Author       string    `json:"author"`
+	Url          string    `json:"url"`
+	Isbn         string    `json:"isbn"`
+	Doi          string    `json:"doi"`
+	CreationTime time.Time `json:"creationTime"`
+}
+
+// SourceLocation is where in its source a note applies, e.g. page 12 or 1:02:03.
+type SourceLocation struct {
+	Type  SourceLocationType `json:"type,omitempty"`
+	Value string             `json:"value,omitempty"`
+}
+
+// NoteSource is the source a note is about, and where in it.
+type NoteSource struct {
+	Source   *Source         `json:"source"`
+	Location *SourceLocation `json:"location"`
+}
+
+// SourceNote is a note about a source.
+type SourceNote struct {
+	NoteId       NoteId          `json:"noteId"`
+	AuthorId     UserId          `json:"authorId"`
+	Content      string          `json:"content"`
+	CreationTime time.Time       `json:"creationTime"`
+	Location     *SourceLocation `json:"location"`
+}
+
+// Normalize cleans up the source's identifiers so the same work is always described the same way.
+// ISBN-10s are converted to ISBN-13s.
+func (source *Source) Normalize() error {
+	source.Title = strings.Join(strings.Fields(source.Title), " ")
+	source.Author = strings.Join(strings.Fields(source.Author), " ")
+	source.Url = strings.TrimSpace(source.Url)
+
+	if len(source.Title) == 0 {
+		return MissingSourceTitleError
+	}
+
+	if len(strings.TrimSpace(source.Isbn)) > 0 {
+		isbn, err := normalizeIsbn(source.Isbn)
+		if err != nil {
+			return err
+		}
+		source.Isbn = isbn
+	} else {
+		source.Isbn = ""
+	}
+
+	source.Doi = normalizeDoi(source.Doi)
+
+	return nil
+}
+
+// DedupKey identifies the work a normalized source describes, preferring the most specific identifier it has.
+func (source *Source) DedupKey() string {
+	if len(source.Isbn) > 0 {
+		return "isbn:" + source.Isbn
+	}
+
+	if len(source.Doi) > 0 {
+		return "doi:" + source.Doi
+	}
+
+	if len(source.Url) > 0 {
+		if parsedUrl, err := url.Parse(source.Url); err == nil && len(parsedUrl.Host) > 0 {
+			host := strings.TrimPrefix(strings.ToLower(parsedUrl.Host), "www.")
+			return "url:" + host + strings.TrimSuffix(parsedUrl.EscapedPath(), "/") + queryForDedup(parsedUrl)
+		}
+		return "url:" + source.Url
+	}
+
+	return "title:" + strings.ToLower(source.Title) + "|" + strings.ToLower(source.Author)
+}
+
+func queryForDedup(parsedUrl *url.URL) string {
+	if len(parsedUrl.RawQuery) == 0 {
+		return ""
+	}
+	return "?" + parsedUrl.Query().Encode()
+}
+
+func normalizeIsbn(input string) (string, error) {
+	digits := make([]byte, 0, 13)
+	for _, character := range strings.ToUpper(input) {
+		switch {
+		case character >= '0' && character <= '9', character == 'X':
+			digits = append(digits, byte(character))
+		case character == '-' || character == ' ':
+		default:
+			return "", InvalidIsbnError
+		}
+	}
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, digit := range digits {
+			value := int(digit - '0')
+			if digit == 'X' {
+				if i != 9 {
+					return "", InvalidIsbnError
+				}
+				value = 10
+			}
+			sum += value * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", InvalidIsbnError
+		}
+
+		isbn13 := append([]byte("978"), digits[:9]...)
+		return string(append(isbn13, isbn13CheckDigit(isbn13))), nil
+
+	case 13:
+		for _, digit := range digits {
+			if digit == 'X' {
+				return "", InvalidIsbnError
+			}
+		}
+		if isbn13CheckDigit(digits[:12]) != digits[12] {
+			return "", InvalidIsbnError
+		}
+		return string(digits), nil
+
+	default:
+		return "", InvalidIsbnError
+	}
+}
+
+func isbn13CheckDigit(digits []byte) byte {
+	sum := 0
+	for i, digit := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// normalizeDoi lowercases the DOI, which is case insensitive, and strips any resolver prefix.
+func normalizeDoi(input string) string {
+	doi := strings.ToLower(strings.TrimSpace(input))
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		doi = strings.TrimPrefix(doi, prefix)
+	}
+	return doi
+}
+
+//  DB methods
+
+// StoreSource stores the source unless the same work is already stored, in which case the
+// existing source is returned with any details it was missing filled in.
+func (db *DB) StoreSource(source *Source) (SourceId, error) {
+	if err := source.Normalize(); err != nil {
+		return 0, err
+	}
+
+	sqlQuery := `
+		INSERT INTO source (title, author, url, isbn, doi, dedup_key, creation_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (dedup_key) DO
+		UPDATE SET
+			author = CASE WHEN source.author = '' THEN EXCLUDED.author ELSE source.author END,
+			url = CASE WHEN source.url = '' THEN EXCLUDED.url ELSE source.url END,
+			isbn = CASE WHEN source.isbn = '' THEN EXCLUDED.isbn ELSE source.isbn END,
+			doi = CASE WHEN source.doi = '' THEN EXCLUDED.doi ELSE source.doi END
+		RETURNING id`
+
+	var sourceId int64
+	if err := db.execOneResult(
+		sqlQuery,
+		&sourceId,
+		source.Title,
+		source.Author,
+		source.Url,
+		source.Isbn,
+		source.Doi,
+		source.DedupKey(),
+		time.Now().UTC(),
+	); err != nil {
+		return 0, err
+	}
+
+	return SourceId(sourceId), nil
+}
+
+func (db *DB) GetSource(sourceId SourceId) (*Source, error) {
+	sqlQuery := `
+		SELECT id, title, author, url, isbn, doi, creation_time FROM source
+		WHERE id = $1`
+
+	source := &Source{}
+	var id int64
+	if err := db.QueryRow(sqlQuery, int64(sourceId)).Scan(
+		&id,
+		&source.Title,
+		&source.Author,
+		&source.Url,
+		&source.Isbn,
+		&source.Doi,
+		&source.CreationTime,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoSourceFoundError
+		}
+		return nil, convertPostgresError(err)
+	}
+	source.Id = SourceId(id)
+
+	return source, nil
+}
+
+func (db *DB) AttachNoteSource(noteId NoteId, sourceId SourceId, location *SourceLocation) error {
+	var locationType sql.NullString
+	if len(location.Type) > 0 {
+		locationType = sql.NullString{String: string(location.Type), Valid: true}
+	}
+
+	sqlQuery := `
+		INSERT INTO note_to_source_relationship (note_id, source_id, location_type, location)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (note_id) DO
+		UPDATE SET source_id = ($2), location_type = ($3), location = ($4)`
+
+	if _, err := db.execNoResults(sqlQuery, int64(noteId), int64(sourceId), locationType, location.Value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *DB) DetachNoteSource(noteId NoteId) error {
+	sqlQuery := `
+		DELETE FROM note_to_source_relationship
+		WHERE note_id = $1`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(noteId))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoSourceFoundError
+	}
+
+	return nil
+}
+
+func (db *DB) GetNoteSource(noteId NoteId) (*NoteSource, error) {
+	sqlQuery := `
+		SELECT source_id, location_type, location FROM note_to_source_relationship
+		WHERE note_id = $1`
+
+	var sourceId int64
+	var locationType sql.NullString
+	location := &SourceLocation{}
+	if err := db.QueryRow(sqlQuery, int64(noteId)).Scan(&sourceId, &locationType, &location.Value); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoSourceFoundError
+		}
+		return nil, convertPostgresError(err)
+	}
+	location.Type = SourceLocationType(locationType.String)
+
+	source, err := db.GetSource(SourceId(sourceId))
+	if err != nil {
+		return nil, err
+	}
+
+	return &NoteSource{Source: source, Location: location}, nil
+}
+
+// GetSourceNotesVisibleBy returns the notes about the source that the user wrote or is allowed to read, oldest first.
+func (db *DB) GetSourceNotesVisibleBy(sourceId SourceId, userId UserId) ([]*SourceNote, error) {
+	publicationCount, err := db.getPublicationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT
+		note.id,
+		note.author_id,
+		note.content,
+		note.creation_time,
+		note2source.location_type,
+		note2source.location
+		FROM   note_to_source_relationship AS note2source
+			   INNER JOIN note
+					   ON note.id = note2source.note_id
+			   LEFT OUTER JOIN note_to_publication_relationship AS note2pub
+							ON note2pub.note_id = note.id
+			   LEFT OUTER JOIN (` + rankedPublicationsSql + `) ranked_pubs
+							ON ranked_pubs.id = note2pub.publication_id
+		WHERE  note2source.source_id = $1
+			   AND note.deleted_time IS NULL
+			   AND (note.author_id = $2 OR (ranked_pubs.rank <= $3 AND ranked_pubs.retracted_time IS NULL))
+		ORDER BY note.creation_time`
+
+	rows, err := db.Query(sqlQuery, int64(sourceId), int64(userId), publicationCount)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	notes := make([]*SourceNote, 0)
+	for rows.Next() {
+		note := &SourceNote{Location: &SourceLocation{}}
+		var noteId int64
+		var locationType sql.NullString
+		if err := rows.Scan(
+			&noteId,
+			&note.AuthorId,
+			&note.Content,
+			&note.CreationTime,
+			&locationType,
+			&note.Location.Value,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		note.NoteId = NoteId(noteId)
+		note.Location.Type = SourceLocationType(locationType.String)
+
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return notes, nil
+}
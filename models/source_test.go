@@ -0,0 +1,42 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+func TestSourceDedupKey(t *testing.T) {
+	normalizedDedupKey := func(source *models.Source) string {
+		test_util.Ok(t, source.Normalize())
+		return source.DedupKey()
+	}
+
+	// an ISBN-10 and its ISBN-13 describe the same book
+	test_util.Equals(t,
+		normalizedDedupKey(&models.Source{Title: "Dune", Isbn: "0-441-17271-7"}),
+		normalizedDedupKey(&models.Source{Title: "Dune (Ace)", Isbn: "978-0441172719"}))
+
+	test_util.Equals(t,
+		"doi:10.1000/xyz123",
+		normalizedDedupKey(&models.Source{Title: "A paper", Doi: "https://doi.org/10.1000/XYZ123"}))
+
+	test_util.Equals(t,
+		normalizedDedupKey(&models.Source{Title: "A talk", Url: "https://www.example.com/talks/1/"}),
+		normalizedDedupKey(&models.Source{Title: "A talk", Url: "http://example.com/talks/1"}))
+
+	test_util.Equals(t,
+		normalizedDedupKey(&models.Source{Title: "  The   Odyssey ", Author: "Homer"}),
+		normalizedDedupKey(&models.Source{Title: "the odyssey", Author: "homer"}))
+}
+
+func TestSourceNormalizeRejectsInvalidSources(t *testing.T) {
+	test_util.Equals(t, models.MissingSourceTitleError, (&models.Source{Title: " ", Isbn: "9780441172719"}).Normalize())
+	test_util.Equals(t, models.InvalidIsbnError, (&models.Source{Title: "Dune", Isbn: "9780441172710"}).Normalize())
+	test_util.Equals(t, models.InvalidIsbnError, (&models.Source{Title: "Dune", Isbn: "12345"}).Normalize())
+
+	source := &models.Source{Title: "Zen and the Art of Motorcycle Maintenance", Isbn: "0-8044-2957-X"}
+	test_util.Ok(t, source.Normalize())
+	test_util.Equals(t, "9780804429573", source.Isbn)
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//  DB methods
+
+// TrashNote moves the note to its author's trash. A published note stays in its issue as a tombstone, but
+// is taken out of readers' collections, which only hold notes they can see. Restoring it doesn't put it back.
+func (db *DB) TrashNote(noteId NoteId, deletionTime time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := trashNote(tx, noteId, deletionTime); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func trashNote(tx *sql.Tx, noteId NoteId, deletionTime time.Time) error {
+	sqlQuery := `
+		UPDATE note SET deleted_time = $2
+		WHERE id = $1 AND deleted_time IS NULL`
+
+	result, err := tx.Exec(sqlQuery, int64(noteId), deletionTime)
+	if err != nil {
+		return convertPostgresError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return convertPostgresError(err)
+	}
+
+	if rowsAffected == 0 {
+		return NoNoteFoundError
+	}
+
+	sqlQueryCollections := `
+		DELETE FROM collection_note
+		WHERE note_id = $1`
+
+	if _, err := tx.Exec(sqlQueryCollections, int64(noteId)); err != nil {
+		return convertPostgresError(err)
+	}
+
+	return nil
+}
+
+// GetTrashedNotes returns the user's notes that are in the trash and not yet purged.
+func (db *DB) GetTrashedNotes(userId UserId) (NotesById, error) {
+	sqlQuery := `
+		SELECT id, author_id, content, creation_time, revision, deleted_time FROM note
+		WHERE author_id = $1 AND deleted_time IS NOT NULL AND purged_time IS NULL`
+
+	rows, err := db.Query(sqlQuery, int64(userId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	notes := make(NotesById)
+	for rows.Next() {
+		var noteId int64
+		note := &Note{}
+		if err := rows.Scan(&noteId, &note.AuthorId, &note.Content, &note.CreationTime, &note.Revision, &note.DeletionTime); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		notes[NoteId(noteId)] = note
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return notes, nil
+}
+
+// RestoreNote takes the note back out of the trash.
+func (db *DB) RestoreNote(noteId NoteId) error {
+	sqlQuery := `
+		UPDATE note SET deleted_time = NULL
+		WHERE id = $1 AND deleted_time IS NOT NULL AND purged_time IS NULL`
+
+	rowsAffected, err := db.execNoResults(sqlQuery, int64(noteId))
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoNoteFoundError
+	}
+
+	return nil
+}
+
+// PurgeNote permanently deletes the trashed note. It returns the note's attachments, whose content the
+// caller should remove from the blob store.
+func (db *DB) PurgeNote(noteId NoteId, purgeTime time.Time) ([]*Attachment, error) {
+	sqlQuery := `
+		SELECT id FROM note
+		WHERE id = $1 AND deleted_time IS NOT NULL AND purged_time IS NULL
+		FOR UPDATE`
+
+	return db.purgeNotes(sqlQuery, purgeTime, int64(noteId))
+}
+
+// PurgeTrashedNotes permanently deletes every note trashed before the given time. It returns the notes'
+// attachments, whose content the caller should remove from the blob store.
+func (db *DB) PurgeTrashedNotes(trashedBefore time.Time, purgeTime time.Time) ([]*Attachment, error) {
+	sqlQuery := `
+		SELECT id FROM note
+		WHERE deleted_time < $1 AND purged_time IS NULL
+		FOR UPDATE`
+
+	attachments, err := db.purgeNotes(sqlQuery, purgeTime, trashedBefore)
+	if err == NoNoteFoundError {
+		return make([]*Attachment, 0), nil
+	}
+
+	return attachments, err
+}
+
+// purgeNotes purges the notes the query selects. Unpublished notes are deleted outright. Published notes
+// would disappear from issues others have already read, so they keep their row as a tombstone with
+// everything that was written in them cleared.
+func (db *DB) purgeNotes(sqlQuerySelect string, purgeTime time.Time, args ...interface{}) ([]*Attachment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	noteIds, err := selectNoteIds(tx, sqlQuerySelect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(noteIds) == 0 {
+		return nil, NoNoteFoundError
+	}
+
+	sqlQueryAttachments := `
+		DELETE FROM note_attachment
+		WHERE note_id = ANY($1)
+		RETURNING id, note_id, uploader_id, file_name, content_type, size, blob_key, thumbnail_key, creation_time`
+
+	rows, err := tx.Query(sqlQueryAttachments, pq.Array(noteIds))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	attachments, err := scanAttachments(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQueryDelete := `
+		DELETE FROM note
+		WHERE id = ANY($1) AND NOT EXISTS (
+			SELECT 1 FROM note_to_publication_relationship AS note2pub
+			WHERE note2pub.note_id = note.id
+		)`
+
+	if _, err := tx.Exec(sqlQueryDelete, pq.Array(noteIds)); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	sqlQueryLinks := `
+		DELETE FROM note_link
+		WHERE source_note_id = ANY($1)`
+
+	if _, err := tx.Exec(sqlQueryLinks, pq.Array(noteIds)); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	sqlQueryTags := `
+		DELETE FROM note_tag
+		WHERE note_id = ANY($1)`
+
+	if _, err := tx.Exec(sqlQueryTags, pq.Array(noteIds)); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	sqlQueryTombstone := `
+		UPDATE note SET content = '', purged_time = $2, revision = revision + 1
+		WHERE id = ANY($1)`
+
+	if _, err := tx.Exec(sqlQueryTombstone, pq.Array(noteIds), purgeTime); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+func selectNoteIds(tx *sql.Tx, sqlQuery string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	noteIds := make([]int64, 0)
+	for rows.Next() {
+		var noteId int64
+		if err := rows.Scan(&noteId); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		noteIds = append(noteIds, noteId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return noteIds, nil
+}
@@ -14,6 +14,8 @@ type UserId int64
 
 type User struct {
 	DisplayName string `json:"displayName"`
+	// CreationTime is only filled in when listing every user
+	CreationTime time.Time `json:"-"`
 }
 
 // EmailAddress ensures that email addresses are always formatted properly within the backend.
@@ -124,7 +126,7 @@ func (db *DB) GetIdForUserWithEmailAddress(emailAddress *EmailAddress) (UserId,
 
 func (db *DB) GetAllUsersById() (UsersById, error) {
 	sqlQuery := `
-		SELECT id, display_name FROM app_user`
+		SELECT id, display_name, creation_time FROM app_user`
 
 	rows, err := db.Query(sqlQuery)
 	if err != nil {
@@ -138,7 +140,7 @@ func (db *DB) GetAllUsersById() (UsersById, error) {
 	for rows.Next() {
 		var tempId int64
 		user := &User{}
-		if err := rows.Scan(&tempId, &user.DisplayName); err != nil {
+		if err := rows.Scan(&tempId, &user.DisplayName, &user.CreationTime); err != nil {
 			return nil, convertPostgresError(err)
 		}
 
@@ -0,0 +1,468 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WebhookSubscriptionId int64
+
+type WebhookDeliveryId int64
+
+type WebhookEventType string
+
+const (
+	NOTE_CREATED          WebhookEventType = "note.created"
+	NOTE_UPDATED          WebhookEventType = "note.updated"
+	NOTE_DELETED          WebhookEventType = "note.deleted"
+	NOTE_RESTORED         WebhookEventType = "note.restored"
+	NOTE_CATEGORIZED      WebhookEventType = "note.categorized"
+	PUBLICATION_CREATED   WebhookEventType = "publication.created"
+	PUBLICATION_RETRACTED WebhookEventType = "publication.retracted"
+	PREDICTION_RESOLVED   WebhookEventType = "prediction.resolved"
+	PING                  WebhookEventType = "ping"
+)
+
+var webhookEventTypes = []WebhookEventType{
+	NOTE_CREATED,
+	NOTE_UPDATED,
+	NOTE_DELETED,
+	NOTE_RESTORED,
+	NOTE_CATEGORIZED,
+	PUBLICATION_CREATED,
+	PUBLICATION_RETRACTED,
+	PREDICTION_RESOLVED,
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DELIVERY_PENDING   WebhookDeliveryStatus = "pending"
+	DELIVERY_DELIVERED WebhookDeliveryStatus = "delivered"
+	DELIVERY_FAILED    WebhookDeliveryStatus = "failed"
+)
+
+var CannotDeserializeWebhookEventTypeStringError = errors.New("String does not correspond to a Webhook Event Type")
+var NoWebhookSubscriptionFoundError = errors.New("No webhook subscription with that information could be found")
+var NoWebhookDeliveryFoundError = errors.New("No webhook delivery with that information could be found")
+
+func DeserializeWebhookEventType(input string) (WebhookEventType, error) {
+	for _, eventType := range webhookEventTypes {
+		if input == string(eventType) {
+			return eventType, nil
+		}
+	}
+	return "", CannotDeserializeWebhookEventTypeStringError
+}
+
+// WebhookEvent describes something that happened to a note or publication.
+// Subscribers receive it when it concerns their own notes, or a note or publication they can read.
+type WebhookEvent struct {
+	Type          WebhookEventType `json:"type"`
+	AuthorId      UserId           `json:"authorId"`
+	NoteId        NoteId           `json:"noteId,omitempty"`
+	PublicationId PublicationId    `json:"publicationId,omitempty"`
+	CreationTime  time.Time        `json:"creationTime"`
+	Data          interface{}      `json:"data,omitempty"`
+}
+
+type WebhookSubscription struct {
+	Id           WebhookSubscriptionId `json:"id"`
+	UserId       UserId                `json:"userId"`
+	Url          string                `json:"url"`
+	Secret       string                `json:"secret,omitempty"`
+	EventTypes   []WebhookEventType    `json:"eventTypes"`
+	CreationTime time.Time             `json:"creationTime"`
+}
+
+type WebhookDelivery struct {
+	Id              WebhookDeliveryId     `json:"id"`
+	SubscriptionId  WebhookSubscriptionId `json:"subscriptionId"`
+	EventType       WebhookEventType      `json:"eventType"`
+	Payload         string                `json:"payload"`
+	Status          WebhookDeliveryStatus `json:"status"`
+	Attempts        int                   `json:"attempts"`
+	NextAttemptTime time.Time             `json:"nextAttemptTime"`
+	LastStatusCode  int                   `json:"lastStatusCode,omitempty"`
+	LastError       string                `json:"lastError,omitempty"`
+	CreationTime    time.Time             `json:"creationTime"`
+	DeliveredTime   *time.Time            `json:"deliveredTime,omitempty"`
+
+	// Url and Secret are copied from the subscription so the delivery can be sent on its own.
+	Url    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+//  DB methods
+
+// StoreNewWebhookSubscription stores the subscription with a newly generated signing secret.
+func (db *DB) StoreNewWebhookSubscription(subscription *WebhookSubscription) (WebhookSubscriptionId, error) {
+	secret, err := generateRandomToken()
+	if err != nil {
+		return 0, err
+	}
+	subscription.Secret = secret
+
+	eventTypes := make([]string, len(subscription.EventTypes))
+	for i, eventType := range subscription.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+
+	sqlQuery := `
+		INSERT INTO webhook_subscription (user_id, url, secret, event_types, creation_time)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var subscriptionId int64
+	if err := db.execOneResult(
+		sqlQuery,
+		&subscriptionId,
+		int64(subscription.UserId),
+		subscription.Url,
+		secret,
+		pq.Array(eventTypes),
+		subscription.CreationTime,
+	); err != nil {
+		return 0, err
+	}
+
+	return WebhookSubscriptionId(subscriptionId), nil
+}
+
+func (db *DB) GetUsersWebhookSubscriptions(userId UserId) ([]*WebhookSubscription, error) {
+	sqlQuery := `
+		SELECT id, user_id, url, event_types, creation_time FROM webhook_subscription
+		WHERE user_id = $1
+		ORDER BY id`
+
+	rows, err := db.Query(sqlQuery, int64(userId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*WebhookSubscription, 0)
+	for rows.Next() {
+		var subscriptionId int64
+		var eventTypes []string
+		subscription := &WebhookSubscription{}
+		if err := rows.Scan(
+			&subscriptionId,
+			&subscription.UserId,
+			&subscription.Url,
+			pq.Array(&eventTypes),
+			&subscription.CreationTime,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		subscription.Id = WebhookSubscriptionId(subscriptionId)
+		subscription.EventTypes = make([]WebhookEventType, len(eventTypes))
+		for i, eventType := range eventTypes {
+			subscription.EventTypes[i] = WebhookEventType(eventType)
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription deletes the subscription, as long as it belongs to the user.
+func (db *DB) DeleteWebhookSubscription(userId UserId, subscriptionId WebhookSubscriptionId) error {
+	sqlQuery := `
+		DELETE FROM webhook_subscription
+		WHERE id = $1 AND user_id = $2`
+
+	num, err := db.execNoResults(sqlQuery, int64(subscriptionId), int64(userId))
+	if err != nil {
+		return err
+	}
+
+	if num == 0 {
+		return NoWebhookSubscriptionFoundError
+	}
+
+	return nil
+}
+
+// eventAudienceSql defines an audience table of the users entitled to see an event,
+// given its author ($1), publication ($2) and note ($3). Readers can't see retracted issues,
+// so events about them only go to their author.
+const eventAudienceSql = `
+		ranked_pubs AS (` + rankedPublicationsSql + `
+		), publication_counts AS (` + publicationCountsSql + `
+		), event_publication AS (
+			SELECT ranked_pubs.rank
+			FROM   ranked_pubs
+			WHERE  (ranked_pubs.id = $2
+					OR ranked_pubs.id = (SELECT publication_id FROM note_to_publication_relationship
+										 WHERE note_id = $3))
+				   AND ranked_pubs.retracted_time IS NULL
+		), audience AS (
+			SELECT $1::bigint AS user_id
+			UNION
+			SELECT publication_counts.user_id
+			FROM   publication_counts
+				   INNER JOIN event_publication
+						   ON publication_counts.publication_count >= event_publication.rank
+		)`
+
+// QueueWebhookDeliveries queues the event for every subscription to its type whose owner is
+// the event's author or, when the event concerns a published note or a publication, can read it.
+func (db *DB) QueueWebhookDeliveries(event *WebhookEvent) error {
+	if event.CreationTime.IsZero() {
+		event.CreationTime = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	sqlQuery := `
+		WITH ` + eventAudienceSql + `
+		INSERT INTO webhook_delivery (subscription_id, event_type, payload, status, attempts, next_attempt_time, creation_time)
+		SELECT sub.id, $4, $5, $7, 0, $6, $6
+		FROM   webhook_subscription AS sub
+			   INNER JOIN audience
+					   ON audience.user_id = sub.user_id
+		WHERE  $4 = ANY(sub.event_types)`
+
+	_, err = db.execNoResults(
+		sqlQuery,
+		int64(event.AuthorId),
+		int64(event.PublicationId),
+		int64(event.NoteId),
+		string(event.Type),
+		string(payload),
+		event.CreationTime,
+		string(DELIVERY_PENDING),
+	)
+
+	return err
+}
+
+// GetEventAudience returns the users entitled to see the event: its author and, when it concerns
+// a published note or a publication, everyone who can read that publication.
+func (db *DB) GetEventAudience(event *WebhookEvent) ([]UserId, error) {
+	sqlQuery := `
+		WITH ` + eventAudienceSql + `
+		SELECT user_id FROM audience`
+
+	rows, err := db.Query(sqlQuery, int64(event.AuthorId), int64(event.PublicationId), int64(event.NoteId))
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	audience := make([]UserId, 0)
+	for rows.Next() {
+		var userId int64
+		if err := rows.Scan(&userId); err != nil {
+			return nil, convertPostgresError(err)
+		}
+		audience = append(audience, UserId(userId))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return audience, nil
+}
+
+// QueueWebhookPing queues a ping to the user's subscription so its endpoint can be tested.
+func (db *DB) QueueWebhookPing(userId UserId, subscriptionId WebhookSubscriptionId) (WebhookDeliveryId, error) {
+	now := time.Now().UTC()
+
+	payload, err := json.Marshal(&WebhookEvent{Type: PING, AuthorId: userId, CreationTime: now})
+	if err != nil {
+		return 0, err
+	}
+
+	sqlQuery := `
+		INSERT INTO webhook_delivery (subscription_id, event_type, payload, status, attempts, next_attempt_time, creation_time)
+		SELECT sub.id, $3, $4, $5, 0, $6, $6
+		FROM   webhook_subscription AS sub
+		WHERE  sub.id = $1 AND sub.user_id = $2
+		RETURNING id`
+
+	var deliveryId int64
+	if err := db.execOneResult(
+		sqlQuery,
+		&deliveryId,
+		int64(subscriptionId),
+		int64(userId),
+		string(PING),
+		string(payload),
+		string(DELIVERY_PENDING),
+		now,
+	); err != nil {
+		if err == QueryResultContainedNoRowsError {
+			return 0, NoWebhookSubscriptionFoundError
+		}
+		return 0, err
+	}
+
+	return WebhookDeliveryId(deliveryId), nil
+}
+
+// GetDueWebhookDeliveries returns up to limit pending deliveries whose next attempt is due, oldest first.
+func (db *DB) GetDueWebhookDeliveries(now time.Time, limit int) ([]*WebhookDelivery, error) {
+	sqlQuery := `
+		SELECT
+		delivery.id,
+		delivery.subscription_id,
+		delivery.event_type,
+		delivery.payload,
+		delivery.status,
+		delivery.attempts,
+		delivery.next_attempt_time,
+		delivery.last_status_code,
+		delivery.last_error,
+		delivery.creation_time,
+		delivery.delivered_time,
+		sub.url,
+		sub.secret
+		FROM   webhook_delivery AS delivery
+			   INNER JOIN webhook_subscription AS sub
+					   ON sub.id = delivery.subscription_id
+		WHERE  delivery.status = $1 AND delivery.next_attempt_time <= $2
+		ORDER BY delivery.next_attempt_time
+		LIMIT $3`
+
+	return db.getWebhookDeliveries(sqlQuery, string(DELIVERY_PENDING), now.UTC(), limit)
+}
+
+// GetWebhookDeliveries returns the most recent deliveries to the user's subscription, newest first.
+func (db *DB) GetWebhookDeliveries(userId UserId, subscriptionId WebhookSubscriptionId) ([]*WebhookDelivery, error) {
+	sqlQuery := `
+		SELECT
+		delivery.id,
+		delivery.subscription_id,
+		delivery.event_type,
+		delivery.payload,
+		delivery.status,
+		delivery.attempts,
+		delivery.next_attempt_time,
+		delivery.last_status_code,
+		delivery.last_error,
+		delivery.creation_time,
+		delivery.delivered_time,
+		sub.url,
+		sub.secret
+		FROM   webhook_delivery AS delivery
+			   INNER JOIN webhook_subscription AS sub
+					   ON sub.id = delivery.subscription_id
+		WHERE  sub.id = $1 AND sub.user_id = $2
+		ORDER BY delivery.creation_time DESC
+		LIMIT 100`
+
+	return db.getWebhookDeliveries(sqlQuery, int64(subscriptionId), int64(userId))
+}
+
+func (db *DB) getWebhookDeliveries(sqlQuery string, args ...interface{}) ([]*WebhookDelivery, error) {
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, convertPostgresError(err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		var deliveryId int64
+		var subscriptionId int64
+		var eventType string
+		var status string
+		var lastStatusCode sql.NullInt64
+		var lastError sql.NullString
+		var deliveredTime pq.NullTime
+		delivery := &WebhookDelivery{}
+		if err := rows.Scan(
+			&deliveryId,
+			&subscriptionId,
+			&eventType,
+			&delivery.Payload,
+			&status,
+			&delivery.Attempts,
+			&delivery.NextAttemptTime,
+			&lastStatusCode,
+			&lastError,
+			&delivery.CreationTime,
+			&deliveredTime,
+			&delivery.Url,
+			&delivery.Secret,
+		); err != nil {
+			return nil, convertPostgresError(err)
+		}
+
+		delivery.Id = WebhookDeliveryId(deliveryId)
+		delivery.SubscriptionId = WebhookSubscriptionId(subscriptionId)
+		delivery.EventType = WebhookEventType(eventType)
+		delivery.Status = WebhookDeliveryStatus(status)
+		delivery.LastStatusCode = int(lastStatusCode.Int64)
+		delivery.LastError = lastError.String
+		if deliveredTime.Valid {
+			delivery.DeliveredTime = &deliveredTime.Time
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, convertPostgresError(err)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery stores the outcome of a delivery attempt.
+func (db *DB) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	sqlQuery := `
+		UPDATE webhook_delivery SET
+		status = ($2),
+		attempts = ($3),
+		next_attempt_time = ($4),
+		last_status_code = ($5),
+		last_error = ($6),
+		delivered_time = ($7)
+		WHERE id = ($1)`
+
+	var deliveredTime pq.NullTime
+	if delivery.DeliveredTime != nil {
+		deliveredTime = pq.NullTime{Time: *delivery.DeliveredTime, Valid: true}
+	}
+
+	rowsAffected, err := db.execNoResults(
+		sqlQuery,
+		int64(delivery.Id),
+		string(delivery.Status),
+		delivery.Attempts,
+		delivery.NextAttemptTime.UTC(),
+		sql.NullInt64{Int64: int64(delivery.LastStatusCode), Valid: delivery.LastStatusCode != 0},
+		sql.NullString{String: delivery.LastError, Valid: len(delivery.LastError) > 0},
+		deliveredTime,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return NoWebhookDeliveryFoundError
+	}
+
+	if rowsAffected > 1 {
+		return TooManyRowsAffectedError
+	}
+
+	return nil
+}
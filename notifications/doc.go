@@ -0,0 +1,8 @@
+/*
+Package notifications emails users when issues become readable by them.
+
+Notifications are recorded by the models package when notes are published.
+A Notifier periodically sends them, either immediately or as a daily digest
+depending on each user's preferences, through a pluggable MailSender.
+*/
+package notifications
@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"sort"
+	"strings"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+	Headers map[string]string
+}
+
+// MailSender delivers a single email.
+type MailSender interface {
+	Send(*Mail) error
+}
+
+// LogMailSender writes emails to the log instead of sending them. It is useful for local development.
+type LogMailSender struct{}
+
+func (sender *LogMailSender) Send(mail *Mail) error {
+	log.Printf("mail to %s: %s\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+// SmtpMailSender sends emails through an SMTP server.
+type SmtpMailSender struct {
+	// Address is the host:port of the SMTP server.
+	Address string
+	From    string
+	Auth    smtp.Auth
+}
+
+func (sender *SmtpMailSender) Send(mail *Mail) error {
+	return smtp.SendMail(sender.Address, sender.Auth, sender.From, []string{mail.To}, sender.Format(mail))
+}
+
+// Format returns the message as it is sent to the SMTP server, headers included.
+func (sender *SmtpMailSender) Format(mail *Mail) []byte {
+	message := &bytes.Buffer{}
+
+	headers := map[string]string{
+		"From":                      sender.From,
+		"To":                        mail.To,
+		"Subject":                   mail.Subject,
+		"MIME-Version":              "1.0",
+		"Content-Type":              "text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding": "8bit",
+	}
+	for name, value := range mail.Headers {
+		headers[name] = value
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(message, "%s: %s\r\n", name, headerValue(headers[name]))
+	}
+	fmt.Fprint(message, "\r\n", mail.Body)
+
+	return message.Bytes()
+}
+
+// headerValue keeps a header value on its own line, so that text from users, like the display names in subjects,
+// can't add headers or recipients. Anything outside printable ASCII is encoded as RFC 2047 requires.
+func headerValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, value)
+
+	return mime.QEncoding.Encode("UTF-8", value)
+}
@@ -0,0 +1,158 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/paths"
+)
+
+const digestInterval = time.Hour * 24
+
+// Notifier sends users the notifications recorded for them.
+type Notifier struct {
+	Db     models.Datastore
+	Sender MailSender
+	// BaseUrl is used to build links back to the site, e.g. "https://cerealnotes.herokuapp.com".
+	BaseUrl string
+}
+
+// Run sends pending notifications and prediction reminders every pollInterval, forever.
+func (notifier *Notifier) Run(pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := notifier.SendPendingNotifications(now); err != nil {
+			log.Print(err)
+		}
+
+		if err := notifier.SendPredictionReminders(now); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// SendPendingNotifications emails every user whose notifications are due at the given time.
+// Users who asked for a daily digest are emailed at most once a day and users who
+// unsubscribed have their notifications discarded.
+func (notifier *Notifier) SendPendingNotifications(now time.Time) error {
+	recipients, err := notifier.Db.GetNotificationRecipients()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, recipient := range recipients {
+		if recipient.Preferences.Frequency == models.DAILY &&
+			now.Sub(recipient.Preferences.LastDigestTime) < digestInterval {
+			continue
+		}
+
+		if recipient.Preferences.Frequency != models.NEVER {
+			if err := notifier.Sender.Send(notifier.composeMail(recipient)); err != nil {
+				log.Printf("could not notify user %d: %s", recipient.UserId, err)
+				lastErr = err
+				continue
+			}
+		}
+
+		publicationIds := make([]models.PublicationId, len(recipient.Notifications))
+		for i, notification := range recipient.Notifications {
+			publicationIds[i] = notification.PublicationId
+		}
+
+		if err := notifier.Db.MarkNotificationsSent(recipient.UserId, publicationIds, now); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// SendPredictionReminders asks the authors of unresolved predictions whose resolution date has
+// passed to resolve them. Each prediction is reminded about once, and not at all to users who unsubscribed.
+func (notifier *Notifier) SendPredictionReminders(now time.Time) error {
+	reminders, err := notifier.Db.GetDuePredictionReminders(now)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, reminder := range reminders {
+		preferences, err := notifier.Db.GetNotificationPreferences(reminder.AuthorId)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if preferences.Frequency != models.NEVER {
+			if err := notifier.Sender.Send(notifier.composeReminderMail(reminder, preferences)); err != nil {
+				log.Printf("could not remind user %d: %s", reminder.AuthorId, err)
+				lastErr = err
+				continue
+			}
+		}
+
+		if err := notifier.Db.MarkPredictionReminderSent(reminder.NoteId, now); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (notifier *Notifier) unsubscribeUrl(preferences *models.NotificationPreferences) string {
+	return notifier.BaseUrl + paths.UnsubscribePage + "?" +
+		url.Values{"token": []string{preferences.UnsubscribeToken}}.Encode()
+}
+
+func (notifier *Notifier) composeMail(recipient *models.NotificationRecipient) *Mail {
+	unsubscribeUrl := notifier.unsubscribeUrl(recipient.Preferences)
+
+	var subject string
+	if len(recipient.Notifications) == 1 {
+		notification := recipient.Notifications[0]
+		subject = fmt.Sprintf("%s's issue %d is ready for you", notification.AuthorDisplayName, notification.IssueNumber)
+	} else {
+		subject = fmt.Sprintf("%d new issues are ready for you", len(recipient.Notifications))
+	}
+
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, "Hi %s,\n\n", recipient.DisplayName)
+	fmt.Fprint(body, "New issues are ready for you on CerealNotes:\n\n")
+	for _, notification := range recipient.Notifications {
+		fmt.Fprintf(body, "  * %s, issue %d\n", notification.AuthorDisplayName, notification.IssueNumber)
+	}
+	fmt.Fprintf(body, "\nRead them at %s%s\n", notifier.BaseUrl, paths.NotesPage)
+	fmt.Fprintf(body, "\nTo stop receiving these emails, visit %s\n", unsubscribeUrl)
+
+	return &Mail{
+		To:      recipient.EmailAddress,
+		Subject: "CerealNotes: " + subject,
+		Body:    body.String(),
+		Headers: map[string]string{"List-Unsubscribe": "<" + unsubscribeUrl + ">"},
+	}
+}
+
+func (notifier *Notifier) composeReminderMail(reminder *models.PredictionReminder, preferences *models.NotificationPreferences) *Mail {
+	unsubscribeUrl := notifier.unsubscribeUrl(preferences)
+
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, "Hi %s,\n\n", reminder.DisplayName)
+	fmt.Fprintf(body, "Your prediction was due to resolve on %s:\n\n", reminder.ResolutionDate.Format("January 2, 2006"))
+	fmt.Fprintf(body, "  %q (%.0f%%)\n", reminder.Content, reminder.Probability*100)
+	fmt.Fprintf(body, "\nResolve it at %s%s\n", notifier.BaseUrl, paths.NotesPage)
+	fmt.Fprintf(body, "\nTo stop receiving these emails, visit %s\n", unsubscribeUrl)
+
+	return &Mail{
+		To:      reminder.EmailAddress,
+		Subject: "CerealNotes: time to resolve your prediction",
+		Body:    body.String(),
+		Headers: map[string]string{"List-Unsubscribe": "<" + unsubscribeUrl + ">"},
+	}
+}
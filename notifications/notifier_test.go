@@ -0,0 +1,157 @@
+package notifications_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/notifications"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+// notificationStore only implements the Datastore methods the notifier uses.
+type notificationStore struct {
+	models.Datastore
+	recipients []*models.NotificationRecipient
+	sentTo     map[models.UserId][]models.PublicationId
+
+	reminders     []*models.PredictionReminder
+	preferences   map[models.UserId]*models.NotificationPreferences
+	remindedAbout []models.NoteId
+}
+
+func (store *notificationStore) GetNotificationRecipients() ([]*models.NotificationRecipient, error) {
+	return store.recipients, nil
+}
+
+func (store *notificationStore) MarkNotificationsSent(userId models.UserId, publicationIds []models.PublicationId, sentTime time.Time) error {
+	store.sentTo[userId] = publicationIds
+	return nil
+}
+
+func (store *notificationStore) GetDuePredictionReminders(now time.Time) ([]*models.PredictionReminder, error) {
+	return store.reminders, nil
+}
+
+func (store *notificationStore) GetNotificationPreferences(userId models.UserId) (*models.NotificationPreferences, error) {
+	return store.preferences[userId], nil
+}
+
+func (store *notificationStore) MarkPredictionReminderSent(noteId models.NoteId, sentTime time.Time) error {
+	store.remindedAbout = append(store.remindedAbout, noteId)
+	return nil
+}
+
+type recordingMailSender struct {
+	mails []*notifications.Mail
+}
+
+func (sender *recordingMailSender) Send(mail *notifications.Mail) error {
+	sender.mails = append(sender.mails, mail)
+	return nil
+}
+
+func newRecipient(userId models.UserId, frequency models.NotificationFrequency, lastDigestTime time.Time) *models.NotificationRecipient {
+	return &models.NotificationRecipient{
+		UserId:       userId,
+		DisplayName:  "bob",
+		EmailAddress: "bob@gmail.com",
+		Preferences: &models.NotificationPreferences{
+			Frequency:        frequency,
+			UnsubscribeToken: "anUnsubscribeToken",
+			LastDigestTime:   lastDigestTime,
+		},
+		Notifications: []*models.PublicationNotification{
+			{PublicationId: 3, AuthorDisplayName: "alice", IssueNumber: 2},
+		},
+	}
+}
+
+func TestSendPendingNotifications(t *testing.T) {
+	now := time.Now()
+
+	store := &notificationStore{
+		recipients: []*models.NotificationRecipient{
+			newRecipient(1, models.IMMEDIATE, now.Add(-time.Minute)),
+			newRecipient(2, models.DAILY, now.Add(-time.Hour)),
+			newRecipient(3, models.DAILY, now.Add(-25*time.Hour)),
+			newRecipient(4, models.NEVER, time.Time{}),
+		},
+		sentTo: make(map[models.UserId][]models.PublicationId),
+	}
+	sender := &recordingMailSender{}
+
+	notifier := &notifications.Notifier{Db: store, Sender: sender, BaseUrl: "https://cerealnotes.example"}
+
+	err := notifier.SendPendingNotifications(now)
+	test_util.Ok(t, err)
+
+	// the immediate and overdue daily users are emailed
+	test_util.Equals(t, 2, len(sender.mails))
+
+	// the daily user who was emailed an hour ago waits, the unsubscribed user's notifications are discarded
+	_, waited := store.sentTo[2]
+	test_util.Assert(t, !waited, "Expected the daily digest to wait")
+	test_util.Equals(t, []models.PublicationId{3}, store.sentTo[4])
+
+	mail := sender.mails[0]
+	test_util.Equals(t, "bob@gmail.com", mail.To)
+	test_util.Assert(t, strings.Contains(mail.Subject, "alice's issue 2"), "Unexpected subject %q", mail.Subject)
+	test_util.Assert(t,
+		strings.Contains(mail.Body, "https://cerealnotes.example/unsubscribe?token=anUnsubscribeToken"),
+		"Expected an unsubscribe link in %q", mail.Body)
+}
+
+func TestSendPredictionReminders(t *testing.T) {
+	now := time.Now()
+
+	store := &notificationStore{
+		reminders: []*models.PredictionReminder{
+			{NoteId: 7, AuthorId: 1, DisplayName: "bob", EmailAddress: "bob@gmail.com", Content: "It will rain", Probability: 0.7, ResolutionDate: now},
+			{NoteId: 8, AuthorId: 2, DisplayName: "alice", EmailAddress: "alice@gmail.com", Content: "It won't", Probability: 0.2, ResolutionDate: now},
+		},
+		preferences: map[models.UserId]*models.NotificationPreferences{
+			1: {Frequency: models.DAILY, UnsubscribeToken: "anUnsubscribeToken"},
+			2: {Frequency: models.NEVER},
+		},
+	}
+	sender := &recordingMailSender{}
+
+	notifier := &notifications.Notifier{Db: store, Sender: sender, BaseUrl: "https://cerealnotes.example"}
+
+	err := notifier.SendPredictionReminders(now)
+	test_util.Ok(t, err)
+
+	// unsubscribed authors are not emailed, but their reminders are still used up
+	test_util.Equals(t, 1, len(sender.mails))
+	test_util.Equals(t, []models.NoteId{7, 8}, store.remindedAbout)
+
+	mail := sender.mails[0]
+	test_util.Equals(t, "bob@gmail.com", mail.To)
+	test_util.Assert(t, strings.Contains(mail.Body, `"It will rain" (70%)`), "Expected the prediction in %q", mail.Body)
+}
+
+func TestSmtpMailFormat(t *testing.T) {
+	sender := &notifications.SmtpMailSender{From: "notes@cerealnotes.example"}
+
+	message := string(sender.Format(&notifications.Mail{
+		To:      "bob@gmail.com",
+		Subject: "Mallory\r\nBcc: victim@example.com\r\n's issue 1 is ready for you",
+		Body:    "Hi bob,\n",
+		Headers: map[string]string{"List-Unsubscribe": "<https://cerealnotes.example/unsubscribe>"},
+	}))
+
+	headers := strings.Split(strings.SplitN(message, "\r\n\r\n", 2)[0], "\r\n")
+
+	// display names end up in subjects, but can't add headers of their own
+	for _, header := range headers {
+		test_util.Assert(t, !strings.HasPrefix(header, "Bcc:"), "Expected no Bcc header in %q", message)
+	}
+	test_util.Assert(t, strings.Contains(message, "\r\nSubject: Mallory  Bcc: victim@example.com  's issue 1 is ready for you\r\n"), "Expected the subject on one line in %q", message)
+	test_util.Assert(t, strings.Contains(message, "\r\nTo: bob@gmail.com\r\n"), "Expected the recipient as is in %q", message)
+	test_util.Assert(t, strings.HasSuffix(message, "\r\n\r\nHi bob,\n"), "Expected the body after the headers in %q", message)
+
+	message = string(sender.Format(&notifications.Mail{To: "bob@gmail.com", Subject: "Zoë's issue 2 is ready for you"}))
+	test_util.Assert(t, strings.Contains(message, "\r\nSubject: =?UTF-8?q?Zo=C3=AB's_issue_2_is_ready_for_you?=\r\n"), "Expected an encoded subject in %q", message)
+}
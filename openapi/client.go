@@ -0,0 +1,480 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// clientRuntime is the part of a generated client that doesn't depend on the document. ERROR_BODY is replaced
+// with the type error responses are decoded into.
+const clientRuntime = `
+// Client calls the api at BaseUrl. Its HttpClient needs a cookie jar to stay logged in between calls.
+type Client struct {
+	BaseUrl    string
+	HttpClient *http.Client
+}
+
+// NewClient returns a client for the api at the base url, which keeps the session cookie between calls.
+func NewClient(baseUrl string) (*Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{BaseUrl: strings.TrimSuffix(baseUrl, "/"), HttpClient: &http.Client{Jar: jar}}, nil
+}
+
+// Error is returned for a response with an unexpected status. Body is the error the api described, if it
+// described one.
+type Error struct {
+	StatusCode int
+	Body       ERROR_BODY
+	raw        []byte
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.StatusCode, http.StatusText(err.StatusCode), strings.TrimSpace(string(err.raw)))
+}
+
+func (client *Client) do(method string, path string, query url.Values, body interface{}, status int, result interface{}) error {
+	var requestBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(encoded)
+	}
+
+	requestUrl := client.BaseUrl + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequest(method, requestUrl, requestBody)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := client.HttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != status {
+		apiError := &Error{StatusCode: response.StatusCode, raw: raw}
+		if strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+			var errorBody ERROR_BODY
+			if json.Unmarshal(raw, &errorBody) == nil {
+				apiError.Body = errorBody
+			}
+		}
+		return apiError
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(raw, result)
+}
+`
+
+// reservedClientNames are declared by every generated client, so no schema can share them.
+var reservedClientNames = map[string]bool{"Client": true, "NewClient": true, "Error": true}
+
+var methodConstants = map[string]string{
+	"GET":    "http.MethodGet",
+	"PUT":    "http.MethodPut",
+	"POST":   "http.MethodPost",
+	"DELETE": "http.MethodDelete",
+	"PATCH":  "http.MethodPatch",
+	"HEAD":   "http.MethodHead",
+}
+
+type clientGenerator struct {
+	document *Document
+	buffer   bytes.Buffer
+}
+
+// GenerateClient returns the source of a Go package with a client for the document's operations that aren't
+// deprecated, and a type for each of its component schemas. Error responses are decoded into the schema of
+// the operations' default response.
+func GenerateClient(document *Document, packageName string) ([]byte, error) {
+	generator := &clientGenerator{document: document}
+
+	errorBody := "interface{}"
+	var err error
+	document.Operations(func(method string, path string, operation *Operation) {
+		if operation.Deprecated || err != nil {
+			return
+		}
+
+		if response, ok := operation.Responses["default"]; ok {
+			if media, ok := response.Content[jsonMediaType]; ok {
+				errorBody = generator.goType(media.Schema, true)
+			}
+		}
+
+		err = generator.operation(method, path, operation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := generator.types(); err != nil {
+		return nil, err
+	}
+
+	declarations := strings.Replace(clientRuntime, "ERROR_BODY", errorBody, -1) + generator.buffer.String()
+
+	imports := []string{
+		"bytes",
+		"encoding/json",
+		"fmt",
+		"io",
+		"io/ioutil",
+		"net/http",
+		"net/http/cookiejar",
+		"net/url",
+		"strings",
+	}
+	if strings.Contains(declarations, "time.Time") {
+		imports = append(imports, "time")
+	}
+
+	source := new(bytes.Buffer)
+	fmt.Fprintf(source, "// Code generated by openapi.GenerateClient; DO NOT EDIT.\n\npackage %s\n\nimport (\n", packageName)
+	for _, path := range imports {
+		fmt.Fprintf(source, "\t%q\n", path)
+	}
+	fmt.Fprintf(source, ")\n%s", declarations)
+
+	return format.Source(source.Bytes())
+}
+
+func (generator *clientGenerator) printf(format string, arguments ...interface{}) {
+	fmt.Fprintf(&generator.buffer, format, arguments...)
+}
+
+// operation writes the method calling the operation, and the type of its query parameters if it has any.
+func (generator *clientGenerator) operation(method string, path string, operation *Operation) error {
+	name := exportName(operation.OperationId)
+	if len(name) == 0 {
+		return fmt.Errorf("openapi: %s %s has no operation id", method, path)
+	}
+
+	methodConstant, ok := methodConstants[method]
+	if !ok {
+		methodConstant = strconv.Quote(method)
+	}
+
+	arguments := make([]string, 0)
+	queryParameters := make([]*Parameter, 0)
+	pathParameters := make(map[string]*Parameter)
+	for _, parameter := range operation.Parameters {
+		switch parameter.In {
+		case "path":
+			pathParameters[parameter.Name] = parameter
+		case "query":
+			queryParameters = append(queryParameters, parameter)
+		}
+	}
+
+	// the path is built up from its segments, with the parameters as arguments in the order they appear
+	pathExpression := make([]string, 0)
+	fixed := ""
+	for _, segment := range strings.Split(path, "/")[1:] {
+		fixed += "/"
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			fixed += segment
+			continue
+		}
+
+		parameterName := segment[1 : len(segment)-1]
+		parameter, ok := pathParameters[parameterName]
+		if !ok {
+			return fmt.Errorf("openapi: %s %s doesn't describe its %s parameter", method, path, parameterName)
+		}
+
+		argument := unexportName(parameterName)
+		arguments = append(arguments, argument+" "+generator.goType(parameter.Schema, false))
+		pathExpression = append(pathExpression, strconv.Quote(fixed), "url.PathEscape(fmt.Sprint("+argument+"))")
+		fixed = ""
+	}
+	if len(fixed) > 0 {
+		pathExpression = append(pathExpression, strconv.Quote(fixed))
+	}
+
+	query := "nil"
+	if len(queryParameters) > 0 {
+		generator.printf("\n// %sParams are the query parameters of %s. Those left empty aren't sent.\n", name, name)
+		generator.printf("type %sParams struct {\n", name)
+		for _, parameter := range queryParameters {
+			if len(parameter.Description) > 0 {
+				generator.printf("// %s\n", parameter.Description)
+			}
+			generator.printf("%s %s\n", exportName(parameter.Name), generator.goType(parameter.Schema, false))
+		}
+		generator.printf("}\n")
+
+		arguments = append(arguments, "params *"+name+"Params")
+		query = "query"
+	}
+
+	body := "nil"
+	if operation.RequestBody != nil {
+		media, ok := operation.RequestBody.Content[jsonMediaType]
+		if !ok {
+			return fmt.Errorf("openapi: %s %s only takes JSON bodies", method, path)
+		}
+
+		arguments = append(arguments, "body "+generator.goType(media.Schema, true))
+		body = "body"
+	}
+
+	status, result := 0, ""
+	for code, response := range operation.Responses {
+		responseStatus, err := strconv.Atoi(code)
+		if err != nil || responseStatus < 200 || responseStatus >= 300 || (status != 0 && responseStatus > status) {
+			continue
+		}
+
+		status, result = responseStatus, ""
+		if media, ok := response.Content[jsonMediaType]; ok {
+			result = generator.goType(media.Schema, true)
+		}
+	}
+	if status == 0 {
+		return fmt.Errorf("openapi: %s %s has no successful response", method, path)
+	}
+
+	generator.printf("\n// %s\n//\n// It calls %s %s.\n", sentence(name, operation.Summary), method, path)
+	if len(result) > 0 {
+		generator.printf("func (client *Client) %s(%s) (%s, error) {\n", name, strings.Join(arguments, ", "), result)
+	} else {
+		generator.printf("func (client *Client) %s(%s) error {\n", name, strings.Join(arguments, ", "))
+	}
+
+	if len(queryParameters) > 0 {
+		generator.printf("query := url.Values{}\nif params != nil {\n")
+		for _, parameter := range queryParameters {
+			field := "params." + exportName(parameter.Name)
+			switch parameter.Schema.Type {
+			case "boolean":
+				generator.printf("if %s {\nquery.Set(%q, \"true\")\n}\n", field, parameter.Name)
+			case "string":
+				generator.printf("if len(%s) > 0 {\nquery.Set(%q, %s)\n}\n", field, parameter.Name, field)
+			default:
+				generator.printf("if %s != 0 {\nquery.Set(%q, fmt.Sprint(%s))\n}\n", field, parameter.Name, field)
+			}
+		}
+		generator.printf("}\n\n")
+	}
+
+	// a nil pointer has to reach do as a nil interface, for there to be no body
+	if body == "body" && strings.HasPrefix(generator.goType(operation.RequestBody.Content[jsonMediaType].Schema, true), "*") {
+		generator.printf("var requestBody interface{}\nif body != nil {\nrequestBody = body\n}\n\n")
+		body = "requestBody"
+	}
+
+	call := fmt.Sprintf("client.do(%s, %s, %s, %s, %d", methodConstant, strings.Join(pathExpression, "+"), query, body, status)
+	if len(result) > 0 {
+		generator.printf("var result %s\nerr := %s, &result)\nreturn result, err\n}\n", result, call)
+	} else {
+		generator.printf("return %s, nil)\n}\n", call)
+	}
+
+	return nil
+}
+
+// types writes a type for each of the component schemas, in order of name.
+func (generator *clientGenerator) types() error {
+	names := make([]string, 0, len(generator.document.Components.Schemas))
+	for name := range generator.document.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if reservedClientNames[name] {
+			return fmt.Errorf("openapi: the %s schema has the name of a type every client declares", name)
+		}
+
+		schema := generator.document.Components.Schemas[name]
+
+		generator.printf("\n")
+		if len(schema.Description) > 0 {
+			generator.printf("// %s\n", sentence(name+" is", schema.Description))
+		}
+
+		if schema.Type != "object" || schema.Properties == nil {
+			generator.printf("type %s %s\n", name, generator.goType(schema, false))
+			continue
+		}
+
+		required := make(map[string]bool, len(schema.Required))
+		for _, property := range schema.Required {
+			required[property] = true
+		}
+
+		properties := make([]string, 0, len(schema.Properties))
+		for property := range schema.Properties {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+
+		generator.printf("type %s struct {\n", name)
+		for _, property := range properties {
+			tag := property
+			if !required[property] {
+				tag += ",omitempty"
+			}
+
+			propertySchema := schema.Properties[property]
+			if len(propertySchema.Description) > 0 {
+				generator.printf("// %s\n", propertySchema.Description)
+			}
+			generator.printf("%s %s `json:%q`\n", exportName(property), generator.goType(propertySchema, !required[property]), tag)
+		}
+		generator.printf("}\n")
+	}
+
+	return nil
+}
+
+// goType returns the Go type of values matching the schema. Optional values of a component schema are
+// pointers, as are those of date-times, whose zero value json would otherwise still write.
+func (generator *clientGenerator) goType(schema *Schema, optional bool) string {
+	if len(schema.Ref) > 0 {
+		name := strings.TrimPrefix(schema.Ref, componentSchemaPrefix)
+		if optional {
+			return "*" + name
+		}
+		return name
+	}
+
+	if len(schema.AllOf) == 1 {
+		return generator.goType(schema.AllOf[0], optional || schema.Nullable)
+	}
+
+	if len(schema.OneOf) > 0 || len(schema.AllOf) > 0 {
+		return "json.RawMessage"
+	}
+
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			if optional || schema.Nullable {
+				return "*time.Time"
+			}
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+
+	case "integer":
+		if schema.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+
+	case "number":
+		if schema.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+
+	case "boolean":
+		return "bool"
+
+	case "array":
+		return "[]" + generator.goType(schema.Items, false)
+
+	case "object":
+		if additionalProperties, ok := schema.AdditionalProperties.(*Schema); ok && schema.Properties == nil {
+			return "map[string]" + generator.goType(additionalProperties, false)
+		}
+		return "map[string]interface{}"
+
+	default:
+		return "interface{}"
+	}
+}
+
+// exportName turns a name from the document, such as noteId, into an exported Go identifier, such as NoteId.
+func exportName(name string) string {
+	identifier := identifierFrom(name)
+	if len(identifier) == 0 {
+		return ""
+	}
+
+	return strings.ToUpper(identifier[:1]) + identifier[1:]
+}
+
+func unexportName(name string) string {
+	identifier := identifierFrom(name)
+	if len(identifier) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(identifier[:1]) + identifier[1:]
+}
+
+// identifierFrom drops the characters of the name that can't be in an identifier, capitalising the letter
+// after each, so that note-category becomes noteCategory.
+func identifierFrom(name string) string {
+	identifier := make([]rune, 0, len(name))
+	capitalise := false
+	for _, character := range name {
+		if !unicode.IsLetter(character) && !unicode.IsDigit(character) {
+			capitalise = len(identifier) > 0
+			continue
+		}
+
+		if len(identifier) == 0 && unicode.IsDigit(character) {
+			continue
+		}
+
+		if capitalise {
+			character = unicode.ToUpper(character)
+			capitalise = false
+		}
+		identifier = append(identifier, character)
+	}
+
+	return string(identifier)
+}
+
+// sentence starts a doc comment with the name of what it documents, so that the summary "Lists the notes."
+// of ListNotes becomes "ListNotes lists the notes.", and the description "A note." of Note, introduced as
+// "Note is", becomes "Note is a note."
+func sentence(name string, description string) string {
+	description = strings.TrimSpace(description)
+	if len(description) == 0 {
+		return name + "."
+	}
+
+	if !strings.HasSuffix(description, ".") {
+		description += "."
+	}
+
+	return name + " " + strings.ToLower(description[:1]) + description[1:]
+}
@@ -0,0 +1,9 @@
+/*
+Package openapi describes the api as an OpenAPI 3 document.
+
+Schemas are generated from the Go types the handlers decode and encode, so the
+document changes along with them. The document can check that a response
+matches what it describes, and can generate a Go client for the operations it
+lists.
+*/
+package openapi
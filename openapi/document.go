@@ -0,0 +1,168 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+// Document is an OpenAPI document, holding only the parts of the specification the api uses.
+type Document struct {
+	OpenApi    string                           `json:"openapi"`
+	Info       *Info                            `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components *Components                      `json:"components"`
+	Security   []map[string][]string            `json:"security,omitempty"`
+
+	// componentTypes are the Go types of the component schemas, by name
+	componentTypes map[string]reflect.Type
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// NewDocument returns an empty document for the api with the given title and version.
+func NewDocument(title string, version string) *Document {
+	return &Document{
+		OpenApi:    Version,
+		Info:       &Info{Title: title, Version: version},
+		Paths:      make(map[string]map[string]*Operation),
+		Components: &Components{Schemas: make(map[string]*Schema)},
+	}
+}
+
+// AddOperation adds the operation answering the method at the path, whose parameters are in braces.
+func (document *Document) AddOperation(method string, path string, operation *Operation) {
+	pathItem, ok := document.Paths[path]
+	if !ok {
+		pathItem = make(map[string]*Operation)
+		document.Paths[path] = pathItem
+	}
+
+	pathItem[strings.ToLower(method)] = operation
+}
+
+// FindOperation returns the operation answering the method at the request path, along with the path it's
+// documented under. Where several paths match, as /notes/batch and /notes/{id} do, the one with the most fixed
+// segments wins, as it does when routing.
+func (document *Document) FindOperation(method string, requestPath string) (*Operation, string, bool) {
+	segments := strings.Split(strings.TrimSuffix(requestPath, "/"), "/")
+
+	var bestOperation *Operation
+	bestPath := ""
+	bestFixedSegments := -1
+
+	for path, pathItem := range document.Paths {
+		operation, ok := pathItem[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+
+		fixedSegments, matched := matchPath(strings.Split(path, "/"), segments)
+		if matched && fixedSegments > bestFixedSegments {
+			bestOperation = operation
+			bestPath = path
+			bestFixedSegments = fixedSegments
+		}
+	}
+
+	return bestOperation, bestPath, bestOperation != nil
+}
+
+func matchPath(pathSegments []string, segments []string) (int, bool) {
+	if len(pathSegments) != len(segments) {
+		return 0, false
+	}
+
+	fixedSegments := 0
+	for i, pathSegment := range pathSegments {
+		if strings.HasPrefix(pathSegment, "{") && strings.HasSuffix(pathSegment, "}") {
+			if len(segments[i]) == 0 {
+				return 0, false
+			}
+			continue
+		}
+
+		if pathSegment != segments[i] {
+			return 0, false
+		}
+		fixedSegments++
+	}
+
+	return fixedSegments, true
+}
+
+// Operations calls the function with each operation, in order of path and then method, so that whatever is
+// built from them comes out the same every time.
+func (document *Document) Operations(function func(method string, path string, operation *Operation)) {
+	paths := make([]string, 0, len(document.Paths))
+	for path := range document.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pathItem := document.Paths[path]
+		for _, method := range methodOrder {
+			if operation, ok := pathItem[method]; ok {
+				function(strings.ToUpper(method), path, operation)
+			}
+		}
+	}
+}
+
+var methodOrder = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
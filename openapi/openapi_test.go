@@ -0,0 +1,130 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/openapi"
+	"github.com/atmiguel/cerealnotes/test_util"
+)
+
+type testTag struct {
+	Name string `json:"name"`
+}
+
+type testItem struct {
+	Id        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Created   time.Time  `json:"created"`
+	Removed   *time.Time `json:"removed,omitempty"`
+	Tags      []*testTag `json:"tags"`
+	Parent    *testItem  `json:"parent,omitempty"`
+	Extra     map[string]int
+	internal  string
+	Forgotten string `json:"-"`
+}
+
+func newTestDocument() (*openapi.Document, *openapi.Schema) {
+	document := openapi.NewDocument("Test", "1")
+	schema := document.SchemaOf(map[int64]*testItem{})
+
+	document.AddOperation(http.MethodGet, "/items/{id}", &openapi.Operation{
+		OperationId: "getItem",
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "OK", Content: map[string]*openapi.MediaType{
+				"application/json": {Schema: document.SchemaOf(testItem{})},
+			}},
+			"default": {Description: "Error", Content: map[string]*openapi.MediaType{
+				"text/plain": {Schema: &openapi.Schema{Type: "string"}},
+			}},
+		},
+	})
+	document.AddOperation(http.MethodGet, "/items/latest", &openapi.Operation{
+		OperationId: "getLatestItem",
+		Responses:   map[string]*openapi.Response{"204": {Description: "No Content"}},
+	})
+
+	return document, schema
+}
+
+func TestSchemaOf(t *testing.T) {
+	document, schema := newTestDocument()
+
+	test_util.Equals(t, "object", schema.Type)
+	test_util.Equals(t, "#/components/schemas/testItem", schema.AdditionalProperties.(*openapi.Schema).Ref)
+
+	item := document.Components.Schemas["testItem"]
+	test_util.Equals(t, []string{"id", "title", "created", "tags", "Extra"}, item.Required)
+	test_util.Equals(t, &openapi.Schema{Type: "string", Format: "date-time"}, item.Properties["created"])
+	test_util.Equals(t, &openapi.Schema{Type: "string", Format: "date-time"}, item.Properties["removed"])
+	test_util.Equals(t, "#/components/schemas/testTag", item.Properties["tags"].Items.Ref)
+	test_util.Assert(t, item.Properties["tags"].Nullable, "Expected a slice that is always written to be nullable")
+	test_util.Equals(t, "#/components/schemas/testItem", item.Properties["parent"].Ref)
+	test_util.Equals(t, 7, len(item.Properties))
+	test_util.Equals(t, "testItem", openapi.ComponentName(&testItem{}))
+}
+
+var validateTests = []struct {
+	name  string
+	json  string
+	error string
+}{
+	{"valid", `{"1": {"id": 1, "title": "a", "created": "2018-01-02T03:04:05Z", "tags": [{"name": "x"}], "Extra": {"b": 2}}}`, ""},
+	{"null slice", `{"1": {"id": 1, "title": "a", "created": "2018-01-02T03:04:05Z", "tags": null, "Extra": null}}`, ""},
+	{"missing property", `{"1": {"id": 1, "created": "2018-01-02T03:04:05Z", "tags": [], "Extra": {}}}`, "$.1: is missing title"},
+	{"undocumented property", `{"1": {"id": 1, "title": "a", "created": "2018-01-02T03:04:05Z", "tags": [], "Extra": {}, "other": 1}}`, "$.1: has the undocumented property other"},
+	{"wrong type", `{"1": {"id": "1", "title": "a", "created": "2018-01-02T03:04:05Z", "tags": [], "Extra": {}}}`, "$.1.id: is a string, where the schema has type integer"},
+	{"not an integer", `{"1": {"id": 1.5, "title": "a", "created": "2018-01-02T03:04:05Z", "tags": [], "Extra": {}}}`, "$.1.id: 1.5 is not an integer"},
+	{"bad date", `{"1": {"id": 1, "title": "a", "created": "yesterday", "tags": [], "Extra": {}}}`, `$.1.created: "yesterday" is not a date-time`},
+	{"null item", `{"1": {"id": 1, "title": "a", "created": "2018-01-02T03:04:05Z", "tags": [null], "Extra": {}}}`, "$.1.tags[0]: is null"},
+}
+
+func TestValidate(t *testing.T) {
+	document, schema := newTestDocument()
+
+	for _, test := range validateTests {
+		t.Run(test.name, func(t *testing.T) {
+			var value interface{}
+			decoder := json.NewDecoder(strings.NewReader(test.json))
+			decoder.UseNumber()
+			test_util.Ok(t, decoder.Decode(&value))
+
+			err := document.Validate(schema, value)
+			if len(test.error) == 0 {
+				test_util.Ok(t, err)
+			} else {
+				test_util.Assert(t, err != nil, "Expected an error")
+				test_util.Equals(t, test.error, err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	document, _ := newTestDocument()
+
+	// fixed segments win over parameters
+	operation, path, ok := document.FindOperation(http.MethodGet, "/items/latest")
+	test_util.Assert(t, ok, "Expected an operation")
+	test_util.Equals(t, "getLatestItem", operation.OperationId)
+	test_util.Equals(t, "/items/latest", path)
+
+	item := `{"id": 1, "title": "a", "created": "2018-01-02T03:04:05Z", "tags": [], "Extra": {}}`
+	test_util.Ok(t, document.ValidateResponse(http.MethodGet, "/items/1", 200, "application/json", []byte(item)))
+	test_util.Ok(t, document.ValidateResponse(http.MethodGet, "/items/1", 404, "text/plain; charset=utf-8", []byte("missing")))
+	test_util.Ok(t, document.ValidateResponse(http.MethodGet, "/items/latest", 204, "", nil))
+	test_util.Ok(t, document.ValidateResponse(http.MethodPost, "/items/1", 200, "text/html", []byte("undescribed")))
+
+	for _, err := range []error{
+		document.ValidateResponse(http.MethodGet, "/items/1", 201, "application/json", []byte(item)),
+		document.ValidateResponse(http.MethodGet, "/items/1", 200, "text/plain", []byte(item)),
+		document.ValidateResponse(http.MethodGet, "/items/1", 200, "application/json", []byte(`{"id": 1}`)),
+		document.ValidateResponse(http.MethodGet, "/items/latest", 204, "text/plain", []byte("unexpected")),
+		document.ValidateResponse(http.MethodGet, "/items/latest", 404, "text/plain", []byte("missing")),
+	} {
+		test_util.Assert(t, err != nil, "Expected the response not to match")
+	}
+}
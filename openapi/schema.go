@@ -0,0 +1,189 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema describes a JSON value. AdditionalProperties is either the *Schema of the values of a map, or false
+// for an object that has only the properties listed.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+const componentSchemaPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf returns the schema of the JSON encoding of the value's type, following its json tags. Named struct
+// types are added to the document's components and referred to by their name.
+func (document *Document) SchemaOf(value interface{}) *Schema {
+	return document.schemaOf(reflect.TypeOf(value))
+}
+
+// ComponentName returns the name the value's type is referred to by once SchemaOf added it to the components.
+func ComponentName(value interface{}) string {
+	valueType := reflect.TypeOf(value)
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	return valueType.Name()
+}
+
+func (document *Document) schemaOf(valueType reflect.Type) *Schema {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if valueType == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch valueType.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		// byte slices are encoded as base64 strings
+		if valueType.Kind() == reflect.Slice && valueType.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: document.schemaOf(valueType.Elem())}
+
+	case reflect.Map:
+		// json writes integer keys as strings, so every map is an object
+		return &Schema{Type: "object", AdditionalProperties: document.schemaOf(valueType.Elem())}
+
+	case reflect.Interface:
+		return &Schema{}
+
+	case reflect.Struct:
+		if len(valueType.Name()) == 0 {
+			return document.objectSchema(valueType)
+		}
+		return document.componentSchema(valueType)
+
+	default:
+		panic(fmt.Sprintf("openapi: %s has no JSON encoding", valueType))
+	}
+}
+
+// componentSchema adds the struct type to the components, unless it's already there, and refers to it.
+func (document *Document) componentSchema(valueType reflect.Type) *Schema {
+	name := valueType.Name()
+	ref := &Schema{Ref: componentSchemaPrefix + name}
+
+	if document.componentTypes == nil {
+		document.componentTypes = make(map[string]reflect.Type)
+	}
+
+	if existingType, ok := document.componentTypes[name]; ok {
+		if existingType != valueType {
+			panic(fmt.Sprintf("openapi: both %s and %s are named %s", existingType, valueType, name))
+		}
+		return ref
+	}
+
+	// recorded before its fields, so that types referring back to it find it
+	document.componentTypes[name] = valueType
+	document.Components.Schemas[name] = document.objectSchema(valueType)
+
+	return ref
+}
+
+func (document *Document) objectSchema(valueType reflect.Type) *Schema {
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma:]
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// the fields of untagged embedded structs are encoded as if they were the outer struct's own
+		if field.Anonymous && len(name) == 0 && fieldType.Kind() == reflect.Struct {
+			embedded := document.objectSchema(fieldType)
+			for propertyName, property := range embedded.Properties {
+				schema.Properties[propertyName] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		property := document.schemaOf(field.Type)
+		if !strings.Contains(options, ",omitempty") {
+			schema.Required = append(schema.Required, name)
+
+			switch field.Type.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+				property = nullable(property)
+			}
+		}
+
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// nullable allows the schema to be null, as fields that are always written can be when they're nil.
+func nullable(schema *Schema) *Schema {
+	if len(schema.Ref) > 0 {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+
+	schema.Nullable = true
+	return schema
+}
@@ -0,0 +1,234 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const jsonMediaType = "application/json"
+
+// ValidateResponse checks a response to the method at the request path against the operation that documents
+// it. The status has to be one the operation lists, though errors may fall back on its default response, and
+// the body has to match the schema given for its media type. Responses to paths the document doesn't describe
+// aren't checked.
+func (document *Document) ValidateResponse(
+	method string,
+	requestPath string,
+	status int,
+	contentType string,
+	body []byte,
+) error {
+	operation, path, ok := document.FindOperation(method, requestPath)
+	if !ok {
+		return nil
+	}
+
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok && status >= 400 {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s %s: %d is not a documented response", method, path, status)
+	}
+
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("%s %s: %d has a body, though none is documented", method, path, status)
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%s %s: %d has no media type: %s", method, path, status, err)
+	}
+
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s %s: %d is %s, not one of the documented media types", method, path, status, mediaType)
+	}
+
+	// only JSON bodies have structure to check
+	if mediaType != jsonMediaType {
+		return nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("%s %s: %d is not JSON: %s", method, path, status, err)
+	}
+
+	if err := document.Validate(media.Schema, value); err != nil {
+		return fmt.Errorf("%s %s: %d doesn't match its schema: %s", method, path, status, err)
+	}
+
+	return nil
+}
+
+// Validate checks the value matches the schema. The value is as decoded from JSON into an interface{}, with
+// numbers kept as json.Number.
+func (document *Document) Validate(schema *Schema, value interface{}) error {
+	return document.validate(schema, value, "$")
+}
+
+func (document *Document) validate(schema *Schema, value interface{}, location string) error {
+	if len(schema.Ref) > 0 {
+		referenced, ok := document.Components.Schemas[strings.TrimPrefix(schema.Ref, componentSchemaPrefix)]
+		if !ok {
+			return fmt.Errorf("%s: refers to the unknown schema %s", location, schema.Ref)
+		}
+		return document.validate(referenced, value, location)
+	}
+
+	if value == nil {
+		if schema.Nullable || isAnySchema(schema) {
+			return nil
+		}
+		return fmt.Errorf("%s: is null", location)
+	}
+
+	for _, allOfSchema := range schema.AllOf {
+		if err := document.validate(allOfSchema, value, location); err != nil {
+			return err
+		}
+	}
+
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		for _, oneOfSchema := range schema.OneOf {
+			if document.validate(oneOfSchema, value, location) == nil {
+				matches++
+			}
+		}
+
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of its %d possible schemas rather than one", location, matches, len(schema.OneOf))
+		}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(schema, value, location)
+		}
+
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: is missing %s", location, name)
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				switch additionalProperties := schema.AdditionalProperties.(type) {
+				case *Schema:
+					propertySchema = additionalProperties
+				case bool:
+					if !additionalProperties {
+						return fmt.Errorf("%s: has the undocumented property %s", location, name)
+					}
+				}
+			}
+
+			if propertySchema != nil {
+				if err := document.validate(propertySchema, object[name], location+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return typeError(schema, value, location)
+		}
+
+		for i, item := range array {
+			if err := document.validate(schema.Items, item, location+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return typeError(schema, value, location)
+		}
+
+		switch schema.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", location, text)
+			}
+		case "byte":
+			if _, err := base64.StdEncoding.DecodeString(text); err != nil {
+				return fmt.Errorf("%s: is not base64", location)
+			}
+		}
+
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return typeError(schema, value, location)
+		}
+
+		if _, err := number.Int64(); err != nil {
+			return fmt.Errorf("%s: %s is not an integer", location, number)
+		}
+
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return typeError(schema, value, location)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(schema, value, location)
+		}
+	}
+
+	return nil
+}
+
+// isAnySchema says whether the schema allows any value at all, as that of an interface{} does.
+func isAnySchema(schema *Schema) bool {
+	return len(schema.Type) == 0 && len(schema.AllOf) == 0 && len(schema.OneOf) == 0
+}
+
+func typeError(schema *Schema, value interface{}, location string) error {
+	return fmt.Errorf("%s: is %s, where the schema has type %s", location, jsonTypeOf(value), schema.Type)
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
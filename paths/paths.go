@@ -3,13 +3,133 @@ Package paths stores all the url path constants.
 */
 package paths
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	LoginOrSignupPage         = "/login-or-signup"
+	HomePage                  = "/home"
+	NotesPage                 = "/notes"
+	UnsubscribePage           = "/unsubscribe"
+	UserApi                   = "/api/user"
+	SessionApi                = "/api/session"
+	NoteApi                   = "/api/note"
+	NoteCategoryApi           = "/api/note-category"
+	PublicationApi            = "/api/publication"
+	PublicationRetractionApi  = "/api/publication/retraction"
+	PublicationPreviewApi     = "/api/publication/preview"
+	ImportApi                 = "/api/import"
+	FeedTokenApi              = "/api/feed-token"
+	AtomFeed                  = "/feed/atom"
+	RssFeed                   = "/feed/rss"
+	NotificationPreferenceApi = "/api/notification-preference"
+	WebhookApi                = "/api/webhook"
+	WebhookPingApi            = "/api/webhook/ping"
+	WebhookDeliveryApi        = "/api/webhook/delivery"
+	EventsApi                 = "/api/events"
+	PredictionApi             = "/api/prediction"
+	PredictionResolutionApi   = "/api/prediction/resolution"
+	CalibrationApi            = "/api/calibration"
+	AnswerApi                 = "/api/answer"
+	AcceptedAnswerApi         = "/api/answer/accepted"
+	SourceApi                 = "/api/source"
+	SourceNotesApi            = "/api/source/notes"
+	NoteSourceApi             = "/api/note-source"
+	CategoryApi               = "/api/category"
+	ReplyApi                  = "/api/reply"
+	ReactionApi               = "/api/reaction"
+	NoteGraphApi              = "/api/note/graph"
+	NoteAttachmentApi         = "/api/note/attachment"
+	NoteAttachmentContentApi  = "/api/note/attachment/content"
+	TrashApi                  = "/api/trash"
+	TrashRestoreApi           = "/api/trash/restore"
+	NoteReadApi               = "/api/note/read"
+	PublicationReadApi        = "/api/publication/read"
+	UnreadApi                 = "/api/unread"
+	MarkAllReadApi            = "/api/mark-all-read"
+	CollectionApi             = "/api/collection"
+	CollectionNoteApi         = "/api/collection/note"
+	CollectionOrderApi        = "/api/collection/order"
+	CollectionExportApi       = "/api/collection/export"
+	NoteStateApi              = "/api/note/state"
+	NoteBatchApi              = "/api/note/batch"
+	OpenApiDocument           = "/api/openapi.json"
+	GraphQlApi                = "/api/graphql"
+)
+
+// ApiV1 is the root of the versioned api, whose paths name resources and take their parameters in braces.
+// The unversioned api paths above are deprecated aliases that take the same parameters in the query string.
 const (
-	LoginOrSignupPage = "/login-or-signup"
-	HomePage          = "/home"
-	NotesPage         = "/notes"
-	UserApi           = "/api/user"
-	SessionApi        = "/api/session"
-	NoteApi           = "/api/note"
-	NoteCategoryApi   = "/api/note-category"
-	PublicationApi    = "/api/publication"
+	ApiV1                      = "/api/v1"
+	UsersV1                    = ApiV1 + "/users"
+	SessionV1                  = ApiV1 + "/session"
+	NotesV1                    = ApiV1 + "/notes"
+	NoteV1                     = ApiV1 + "/notes/{id}"
+	NoteBatchV1                = ApiV1 + "/notes/batch"
+	NoteStatesV1               = ApiV1 + "/notes/state"
+	NoteGraphV1                = ApiV1 + "/notes/graph"
+	NoteCategoryV1             = ApiV1 + "/notes/{id}/category"
+	NoteReadV1                 = ApiV1 + "/notes/{id}/read"
+	NoteReactionsV1            = ApiV1 + "/notes/{noteId}/reactions"
+	NoteRepliesV1              = ApiV1 + "/notes/{noteId}/replies"
+	NoteAttachmentsV1          = ApiV1 + "/notes/{noteId}/attachments"
+	NoteSourceV1               = ApiV1 + "/notes/{id}/source"
+	NotePredictionV1           = ApiV1 + "/notes/{id}/prediction"
+	NotePredictionResolutionV1 = ApiV1 + "/notes/{id}/prediction/resolution"
+	NoteAnswersV1              = ApiV1 + "/notes/{questionId}/answers"
+	NoteAcceptedAnswerV1       = ApiV1 + "/notes/{questionId}/answers/accepted"
+	ReactionsV1                = ApiV1 + "/reactions"
+	ReplyV1                    = ApiV1 + "/replies/{id}"
+	AttachmentV1               = ApiV1 + "/attachments/{id}"
+	AttachmentContentV1        = ApiV1 + "/attachments/{id}/content"
+	CalibrationV1              = ApiV1 + "/calibration"
+	PublicationsV1             = ApiV1 + "/publications"
+	PublicationPreviewV1       = ApiV1 + "/publications/preview"
+	PublicationRetractionV1    = ApiV1 + "/publications/{id}/retraction"
+	PublicationReadV1          = ApiV1 + "/publications/{id}/read"
+	ImportV1                   = ApiV1 + "/import"
+	FeedTokenV1                = ApiV1 + "/feed-token"
+	NotificationPreferenceV1   = ApiV1 + "/notification-preference"
+	WebhooksV1                 = ApiV1 + "/webhooks"
+	WebhookV1                  = ApiV1 + "/webhooks/{id}"
+	WebhookPingV1              = ApiV1 + "/webhooks/{id}/ping"
+	WebhookDeliveriesV1        = ApiV1 + "/webhooks/{id}/deliveries"
+	EventsV1                   = ApiV1 + "/events"
+	SourcesV1                  = ApiV1 + "/sources"
+	SourceV1                   = ApiV1 + "/sources/{id}"
+	SourceNotesV1              = ApiV1 + "/sources/{id}/notes"
+	CategoriesV1               = ApiV1 + "/categories"
+	CategoryV1                 = ApiV1 + "/categories/{name}"
+	TrashV1                    = ApiV1 + "/trash"
+	TrashedNoteV1              = ApiV1 + "/trash/{id}"
+	TrashRestoreV1             = ApiV1 + "/trash/{id}/restore"
+	UnreadV1                   = ApiV1 + "/unread"
+	MarkAllReadV1              = ApiV1 + "/mark-all-read"
+	CollectionsV1              = ApiV1 + "/collections"
+	CollectionV1               = ApiV1 + "/collections/{id}"
+	CollectionNotesV1          = ApiV1 + "/collections/{collectionId}/notes"
+	CollectionNoteV1           = ApiV1 + "/collections/{collectionId}/notes/{noteId}"
+	CollectionOrderV1          = ApiV1 + "/collections/{id}/order"
+	CollectionExportV1         = ApiV1 + "/collections/{id}/export"
 )
+
+// Expand fills in the parameters of a versioned api path, in the order they appear in it.
+func Expand(pattern string, values ...interface{}) string {
+	segments := strings.Split(pattern, "/")
+
+	for i, segment := range segments {
+		if len(values) == 0 {
+			break
+		}
+
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = url.PathEscape(fmt.Sprint(values[0]))
+			values = values[1:]
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
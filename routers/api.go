@@ -0,0 +1,169 @@
+package routers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/atmiguel/cerealnotes/handlers"
+	"github.com/atmiguel/cerealnotes/paths"
+)
+
+// apiResource is a path under /api/v1 and the methods it answers.
+type apiResource struct {
+	pattern string
+	methods []string
+}
+
+func v1(pattern string, methods ...string) apiResource {
+	return apiResource{pattern: pattern, methods: methods}
+}
+
+type apiRoute struct {
+	segments    []string
+	methods     []string
+	handlerFunc http.HandlerFunc
+}
+
+// apiRouter serves /api/v1. The parameters in a resource's path are handed to its handler as query parameters,
+// which is where the handlers, shared with the deprecated routes, read them from.
+type apiRouter struct {
+	routes []*apiRoute
+}
+
+func (router *apiRouter) handle(resource apiResource, handlerFunc http.HandlerFunc) {
+	router.routes = append(router.routes, &apiRoute{
+		segments:    strings.Split(resource.pattern, "/"),
+		methods:     resource.methods,
+		handlerFunc: handlerFunc,
+	})
+}
+
+func (router *apiRouter) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	route, parameters := router.match(strings.Split(strings.TrimSuffix(request.URL.Path, "/"), "/"))
+	if route == nil {
+		handlers.RespondWithError(responseWriter, request, handlers.NoApiRouteError, http.StatusNotFound)
+		return
+	}
+
+	if !containsMethod(route.methods, request.Method) {
+		responseWriter.Header().Set("Allow", strings.Join(route.methods, ", "))
+		handlers.RespondWithError(responseWriter, request, handlers.InvalidMethodError, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// path parameters take the place of any query parameters of the same name
+	query := request.URL.Query()
+	for name, value := range parameters {
+		query.Set(name, value)
+	}
+
+	routedUrl := *request.URL
+	routedUrl.RawQuery = query.Encode()
+
+	routedRequest := new(http.Request)
+	*routedRequest = *request
+	routedRequest.URL = &routedUrl
+
+	route.handlerFunc(responseWriter, routedRequest)
+}
+
+// match finds the route for the path. Where several match, as /notes/batch and /notes/{id} do, the one with
+// the most fixed segments wins.
+func (router *apiRouter) match(segments []string) (*apiRoute, map[string]string) {
+	var bestRoute *apiRoute
+	var bestParameters map[string]string
+	bestFixedSegments := -1
+
+	for _, route := range router.routes {
+		if len(route.segments) != len(segments) {
+			continue
+		}
+
+		parameters := make(map[string]string)
+		fixedSegments := 0
+		matched := true
+		for i, routeSegment := range route.segments {
+			if name, ok := parameterName(routeSegment); ok {
+				if len(segments[i]) == 0 {
+					matched = false
+					break
+				}
+				parameters[name] = segments[i]
+				continue
+			}
+
+			if routeSegment != segments[i] {
+				matched = false
+				break
+			}
+			fixedSegments++
+		}
+
+		if matched && fixedSegments > bestFixedSegments {
+			bestRoute = route
+			bestParameters = parameters
+			bestFixedSegments = fixedSegments
+		}
+	}
+
+	return bestRoute, bestParameters
+}
+
+// deprecated marks the responses of a deprecated route, linking to the resource that replaces it. Of the
+// resources, the one whose parameters are all in the request's query is linked, preferring those with the most.
+func deprecated(resources []apiResource, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set("Deprecation", "true")
+		if successor, ok := successorPath(resources, request.URL.Query()); ok {
+			responseWriter.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+
+		handlerFunc(responseWriter, request)
+	}
+}
+
+func successorPath(resources []apiResource, query url.Values) (string, bool) {
+	successor := ""
+	successorParameters := -1
+
+	for _, resource := range resources {
+		values := make([]interface{}, 0)
+		complete := true
+		for _, segment := range strings.Split(resource.pattern, "/") {
+			if name, ok := parameterName(segment); ok {
+				value := query.Get(name)
+				if len(value) == 0 {
+					complete = false
+					break
+				}
+				values = append(values, value)
+			}
+		}
+
+		if complete && len(values) > successorParameters {
+			successor = paths.Expand(resource.pattern, values...)
+			successorParameters = len(values)
+		}
+	}
+
+	return successor, successorParameters >= 0
+}
+
+func parameterName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+
+	return "", false
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, allowedMethod := range methods {
+		if allowedMethod == method {
+			return true
+		}
+	}
+
+	return false
+}
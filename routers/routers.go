@@ -22,12 +22,34 @@ func (mux *routeHandler) handleAuthenticatedPage(
 	mux.HandleFunc(pattern, handlers.AuthenticateOrRedirect(env, handlerFunc, paths.LoginOrSignupPage))
 }
 
+// handleAuthenticatedApi serves the handler at each of its resources under /api/v1, and at its unversioned
+// path as a deprecated alias.
 func (mux *routeHandler) handleAuthenticatedApi(
 	env *handlers.Environment,
+	api *apiRouter,
 	pattern string,
 	handlerFunc handlers.AuthenticatedRequestHandlerType,
+	resources ...apiResource,
 ) {
-	mux.HandleFunc(pattern, handlers.AuthenticateOrReturnUnauthorized(env, handlerFunc))
+	mux.handleApi(api, pattern, handlers.AuthenticateOrReturnUnauthorized(env, handlerFunc), resources)
+}
+
+func (mux *routeHandler) handleUnauthenticatedApi(
+	env *handlers.Environment,
+	api *apiRouter,
+	pattern string,
+	handlerFunc handlers.UnauthenticatedEndpointHandlerType,
+	resources ...apiResource,
+) {
+	mux.handleApi(api, pattern, handlers.WrapUnauthenticatedEndpoint(env, handlerFunc), resources)
+}
+
+func (mux *routeHandler) handleApi(api *apiRouter, pattern string, handlerFunc http.HandlerFunc, resources []apiResource) {
+	for _, resource := range resources {
+		api.handle(resource, handlerFunc)
+	}
+
+	mux.HandleFunc(pattern, deprecated(resources, handlerFunc))
 }
 
 func (mux *routeHandler) handleUnAutheticedRequest(
@@ -64,13 +86,114 @@ func DefineRoutes(env *handlers.Environment) http.Handler {
 	mux.handleAuthenticatedPage(env, paths.HomePage, handlers.HandleHomePageRequest)
 	mux.handleAuthenticatedPage(env, paths.NotesPage, handlers.HandleNotesPageRequest)
 
+	// linked from notification emails, so it is authenticated by the unsubscribe token instead
+	mux.handleUnAutheticedRequest(env, paths.UnsubscribePage, handlers.HandleUnsubscribeRequest)
+
 	// api
-	mux.handleUnAutheticedRequest(env, paths.UserApi, handlers.HandleUserApiRequest)
-	mux.handleUnAutheticedRequest(env, paths.SessionApi, handlers.HandleSessionApiRequest)
+	api := &apiRouter{}
+	mux.Handle(paths.ApiV1+"/", api)
+
+	mux.handleUnauthenticatedApi(env, api, paths.UserApi, handlers.HandleUserApiRequest,
+		v1(paths.UsersV1, http.MethodGet, http.MethodPost))
+	mux.handleUnauthenticatedApi(env, api, paths.SessionApi, handlers.HandleSessionApiRequest,
+		v1(paths.SessionV1, http.MethodPost, http.MethodDelete))
+
+	mux.handleAuthenticatedApi(env, api, paths.NoteApi, handlers.HandleNoteApiRequest,
+		v1(paths.NotesV1, http.MethodGet, http.MethodPost),
+		v1(paths.NoteV1, http.MethodGet, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.NoteCategoryApi, handlers.HandleNoteCateogryApiRequest,
+		v1(paths.NoteCategoryV1, http.MethodGet, http.MethodPost, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.PublicationApi, handlers.HandlePublicationApiRequest,
+		v1(paths.PublicationsV1, http.MethodGet, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.ImportApi, handlers.HandleImportApiRequest,
+		v1(paths.ImportV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.FeedTokenApi, handlers.HandleFeedTokenApiRequest,
+		v1(paths.FeedTokenV1, http.MethodGet, http.MethodPost, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.NotificationPreferenceApi, handlers.HandleNotificationPreferenceApiRequest,
+		v1(paths.NotificationPreferenceV1, http.MethodGet, http.MethodPut))
+	mux.handleAuthenticatedApi(env, api, paths.WebhookApi, handlers.HandleWebhookApiRequest,
+		v1(paths.WebhooksV1, http.MethodGet, http.MethodPost),
+		v1(paths.WebhookV1, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.WebhookPingApi, handlers.HandleWebhookPingApiRequest,
+		v1(paths.WebhookPingV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.WebhookDeliveryApi, handlers.HandleWebhookDeliveryApiRequest,
+		v1(paths.WebhookDeliveriesV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.EventsApi, handlers.HandleEventsApiRequest,
+		v1(paths.EventsV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.PredictionApi, handlers.HandlePredictionApiRequest,
+		v1(paths.NotePredictionV1, http.MethodGet, http.MethodPut))
+	mux.handleAuthenticatedApi(env, api, paths.PredictionResolutionApi, handlers.HandlePredictionResolutionApiRequest,
+		v1(paths.NotePredictionResolutionV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.CalibrationApi, handlers.HandleCalibrationApiRequest,
+		v1(paths.CalibrationV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.AnswerApi, handlers.HandleAnswerApiRequest,
+		v1(paths.NoteAnswersV1, http.MethodGet, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.AcceptedAnswerApi, handlers.HandleAcceptedAnswerApiRequest,
+		v1(paths.NoteAcceptedAnswerV1, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.SourceApi, handlers.HandleSourceApiRequest,
+		v1(paths.SourcesV1, http.MethodPost),
+		v1(paths.SourceV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.SourceNotesApi, handlers.HandleSourceNotesApiRequest,
+		v1(paths.SourceNotesV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.NoteSourceApi, handlers.HandleNoteSourceApiRequest,
+		v1(paths.NoteSourceV1, http.MethodGet, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.CategoryApi, handlers.HandleCategoryApiRequest,
+		v1(paths.CategoriesV1, http.MethodGet, http.MethodPost),
+		v1(paths.CategoryV1, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.ReplyApi, handlers.HandleReplyApiRequest,
+		v1(paths.NoteRepliesV1, http.MethodGet, http.MethodPost),
+		v1(paths.ReplyV1, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.ReactionApi, handlers.HandleReactionApiRequest,
+		v1(paths.ReactionsV1, http.MethodGet),
+		v1(paths.NoteReactionsV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.NoteGraphApi, handlers.HandleNoteGraphApiRequest,
+		v1(paths.NoteGraphV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.NoteAttachmentApi, handlers.HandleNoteAttachmentApiRequest,
+		v1(paths.NoteAttachmentsV1, http.MethodGet, http.MethodPost),
+		v1(paths.AttachmentV1, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.NoteAttachmentContentApi, handlers.HandleNoteAttachmentContentApiRequest,
+		v1(paths.AttachmentContentV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.TrashApi, handlers.HandleTrashApiRequest,
+		v1(paths.TrashV1, http.MethodGet),
+		v1(paths.TrashedNoteV1, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.TrashRestoreApi, handlers.HandleTrashRestoreApiRequest,
+		v1(paths.TrashRestoreV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.PublicationRetractionApi, handlers.HandlePublicationRetractionApiRequest,
+		v1(paths.PublicationRetractionV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.PublicationPreviewApi, handlers.HandlePublicationPreviewApiRequest,
+		v1(paths.PublicationPreviewV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.NoteReadApi, handlers.HandleNoteReadApiRequest,
+		v1(paths.NoteReadV1, http.MethodPost, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.PublicationReadApi, handlers.HandlePublicationReadApiRequest,
+		v1(paths.PublicationReadV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.UnreadApi, handlers.HandleUnreadApiRequest,
+		v1(paths.UnreadV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.MarkAllReadApi, handlers.HandleMarkAllReadApiRequest,
+		v1(paths.MarkAllReadV1, http.MethodPost))
+	mux.handleAuthenticatedApi(env, api, paths.CollectionApi, handlers.HandleCollectionApiRequest,
+		v1(paths.CollectionsV1, http.MethodGet, http.MethodPost),
+		v1(paths.CollectionV1, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.CollectionNoteApi, handlers.HandleCollectionNoteApiRequest,
+		v1(paths.CollectionNotesV1, http.MethodGet, http.MethodPost),
+		v1(paths.CollectionNoteV1, http.MethodPut, http.MethodDelete))
+	mux.handleAuthenticatedApi(env, api, paths.CollectionOrderApi, handlers.HandleCollectionOrderApiRequest,
+		v1(paths.CollectionOrderV1, http.MethodPut))
+	mux.handleAuthenticatedApi(env, api, paths.CollectionExportApi, handlers.HandleCollectionExportApiRequest,
+		v1(paths.CollectionExportV1, http.MethodGet))
+	mux.handleAuthenticatedApi(env, api, paths.NoteStateApi, handlers.HandleNoteStateApiRequest,
+		v1(paths.NoteStatesV1, http.MethodPut))
+	mux.handleAuthenticatedApi(env, api, paths.NoteBatchApi, handlers.HandleNoteBatchApiRequest,
+		v1(paths.NoteBatchV1, http.MethodPost))
+
+	// the GraphQL endpoint has a single path, so it isn't versioned with the resources under /api/v1
+	mux.HandleFunc(paths.GraphQlApi, handlers.AuthenticateOrReturnUnauthorized(env, handlers.HandleGraphQlApiRequest))
+
+	// the OpenAPI document describing the api is public, like any other documentation
+	mux.handleUnAutheticedRequest(env, paths.OpenApiDocument, handlers.HandleOpenApiRequest)
 
-	mux.handleAuthenticatedApi(env, paths.NoteApi, handlers.HandleNoteApiRequest)
-	mux.handleAuthenticatedApi(env, paths.NoteCategoryApi, handlers.HandleNoteCateogryApiRequest)
-	mux.handleAuthenticatedApi(env, paths.PublicationApi, handlers.HandlePublicationApiRequest)
+	// feeds are authenticated by a private token in the url since feed readers can't hold cookies
+	mux.handleUnAutheticedRequest(env, paths.AtomFeed, handlers.HandleAtomFeedRequest)
+	mux.handleUnAutheticedRequest(env, paths.RssFeed, handlers.HandleRssFeedRequest)
 
 	return mux
 }
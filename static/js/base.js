@@ -31,4 +31,13 @@ $(function() {
     throw "jQuery Object contains more than 1 object";
   };
 
-});
\ No newline at end of file
+});
+
+// apiErrorMessage returns the message of an error response from /api/v1.
+function apiErrorMessage($XmlHttpResponse) {
+  try {
+    return JSON.parse($XmlHttpResponse.responseText).error.message;
+  } catch (e) {
+    return $XmlHttpResponse.responseText;
+  }
+}
@@ -3,7 +3,7 @@
 $(function() {
   $("#logout-button").click(() => {
     $.ajax({
-      url: '/api/session',
+      url: '/api/v1/session',
       type: 'DELETE',
       success: function() {
         alert("you've been successfully logged out");
@@ -134,7 +134,7 @@ $(function() {
   };
 
   attachSubmitClickHandler(signupFormMetadata, (formDataAsJsonString) => {
-    $.post('/api/user', formDataAsJsonString, (responseBody, _, $XmlHttpResponse) => {
+    $.post('/api/v1/users', formDataAsJsonString, (responseBody, _, $XmlHttpResponse) => {
       if ($XmlHttpResponse.status === 201) {
         mui.tabs.activate('login-form');
         alert('Successfully created user, please sign in');
@@ -145,13 +145,13 @@ $(function() {
       if ($XmlHttpResponse.status === 409) {
         alert('Email address already in use');
       } else {
-        alert('Unexpected error ' + $XmlHttpResponse.responseText);
+        alert('Unexpected error ' + apiErrorMessage($XmlHttpResponse));
       }
     });
   });
 
   attachSubmitClickHandler(loginFormMetadata, (formDataAsJsonString) => {
-    $.post('/api/session', formDataAsJsonString, (responseBody, _, $XmlHttpResponse) => {
+    $.post('/api/v1/session', formDataAsJsonString, (responseBody, _, $XmlHttpResponse) => {
       if ($XmlHttpResponse.status === 201) {
         location.reload();
       } else {
@@ -161,7 +161,7 @@ $(function() {
       if ($XmlHttpResponse.status === 401) {
         alert('Email address and/or password was incorrect');
       } else {
-        alert('Unexpected error ' + $XmlHttpResponse.responseText);
+        alert('Unexpected error ' + apiErrorMessage($XmlHttpResponse));
       }
     });
   });
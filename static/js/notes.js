@@ -2,12 +2,11 @@
 
 var USERS_BY_ID = {};
 
-const NOTE_TYPES = [
-  'Marginalia',
-  'Meta',
-  'Prediction',
-  'Question',
-];
+// Categories are stored on the server and can be edited through /api/v1/categories.
+var CATEGORIES_BY_NAME = {};
+
+// The reactions this deployment offers, configured with the REACTIONS environment variable.
+var REACTIONS = [];
 
 const classNamesByName = {
   noteTypeButton: 'note-type-button',
@@ -17,6 +16,8 @@ const classNamesByName = {
   noteTimeSpan: 'note-time',
   noteIdSpan: 'note-id',
   noteContent: 'note-content',
+  noteReplyCountSpan: 'note-reply-count',
+  noteReactionsDiv: 'note-reactions',
 
 };
 
@@ -75,35 +76,106 @@ function capitalizeFirstLetter(string) {
   return string.charAt(0).toUpperCase() + string.slice(1);
 };
 
+function showCategory($category, name) {
+  const category = CATEGORIES_BY_NAME[name];
+  $category.text(capitalizeFirstLetter(name))
+    .css('color', category ? category.colour : '');
+}
+
 function assignCategory(noteId, $cateogry) {
   let id = `${noteId}_category`;
   $cateogry.prop('id', id);
 
-  return $.get('/api/note-category?id=' + noteId, function(responseObj) {
-    $(`#${id}`).text(capitalizeFirstLetter(responseObj.category));
+  return $.get('/api/v1/notes/' + noteId + '/category', function(responseObj) {
+    showCategory($(`#${id}`), responseObj.category);
   });
 }
 
+// contentHtml is rendered from Markdown and sanitised by the server, so it is safe to insert as HTML.
+function showContent($note, note) {
+  const $content = $note.find(`.${classNamesByName.noteContent}`);
+  if (note.deletionTime) {
+    // published notes stay in their issue as tombstones once deleted
+    $content.text('This note was deleted.');
+  } else if (note.contentHtml) {
+    $content.html(note.contentHtml);
+  } else {
+    $content.text(note.content);
+  }
+}
+
 function $createNote(noteId, note) {
   let $newNote = $("#templates .note").clone();
+  $newNote.attr('data-note-id', noteId);
   $newNote.find(`.${classNamesByName.noteIdSpan}`).text(noteId);
   $newNote.find(`.${classNamesByName.noteAuthorSpan}`).text(USERS_BY_ID[note.authorId].displayName);
   $newNote.find(`.${classNamesByName.noteTimeSpan}`).text(moment(note.creationTime).fromNow());
-  $newNote.find(`.${classNamesByName.noteContent}`).text(note.content);
+  showContent($newNote, note);
+  if (note.replyCount) {
+    $newNote.find(`.${classNamesByName.noteReplyCountSpan}`)
+      .text(note.replyCount === 1 ? '1 reply' : `${note.replyCount} replies`);
+  }
+
+  showReactions(noteId, $newNote.find(`.${classNamesByName.noteReactionsDiv}`), note.reactions || []);
 
   // Assign type info
   assignCategory(noteId, $newNote.find(`.${classNamesByName.noteCategorySpan}`));
 
+  if (note.unread) {
+    $newNote.addClass('note-unread');
+    $newNote.one('click', function() {
+      $.post('/api/v1/notes/' + noteId + '/read').done(function() {
+        $newNote.removeClass('note-unread');
+      });
+    });
+  }
+
   return $newNote;
 
 }
 
+// REACTIONS
+function showReactions(noteId, $reactions, summaries) {
+  const summariesByReaction = {};
+  for (const summary of summaries) {
+    summariesByReaction[summary.reaction] = summary;
+  }
+
+  $reactions.empty();
+  for (const reaction of REACTIONS) {
+    const summary = summariesByReaction[reaction] || {count: 0, reactedByMe: false};
+    const $button = $createButtonWithText(summary.count ? `${reaction} ${summary.count}` : reaction)
+      .addClass('mui-btn--small mui-btn--flat');
+    if (summary.reactedByMe) {
+      activateButton($button);
+    }
+
+    $button.click(function() {
+      $.ajax({
+        url: '/api/v1/notes/' + noteId + '/reactions',
+        type: 'POST',
+        data: JSON.stringify({'reaction': reaction}),
+        contentType: 'application/json; charset=utf-8',
+        dataType: 'json',
+      }).done(function(newSummaries) {
+        showReactions(noteId, $reactions, newSummaries);
+      });
+    });
+
+    $reactions.append($button);
+  }
+}
+
 // ADD
-function $createAddNoteModal() {
+function $createAddNoteModal(categories) {
   const $modal = $('<div>').addClass('modal').addClass('mui-container')
 
-  const $buttons = NOTE_TYPES.map(noteType => {
-    return $createButtonWithText(noteType).addClass(classNamesByName.noteTypeButton);
+  const $buttons = categories.map(category => {
+    return $createButtonWithText(capitalizeFirstLetter(category.name))
+      .addClass(classNamesByName.noteTypeButton)
+      .attr('data-category', category.name)
+      .attr('title', category.description)
+      .css('border-left', `4px solid ${category.colour}`);
   });
 
   const $textareaDiv = $createTextAreaDiv('Note').addClass('note-content');
@@ -115,7 +187,7 @@ function $createAddNoteModal() {
     let category = "";
     for (const $button of $buttons) {
       if ($button.hasClass(classNamesByName.primaryButton)) {
-        category = $button.text();
+        category = $button.attr('data-category');
         break;
       }
     }
@@ -136,10 +208,13 @@ function $createAddNoteModal() {
 
   $submitNoteButton.click(onSubmitNoteClick);
 
+  const $grid = $createGridContainer();
+  for (let i = 0; i < $buttons.length; i += 2) {
+    $grid.append($createRowWithTwoElements($buttons[i], $buttons[i + 1]));
+  }
+
   return $modal
-    .append($createGridContainer()
-      .append($createRowWithTwoElements($buttons[0], $buttons[1]))
-      .append($createRowWithTwoElements($buttons[2], $buttons[3])))
+    .append($grid)
     .append($textareaDiv)
     .append($submitNoteButton);
 };
@@ -148,9 +223,49 @@ async function refreshNotes() {
   location.reload();
 }
 
+function $findNote(noteId) {
+  return $(`#notes .note[data-note-id="${noteId}"]`);
+}
+
+// LIVE UPDATES
+// EventSource reconnects by itself, resending the id of the last event it saw so that
+// the server can replay anything missed in between.
+function listenForEvents() {
+  const eventSource = new EventSource('/api/v1/events');
+
+  eventSource.addEventListener('note.created', function(message) {
+    const event = JSON.parse(message.data);
+    if ($findNote(event.noteId).length === 0 && USERS_BY_ID[event.authorId]) {
+      $('#notes').append($createNote(event.noteId, event.data));
+    }
+  });
+
+  eventSource.addEventListener('note.updated', function(message) {
+    const event = JSON.parse(message.data);
+    showContent($findNote(event.noteId), event.data);
+  });
+
+  eventSource.addEventListener('note.deleted', function(message) {
+    const event = JSON.parse(message.data);
+    $findNote(event.noteId).remove();
+  });
+
+  eventSource.addEventListener('note.restored', refreshNotes);
+
+  eventSource.addEventListener('note.categorized', function(message) {
+    const event = JSON.parse(message.data);
+    showCategory($findNote(event.noteId).find(`.${classNamesByName.noteCategorySpan}`), event.data.category);
+  });
+
+  // a publication can make many notes readable at once, as can events we missed
+  eventSource.addEventListener('publication.created', refreshNotes);
+  eventSource.addEventListener('publication.retracted', refreshNotes);
+  eventSource.addEventListener('reset', refreshNotes);
+}
+
 async function sendNewNote(noteContent, cateogry) {
   var data = await $.ajax({
-    url: '/api/note',
+    url: '/api/v1/notes',
     type: "POST",
     data: JSON.stringify({
       'content': noteContent
@@ -165,10 +280,10 @@ async function sendNewNote(noteContent, cateogry) {
   if (cateogry) {
     const noteId = data.noteId;
     var bob = await $.ajax({
-      url: '/api/note-category?id=' + noteId,
+      url: '/api/v1/notes/' + noteId + '/category',
       type: "POST",
       data: JSON.stringify({
-        'category': cateogry.toLowerCase()
+        'category': cateogry
       }),
       contentType: "application/json; charset=utf-8",
     }).fail(function(jqXHR, textStatus, errorThrown) {
@@ -183,22 +298,47 @@ const activateModal = function($modal) {
 };
 
 $(function() {
-  const $addNoteModal = $createAddNoteModal();
+  let $addNoteModal;
 
-  $.get('/api/user', function(usersById) {
-    USERS_BY_ID = usersById;
+  $.when($.get('/api/v1/users'), $.get('/api/v1/categories'), $.get('/api/v1/reactions')).done(function(usersResponse, categoriesResponse, reactionsResponse) {
+    USERS_BY_ID = usersResponse[0];
+    REACTIONS = reactionsResponse[0];
 
-    $.get('/api/note', function(notes) {
+    const categories = categoriesResponse[0];
+    for (const category of categories) {
+      CATEGORIES_BY_NAME[category.name] = category;
+    }
+    $addNoteModal = $createAddNoteModal(categories);
+
+    $.get('/api/v1/notes', function(notes) {
       const $notes = $('#notes');
 
-      for (const key of Object.keys(notes)) {
+      // pinned drafts first, then the other unpublished notes, then each issue newest first in the
+      // order its author laid it out
+      const noteIds = Object.keys(notes).sort(function(a, b) {
+        if (Boolean(notes[a].pinnedTime) !== Boolean(notes[b].pinnedTime)) {
+          return notes[a].pinnedTime ? -1 : 1;
+        }
+        const issueA = notes[a].issueNumber || Infinity;
+        const issueB = notes[b].issueNumber || Infinity;
+        if (issueA !== issueB) {
+          return issueB - issueA;
+        }
+        return (notes[a].position || 0) - (notes[b].position || 0);
+      });
+
+      for (const key of noteIds) {
         $notes.append($createNote(key, notes[key]));
       }
+
+      listenForEvents();
     });
   });
 
   $('#add-note-button').click(function() {
-    activateModal($addNoteModal);
+    if ($addNoteModal) {
+      activateModal($addNoteModal);
+    }
   });
 
   $(document).on('click', classesByName.noteTypeButton, function() {
@@ -0,0 +1,7 @@
+/*
+Package trash purges notes that have been in the trash for longer than the retention period.
+
+Notes are moved to the trash by the handlers package and purged by a Purger,
+which also removes the content of their attachments from the blob store.
+*/
+package trash
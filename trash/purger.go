@@ -0,0 +1,48 @@
+package trash
+
+import (
+	"log"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+// Purger purges notes once they have been in the trash for the retention period.
+type Purger struct {
+	Db        models.Datastore
+	Blobs     blobstore.Store
+	Retention time.Duration
+}
+
+// Run purges expired notes every pollInterval, forever.
+func (purger *Purger) Run(pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := purger.PurgeExpired(now); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// PurgeExpired purges every note trashed more than the retention period before now, along with the
+// content of its attachments. Attachment content that can't be removed is only logged, since nothing
+// refers to it any more.
+func (purger *Purger) PurgeExpired(now time.Time) error {
+	attachments, err := purger.Db.PurgeTrashedNotes(now.UTC().Add(-purger.Retention), now.UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		for _, key := range attachment.BlobKeys() {
+			if err := purger.Blobs.Delete(key); err != nil && err != blobstore.NoBlobFoundError {
+				log.Printf("could not remove attachment %d of note %d: %s", attachment.Id, attachment.NoteId, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package trash_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/blobstore"
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+	"github.com/atmiguel/cerealnotes/trash"
+)
+
+// trashStore only implements the Datastore methods the purger uses.
+type trashStore struct {
+	models.Datastore
+	attachments   []*models.Attachment
+	trashedBefore time.Time
+}
+
+func (store *trashStore) PurgeTrashedNotes(trashedBefore time.Time, purgeTime time.Time) ([]*models.Attachment, error) {
+	store.trashedBefore = trashedBefore
+	return store.attachments, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	blobDir, err := ioutil.TempDir("", "blobs")
+	test_util.Ok(t, err)
+	defer os.RemoveAll(blobDir)
+
+	blobs := blobstore.NewLocalStore(blobDir)
+	for _, key := range []string{"attachments/1/picture", "attachments/1/picture-thumbnail", "attachments/2/kept"} {
+		test_util.Ok(t, blobs.Put(key, bytes.NewReader([]byte("content")), 7, "text/plain"))
+	}
+
+	store := &trashStore{
+		attachments: []*models.Attachment{
+			{Id: 1, NoteId: 1, BlobKey: "attachments/1/picture", ThumbnailKey: "attachments/1/picture-thumbnail", HasThumbnail: true},
+			// already gone, which shouldn't stop the rest from being removed
+			{Id: 2, NoteId: 1, BlobKey: "attachments/1/missing"},
+		},
+	}
+
+	purger := &trash.Purger{Db: store, Blobs: blobs, Retention: time.Hour * 24 * 30}
+
+	now := time.Date(2019, time.March, 31, 12, 0, 0, 0, time.UTC)
+	test_util.Ok(t, purger.PurgeExpired(now))
+	test_util.Equals(t, time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC), store.trashedBefore)
+
+	for _, key := range []string{"attachments/1/picture", "attachments/1/picture-thumbnail"} {
+		_, err := blobs.Get(key)
+		test_util.Equals(t, blobstore.NoBlobFoundError, err)
+	}
+
+	content, err := blobs.Get("attachments/2/kept")
+	test_util.Ok(t, err)
+	content.Close()
+
+	// the cutoff is compared with times stored in UTC, whatever zone the clock is in
+	store.attachments = nil
+	test_util.Ok(t, purger.PurgeExpired(now.In(time.FixedZone("UTC-7", -7*60*60))))
+	test_util.Equals(t, time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC), store.trashedBefore)
+}
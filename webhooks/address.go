@@ -0,0 +1,103 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ForbiddenAddressError = errors.New("Webhooks can't be sent to loopback, link-local or private addresses")
+
+// forbiddenNetworks are the addresses that reach this server or the network it runs in rather than a
+// subscriber, such as cloud metadata endpoints. Loopback, link-local and multicast addresses are checked
+// separately.
+var forbiddenNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// IsForbiddenAddress says whether webhooks must not be sent to the ip.
+func IsForbiddenAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckUrl rejects webhook urls whose host is a forbidden address or a name for this machine. Other names are
+// only resolved when deliveries are sent, since what they resolve to can change.
+func CheckUrl(webhookUrl *url.URL) error {
+	host := strings.ToLower(strings.TrimSuffix(webhookUrl.Hostname(), "."))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ForbiddenAddressError
+	}
+
+	if ip := net.ParseIP(host); ip != nil && IsForbiddenAddress(ip) {
+		return ForbiddenAddressError
+	}
+
+	return nil
+}
+
+// newSafeDialer returns a dialer that refuses to connect to forbidden addresses. It checks the address each
+// connection is actually made to, after names are resolved and redirects followed.
+func newSafeDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: deliveryTimeout,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || IsForbiddenAddress(ip) {
+				return ForbiddenAddressError
+			}
+
+			return nil
+		},
+	}
+}
+
+// newSafeClient returns the client deliveries are sent with. It doesn't go through proxies from the
+// environment, as the dialer would then only check the proxy's address.
+func newSafeClient() *http.Client {
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		Transport: &http.Transport{
+			DialContext:         newSafeDialer().DialContext,
+			TLSHandshakeTimeout: deliveryTimeout,
+			IdleConnTimeout:     time.Minute,
+		},
+	}
+}
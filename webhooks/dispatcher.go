@@ -0,0 +1,146 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+)
+
+const SignatureHeader = "X-CerealNotes-Signature"
+const EventHeader = "X-CerealNotes-Event"
+const DeliveryHeader = "X-CerealNotes-Delivery"
+
+const maxAttempts = 8
+const initialRetryDelay = time.Second * 30
+const maxRetryDelay = time.Hour * 6
+const deliveriesPerPoll = 50
+const deliveryTimeout = time.Second * 10
+
+// Dispatcher sends due webhook deliveries and records the outcome of every attempt.
+type Dispatcher struct {
+	Db     models.Datastore
+	Client *http.Client
+}
+
+// NewDispatcher returns a dispatcher whose client won't send deliveries to forbidden addresses.
+func NewDispatcher(db models.Datastore) *Dispatcher {
+	return &Dispatcher{
+		Db:     db,
+		Client: newSafeClient(),
+	}
+}
+
+// Run sends due deliveries every pollInterval, forever.
+func (dispatcher *Dispatcher) Run(pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := dispatcher.DeliverDue(now); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// DeliverDue attempts every delivery that is due at the given time.
+func (dispatcher *Dispatcher) DeliverDue(now time.Time) error {
+	deliveries, err := dispatcher.Db.GetDueWebhookDeliveries(now, deliveriesPerPoll)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		dispatcher.attempt(delivery, now)
+
+		if err := dispatcher.Db.UpdateWebhookDelivery(delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sign returns the value of the signature header for a payload: the hex encoded HMAC-SHA256 of the payload keyed by the subscription's secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay doubles with every failed attempt, up to maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+func (dispatcher *Dispatcher) attempt(delivery *models.WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+
+	statusCode, err := dispatcher.send(delivery)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		deliveredTime := now.UTC()
+		delivery.Status = models.DELIVERY_DELIVERED
+		delivery.DeliveredTime = &deliveredTime
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = models.DELIVERY_FAILED
+		return
+	}
+
+	delivery.NextAttemptTime = now.Add(retryDelay(delivery.Attempts))
+}
+
+func (dispatcher *Dispatcher) send(delivery *models.WebhookDelivery) (int, error) {
+	payload := []byte(delivery.Payload)
+
+	request, err := http.NewRequest(http.MethodPost, delivery.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "CerealNotes-Webhooks")
+	request.Header.Set(EventHeader, string(delivery.EventType))
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(int64(delivery.Id), 10))
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, payload))
+
+	response, err := dispatcher.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("endpoint responded with %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
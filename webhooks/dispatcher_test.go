@@ -0,0 +1,171 @@
+package webhooks_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmiguel/cerealnotes/models"
+	"github.com/atmiguel/cerealnotes/test_util"
+	"github.com/atmiguel/cerealnotes/webhooks"
+)
+
+// deliveryStore only implements the Datastore methods the dispatcher uses.
+type deliveryStore struct {
+	models.Datastore
+	due     []*models.WebhookDelivery
+	updated []*models.WebhookDelivery
+}
+
+func (store *deliveryStore) GetDueWebhookDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return store.due, nil
+}
+
+func (store *deliveryStore) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	store.updated = append(store.updated, delivery)
+	return nil
+}
+
+func TestDeliverDue(t *testing.T) {
+	payload := `{"type":"note.created","authorId":1,"noteId":2}`
+	secret := "aSigningSecret"
+
+	succeed := true
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		test_util.Ok(t, err)
+
+		test_util.Equals(t, payload, string(body))
+		test_util.Equals(t, "note.created", request.Header.Get(webhooks.EventHeader))
+		test_util.Equals(t, webhooks.Sign(secret, body), request.Header.Get(webhooks.SignatureHeader))
+
+		if succeed {
+			responseWriter.WriteHeader(http.StatusNoContent)
+		} else {
+			responseWriter.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		Id:        1,
+		EventType: models.NOTE_CREATED,
+		Payload:   payload,
+		Status:    models.DELIVERY_PENDING,
+		Url:       server.URL,
+		Secret:    secret,
+	}
+
+	store := &deliveryStore{due: []*models.WebhookDelivery{delivery}}
+	dispatcher := webhooks.NewDispatcher(store)
+	// the test server listens on loopback, which the dispatcher's own client won't connect to
+	dispatcher.Client = server.Client()
+
+	// a failed attempt is retried later
+	succeed = false
+	err := dispatcher.DeliverDue(now)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.DELIVERY_PENDING, delivery.Status)
+	test_util.Equals(t, 1, delivery.Attempts)
+	test_util.Equals(t, http.StatusBadGateway, delivery.LastStatusCode)
+	test_util.Assert(t, delivery.NextAttemptTime.After(now), "Expected the retry to be scheduled in the future")
+
+	succeed = true
+	err = dispatcher.DeliverDue(delivery.NextAttemptTime)
+	test_util.Ok(t, err)
+	test_util.Equals(t, models.DELIVERY_DELIVERED, delivery.Status)
+	test_util.Equals(t, 2, delivery.Attempts)
+	test_util.Equals(t, "", delivery.LastError)
+	test_util.Equals(t, 2, len(store.updated))
+}
+
+func TestDeliveryGivesUpAfterRepeatedFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	delivery := &models.WebhookDelivery{Id: 1, Status: models.DELIVERY_PENDING, Url: server.URL}
+	store := &deliveryStore{due: []*models.WebhookDelivery{delivery}}
+	dispatcher := webhooks.NewDispatcher(store)
+	dispatcher.Client = server.Client()
+
+	now := time.Now()
+	for delivery.Status == models.DELIVERY_PENDING {
+		test_util.Assert(t, delivery.Attempts < 100, "Expected the delivery to give up")
+
+		err := dispatcher.DeliverDue(now)
+		test_util.Ok(t, err)
+
+		if delivery.Status == models.DELIVERY_PENDING {
+			test_util.Assert(t, delivery.NextAttemptTime.Sub(now) <= 6*time.Hour, "Expected the backoff to be capped")
+			now = delivery.NextAttemptTime
+		}
+	}
+
+	test_util.Equals(t, models.DELIVERY_FAILED, delivery.Status)
+}
+
+func TestDeliveriesToForbiddenAddressesAreRefused(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	// the url's host is a name, so only the dialer sees the loopback address it resolves to
+	serverUrl, err := url.Parse(server.URL)
+	test_util.Ok(t, err)
+	_, port, err := net.SplitHostPort(serverUrl.Host)
+	test_util.Ok(t, err)
+
+	delivery := &models.WebhookDelivery{Id: 1, Status: models.DELIVERY_PENDING, Url: "http://localhost:" + port}
+	store := &deliveryStore{due: []*models.WebhookDelivery{delivery}}
+
+	err = webhooks.NewDispatcher(store).DeliverDue(time.Now())
+	test_util.Ok(t, err)
+	test_util.Assert(t, !reached, "Expected the delivery not to reach a loopback address")
+	test_util.Equals(t, models.DELIVERY_PENDING, delivery.Status)
+	test_util.Assert(t, strings.Contains(delivery.LastError, webhooks.ForbiddenAddressError.Error()), "Expected the delivery to be refused, not %q", delivery.LastError)
+}
+
+func TestCheckUrl(t *testing.T) {
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://chat.example.com/hooks", true},
+		{"http://93.184.216.34/hooks", true},
+		{"http://localhost/hooks", false},
+		{"http://LOCALHOST./hooks", false},
+		{"http://api.localhost/hooks", false},
+		{"http://127.0.0.1:8080/hooks", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://10.1.2.3/hooks", false},
+		{"http://172.16.0.1/hooks", false},
+		{"http://192.168.1.1/hooks", false},
+		{"http://0.0.0.0/hooks", false},
+		{"http://[::1]/hooks", false},
+		{"http://[fd00::1]/hooks", false},
+		{"http://[fe80::1]/hooks", false},
+		{"http://[::ffff:127.0.0.1]/hooks", false},
+	}
+
+	for _, testCase := range cases {
+		webhookUrl, err := url.Parse(testCase.url)
+		test_util.Ok(t, err)
+
+		err = webhooks.CheckUrl(webhookUrl)
+		if testCase.allowed {
+			test_util.Assert(t, err == nil, "Expected %s to be allowed, not %v", testCase.url, err)
+		} else {
+			test_util.Assert(t, err == webhooks.ForbiddenAddressError, "Expected %s to be forbidden", testCase.url)
+		}
+	}
+}
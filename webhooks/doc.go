@@ -0,0 +1,7 @@
+/*
+Package webhooks delivers queued webhook events to subscribers' endpoints.
+
+Deliveries are queued by the models package and sent by a Dispatcher, which
+signs each payload and retries failures with exponential backoff.
+*/
+package webhooks